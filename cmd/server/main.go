@@ -9,14 +9,27 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
 
 	_ "inventory-system/docs"
 	"inventory-system/internal"
+	"inventory-system/internal/adjustments"
+	"inventory-system/internal/categories"
 	"inventory-system/internal/database"
+	"inventory-system/internal/kits"
+	"inventory-system/internal/locations"
 	"inventory-system/internal/products"
+	"inventory-system/internal/purchasing"
+	"inventory-system/internal/replenishment"
+	"inventory-system/internal/reports"
+	"inventory-system/internal/returns"
+	"inventory-system/internal/sales"
+	"inventory-system/internal/stocktake"
+	"inventory-system/internal/suppliers"
+	"inventory-system/internal/transfers"
 	"inventory-system/internal/users"
 
 	"github.com/go-chi/chi/v5"
@@ -54,6 +67,8 @@ func main() {
 		log.Fatalf("Erro ao rodar migrations: %v", err)
 	}
 
+	products.StartBackgroundJobs(context.Background(), db)
+
 	r := chi.NewRouter()
 	r.Use(internal.CORSMiddleware)
 
@@ -62,6 +77,18 @@ func main() {
 	r.Get("/swagger/*", httpSwagger.WrapHandler)
 	users.RegisterRoutes(r, db)
 	products.RegisterRoutes(r, db)
+	locations.RegisterRoutes(r, db)
+	categories.RegisterRoutes(r, db)
+	transfers.RegisterRoutes(r, db)
+	adjustments.RegisterRoutes(r, db)
+	stocktake.RegisterRoutes(r, db)
+	suppliers.RegisterRoutes(r, db)
+	purchasing.RegisterRoutes(r, db)
+	replenishment.RegisterRoutes(r, db)
+	sales.RegisterRoutes(r, db)
+	kits.RegisterRoutes(r, db)
+	returns.RegisterRoutes(r, db)
+	reports.RegisterRoutes(r, db)
 
 	log.Println("Servidor rodando na porta 8080...")
 	http.ListenAndServe(":8080", r)
@@ -0,0 +1,243 @@
+package adjustments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+
+	"inventory-system/internal"
+	"inventory-system/internal/products"
+	"inventory-system/internal/users"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var validate = validator.New()
+
+func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(payload)
+}
+
+func respondError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, map[string]string{"error": message})
+}
+
+// ThresholdsFromEnv lê ADJUSTMENT_APPROVAL_QTY (padrão 10) e ADJUSTMENT_APPROVAL_VALUE (padrão 0, desligado).
+func ThresholdsFromEnv() Thresholds {
+	t := Thresholds{Quantity: 10}
+	if v, err := strconv.Atoi(os.Getenv("ADJUSTMENT_APPROVAL_QTY")); err == nil && v >= 0 {
+		t.Quantity = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("ADJUSTMENT_APPROVAL_VALUE"), 64); err == nil && v >= 0 {
+		t.Value = v
+	}
+	return t
+}
+
+func RegisterRoutes(r chi.Router, db *pgxpool.Pool) {
+	service := NewService(NewRepository(db), ThresholdsFromEnv())
+	admin := users.RequireRole("admin", []byte("changeme"))
+
+	r.Route("/adjustments", func(r chi.Router) {
+		r.Use(internal.AuthMiddleware)
+		r.Post("/", createAdjustmentHandler(service))
+		r.Get("/", getAdjustmentsHandler(service))
+		r.Get("/reasons", getReasonsHandler(service))
+		r.With(admin).Post("/reasons", createReasonHandler(service))
+		r.Get("/{id}", getAdjustmentHandler(service))
+		r.With(admin).Post("/{id}/approve", reviewHandler(service.Approve))
+		r.With(admin).Post("/{id}/reject", reviewHandler(service.Reject))
+	})
+}
+
+func respondAdjustmentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		respondError(w, http.StatusNotFound, "Adjustment not found")
+	case errors.Is(err, products.ErrProductNotFound):
+		respondError(w, http.StatusNotFound, "Product not found")
+	case errors.Is(err, products.ErrLocationNotFound):
+		respondError(w, http.StatusNotFound, "Location not found")
+	case errors.Is(err, ErrUnknownReason), errors.Is(err, ErrSystemReason), errors.Is(err, products.ErrInsufficientStock),
+		errors.Is(err, products.ErrSerialsRequired), errors.Is(err, products.ErrSerialCount), errors.Is(err, products.ErrSerialNotInStock),
+		errors.Is(err, products.ErrNotSerialized), errors.Is(err, products.ErrReservedStock), errors.Is(err, products.ErrSystemLocation):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrNotPending), errors.Is(err, ErrReasonExists), errors.Is(err, products.ErrSerialConflict):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Create a stock adjustment
+// @Description Adjustments within ADJUSTMENT_APPROVAL_QTY / ADJUSTMENT_APPROVAL_VALUE are applied immediately;
+// @Description larger ones stay pending until an admin approves them. Serialized products must list the serials,
+// @Description one per unit; count_correction and return_scrap are reserved for stocktakes and returns.
+// @Tags adjustments
+// @Accept json
+// @Produce json
+// @Param adjustment body AdjustmentRequest true "Adjustment data (negative quantity removes stock)" example({"barcode":"7891234567895","location_id":1,"quantity":-2,"reason_code":"damage","note":"broken in transport"})
+// @Success 201 {object} Adjustment "Created adjustment (status applied or pending)"
+// @Failure 400 {object} map[string]string "Invalid data, unknown reason or insufficient stock"
+// @Failure 404 {object} map[string]string "Product or location not found"
+// @Router /adjustments [post]
+func createAdjustmentHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req AdjustmentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		a, err := s.CreateAdjustment(r.Context(), req)
+		if err != nil {
+			respondAdjustmentError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusCreated, a)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary List stock adjustments
+// @Tags adjustments
+// @Produce json
+// @Param status query string false "Filter by status (pending, applied, rejected)"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 20, max: 100)"
+// @Success 200 {array} Adjustment "List of adjustments, newest first"
+// @Header 200 {int} X-Total-Count "Total number of adjustments"
+// @Router /adjustments [get]
+func getAdjustmentsHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 1 {
+			page = 1
+		}
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit < 1 || limit > 100 {
+			limit = 20
+		}
+		q := AdjustmentsQuery{Status: r.URL.Query().Get("status"), Page: page, Limit: limit}
+		adjustments, total, err := s.GetAdjustments(r.Context(), q)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		respondJSON(w, http.StatusOK, adjustments)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Get a stock adjustment
+// @Tags adjustments
+// @Produce json
+// @Param id path int true "Adjustment ID"
+// @Success 200 {object} Adjustment "Adjustment"
+// @Failure 404 {object} map[string]string "Adjustment not found"
+// @Router /adjustments/{id} [get]
+func getAdjustmentHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		a, err := s.GetAdjustment(r.Context(), id)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if a == nil {
+			respondError(w, http.StatusNotFound, "Adjustment not found")
+			return
+		}
+		respondJSON(w, http.StatusOK, a)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Approve or reject a pending adjustment (admin)
+// @Description approve applies the adjustment to stock; reject closes it without moving stock.
+// @Tags adjustments
+// @Produce json
+// @Param id path int true "Adjustment ID"
+// @Success 200 {object} Adjustment "Reviewed adjustment"
+// @Failure 400 {object} map[string]string "Insufficient stock"
+// @Failure 403 {object} map[string]string "Insufficient permissions"
+// @Failure 404 {object} map[string]string "Adjustment not found"
+// @Failure 409 {object} map[string]string "Adjustment not pending"
+// @Router /adjustments/{id}/approve [post]
+// @Router /adjustments/{id}/reject [post]
+func reviewHandler(action func(ctx context.Context, id int) (*Adjustment, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		a, err := action(r.Context(), id)
+		if err != nil {
+			respondAdjustmentError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, a)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary List adjustment reason codes
+// @Tags adjustments
+// @Produce json
+// @Success 200 {array} Reason "Reason code catalogue"
+// @Router /adjustments/reasons [get]
+func getReasonsHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reasons, err := s.GetReasons(r.Context())
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, reasons)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Add an adjustment reason code (admin)
+// @Tags adjustments
+// @Accept json
+// @Param reason body Reason true "Reason" example({"code":"expired","description":"Expired goods"})
+// @Success 201 {object} Reason "Created reason"
+// @Failure 400 {object} map[string]string "Invalid data"
+// @Failure 409 {object} map[string]string "Reason code already exists"
+// @Router /adjustments/reasons [post]
+func createReasonHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var reason Reason
+		if err := json.NewDecoder(r.Body).Decode(&reason); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&reason); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		if err := s.CreateReason(r.Context(), &reason); err != nil {
+			respondAdjustmentError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusCreated, reason)
+	}
+}
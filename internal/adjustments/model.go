@@ -0,0 +1,72 @@
+package adjustments
+
+import (
+	"time"
+
+	"inventory-system/internal/products"
+)
+
+// Estados de um ajuste
+const (
+	StatusPending  = "pending"
+	StatusApplied  = "applied"
+	StatusRejected = "rejected"
+)
+
+// Motivos usados nos ajustes gerados por inventários e pelo descarte de devoluções; não podem ser
+// informados em POST /adjustments
+const (
+	ReasonCountCorrection = "count_correction"
+	ReasonReturnScrap     = "return_scrap"
+)
+
+// Reason é um motivo do catálogo de ajustes; o código é gravado como motivo da movimentação.
+type Reason struct {
+	Code        string `json:"code" validate:"required"`
+	Description string `json:"description" validate:"required"`
+}
+
+type Adjustment struct {
+	ID         int        `json:"id"`
+	ProductID  int        `json:"product_id"`
+	Barcode    string     `json:"barcode"`
+	LocationID int        `json:"location_id"`
+	Quantity   int        `json:"quantity"`
+	ReasonCode string     `json:"reason_code"`
+	Note       string     `json:"note"`
+	Value      float64    `json:"value"`
+	Status     string     `json:"status"`
+	CreatedBy  *int       `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ReviewedBy *int       `json:"reviewed_by"`
+	ReviewedAt *time.Time `json:"reviewed_at"`
+	MovementID *int       `json:"movement_id"`
+	// Serials são as unidades de um produto serializado que o ajuste acrescenta ou baixa
+	Serials []string `json:"serials,omitempty"`
+	// Lotes baixados quando o ajuste é aplicado na mesma transação em que as unidades entraram
+	// (descarte de devoluções); não são gravados no ajuste, só na movimentação
+	Lots []products.LotQuantity `json:"-"`
+}
+
+type AdjustmentRequest struct {
+	Barcode    string `json:"barcode" validate:"required"`
+	LocationID int    `json:"location_id"`
+	// Positiva para acrescentar e negativa para baixar estoque
+	Quantity   int    `json:"quantity" validate:"required,ne=0"`
+	ReasonCode string `json:"reason_code" validate:"required"`
+	Note       string `json:"note"`
+	// Serials nomeia as unidades de um produto serializado, uma por unidade ajustada, e é obrigatória para ele
+	Serials []string `json:"serials" validate:"omitempty,unique,dive,required"`
+}
+
+// Thresholds define a partir de quando um ajuste precisa de aprovação; zero desliga o limite.
+type Thresholds struct {
+	Quantity int
+	Value    float64
+}
+
+type AdjustmentsQuery struct {
+	Status string
+	Page   int
+	Limit  int
+}
@@ -0,0 +1,263 @@
+package adjustments
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+
+	"inventory-system/internal/products"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrNotFound      = errors.New("adjustment not found")
+	ErrNotPending    = errors.New("adjustment is not pending approval")
+	ErrUnknownReason = errors.New("unknown reason code")
+	ErrReasonExists  = errors.New("reason code already exists")
+	ErrSystemReason  = errors.New("reason code is reserved for stocktakes and returns")
+)
+
+type Repository struct {
+	DB *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{DB: db}
+}
+
+func (r *Repository) GetReasons(ctx context.Context) ([]Reason, error) {
+	rows, err := r.DB.Query(ctx, `SELECT code, description FROM adjustment_reasons ORDER BY code`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	reasons := []Reason{}
+	for rows.Next() {
+		var reason Reason
+		if err := rows.Scan(&reason.Code, &reason.Description); err != nil {
+			return nil, err
+		}
+		reasons = append(reasons, reason)
+	}
+	return reasons, rows.Err()
+}
+
+func (r *Repository) CreateReason(ctx context.Context, reason *Reason) error {
+	_, err := r.DB.Exec(ctx, `INSERT INTO adjustment_reasons (code, description) VALUES ($1, $2)`, reason.Code, reason.Description)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrReasonExists
+	}
+	return err
+}
+
+// FindProduct devolve o id e o preço do produto, usados para valorizar o ajuste.
+func (r *Repository) FindProduct(ctx context.Context, barcode string) (int, float64, error) {
+	var id int
+	var price float64
+	err := r.DB.QueryRow(ctx, `SELECT id, price FROM products WHERE `+products.ByCode("id", "$1"), barcode).Scan(&id, &price)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, 0, products.ErrProductNotFound
+	}
+	return id, price, err
+}
+
+const adjustmentColumns = `a.id, a.product_id, p.barcode, a.location_id, a.quantity, a.reason_code, a.note, a.value, a.status,
+	a.created_by, a.created_at, a.reviewed_by, a.reviewed_at, a.movement_id, a.serials`
+
+func scanAdjustment(row pgx.Row, a *Adjustment) error {
+	return row.Scan(&a.ID, &a.ProductID, &a.Barcode, &a.LocationID, &a.Quantity, &a.ReasonCode, &a.Note, &a.Value, &a.Status,
+		&a.CreatedBy, &a.CreatedAt, &a.ReviewedBy, &a.ReviewedAt, &a.MovementID, &a.Serials)
+}
+
+// CreateAdjustment grava o ajuste com o status decidido pelo serviço; se já estiver aplicado,
+// o estoque é movimentado na mesma transação. Como nas entradas e saídas pela API, as unidades de
+// um produto serializado precisam ser nomeadas, e isso é verificado já na criação, mesmo que o
+// ajuste fique pendente.
+func (r *Repository) CreateAdjustment(ctx context.Context, a *Adjustment) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	var serialized bool
+	if err := tx.QueryRow(ctx, `SELECT serialized FROM products WHERE id = $1`, a.ProductID).Scan(&serialized); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return products.ErrProductNotFound
+		}
+		return err
+	}
+	if serialized && len(a.Serials) == 0 {
+		return products.ErrSerialsRequired
+	}
+	if !serialized && len(a.Serials) > 0 {
+		return products.ErrNotSerialized
+	}
+	if err := Insert(ctx, tx, a); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Insert grava o ajuste dentro de tx (no local padrão quando a.LocationID é 0) e, se a.Status for
+// applied, movimenta o estoque. Outros pacotes, como o inventário, usam esta função para lançar
+// ajustes junto com suas próprias alterações.
+func Insert(ctx context.Context, tx pgx.Tx, a *Adjustment) error {
+	err := tx.QueryRow(ctx, `SELECT id FROM locations WHERE id = $1 OR ($1 = 0 AND is_default)`, a.LocationID).Scan(&a.LocationID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return products.ErrLocationNotFound
+		}
+		return err
+	}
+	serials := a.Serials
+	if serials == nil {
+		serials = []string{}
+	}
+	query := `INSERT INTO adjustments (product_id, location_id, quantity, reason_code, note, value, status, created_by, serials)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, created_at`
+	err = tx.QueryRow(ctx, query, a.ProductID, a.LocationID, a.Quantity, a.ReasonCode, a.Note, a.Value, a.Status, a.CreatedBy, serials).
+		Scan(&a.ID, &a.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return ErrUnknownReason
+		}
+		return err
+	}
+	if a.Status == StatusApplied {
+		return apply(ctx, tx, a)
+	}
+	return nil
+}
+
+func (r *Repository) GetAdjustments(ctx context.Context, q AdjustmentsQuery) ([]Adjustment, int, error) {
+	args := []interface{}{}
+	where := ""
+	idx := 1
+	if q.Status != "" {
+		where += " AND a.status = $" + strconv.Itoa(idx)
+		args = append(args, q.Status)
+		idx++
+	}
+	limit := q.Limit
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	page := q.Page
+	if page < 1 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+	query := "SELECT " + adjustmentColumns + " FROM adjustments a JOIN products p ON p.id = a.product_id WHERE 1=1" + where +
+		" ORDER BY a.id DESC LIMIT $" + strconv.Itoa(idx) + " OFFSET $" + strconv.Itoa(idx+1)
+	rows, err := r.DB.Query(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	adjustments := []Adjustment{}
+	for rows.Next() {
+		var a Adjustment
+		if err := scanAdjustment(rows, &a); err != nil {
+			return nil, 0, err
+		}
+		adjustments = append(adjustments, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	total := 0
+	if err := r.DB.QueryRow(ctx, "SELECT COUNT(*) FROM adjustments a WHERE 1=1"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	return adjustments, total, nil
+}
+
+func (r *Repository) GetAdjustment(ctx context.Context, id int) (*Adjustment, error) {
+	var a Adjustment
+	query := "SELECT " + adjustmentColumns + " FROM adjustments a JOIN products p ON p.id = a.product_id WHERE a.id = $1"
+	if err := scanAdjustment(r.DB.QueryRow(ctx, query, id), &a); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Review aprova (status applied) ou rejeita um ajuste pendente. A aprovação movimenta o estoque
+// na mesma transação.
+func (r *Repository) Review(ctx context.Context, id int, status string, reviewerID *int) (*Adjustment, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	var a Adjustment
+	query := "SELECT " + adjustmentColumns + " FROM adjustments a JOIN products p ON p.id = a.product_id WHERE a.id = $1 FOR UPDATE OF a"
+	if err := scanAdjustment(tx.QueryRow(ctx, query, id), &a); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if a.Status != StatusPending {
+		return nil, ErrNotPending
+	}
+	a.Status = status
+	err = tx.QueryRow(ctx, `UPDATE adjustments SET status = $1, reviewed_by = $2, reviewed_at = NOW() WHERE id = $3 RETURNING reviewed_by, reviewed_at`,
+		status, reviewerID, id).Scan(&a.ReviewedBy, &a.ReviewedAt)
+	if err != nil {
+		return nil, err
+	}
+	if status == StatusApplied {
+		if err := apply(ctx, tx, &a); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// apply lança o ajuste no estoque, com o código do motivo como motivo da movimentação. Baixas não
+// podem consumir unidades reservadas ou alocadas, exceto as correções de contagem (que só os
+// inventários lançam), que registram o que foi encontrado fisicamente.
+func apply(ctx context.Context, tx pgx.Tx, a *Adjustment) error {
+	m := &products.StockMovement{
+		ProductID:  a.ProductID,
+		LocationID: a.LocationID,
+		Delta:      a.Quantity,
+		Reason:     a.ReasonCode,
+		Reference:  "adjustment #" + strconv.Itoa(a.ID),
+		Lots:       a.Lots,
+		Serials:    slices.Clone(a.Serials),
+	}
+	if err := products.ApplyMovement(ctx, tx, m); err != nil {
+		return err
+	}
+	if a.ReasonCode != ReasonCountCorrection {
+		if err := products.CheckHeldStock(ctx, tx, m); err != nil {
+			return err
+		}
+	}
+	a.MovementID = &m.ID
+	_, err := tx.Exec(ctx, `UPDATE adjustments SET movement_id = $1 WHERE id = $2`, m.ID, a.ID)
+	return err
+}
+
+type RepositoryInterface interface {
+	GetReasons(ctx context.Context) ([]Reason, error)
+	CreateReason(ctx context.Context, reason *Reason) error
+	FindProduct(ctx context.Context, barcode string) (int, float64, error)
+	CreateAdjustment(ctx context.Context, a *Adjustment) error
+	GetAdjustments(ctx context.Context, q AdjustmentsQuery) ([]Adjustment, int, error)
+	GetAdjustment(ctx context.Context, id int) (*Adjustment, error)
+	Review(ctx context.Context, id int, status string, reviewerID *int) (*Adjustment, error)
+}
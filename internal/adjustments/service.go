@@ -0,0 +1,113 @@
+package adjustments
+
+import (
+	"context"
+	"math"
+
+	"inventory-system/internal"
+	"inventory-system/internal/products"
+)
+
+type Service struct {
+	Repo       RepositoryInterface
+	Thresholds Thresholds
+}
+
+func NewService(repo RepositoryInterface, thresholds Thresholds) *Service {
+	return &Service{Repo: repo, Thresholds: thresholds}
+}
+
+// NeedsApproval informa se um ajuste de quantity unidades, valendo value, passa de algum dos limites.
+func (t Thresholds) NeedsApproval(quantity int, value float64) bool {
+	if quantity < 0 {
+		quantity = -quantity
+	}
+	if t.Quantity > 0 && quantity > t.Quantity {
+		return true
+	}
+	return t.Value > 0 && value > t.Value
+}
+
+// Prepare valoriza o ajuste pelo preço unitário do produto e decide o seu status: aplicado na hora
+// dentro dos limites ou pendente de aprovação acima deles. O inventário usa a mesma regra.
+func (t Thresholds) Prepare(a *Adjustment, price float64) {
+	a.Value = math.Abs(float64(a.Quantity)) * price
+	a.Status = StatusApplied
+	if t.NeedsApproval(a.Quantity, a.Value) {
+		a.Status = StatusPending
+	}
+}
+
+func (s *Service) GetReasons(ctx context.Context) ([]Reason, error) {
+	return s.Repo.GetReasons(ctx)
+}
+
+func (s *Service) CreateReason(ctx context.Context, reason *Reason) error {
+	return s.Repo.CreateReason(ctx, reason)
+}
+
+// CreateAdjustment registra o ajuste. Dentro dos limites ele é aplicado na hora; acima deles
+// fica pendente até um admin aprovar. Os motivos de sistema (correção de contagem e descarte de
+// devolução) só vêm dos inventários e das devoluções, e as séries, quando informadas, precisam ser
+// uma por unidade ajustada.
+func (s *Service) CreateAdjustment(ctx context.Context, req AdjustmentRequest) (*Adjustment, error) {
+	if req.ReasonCode == ReasonCountCorrection || req.ReasonCode == ReasonReturnScrap {
+		return nil, ErrSystemReason
+	}
+	if len(req.Serials) > 0 && len(req.Serials) != abs(req.Quantity) {
+		return nil, products.ErrSerialCount
+	}
+	productID, price, err := s.Repo.FindProduct(ctx, req.Barcode)
+	if err != nil {
+		return nil, err
+	}
+	a := &Adjustment{
+		ProductID:  productID,
+		Barcode:    req.Barcode,
+		LocationID: req.LocationID,
+		Quantity:   req.Quantity,
+		ReasonCode: req.ReasonCode,
+		Note:       req.Note,
+		Serials:    req.Serials,
+	}
+	s.Thresholds.Prepare(a, price)
+	if userID, ok := internal.UserIDFromContext(ctx); ok {
+		a.CreatedBy = &userID
+	}
+	if err := s.Repo.CreateAdjustment(ctx, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (s *Service) GetAdjustments(ctx context.Context, q AdjustmentsQuery) ([]Adjustment, int, error) {
+	return s.Repo.GetAdjustments(ctx, q)
+}
+
+func (s *Service) GetAdjustment(ctx context.Context, id int) (*Adjustment, error) {
+	return s.Repo.GetAdjustment(ctx, id)
+}
+
+// Approve aplica um ajuste pendente ao estoque.
+func (s *Service) Approve(ctx context.Context, id int) (*Adjustment, error) {
+	return s.Repo.Review(ctx, id, StatusApplied, reviewer(ctx))
+}
+
+// Reject recusa um ajuste pendente sem movimentar estoque.
+func (s *Service) Reject(ctx context.Context, id int) (*Adjustment, error) {
+	return s.Repo.Review(ctx, id, StatusRejected, reviewer(ctx))
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func reviewer(ctx context.Context) *int {
+	if userID, ok := internal.UserIDFromContext(ctx); ok {
+		return &userID
+	}
+	return nil
+}
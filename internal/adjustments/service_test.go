@@ -0,0 +1,144 @@
+package adjustments
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"inventory-system/internal/products"
+)
+
+type mockAdjustmentRepo struct {
+	prices      map[string]float64
+	adjustments []Adjustment
+	applied     int
+	fail        bool
+}
+
+func (m *mockAdjustmentRepo) GetReasons(ctx context.Context) ([]Reason, error) {
+	return []Reason{{Code: "damage", Description: "Damaged goods"}}, nil
+}
+func (m *mockAdjustmentRepo) CreateReason(ctx context.Context, reason *Reason) error {
+	return nil
+}
+func (m *mockAdjustmentRepo) FindProduct(ctx context.Context, barcode string) (int, float64, error) {
+	if m.fail {
+		return 0, 0, fmt.Errorf("db error")
+	}
+	price, ok := m.prices[barcode]
+	if !ok {
+		return 0, 0, products.ErrProductNotFound
+	}
+	return 1, price, nil
+}
+func (m *mockAdjustmentRepo) CreateAdjustment(ctx context.Context, a *Adjustment) error {
+	a.ID = len(m.adjustments) + 1
+	if a.Status == StatusApplied {
+		m.applied += a.Quantity
+	}
+	m.adjustments = append(m.adjustments, *a)
+	return nil
+}
+func (m *mockAdjustmentRepo) GetAdjustments(ctx context.Context, q AdjustmentsQuery) ([]Adjustment, int, error) {
+	return m.adjustments, len(m.adjustments), nil
+}
+func (m *mockAdjustmentRepo) GetAdjustment(ctx context.Context, id int) (*Adjustment, error) {
+	if id < 1 || id > len(m.adjustments) {
+		return nil, nil
+	}
+	return &m.adjustments[id-1], nil
+}
+func (m *mockAdjustmentRepo) Review(ctx context.Context, id int, status string, reviewerID *int) (*Adjustment, error) {
+	if id < 1 || id > len(m.adjustments) {
+		return nil, ErrNotFound
+	}
+	a := &m.adjustments[id-1]
+	if a.Status != StatusPending {
+		return nil, ErrNotPending
+	}
+	a.Status = status
+	if status == StatusApplied {
+		m.applied += a.Quantity
+	}
+	return a, nil
+}
+
+func TestThresholds_NeedsApproval(t *testing.T) {
+	cases := []struct {
+		thresholds Thresholds
+		quantity   int
+		value      float64
+		want       bool
+	}{
+		{Thresholds{Quantity: 10}, 10, 0, false},
+		{Thresholds{Quantity: 10}, 11, 0, true},
+		{Thresholds{Quantity: 10}, -11, 0, true},
+		{Thresholds{Value: 100}, 1, 100, false},
+		{Thresholds{Value: 100}, 1, 100.01, true},
+		{Thresholds{}, 1000, 1e6, false},
+	}
+	for _, c := range cases {
+		if got := c.thresholds.NeedsApproval(c.quantity, c.value); got != c.want {
+			t.Errorf("%+v com %d/%.2f: esperado %v, veio %v", c.thresholds, c.quantity, c.value, c.want, got)
+		}
+	}
+}
+
+func TestService_CreateAdjustment_Mock(t *testing.T) {
+	repo := &mockAdjustmentRepo{prices: map[string]float64{"123": 2.5}}
+	svc := NewService(repo, Thresholds{Quantity: 5, Value: 50})
+	a, err := svc.CreateAdjustment(context.Background(), AdjustmentRequest{Barcode: "123", Quantity: -2, ReasonCode: "damage"})
+	if err != nil {
+		t.Fatalf("erro ao criar ajuste: %v", err)
+	}
+	if a.Status != StatusApplied || a.Value != 5 || repo.applied != -2 {
+		t.Errorf("ajuste pequeno deveria ser aplicado: %+v", a)
+	}
+	// Acima do limite de quantidade fica pendente e não movimenta estoque
+	a, _ = svc.CreateAdjustment(context.Background(), AdjustmentRequest{Barcode: "123", Quantity: 6, ReasonCode: "damage"})
+	if a.Status != StatusPending || repo.applied != -2 {
+		t.Errorf("ajuste grande deveria ficar pendente: %+v", a)
+	}
+	if _, err := svc.CreateAdjustment(context.Background(), AdjustmentRequest{Barcode: "999", Quantity: 1, ReasonCode: "damage"}); err != products.ErrProductNotFound {
+		t.Errorf("esperado ErrProductNotFound, veio %v", err)
+	}
+	// Motivos de sistema só vêm dos inventários e das devoluções
+	for _, reason := range []string{ReasonCountCorrection, ReasonReturnScrap} {
+		if _, err := svc.CreateAdjustment(context.Background(), AdjustmentRequest{Barcode: "123", Quantity: -1, ReasonCode: reason}); err != ErrSystemReason {
+			t.Errorf("%s: esperado ErrSystemReason, veio %v", reason, err)
+		}
+	}
+	// Uma série por unidade ajustada, que segue com o ajuste
+	if _, err := svc.CreateAdjustment(context.Background(), AdjustmentRequest{Barcode: "123", Quantity: -2, ReasonCode: "damage", Serials: []string{"S1"}}); err != products.ErrSerialCount {
+		t.Errorf("esperado ErrSerialCount, veio %v", err)
+	}
+	a, err = svc.CreateAdjustment(context.Background(), AdjustmentRequest{Barcode: "123", Quantity: -2, ReasonCode: "damage", Serials: []string{"S1", "S2"}})
+	if err != nil || len(a.Serials) != 2 {
+		t.Errorf("séries deveriam seguir com o ajuste: %v %+v", err, a)
+	}
+}
+
+func TestService_Review_Mock(t *testing.T) {
+	repo := &mockAdjustmentRepo{prices: map[string]float64{"123": 100}}
+	svc := NewService(repo, Thresholds{Value: 50})
+	pending, _ := svc.CreateAdjustment(context.Background(), AdjustmentRequest{Barcode: "123", Quantity: -1, ReasonCode: "theft"})
+	rejected, _ := svc.CreateAdjustment(context.Background(), AdjustmentRequest{Barcode: "123", Quantity: -3, ReasonCode: "theft"})
+	if pending.Status != StatusPending || rejected.Status != StatusPending {
+		t.Fatalf("ajustes acima do valor deveriam ficar pendentes: %+v %+v", pending, rejected)
+	}
+	if a, err := svc.Approve(context.Background(), pending.ID); err != nil || a.Status != StatusApplied {
+		t.Fatalf("erro ao aprovar: %v", err)
+	}
+	if _, err := svc.Reject(context.Background(), rejected.ID); err != nil {
+		t.Fatalf("erro ao rejeitar: %v", err)
+	}
+	if repo.applied != -1 {
+		t.Errorf("esperado -1 aplicado, veio %d", repo.applied)
+	}
+	if _, err := svc.Approve(context.Background(), pending.ID); err != ErrNotPending {
+		t.Errorf("esperado ErrNotPending, veio %v", err)
+	}
+	if _, err := svc.Reject(context.Background(), 99); err != ErrNotFound {
+		t.Errorf("esperado ErrNotFound, veio %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+// Package barcode valida códigos GTIN (EAN-8, UPC-A, EAN-13 e GTIN-14) e interpreta leituras GS1-128
+// e GS1 DataMatrix.
+package barcode
+
+import "errors"
+
+var (
+	ErrInvalidFormat = errors.New("barcode must be an EAN-8, UPC-A, EAN-13 or GTIN-14 with 8, 12, 13 or 14 digits")
+	ErrCheckDigit    = errors.New("invalid barcode check digit")
+)
+
+// Validate confere se code é um GTIN de 8, 12, 13 ou 14 dígitos com dígito verificador correto.
+func Validate(code string) error {
+	switch len(code) {
+	case 8, 12, 13, 14:
+	default:
+		return ErrInvalidFormat
+	}
+	if !digits(code) {
+		return ErrInvalidFormat
+	}
+	if int(code[len(code)-1]-'0') != CheckDigit(code[:len(code)-1]) {
+		return ErrCheckDigit
+	}
+	return nil
+}
+
+// CheckDigit calcula o dígito verificador GS1 (módulo 10) dos dígitos informados, sem o verificador:
+// da direita para a esquerda, os dígitos têm peso 3 e 1 alternadamente.
+func CheckDigit(payload string) int {
+	sum := 0
+	for i := 0; i < len(payload); i++ {
+		d := int(payload[len(payload)-1-i] - '0')
+		if i%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return (10 - sum%10) % 10
+}
+
+// Candidates devolve as formas de um GTIN-14 pelas quais o produto pode estar cadastrado: o próprio
+// GTIN-14 e, quando os zeros à esquerda permitem, o EAN-13, o UPC-A e o EAN-8 equivalentes.
+func Candidates(gtin string) []string {
+	codes := []string{gtin}
+	if len(gtin) != 14 {
+		return codes
+	}
+	for _, n := range []int{13, 12, 8} {
+		if prefix := gtin[:14-n]; prefix == "00000000"[:14-n] {
+			codes = append(codes, gtin[14-n:])
+		}
+	}
+	return codes
+}
+
+func digits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
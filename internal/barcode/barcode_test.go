@@ -0,0 +1,211 @@
+package barcode
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		code string
+		want error
+	}{
+		{"96385074", nil},
+		{"036000291452", nil},
+		{"7891234567895", nil},
+		{"07891234567895", nil},
+		{"7891234567890", ErrCheckDigit},
+		{"123456", ErrInvalidFormat},
+		{"78912345678A5", ErrInvalidFormat},
+		{"", ErrInvalidFormat},
+	}
+	for _, c := range cases {
+		if got := Validate(c.code); got != c.want {
+			t.Errorf("%q: esperado %v, veio %v", c.code, c.want, got)
+		}
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	if got := Candidates("07891234567895"); !slices.Equal(got, []string{"07891234567895", "7891234567895"}) {
+		t.Errorf("candidatos do EAN-13 incorretos: %v", got)
+	}
+	if got := Candidates("00036000291452"); !slices.Equal(got, []string{"00036000291452", "0036000291452", "036000291452"}) {
+		t.Errorf("candidatos do UPC-A incorretos: %v", got)
+	}
+	if got := Candidates("00000096385074"); len(got) != 4 || got[3] != "96385074" {
+		t.Errorf("candidatos do EAN-8 incorretos: %v", got)
+	}
+}
+
+func TestIsGS1(t *testing.T) {
+	for _, s := range []string{"]C10107891234567895", "(01)07891234567895", "010789123456789510L1\x1d17261231", "01078912345678951726123110L1"} {
+		if !IsGS1(s) {
+			t.Errorf("%q deveria ser GS1", s)
+		}
+	}
+	for _, s := range []string{"7891234567895", "07891234567895", "CAF-01", "0112345678901234567"} {
+		if IsGS1(s) {
+			t.Errorf("%q não deveria ser GS1", s)
+		}
+	}
+}
+
+func TestParseGS1(t *testing.T) {
+	expiry := time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)
+	for _, s := range []string{
+		"(01)07891234567895(17)261231(10)L42(21)SN-9(30)12",
+		"]d2010789123456789517261231" + "10L42\x1d" + "21SN-9\x1d" + "3012",
+		"\x1d01078912345678951726120010L42\x1d21SN-9\x1d3012",
+	} {
+		g, err := ParseGS1(s)
+		if err != nil {
+			t.Fatalf("%q: %v", s, err)
+		}
+		if g.GTIN != "07891234567895" || g.Lot != "L42" || g.Serial != "SN-9" || g.Quantity != 12 || g.Expiry == nil || !g.Expiry.Equal(expiry) {
+			t.Errorf("%q: leitura incorreta %+v", s, g)
+		}
+	}
+	// AIs não usados nas movimentações continuam disponíveis em Elements
+	g, err := ParseGS1("(01)07891234567895(3103)001250(11)260115")
+	if err != nil || g.Elements["3103"] != "001250" || g.Elements["11"] != "260115" || g.Expiry != nil {
+		t.Errorf("elementos incorretos: %v %+v", err, g)
+	}
+
+	cases := []struct {
+		s    string
+		want error
+	}{
+		{"(01)07891234567890", ErrInvalidGS1},
+		{"(10)L42", ErrInvalidGS1},
+		{"(01)07891234567895(17)261331", ErrInvalidExpiry},
+		{"(01)07891234567895(17)260231", ErrInvalidExpiry},
+		{"(01)07891234567895(30)0", ErrInvalidGS1Qty},
+		{"(01)07891234567895(80)X", ErrUnsupportedAI},
+		{"0107891234567895172612", ErrInvalidGS1},
+	}
+	for _, c := range cases {
+		if _, err := ParseGS1(c.s); err != c.want {
+			t.Errorf("%q: esperado %v, veio %v", c.s, c.want, err)
+		}
+	}
+}
+
+func TestVariable(t *testing.T) {
+	rules := []VariableRule{
+		{Prefix: "2", ItemDigits: 6, ValueDigits: 5},
+		{Prefix: "20", ItemDigits: 4, ValueDigits: 5},
+		{Prefix: "21", ItemDigits: 12, ValueDigits: 1}, // inválida, ignorada
+	}
+	// 20 + item 1234 + verificador do preço 0 + valor 01250 + dígito verificador
+	payload := "201234001250"
+	code := payload + string(rune('0'+CheckDigit(payload)))
+	i := MatchVariable(code, rules)
+	if i != 1 {
+		t.Fatalf("esperada a regra de prefixo 20, veio %d", i)
+	}
+	base, value := DecodeVariable(code, rules[i])
+	if base != "2012340000006" || value != 1250 {
+		t.Errorf("decodificação incorreta: %s %d", base, value)
+	}
+	if Validate(base) != nil {
+		t.Errorf("código base inválido: %s", base)
+	}
+
+	payload = "231234512345"
+	code = payload + string(rune('0'+CheckDigit(payload)))
+	if i := MatchVariable(code, rules); i != 0 {
+		t.Errorf("esperada a regra de prefixo 2, veio %d", i)
+	}
+	if i := MatchVariable("7891234567895", rules); i != -1 {
+		t.Errorf("EAN-13 comum não deveria casar, veio %d", i)
+	}
+	if i := MatchVariable(code[:12]+"0", rules); code[12] != '0' && i != -1 {
+		t.Errorf("dígito verificador inválido não deveria casar, veio %d", i)
+	}
+	if (VariableRule{Prefix: "12", ItemDigits: 5, ValueDigits: 5}).Check() == nil {
+		t.Error("prefixo fora da faixa 2 deveria ser inválido")
+	}
+}
+
+func TestEncodeEAN13(t *testing.T) {
+	s, err := Encode("4006381333931", "")
+	if err != nil || s.Symbology != EAN13 || !s.Linear() || s.Width() != 95 {
+		t.Fatalf("EAN-13 incorreto: %v %+v", err, s)
+	}
+	// Guarda inicial, primeiro dígito da esquerda (0, paridade L) e guarda central
+	want := "101" + "0001101"
+	for i, c := range want {
+		if s.Rows[0][i] != (c == '1') {
+			t.Fatalf("módulo %d incorreto", i)
+		}
+	}
+	if _, err := Encode("4006381333932", EAN13); err != ErrNotEAN13 {
+		t.Errorf("esperado ErrNotEAN13, veio %v", err)
+	}
+	// UPC-A vira EAN-13 com zero à esquerda
+	if s, err := Encode("036000291452", ""); err != nil || s.Symbology != EAN13 {
+		t.Errorf("UPC-A incorreto: %v %+v", err, s.Symbology)
+	}
+}
+
+func TestEncodeCode128(t *testing.T) {
+	seen := map[string]bool{}
+	for i, p := range code128Patterns {
+		sum := 0
+		for _, c := range p {
+			sum += int(c - '0')
+		}
+		if sum != 11 || seen[p] {
+			t.Errorf("padrão %d inválido: %s", i, p)
+		}
+		seen[p] = true
+	}
+	s, err := Encode("CAF-01", "")
+	if err != nil || s.Symbology != Code128 {
+		t.Fatal(err)
+	}
+	// Início, seis caracteres, verificador e parada de 13 módulos
+	if s.Width() != 11*8+13 {
+		t.Errorf("largura incorreta: %d", s.Width())
+	}
+	if s, _ := Encode("123456", Code128); s.Width() != 11*5+13 {
+		t.Errorf("conjunto C deveria codificar dois dígitos por símbolo: %d", s.Width())
+	}
+	if _, err := Encode("café", Code128); err != ErrUnencodable {
+		t.Errorf("esperado ErrUnencodable, veio %v", err)
+	}
+}
+
+func TestEncodeQR(t *testing.T) {
+	// Exemplo de Reed-Solomon da versão 1-M ("HELLO WORLD" em modo alfanumérico)
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsRemainder(data, rsDivisor(10)); string(got) != string(want) {
+		t.Errorf("correção de erros incorreta: %v", got)
+	}
+	if got := qrFormatBits(0); got != 0b101010000010010 {
+		t.Errorf("campo de formato incorreto: %015b", got)
+	}
+
+	s, err := Encode("7891234567895", QR)
+	if err != nil || len(s.Rows) != 21 || s.Width() != 21 {
+		t.Fatalf("QR incorreto: %v %d", err, len(s.Rows))
+	}
+	// Padrões de localização nos três cantos
+	for _, c := range [][2]int{{0, 0}, {0, 14}, {14, 0}} {
+		for i := 0; i < 7; i++ {
+			if !s.Rows[c[0]][c[1]+i] || !s.Rows[c[0]+i][c[1]] {
+				t.Errorf("padrão de localização ausente em %v", c)
+			}
+		}
+	}
+	if s, _ := Encode(strings.Repeat("9", 100), QR); len(s.Rows) != 41 {
+		t.Errorf("esperada a versão 6, veio tamanho %d", len(s.Rows))
+	}
+	if _, err := Encode(strings.Repeat("9", 107), QR); err != ErrTooLong {
+		t.Errorf("esperado ErrTooLong, veio %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+package barcode
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidGS1    = errors.New("invalid GS1 element string")
+	ErrUnsupportedAI = errors.New("unsupported GS1 application identifier")
+	ErrInvalidExpiry = errors.New("invalid GS1 expiry date, expected YYMMDD")
+	ErrInvalidGS1Qty = errors.New("invalid GS1 quantity")
+)
+
+// groupSeparator é o caractere FNC1 (GS, ASCII 29) que encerra os campos de tamanho variável.
+const groupSeparator = "\x1d"
+
+// GS1 traz os campos de uma leitura GS1 usados nas movimentações de estoque. Elements guarda todos os
+// identificadores de aplicação (AI) lidos, inclusive os não interpretados.
+type GS1 struct {
+	GTIN     string            `json:"gtin"`
+	Lot      string            `json:"lot,omitempty"`
+	Expiry   *time.Time        `json:"expiry,omitempty"`
+	Serial   string            `json:"serial,omitempty"`
+	Quantity int               `json:"quantity,omitempty"`
+	Elements map[string]string `json:"elements"`
+}
+
+// aiSpec descreve um AI: quantos dígitos tem e o tamanho do dado (fixo, ou máximo quando variável).
+type aiSpec struct {
+	aiLength int
+	length   int
+	variable bool
+}
+
+// aiSpecs é indexado pelos dois primeiros dígitos do AI.
+var aiSpecs = map[string]aiSpec{
+	"00": {2, 18, false}, "01": {2, 14, false}, "02": {2, 14, false},
+	"10": {2, 20, true}, "11": {2, 6, false}, "12": {2, 6, false}, "13": {2, 6, false},
+	"15": {2, 6, false}, "16": {2, 6, false}, "17": {2, 6, false},
+	"20": {2, 2, false}, "21": {2, 20, true}, "22": {2, 20, true},
+	"24": {3, 30, true}, "25": {3, 30, true},
+	"30": {2, 8, true}, "31": {4, 6, false}, "32": {4, 6, false}, "33": {4, 6, false},
+	"34": {4, 6, false}, "35": {4, 6, false}, "36": {4, 6, false}, "37": {2, 8, true},
+	"41": {3, 13, false},
+	"90": {2, 30, true}, "91": {2, 90, true}, "92": {2, 90, true}, "93": {2, 90, true}, "94": {2, 90, true},
+	"95": {2, 90, true}, "96": {2, 90, true}, "97": {2, 90, true}, "98": {2, 90, true}, "99": {2, 90, true},
+}
+
+// symbologyIDs são os prefixos que os leitores enviam antes de uma leitura GS1: GS1-128, GS1
+// DataMatrix, GS1 QR Code e GS1 DataBar.
+var symbologyIDs = []string{"]C1", "]d2", "]Q3", "]e0"}
+
+// IsGS1 diz se a leitura é uma cadeia GS1 e não um código de barras simples: com prefixo de
+// simbologia, no formato legível com AIs entre parênteses, com separadores FNC1 ou começando pelo AI
+// 01 seguido de um GTIN-14 válido e mais dados.
+func IsGS1(s string) bool {
+	for _, id := range symbologyIDs {
+		if strings.HasPrefix(s, id) {
+			return true
+		}
+	}
+	if strings.HasPrefix(s, "(") || strings.Contains(s, groupSeparator) {
+		return true
+	}
+	return len(s) > 16 && strings.HasPrefix(s, "01") && Validate(s[2:16]) == nil
+}
+
+// ParseGS1 interpreta uma leitura GS1-128 ou GS1 DataMatrix, no formato bruto (campos variáveis
+// encerrados por FNC1) ou no legível, como "(01)07891234567895(17)261231(10)L42". O AI 01 é
+// obrigatório e seu dígito verificador é conferido. Datas com dia 00 valem o último dia do mês, e
+// os anos são deste século.
+func ParseGS1(s string) (*GS1, error) {
+	for _, id := range symbologyIDs {
+		s = strings.TrimPrefix(s, id)
+	}
+	var elements map[string]string
+	var err error
+	if strings.HasPrefix(s, "(") {
+		elements, err = parseBracketed(s)
+	} else {
+		elements, err = parseRaw(strings.TrimPrefix(s, groupSeparator))
+	}
+	if err != nil {
+		return nil, err
+	}
+	g := &GS1{GTIN: elements["01"], Lot: elements["10"], Serial: elements["21"], Elements: elements}
+	if err := Validate(g.GTIN); err != nil || len(g.GTIN) != 14 {
+		return nil, ErrInvalidGS1
+	}
+	if v, ok := elements["17"]; ok {
+		expiry, err := parseDate(v)
+		if err != nil {
+			return nil, err
+		}
+		g.Expiry = &expiry
+	}
+	if v, ok := elements["30"]; ok {
+		q, err := strconv.Atoi(v)
+		if err != nil || q <= 0 || !digits(v) {
+			return nil, ErrInvalidGS1Qty
+		}
+		g.Quantity = q
+	}
+	return g, nil
+}
+
+func parseRaw(s string) (map[string]string, error) {
+	elements := map[string]string{}
+	for s != "" {
+		if len(s) < 2 {
+			return nil, ErrInvalidGS1
+		}
+		spec, ok := aiSpecs[s[:2]]
+		if !ok {
+			return nil, ErrUnsupportedAI
+		}
+		if len(s) < spec.aiLength || !digits(s[:spec.aiLength]) {
+			return nil, ErrInvalidGS1
+		}
+		ai := s[:spec.aiLength]
+		s = s[spec.aiLength:]
+		var value string
+		if spec.variable {
+			end := strings.Index(s, groupSeparator)
+			if end < 0 {
+				end = len(s)
+			}
+			value, s = s[:end], strings.TrimPrefix(s[end:], groupSeparator)
+			if value == "" || len(value) > spec.length {
+				return nil, ErrInvalidGS1
+			}
+		} else {
+			if len(s) < spec.length {
+				return nil, ErrInvalidGS1
+			}
+			value, s = s[:spec.length], strings.TrimPrefix(s[spec.length:], groupSeparator)
+		}
+		elements[ai] = value
+	}
+	return elements, nil
+}
+
+func parseBracketed(s string) (map[string]string, error) {
+	elements := map[string]string{}
+	for s != "" {
+		if !strings.HasPrefix(s, "(") {
+			return nil, ErrInvalidGS1
+		}
+		end := strings.Index(s, ")")
+		if end < 0 {
+			return nil, ErrInvalidGS1
+		}
+		ai := s[1:end]
+		s = s[end+1:]
+		spec, ok := aiSpecs[ai[:min(2, len(ai))]]
+		if !ok {
+			return nil, ErrUnsupportedAI
+		}
+		if len(ai) != spec.aiLength || !digits(ai) {
+			return nil, ErrInvalidGS1
+		}
+		next := strings.Index(s, "(")
+		if next < 0 {
+			next = len(s)
+		}
+		value := s[:next]
+		s = s[next:]
+		if value == "" || len(value) > spec.length || (!spec.variable && len(value) != spec.length) {
+			return nil, ErrInvalidGS1
+		}
+		elements[ai] = value
+	}
+	return elements, nil
+}
+
+// parseDate lê uma data GS1 YYMMDD; dia 00 é o último dia do mês.
+func parseDate(v string) (time.Time, error) {
+	if len(v) != 6 || !digits(v) {
+		return time.Time{}, ErrInvalidExpiry
+	}
+	year, _ := strconv.Atoi(v[:2])
+	month, _ := strconv.Atoi(v[2:4])
+	day, _ := strconv.Atoi(v[4:])
+	if month < 1 || month > 12 {
+		return time.Time{}, ErrInvalidExpiry
+	}
+	first := time.Date(2000+year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	if day == 0 {
+		return first.AddDate(0, 1, -1), nil
+	}
+	date := first.AddDate(0, 0, day-1)
+	if date.Month() != time.Month(month) {
+		return time.Time{}, ErrInvalidExpiry
+	}
+	return date, nil
+}
@@ -0,0 +1,342 @@
+package barcode
+
+// Versões 1 a 6 do QR Code com correção de erros nível M (cerca de 15%), em modo byte: até 106
+// bytes, o bastante para códigos de produto. Nessas versões todos os blocos têm o mesmo tamanho.
+var qrVersions = [7]struct {
+	blocks, data, ec int // blocos, palavras de dados e de correção por bloco
+	align            []int
+}{
+	{},
+	{1, 16, 10, nil},
+	{1, 28, 16, []int{6, 18}},
+	{1, 44, 26, []int{6, 22}},
+	{2, 32, 18, []int{6, 26}},
+	{2, 43, 24, []int{6, 30}},
+	{4, 27, 16, []int{6, 34}},
+}
+
+// Bits do nível M no campo de formato
+const qrLevelM = 0
+
+type qrMatrix struct {
+	size     int
+	dark     [][]bool
+	function [][]bool
+}
+
+func encodeQR(code string) (Symbol, error) {
+	version := 0
+	for v := 1; v < len(qrVersions); v++ {
+		if 4+8+8*len(code) <= qrVersions[v].blocks*qrVersions[v].data*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return Symbol{}, ErrTooLong
+	}
+	q := newQRMatrix(version)
+	q.place(qrCodewords([]byte(code), version))
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormat(mask)
+		if p := q.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		q.applyMask(mask)
+	}
+	q.applyMask(best)
+	q.drawFormat(best)
+	return Symbol{Symbology: QR, Rows: q.dark}, nil
+}
+
+// qrCodewords monta os dados em modo byte, completa com os bytes de preenchimento e intercala as
+// palavras de dados e de correção dos blocos.
+func qrCodewords(data []byte, version int) []byte {
+	spec := qrVersions[version]
+	capacity := spec.blocks * spec.data * 8
+	var bits []bool
+	put := func(v, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, v>>i&1 == 1)
+		}
+	}
+	put(0b0100, 4)
+	put(len(data), 8)
+	for _, b := range data {
+		put(int(b), 8)
+	}
+	put(0, min(4, capacity-len(bits)))
+	put(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		put(pad, 8)
+	}
+	codewords := make([]byte, len(bits)/8)
+	for i, b := range bits {
+		if b {
+			codewords[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+
+	divisor := rsDivisor(spec.ec)
+	blocks := make([][]byte, spec.blocks)
+	ecBlocks := make([][]byte, spec.blocks)
+	for i := range blocks {
+		blocks[i] = codewords[i*spec.data : (i+1)*spec.data]
+		ecBlocks[i] = rsRemainder(blocks[i], divisor)
+	}
+	var out []byte
+	for i := 0; i < spec.data; i++ {
+		for _, b := range blocks {
+			out = append(out, b[i])
+		}
+	}
+	for i := 0; i < spec.ec; i++ {
+		for _, b := range ecBlocks {
+			out = append(out, b[i])
+		}
+	}
+	return out
+}
+
+// rsMultiply multiplica no corpo GF(256) com o polinômio do QR Code, x^8 + x^4 + x^3 + x^2 + 1.
+func rsMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+// rsDivisor calcula o polinômio gerador de Reed-Solomon do grau pedido, sem o coeficiente de maior grau.
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = rsMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = rsMultiply(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder calcula as palavras de correção dos dados.
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coef := range divisor {
+			result[i] ^= rsMultiply(coef, factor)
+		}
+	}
+	return result
+}
+
+func newQRMatrix(version int) *qrMatrix {
+	size := 17 + 4*version
+	q := &qrMatrix{size: size, dark: make([][]bool, size), function: make([][]bool, size)}
+	for i := range q.dark {
+		q.dark[i] = make([]bool, size)
+		q.function[i] = make([]bool, size)
+	}
+	for i := 0; i < size; i++ {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+	q.finder(3, 3)
+	q.finder(size-4, 3)
+	q.finder(3, size-4)
+	align := qrVersions[version].align
+	for _, x := range align {
+		for _, y := range align {
+			first, last := align[0], align[len(align)-1]
+			if (x == first && y == first) || (x == first && y == last) || (x == last && y == first) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+	// Reserva a área de formato, desenhada de fato depois da escolha da máscara
+	q.drawFormat(0)
+	return q
+}
+
+// set marca um módulo de função na coluna x e linha y.
+func (q *qrMatrix) set(x, y int, dark bool) {
+	q.dark[y][x] = dark
+	q.function[y][x] = true
+}
+
+// finder desenha um padrão de localização com o separador em volta, centrado em x, y.
+func (q *qrMatrix) finder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= q.size || yy < 0 || yy >= q.size {
+				continue
+			}
+			d := max(abs(dx), abs(dy))
+			q.set(xx, yy, d != 2 && d != 4)
+		}
+	}
+}
+
+// drawFormat grava o campo de formato nas suas duas cópias e o módulo escuro fixo.
+func (q *qrMatrix) drawFormat(mask int) {
+	bits := qrFormatBits(mask)
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+	for i := 0; i <= 5; i++ {
+		q.set(8, i, bit(i))
+	}
+	q.set(8, 7, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		q.set(q.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(8, q.size-15+i, bit(i))
+	}
+	q.set(8, q.size-8, true)
+}
+
+// qrFormatBits devolve os 15 bits do campo de formato: nível M e máscara protegidos por BCH(15,5) e
+// combinados com a máscara fixa da norma.
+func qrFormatBits(mask int) int {
+	data := qrLevelM<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// place distribui os bits em zigue-zague de baixo para cima, em pares de colunas da direita para a
+// esquerda, pulando os módulos de função e a coluna do padrão de sincronismo.
+func (q *qrMatrix) place(codewords []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = q.size - 1 - vert
+				}
+				if !q.function[y][x] && i < len(codewords)*8 {
+					q.dark[y][x] = codewords[i/8]>>(7-i%8)&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask inverte os módulos de dados selecionados pela máscara; aplicar duas vezes desfaz.
+func (q *qrMatrix) applyMask(mask int) {
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !q.function[y][x] {
+				q.dark[y][x] = !q.dark[y][x]
+			}
+		}
+	}
+}
+
+// penalty pontua a matriz pelas regras da norma: sequências de cinco ou mais módulos iguais, blocos
+// 2x2 da mesma cor, padrões parecidos com os de localização e desequilíbrio entre claros e escuros.
+func (q *qrMatrix) penalty() int {
+	at := func(y, x int, transpose bool) bool {
+		if transpose {
+			return q.dark[x][y]
+		}
+		return q.dark[y][x]
+	}
+	result, dark := 0, 0
+	for _, transpose := range []bool{false, true} {
+		for y := 0; y < q.size; y++ {
+			run := 1
+			for x := 1; x <= q.size; x++ {
+				if x < q.size && at(y, x, transpose) == at(y, x-1, transpose) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					result += 3 + run - 5
+				}
+				run = 1
+			}
+			for x := 0; x+11 <= q.size; x++ {
+				var pattern [11]bool
+				for k := range pattern {
+					pattern[k] = at(y, x+k, transpose)
+				}
+				if pattern == [11]bool{true, false, true, true, true, false, true, false, false, false, false} ||
+					pattern == [11]bool{false, false, false, false, true, false, true, true, true, false, true} {
+					result += 40
+				}
+			}
+		}
+	}
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if q.dark[y][x] {
+				dark++
+			}
+			if x+1 < q.size && y+1 < q.size {
+				c := q.dark[y][x]
+				if c == q.dark[y][x+1] && c == q.dark[y+1][x] && c == q.dark[y+1][x+1] {
+					result += 3
+				}
+			}
+		}
+	}
+	total := q.size * q.size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return result + k*10
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
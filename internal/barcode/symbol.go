@@ -0,0 +1,193 @@
+package barcode
+
+import (
+	"errors"
+	"strings"
+)
+
+// Simbologias que sabemos desenhar
+const (
+	EAN13   = "ean13"
+	Code128 = "code128"
+	QR      = "qr"
+)
+
+var (
+	ErrUnknownSymbology = errors.New("unknown symbology, expected ean13, code128 or qr")
+	ErrNotEAN13         = errors.New("barcode is not an EAN-13 or UPC-A with a valid check digit")
+	ErrUnencodable      = errors.New("barcode has characters Code 128 cannot encode")
+	ErrTooLong          = errors.New("barcode is too long for a QR code")
+)
+
+// Symbol é o desenho de um código: os módulos de cada linha, true nos escuros. Códigos lineares têm
+// uma única linha, esticada na altura das barras; o QR é uma matriz quadrada.
+type Symbol struct {
+	Symbology string
+	Rows      [][]bool
+}
+
+// Linear diz se o símbolo é de barras.
+func (s Symbol) Linear() bool {
+	return len(s.Rows) == 1
+}
+
+// Width é a largura do símbolo em módulos, sem a zona de silêncio.
+func (s Symbol) Width() int {
+	return len(s.Rows[0])
+}
+
+// CheckSymbology confere se a simbologia é uma das que sabemos desenhar; vazia também vale.
+func CheckSymbology(symbology string) error {
+	switch symbology {
+	case "", EAN13, Code128, QR:
+		return nil
+	}
+	return ErrUnknownSymbology
+}
+
+// Encode desenha o código na simbologia pedida. Vazia escolhe EAN-13 para EAN-13 e UPC-A válidos,
+// Code 128 para os demais e QR para os que têm caracteres fora do ASCII imprimível.
+func Encode(code, symbology string) (Symbol, error) {
+	if symbology == "" {
+		if (len(code) == 13 || len(code) == 12) && Validate(code) == nil {
+			return encodeEAN13(code)
+		}
+		if s, err := encodeCode128(code); err != ErrUnencodable {
+			return s, err
+		}
+		return encodeQR(code)
+	}
+	switch symbology {
+	case EAN13:
+		return encodeEAN13(code)
+	case Code128:
+		return encodeCode128(code)
+	case QR:
+		return encodeQR(code)
+	}
+	return Symbol{}, ErrUnknownSymbology
+}
+
+// Padrões L dos dígitos do EAN-13; os R são o complemento e os G, o R invertido
+var eanL = [10]string{"0001101", "0011001", "0010011", "0111101", "0100011", "0110001", "0101111", "0111011", "0110111", "0001011"}
+
+// Paridade (L ou G) dos seis dígitos da esquerda conforme o primeiro dígito, que não é desenhado
+var eanParity = [10]string{"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG", "LGGLLG", "LGGGLL", "LGLGLG", "LGLGGL", "LGGLGL"}
+
+func encodeEAN13(code string) (Symbol, error) {
+	if len(code) == 12 {
+		code = "0" + code
+	}
+	if len(code) != 13 || Validate(code) != nil {
+		return Symbol{}, ErrNotEAN13
+	}
+	var b strings.Builder
+	b.WriteString("101")
+	parity := eanParity[code[0]-'0']
+	for i := 1; i <= 6; i++ {
+		l := eanL[code[i]-'0']
+		if parity[i-1] == 'G' {
+			l = reverse(complement(l))
+		}
+		b.WriteString(l)
+	}
+	b.WriteString("01010")
+	for i := 7; i <= 12; i++ {
+		b.WriteString(complement(eanL[code[i]-'0']))
+	}
+	b.WriteString("101")
+	return Symbol{Symbology: EAN13, Rows: [][]bool{modules(b.String())}}, nil
+}
+
+// Larguras alternadas de barras e espaços dos símbolos 0 a 105 do Code 128; 103 a 105 iniciam os
+// conjuntos A, B e C
+var code128Patterns = [106]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232",
+}
+
+const (
+	code128StartB = 104
+	code128StartC = 105
+	code128Stop   = "2331112"
+)
+
+// encodeCode128 usa o conjunto C, dois dígitos por símbolo, quando o código tem só dígitos em número
+// par, e o conjunto B (ASCII imprimível) nos demais.
+func encodeCode128(code string) (Symbol, error) {
+	if code == "" {
+		return Symbol{}, ErrUnencodable
+	}
+	var values []int
+	if digits(code) && len(code)%2 == 0 {
+		values = append(values, code128StartC)
+		for i := 0; i < len(code); i += 2 {
+			values = append(values, int(code[i]-'0')*10+int(code[i+1]-'0'))
+		}
+	} else {
+		values = append(values, code128StartB)
+		for i := 0; i < len(code); i++ {
+			if code[i] < 32 || code[i] > 126 {
+				return Symbol{}, ErrUnencodable
+			}
+			values = append(values, int(code[i])-32)
+		}
+	}
+	check := values[0]
+	for i, v := range values[1:] {
+		check += (i + 1) * v
+	}
+	values = append(values, check%103)
+	var b strings.Builder
+	for _, v := range values {
+		b.WriteString(widths(code128Patterns[v]))
+	}
+	b.WriteString(widths(code128Stop))
+	return Symbol{Symbology: Code128, Rows: [][]bool{modules(b.String())}}, nil
+}
+
+// widths expande larguras alternadas de barra e espaço em módulos ("1" escuro, "0" claro).
+func widths(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := "1"
+		if i%2 == 1 {
+			c = "0"
+		}
+		b.WriteString(strings.Repeat(c, int(pattern[i]-'0')))
+	}
+	return b.String()
+}
+
+func modules(s string) []bool {
+	row := make([]bool, len(s))
+	for i := range s {
+		row[i] = s[i] == '1'
+	}
+	return row
+}
+
+func complement(s string) string {
+	b := []byte(s)
+	for i := range b {
+		b[i] = '0' + '1' - b[i]
+	}
+	return string(b)
+}
+
+func reverse(s string) string {
+	b := []byte(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
@@ -0,0 +1,57 @@
+package barcode
+
+import "errors"
+
+var ErrInvalidRule = errors.New("invalid variable measure rule: the prefix must have 1 to 3 digits starting with 2 and prefix, item and value digits must fit in 12 digits")
+
+// VariableRule descreve um EAN-13 de medida variável, de uso interno (prefixo 2): depois de Prefix vêm
+// ItemDigits dígitos do código do item, e os ValueDigits dígitos imediatamente antes do dígito
+// verificador trazem o peso, a quantidade ou o preço. Dígitos entre os dois, como o verificador do
+// preço de alguns formatos de balança, são ignorados.
+type VariableRule struct {
+	Prefix      string
+	ItemDigits  int
+	ValueDigits int
+}
+
+// Check confere se a regra cabe em um EAN-13 de uso interno.
+func (r VariableRule) Check() error {
+	if len(r.Prefix) < 1 || len(r.Prefix) > 3 || r.Prefix[0] != '2' || !digits(r.Prefix) {
+		return ErrInvalidRule
+	}
+	if r.ItemDigits < 1 || r.ValueDigits < 1 || len(r.Prefix)+r.ItemDigits+r.ValueDigits > 12 {
+		return ErrInvalidRule
+	}
+	return nil
+}
+
+// MatchVariable devolve o índice da regra de prefixo mais longo que se aplica ao código, ou -1 quando
+// nenhuma se aplica ou o código não é um EAN-13 válido começado por 2.
+func MatchVariable(code string, rules []VariableRule) int {
+	if len(code) != 13 || code[0] != '2' || Validate(code) != nil {
+		return -1
+	}
+	match := -1
+	for i, r := range rules {
+		if r.Check() != nil || code[:len(r.Prefix)] != r.Prefix {
+			continue
+		}
+		if match < 0 || len(r.Prefix) > len(rules[match].Prefix) {
+			match = i
+		}
+	}
+	return match
+}
+
+// DecodeVariable separa o código lido no código do produto base e no valor embutido. O código base é
+// o mesmo EAN-13 com os dígitos depois do item zerados e o dígito verificador recalculado, que é como
+// o produto deve estar cadastrado.
+func DecodeVariable(code string, r VariableRule) (string, int) {
+	item := len(r.Prefix) + r.ItemDigits
+	payload := code[:item] + "000000000000"[:12-item]
+	value := 0
+	for _, c := range code[12-r.ValueDigits : 12] {
+		value = value*10 + int(c-'0')
+	}
+	return payload + string(rune('0'+CheckDigit(payload))), value
+}
@@ -0,0 +1,210 @@
+package categories
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"inventory-system/internal"
+	"inventory-system/internal/users"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var validate = validator.New()
+
+func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(payload)
+}
+
+func respondError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, map[string]string{"error": message})
+}
+
+func RegisterRoutes(r chi.Router, db *pgxpool.Pool) {
+	service := NewService(NewRepository(db))
+
+	r.Route("/categories", func(r chi.Router) {
+		r.Use(internal.AuthMiddleware)
+		r.Post("/", createCategoryHandler(service))
+		r.Get("/", getCategoriesHandler(service))
+		r.Get("/rollups", getRollupsHandler(service))
+		r.Get("/{id}", getCategoryHandler(service))
+		r.Put("/{id}", updateCategoryHandler(service))
+		r.With(users.RequireRole("admin", []byte("changeme"))).Delete("/{id}", deleteCategoryHandler(service))
+	})
+}
+
+// @Security ApiKeyAuth
+// @Summary Create a category
+// @Description Categories form a tree; omit parent_id for a root category.
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param category body Category true "Category data" example({"name":"Dairy","parent_id":1})
+// @Success 201 {object} Category "Created category"
+// @Failure 400 {object} map[string]string "Invalid data"
+// @Failure 404 {object} map[string]string "Parent category not found"
+// @Router /categories [post]
+func createCategoryHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var c Category
+		if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&c); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		if err := s.CreateCategory(r.Context(), &c); err != nil {
+			respondCategoryError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusCreated, c)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary List categories
+// @Description Returns the root categories with their subcategories nested in children.
+// @Tags categories
+// @Produce json
+// @Success 200 {array} Category "Category tree"
+// @Router /categories [get]
+func getCategoriesHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		categories, err := s.GetTree(r.Context())
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, categories)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Category stock rollups
+// @Description Returns the category tree where each category's stock totals the products assigned to it and to all
+// @Description of its subcategories: number of products, quantity in stock and products below their minimum stock.
+// @Tags categories
+// @Produce json
+// @Success 200 {array} Category "Category tree with stock totals"
+// @Router /categories/rollups [get]
+func getRollupsHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		categories, err := s.GetRollups(r.Context())
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, categories)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Get a category
+// @Description Returns the category with its subcategories nested in children.
+// @Tags categories
+// @Produce json
+// @Param id path int true "Category ID"
+// @Success 200 {object} Category "Category data"
+// @Failure 404 {object} map[string]string "Category not found"
+// @Router /categories/{id} [get]
+func getCategoryHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		c, err := s.GetCategory(r.Context(), id)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if c == nil {
+			respondError(w, http.StatusNotFound, "Category not found")
+			return
+		}
+		respondJSON(w, http.StatusOK, c)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Update a category
+// @Description Renames the category or moves it, with its subcategories and products, under another parent.
+// @Tags categories
+// @Accept json
+// @Param id path int true "Category ID"
+// @Param category body Category true "Category data" example({"name":"Dairy","parent_id":1})
+// @Success 200 {object} map[string]string "Updated"
+// @Failure 400 {object} map[string]string "Invalid data or move under one of its own subcategories"
+// @Failure 404 {object} map[string]string "Category or parent category not found"
+// @Router /categories/{id} [put]
+func updateCategoryHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		var c Category
+		if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&c); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		if err := s.UpdateCategory(r.Context(), id, &c); err != nil {
+			respondCategoryError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, nil)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Delete a category
+// @Tags categories
+// @Param id path int true "Category ID"
+// @Success 204 {object} map[string]string "Deleted"
+// @Failure 404 {object} map[string]string "Category not found"
+// @Failure 409 {object} map[string]string "Category still has subcategories or products"
+// @Router /categories/{id} [delete]
+func deleteCategoryHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		if err := s.DeleteCategory(r.Context(), id); err != nil {
+			respondCategoryError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusNoContent, nil)
+	}
+}
+
+func respondCategoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		respondError(w, http.StatusNotFound, "Category not found")
+	case errors.Is(err, ErrParentNotFound):
+		respondError(w, http.StatusNotFound, "Parent category not found")
+	case errors.Is(err, ErrCycle):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrInUse):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,19 @@
+package categories
+
+// Category é um nó da árvore de categorias de produtos. ParentID vazio indica uma categoria raiz.
+type Category struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name" validate:"required"`
+	ParentID *int   `json:"parent_id"`
+	// Subcategorias, preenchidas apenas nas respostas em árvore
+	Children []*Category `json:"children,omitempty"`
+	// Totais da categoria com todas as subcategorias, preenchidos apenas em /categories/rollups
+	Stock *Stock `json:"stock,omitempty"`
+}
+
+// Stock totaliza produtos, quantidade em estoque e produtos abaixo do estoque mínimo.
+type Stock struct {
+	Products int `json:"products"`
+	Quantity int `json:"quantity"`
+	LowStock int `json:"low_stock"`
+}
@@ -0,0 +1,138 @@
+package categories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrNotFound       = errors.New("category not found")
+	ErrParentNotFound = errors.New("parent category not found")
+	ErrCycle          = errors.New("a category cannot be moved under itself or one of its subcategories")
+	ErrInUse          = errors.New("category still has subcategories or products")
+)
+
+type Repository struct {
+	DB *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{DB: db}
+}
+
+func (r *Repository) CreateCategory(ctx context.Context, c *Category) error {
+	return r.DB.QueryRow(ctx, `INSERT INTO categories (name, parent_id) VALUES ($1, $2) RETURNING id`, c.Name, c.ParentID).Scan(&c.ID)
+}
+
+// querier é atendido tanto pelo pool quanto por uma transação.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
+// GetCategories devolve todas as categorias, sem montar a árvore, em ordem alfabética.
+func (r *Repository) GetCategories(ctx context.Context) ([]Category, error) {
+	return queryCategories(ctx, r.DB)
+}
+
+func queryCategories(ctx context.Context, q querier) ([]Category, error) {
+	rows, err := q.Query(ctx, `SELECT id, name, parent_id FROM categories ORDER BY name, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	categories := []Category{}
+	for rows.Next() {
+		var c Category
+		if err := rows.Scan(&c.ID, &c.Name, &c.ParentID); err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+func (r *Repository) GetCategory(ctx context.Context, id int) (*Category, error) {
+	var c Category
+	err := r.DB.QueryRow(ctx, `SELECT id, name, parent_id FROM categories WHERE id=$1`, id).Scan(&c.ID, &c.Name, &c.ParentID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+// UpdateCategory confere o novo pai e grava a categoria na mesma transação, com a tabela bloqueada
+// para escrita: duas mudanças simultâneas não podem passar cada uma pela verificação de ciclo e
+// juntas formar um ciclo.
+func (r *Repository) UpdateCategory(ctx context.Context, id int, c *Category) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `LOCK TABLE categories IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return err
+	}
+	categories, err := queryCategories(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if err := checkMove(categories, id, c.ParentID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE categories SET name=$1, parent_id=$2 WHERE id=$3`, c.Name, c.ParentID, id); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *Repository) DeleteCategory(ctx context.Context, id int) error {
+	cmd, err := r.DB.Exec(ctx, `DELETE FROM categories WHERE id=$1`, id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return ErrInUse
+		}
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetStock totaliza, por categoria, apenas os produtos atribuídos diretamente a ela. Abaixo do mínimo
+// são os produtos com quantidade menor que min_stock, o mesmo critério dos avisos de estoque baixo.
+func (r *Repository) GetStock(ctx context.Context) (map[int]Stock, error) {
+	rows, err := r.DB.Query(ctx, `SELECT category_id, COUNT(*), COALESCE(SUM(quantity), 0), COUNT(*) FILTER (WHERE quantity < min_stock)
+		FROM products WHERE category_id IS NOT NULL GROUP BY category_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stock := map[int]Stock{}
+	for rows.Next() {
+		var id int
+		var s Stock
+		if err := rows.Scan(&id, &s.Products, &s.Quantity, &s.LowStock); err != nil {
+			return nil, err
+		}
+		stock[id] = s
+	}
+	return stock, rows.Err()
+}
+
+type RepositoryInterface interface {
+	CreateCategory(ctx context.Context, c *Category) error
+	GetCategories(ctx context.Context) ([]Category, error)
+	GetCategory(ctx context.Context, id int) (*Category, error)
+	UpdateCategory(ctx context.Context, id int, c *Category) error
+	DeleteCategory(ctx context.Context, id int) error
+	GetStock(ctx context.Context) (map[int]Stock, error)
+}
@@ -0,0 +1,128 @@
+package categories
+
+import "context"
+
+type Service struct {
+	Repo RepositoryInterface
+}
+
+func NewService(repo RepositoryInterface) *Service {
+	return &Service{Repo: repo}
+}
+
+func (s *Service) CreateCategory(ctx context.Context, c *Category) error {
+	if c.ParentID != nil {
+		parent, err := s.Repo.GetCategory(ctx, *c.ParentID)
+		if err != nil {
+			return err
+		}
+		if parent == nil {
+			return ErrParentNotFound
+		}
+	}
+	return s.Repo.CreateCategory(ctx, c)
+}
+
+// GetTree devolve as categorias raiz com as subcategorias aninhadas.
+func (s *Service) GetTree(ctx context.Context) ([]*Category, error) {
+	categories, err := s.Repo.GetCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	roots, _ := buildTree(categories)
+	return roots, nil
+}
+
+// GetCategory devolve a categoria com a sua subárvore.
+func (s *Service) GetCategory(ctx context.Context, id int) (*Category, error) {
+	categories, err := s.Repo.GetCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, nodes := buildTree(categories)
+	return nodes[id], nil
+}
+
+// UpdateCategory renomeia a categoria ou a move para outro pai; mover para baixo de si mesma ou de
+// uma subcategoria criaria um ciclo e é recusado (veja checkMove).
+func (s *Service) UpdateCategory(ctx context.Context, id int, c *Category) error {
+	return s.Repo.UpdateCategory(ctx, id, c)
+}
+
+// checkMove confere, sobre a lista completa de categorias, que a categoria id existe e que o novo pai
+// existe e não é ela mesma nem uma de suas subcategorias.
+func checkMove(categories []Category, id int, parentID *int) error {
+	parents := map[int]*int{}
+	for _, existing := range categories {
+		parents[existing.ID] = existing.ParentID
+	}
+	if _, ok := parents[id]; !ok {
+		return ErrNotFound
+	}
+	if parentID != nil {
+		if _, ok := parents[*parentID]; !ok {
+			return ErrParentNotFound
+		}
+		for p := parentID; p != nil; p = parents[*p] {
+			if *p == id {
+				return ErrCycle
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteCategory remove uma categoria sem subcategorias nem produtos.
+func (s *Service) DeleteCategory(ctx context.Context, id int) error {
+	return s.Repo.DeleteCategory(ctx, id)
+}
+
+// GetRollups devolve a árvore de categorias com os totais de cada uma somados aos de todas as
+// suas subcategorias.
+func (s *Service) GetRollups(ctx context.Context) ([]*Category, error) {
+	categories, err := s.Repo.GetCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stock, err := s.Repo.GetStock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	roots, _ := buildTree(categories)
+	for _, root := range roots {
+		rollup(root, stock)
+	}
+	return roots, nil
+}
+
+func rollup(c *Category, stock map[int]Stock) Stock {
+	total := stock[c.ID]
+	for _, child := range c.Children {
+		sub := rollup(child, stock)
+		total.Products += sub.Products
+		total.Quantity += sub.Quantity
+		total.LowStock += sub.LowStock
+	}
+	c.Stock = &total
+	return total
+}
+
+// buildTree aninha as categorias sob os pais, mantendo a ordem recebida entre irmãs, e devolve as
+// raízes e os nós por ID.
+func buildTree(categories []Category) ([]*Category, map[int]*Category) {
+	nodes := make(map[int]*Category, len(categories))
+	for i := range categories {
+		nodes[categories[i].ID] = &categories[i]
+	}
+	roots := []*Category{}
+	for i := range categories {
+		c := &categories[i]
+		if c.ParentID == nil || nodes[*c.ParentID] == nil {
+			roots = append(roots, c)
+			continue
+		}
+		parent := nodes[*c.ParentID]
+		parent.Children = append(parent.Children, c)
+	}
+	return roots, nodes
+}
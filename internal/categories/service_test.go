@@ -0,0 +1,185 @@
+package categories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type mockCategoryRepo struct {
+	categories []Category
+	stock      map[int]Stock
+	fail       bool
+}
+
+func (m *mockCategoryRepo) CreateCategory(ctx context.Context, c *Category) error {
+	if m.fail {
+		return fmt.Errorf("db error")
+	}
+	c.ID = len(m.categories) + 1
+	m.categories = append(m.categories, Category{ID: c.ID, Name: c.Name, ParentID: c.ParentID})
+	return nil
+}
+func (m *mockCategoryRepo) GetCategories(ctx context.Context) ([]Category, error) {
+	if m.fail {
+		return nil, fmt.Errorf("db error")
+	}
+	// Cópia, como uma nova leitura do banco
+	return append([]Category{}, m.categories...), nil
+}
+func (m *mockCategoryRepo) GetCategory(ctx context.Context, id int) (*Category, error) {
+	if m.fail {
+		return nil, fmt.Errorf("db error")
+	}
+	for _, c := range m.categories {
+		if c.ID == id {
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+func (m *mockCategoryRepo) UpdateCategory(ctx context.Context, id int, c *Category) error {
+	if err := checkMove(m.categories, id, c.ParentID); err != nil {
+		return err
+	}
+	for i := range m.categories {
+		if m.categories[i].ID == id {
+			m.categories[i].Name, m.categories[i].ParentID = c.Name, c.ParentID
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+func (m *mockCategoryRepo) DeleteCategory(ctx context.Context, id int) error {
+	if m.stock[id].Products > 0 {
+		return ErrInUse
+	}
+	for _, c := range m.categories {
+		if c.ParentID != nil && *c.ParentID == id {
+			return ErrInUse
+		}
+	}
+	for i, c := range m.categories {
+		if c.ID == id {
+			m.categories = append(m.categories[:i], m.categories[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+func (m *mockCategoryRepo) GetStock(ctx context.Context) (map[int]Stock, error) {
+	if m.fail {
+		return nil, fmt.Errorf("db error")
+	}
+	return m.stock, nil
+}
+
+// Alimentos (1) > Laticínios (2) > Queijos (3); Limpeza (4)
+func newTree(t *testing.T) (*Service, *mockCategoryRepo) {
+	repo := &mockCategoryRepo{}
+	svc := NewService(repo)
+	ctx := context.Background()
+	for _, c := range []*Category{{Name: "Alimentos"}, {Name: "Laticínios", ParentID: intPtr(1)}, {Name: "Queijos", ParentID: intPtr(2)}, {Name: "Limpeza"}} {
+		if err := svc.CreateCategory(ctx, c); err != nil {
+			t.Fatalf("erro ao criar categoria: %v", err)
+		}
+	}
+	return svc, repo
+}
+
+func intPtr(v int) *int { return &v }
+
+func TestService_Tree_Mock(t *testing.T) {
+	svc, _ := newTree(t)
+	ctx := context.Background()
+	if err := svc.CreateCategory(ctx, &Category{Name: "X", ParentID: intPtr(99)}); !errors.Is(err, ErrParentNotFound) {
+		t.Errorf("esperado ErrParentNotFound, veio %v", err)
+	}
+	roots, err := svc.GetTree(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 2 || roots[0].Name != "Alimentos" || len(roots[0].Children) != 1 || roots[0].Children[0].Children[0].Name != "Queijos" {
+		t.Fatalf("árvore incorreta: %+v", roots)
+	}
+	c, _ := svc.GetCategory(ctx, 2)
+	if c == nil || len(c.Children) != 1 || c.Children[0].ID != 3 {
+		t.Errorf("subárvore incorreta: %+v", c)
+	}
+	if c, _ := svc.GetCategory(ctx, 99); c != nil {
+		t.Error("esperada categoria inexistente")
+	}
+}
+
+func TestService_UpdateCategory_Mock(t *testing.T) {
+	svc, repo := newTree(t)
+	ctx := context.Background()
+	// Mover para baixo de si mesma ou de uma descendente criaria um ciclo
+	for _, parent := range []int{1, 3} {
+		if err := svc.UpdateCategory(ctx, 1, &Category{Name: "Alimentos", ParentID: intPtr(parent)}); !errors.Is(err, ErrCycle) {
+			t.Errorf("pai %d: esperado ErrCycle, veio %v", parent, err)
+		}
+	}
+	if err := svc.UpdateCategory(ctx, 99, &Category{Name: "X"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("esperado ErrNotFound, veio %v", err)
+	}
+	if err := svc.UpdateCategory(ctx, 2, &Category{Name: "Laticínios", ParentID: intPtr(99)}); !errors.Is(err, ErrParentNotFound) {
+		t.Errorf("esperado ErrParentNotFound, veio %v", err)
+	}
+	// Laticínios passa para Limpeza levando Queijos
+	if err := svc.UpdateCategory(ctx, 2, &Category{Name: "Laticínios", ParentID: intPtr(4)}); err != nil {
+		t.Fatalf("erro ao mover categoria: %v", err)
+	}
+	if *repo.categories[1].ParentID != 4 {
+		t.Error("categoria não foi movida")
+	}
+	// E volta a ser raiz
+	if err := svc.UpdateCategory(ctx, 2, &Category{Name: "Laticínios"}); err != nil || repo.categories[1].ParentID != nil {
+		t.Errorf("categoria não virou raiz: %v", err)
+	}
+}
+
+func TestService_Rollups_Mock(t *testing.T) {
+	svc, repo := newTree(t)
+	ctx := context.Background()
+	repo.stock = map[int]Stock{
+		1: {Products: 1, Quantity: 10},
+		2: {Products: 2, Quantity: 5, LowStock: 1},
+		3: {Products: 3, Quantity: 30, LowStock: 2},
+	}
+	roots, err := svc.GetRollups(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	food, dairy, cleaning := roots[0], roots[0].Children[0], roots[1]
+	if *food.Stock != (Stock{Products: 6, Quantity: 45, LowStock: 3}) {
+		t.Errorf("totais de Alimentos incorretos: %+v", *food.Stock)
+	}
+	if *dairy.Stock != (Stock{Products: 5, Quantity: 35, LowStock: 3}) {
+		t.Errorf("totais de Laticínios incorretos: %+v", *dairy.Stock)
+	}
+	if *cleaning.Stock != (Stock{}) {
+		t.Errorf("categoria sem produtos com totais: %+v", *cleaning.Stock)
+	}
+	// Categorias com subcategorias ou produtos não são removidas
+	if err := svc.DeleteCategory(ctx, 2); !errors.Is(err, ErrInUse) {
+		t.Errorf("esperado ErrInUse, veio %v", err)
+	}
+	if err := svc.DeleteCategory(ctx, 4); err != nil {
+		t.Errorf("erro ao remover categoria: %v", err)
+	}
+}
+
+func TestService_Failures_Mock(t *testing.T) {
+	svc := NewService(&mockCategoryRepo{fail: true})
+	if _, err := svc.GetTree(context.Background()); err == nil {
+		t.Error("esperado erro de banco")
+	}
+	if _, err := svc.GetRollups(context.Background()); err == nil {
+		t.Error("esperado erro de banco")
+	}
+	if err := svc.UpdateCategory(context.Background(), 1, &Category{Name: "X"}); err == nil {
+		t.Error("esperado erro de banco")
+	}
+}
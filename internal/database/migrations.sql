@@ -18,4 +18,532 @@ CREATE TABLE IF NOT EXISTS refresh_tokens (
     user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
     token TEXT NOT NULL UNIQUE,
     expires_at TIMESTAMP NOT NULL
-);
\ No newline at end of file
+);
+
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products (id) ON DELETE RESTRICT,
+    delta INTEGER NOT NULL,
+    balance INTEGER NOT NULL,
+    user_id INTEGER,
+    reason TEXT NOT NULL,
+    reference TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_movements_product_created ON stock_movements (product_id, created_at);
+
+-- O histórico de movimentações é append-only: linhas nunca são alteradas nem removidas
+CREATE OR REPLACE FUNCTION stock_movements_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'stock_movements is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS stock_movements_no_update ON stock_movements;
+CREATE TRIGGER stock_movements_no_update BEFORE UPDATE OR DELETE ON stock_movements
+    FOR EACH ROW EXECUTE FUNCTION stock_movements_append_only();
+
+-- Bancos criados antes tinham ON DELETE CASCADE, que apagaria o histórico junto com o produto;
+-- produtos com movimentações não podem mais ser removidos
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'stock_movements_product_id_fkey' AND confdeltype = 'c') THEN
+        ALTER TABLE stock_movements DROP CONSTRAINT stock_movements_product_id_fkey;
+        ALTER TABLE stock_movements ADD CONSTRAINT stock_movements_product_id_fkey
+            FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE RESTRICT;
+    END IF;
+END;
+$$;
+
+-- Saldo de abertura para produtos cadastrados antes do histórico existir
+INSERT INTO stock_movements (product_id, delta, balance, reason)
+SELECT p.id, p.quantity, p.quantity, 'opening'
+FROM products p
+WHERE p.quantity <> 0
+  AND NOT EXISTS (SELECT 1 FROM stock_movements m WHERE m.product_id = p.id);
+
+CREATE TABLE IF NOT EXISTS locations (
+    id SERIAL PRIMARY KEY,
+    code TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+-- Apenas um local pode ser o padrão (usado quando a requisição não informa location_id)
+CREATE UNIQUE INDEX IF NOT EXISTS idx_locations_single_default ON locations (is_default) WHERE is_default;
+
+INSERT INTO locations (code, name, is_default) VALUES ('MAIN', 'Main stockroom', TRUE) ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS stock_levels (
+    product_id INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    location_id INTEGER NOT NULL REFERENCES locations (id),
+    quantity INTEGER NOT NULL DEFAULT 0,
+    min_stock INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (product_id, location_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_levels_location ON stock_levels (location_id);
+
+-- Estoque existente antes dos locais passa a pertencer ao local padrão
+INSERT INTO stock_levels (product_id, location_id, quantity)
+SELECT p.id, l.id, p.quantity
+FROM products p
+JOIN locations l ON l.is_default
+WHERE NOT EXISTS (SELECT 1 FROM stock_levels s WHERE s.product_id = p.id);
+
+ALTER TABLE stock_movements ADD COLUMN IF NOT EXISTS location_id INTEGER REFERENCES locations (id);
+ALTER TABLE stock_movements ADD COLUMN IF NOT EXISTS location_balance INTEGER;
+
+-- Locais de sistema: mercadorias despachadas em transferências ficam no local de trânsito até o recebimento
+ALTER TABLE locations ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'storage';
+
+INSERT INTO locations (code, name, kind) VALUES ('TRANSIT', 'In transit', 'transit') ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS transfers (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    from_location_id INTEGER NOT NULL REFERENCES locations (id),
+    to_location_id INTEGER NOT NULL REFERENCES locations (id),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    status TEXT NOT NULL DEFAULT 'draft',
+    reference TEXT NOT NULL DEFAULT '',
+    created_by INTEGER,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    dispatched_at TIMESTAMPTZ,
+    received_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_transfers_status ON transfers (status);
+
+CREATE TABLE IF NOT EXISTS lots (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    location_id INTEGER NOT NULL REFERENCES locations (id),
+    lot_number TEXT NOT NULL,
+    expiry_date DATE,
+    quantity INTEGER NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+    expiry_notified_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (product_id, location_id, lot_number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_lots_expiry ON lots (expiry_date) WHERE quantity > 0;
+
+-- Quantidade (sempre positiva) de cada lote envolvido em uma movimentação
+CREATE TABLE IF NOT EXISTS stock_movement_lots (
+    movement_id INTEGER NOT NULL REFERENCES stock_movements (id) ON DELETE CASCADE,
+    lot_id INTEGER NOT NULL REFERENCES lots (id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL,
+    PRIMARY KEY (movement_id, lot_id)
+);
+
+-- Produtos serializados: o saldo é a contagem de números de série em estoque
+ALTER TABLE products ADD COLUMN IF NOT EXISTS serialized BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS serial_numbers (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    serial TEXT NOT NULL UNIQUE,
+    location_id INTEGER NOT NULL REFERENCES locations (id),
+    status TEXT NOT NULL DEFAULT 'in_stock',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_serial_numbers_stock ON serial_numbers (product_id, location_id) WHERE status = 'in_stock';
+
+CREATE TABLE IF NOT EXISTS stock_movement_serials (
+    movement_id INTEGER NOT NULL REFERENCES stock_movements (id) ON DELETE CASCADE,
+    serial_id INTEGER NOT NULL REFERENCES serial_numbers (id) ON DELETE CASCADE,
+    PRIMARY KEY (movement_id, serial_id)
+);
+
+-- Reservas seguram estoque para pedidos pendentes até expires_at
+CREATE TABLE IF NOT EXISTS reservations (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    status TEXT NOT NULL DEFAULT 'active',
+    reference TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_by INTEGER,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    closed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_reservations_active ON reservations (product_id, expires_at) WHERE status = 'active';
+
+-- Política de estoque negativo por produto: forbid (padrão), allow ou floor (até negative_stock_floor)
+ALTER TABLE products ADD COLUMN IF NOT EXISTS negative_stock_policy TEXT NOT NULL DEFAULT 'forbid';
+ALTER TABLE products ADD COLUMN IF NOT EXISTS negative_stock_floor INTEGER NOT NULL DEFAULT 0;
+
+-- Preço de venda, usado no valor dos ajustes de estoque
+ALTER TABLE products ADD COLUMN IF NOT EXISTS price NUMERIC(12, 2) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS adjustment_reasons (
+    code TEXT PRIMARY KEY,
+    description TEXT NOT NULL
+);
+
+INSERT INTO adjustment_reasons (code, description) VALUES
+    ('damage', 'Damaged goods'),
+    ('theft', 'Theft or loss'),
+    ('count_correction', 'Physical count correction'),
+    ('sample', 'Samples and giveaways')
+ON CONFLICT (code) DO NOTHING;
+
+-- Ajustes acima dos limites ficam pendentes até a aprovação de um admin
+CREATE TABLE IF NOT EXISTS adjustments (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    location_id INTEGER NOT NULL REFERENCES locations (id),
+    quantity INTEGER NOT NULL CHECK (quantity <> 0),
+    reason_code TEXT NOT NULL REFERENCES adjustment_reasons (code),
+    note TEXT NOT NULL DEFAULT '',
+    value NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    status TEXT NOT NULL DEFAULT 'pending',
+    created_by INTEGER,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    reviewed_by INTEGER,
+    reviewed_at TIMESTAMPTZ,
+    movement_id INTEGER REFERENCES stock_movements (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_adjustments_status ON adjustments (status);
+
+-- Inventários: as quantidades esperadas são congeladas na abertura da sessão
+CREATE TABLE IF NOT EXISTS stocktakes (
+    id SERIAL PRIMARY KEY,
+    location_id INTEGER REFERENCES locations (id),
+    name_filter TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'open',
+    created_by INTEGER,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    closed_by INTEGER,
+    closed_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS stocktake_lines (
+    stocktake_id INTEGER NOT NULL REFERENCES stocktakes (id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    location_id INTEGER NOT NULL REFERENCES locations (id),
+    expected INTEGER NOT NULL DEFAULT 0,
+    counted INTEGER CHECK (counted >= 0),
+    counted_by INTEGER,
+    counted_at TIMESTAMPTZ,
+    adjustment_id INTEGER REFERENCES adjustments (id),
+    PRIMARY KEY (stocktake_id, product_id, location_id)
+);
+
+-- Fotografias diárias do estoque: saldo de cada produto e local ao fim de cada dia (meia-noite UTC).
+-- Consultas as_of partem da fotografia mais recente e somam só as movimentações posteriores.
+CREATE TABLE IF NOT EXISTS stock_snapshot_runs (
+    taken_at TIMESTAMPTZ PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS stock_snapshots (
+    taken_at TIMESTAMPTZ NOT NULL,
+    product_id INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    location_id INTEGER NOT NULL REFERENCES locations (id),
+    quantity INTEGER NOT NULL,
+    PRIMARY KEY (taken_at, product_id, location_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_movements_created ON stock_movements (created_at);
+
+-- Custeio do estoque: cada movimentação guarda o custo unitário e o efeito no valor do estoque
+ALTER TABLE products ADD COLUMN IF NOT EXISTS costing_method TEXT NOT NULL DEFAULT 'average';
+ALTER TABLE products ADD COLUMN IF NOT EXISTS average_cost NUMERIC(14, 4) NOT NULL DEFAULT 0;
+ALTER TABLE stock_movements ADD COLUMN IF NOT EXISTS unit_cost NUMERIC(14, 4);
+ALTER TABLE stock_movements ADD COLUMN IF NOT EXISTS value NUMERIC(14, 4) NOT NULL DEFAULT 0;
+
+-- Camadas de custo das entradas de produtos FIFO, consumidas da mais antiga para a mais nova
+CREATE TABLE IF NOT EXISTS cost_layers (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    unit_cost NUMERIC(14, 4) NOT NULL,
+    remaining INTEGER NOT NULL CHECK (remaining >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_cost_layers_open ON cost_layers (product_id, id) WHERE remaining > 0;
+
+CREATE TABLE IF NOT EXISTS suppliers (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    email TEXT NOT NULL DEFAULT '',
+    phone TEXT NOT NULL DEFAULT '',
+    notes TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Vínculo produto-fornecedor: código do produto no fornecedor, custo e prazo de entrega
+CREATE TABLE IF NOT EXISTS supplier_products (
+    supplier_id INTEGER NOT NULL REFERENCES suppliers (id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    supplier_sku TEXT NOT NULL DEFAULT '',
+    unit_cost NUMERIC(14, 4) NOT NULL DEFAULT 0,
+    lead_time_days INTEGER NOT NULL DEFAULT 0 CHECK (lead_time_days >= 0),
+    PRIMARY KEY (supplier_id, product_id)
+);
+
+CREATE TABLE IF NOT EXISTS purchase_orders (
+    id SERIAL PRIMARY KEY,
+    supplier_id INTEGER NOT NULL REFERENCES suppliers (id),
+    location_id INTEGER REFERENCES locations (id),
+    status TEXT NOT NULL DEFAULT 'draft',
+    reference TEXT NOT NULL DEFAULT '',
+    notes TEXT NOT NULL DEFAULT '',
+    expected_at DATE,
+    created_by INTEGER,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMPTZ,
+    closed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_purchase_orders_status ON purchase_orders (status);
+
+CREATE TABLE IF NOT EXISTS purchase_order_lines (
+    id SERIAL PRIMARY KEY,
+    purchase_order_id INTEGER NOT NULL REFERENCES purchase_orders (id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES products (id),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    received INTEGER NOT NULL DEFAULT 0 CHECK (received >= 0),
+    unit_cost NUMERIC(14, 4) NOT NULL DEFAULT 0,
+    UNIQUE (purchase_order_id, product_id)
+);
+
+-- Reposição: ponto de pedido, lote de compra e estoque máximo por produto
+ALTER TABLE products ADD COLUMN IF NOT EXISTS reorder_point INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS reorder_qty INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS max_stock INTEGER NOT NULL DEFAULT 0;
+
+-- Fornecedor preferido de cada produto, usado nas sugestões de reposição
+ALTER TABLE supplier_products ADD COLUMN IF NOT EXISTS preferred BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_supplier_products_preferred ON supplier_products (product_id) WHERE preferred;
+
+-- Pedidos de venda: as alocações seguram estoque por local até a expedição
+CREATE TABLE IF NOT EXISTS sales_orders (
+    id SERIAL PRIMARY KEY,
+    customer TEXT NOT NULL,
+    reference TEXT NOT NULL DEFAULT '',
+    notes TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'draft',
+    created_by INTEGER,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    closed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_sales_orders_status ON sales_orders (status);
+
+CREATE TABLE IF NOT EXISTS sales_order_lines (
+    id SERIAL PRIMARY KEY,
+    sales_order_id INTEGER NOT NULL REFERENCES sales_orders (id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES products (id),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    unit_price NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    allocated INTEGER NOT NULL DEFAULT 0 CHECK (allocated >= 0),
+    packed INTEGER NOT NULL DEFAULT 0 CHECK (packed >= 0 AND packed <= allocated),
+    shipped INTEGER NOT NULL DEFAULT 0 CHECK (shipped >= 0),
+    CHECK (allocated + shipped <= quantity),
+    UNIQUE (sales_order_id, product_id)
+);
+
+CREATE TABLE IF NOT EXISTS sales_allocations (
+    id SERIAL PRIMARY KEY,
+    line_id INTEGER NOT NULL REFERENCES sales_order_lines (id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES products (id),
+    location_id INTEGER NOT NULL REFERENCES locations (id),
+    quantity INTEGER NOT NULL CHECK (quantity > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sales_allocations_product ON sales_allocations (product_id, location_id);
+
+CREATE TABLE IF NOT EXISTS sales_shipments (
+    id SERIAL PRIMARY KEY,
+    sales_order_id INTEGER NOT NULL REFERENCES sales_orders (id) ON DELETE CASCADE,
+    tracking TEXT NOT NULL DEFAULT '',
+    created_by INTEGER,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS sales_shipment_lines (
+    shipment_id INTEGER NOT NULL REFERENCES sales_shipments (id) ON DELETE CASCADE,
+    line_id INTEGER NOT NULL REFERENCES sales_order_lines (id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (shipment_id, line_id)
+);
+
+-- Devoluções de clientes (RMA). Itens em inspeção ficam no local de quarentena, fora da alocação de
+-- pedidos; cada recebimento registra a destinação, e os descartados são baixas pelo custo médio.
+INSERT INTO locations (code, name, kind) VALUES ('QUARANTINE', 'Quarantine', 'quarantine') ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS returns (
+    id SERIAL PRIMARY KEY,
+    sales_order_id INTEGER REFERENCES sales_orders (id),
+    customer TEXT NOT NULL DEFAULT '',
+    reason TEXT NOT NULL DEFAULT '',
+    notes TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'open',
+    created_by INTEGER,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    closed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_returns_sales_order ON returns (sales_order_id);
+
+CREATE TABLE IF NOT EXISTS return_lines (
+    id SERIAL PRIMARY KEY,
+    return_id INTEGER NOT NULL REFERENCES returns (id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES products (id),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    received INTEGER NOT NULL DEFAULT 0 CHECK (received >= 0 AND received <= quantity),
+    UNIQUE (return_id, product_id)
+);
+
+CREATE TABLE IF NOT EXISTS return_receipts (
+    id SERIAL PRIMARY KEY,
+    return_id INTEGER NOT NULL REFERENCES returns (id) ON DELETE CASCADE,
+    line_id INTEGER NOT NULL REFERENCES return_lines (id) ON DELETE CASCADE,
+    disposition TEXT NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    location_id INTEGER REFERENCES locations (id),
+    value NUMERIC(14, 4) NOT NULL DEFAULT 0,
+    note TEXT NOT NULL DEFAULT '',
+    created_by INTEGER,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Listas de materiais (BOM): um kit é um produto montado a partir de componentes. Montagens e
+-- desmontagens ficam registradas e suas movimentações referenciam o registro.
+CREATE TABLE IF NOT EXISTS bom_components (
+    kit_id INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    component_id INTEGER NOT NULL REFERENCES products (id),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (kit_id, component_id),
+    CHECK (kit_id <> component_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_bom_components_component ON bom_components (component_id);
+
+CREATE TABLE IF NOT EXISTS kit_builds (
+    id SERIAL PRIMARY KEY,
+    kit_id INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('assemble', 'disassemble')),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    location_id INTEGER NOT NULL REFERENCES locations (id),
+    value NUMERIC(14, 4) NOT NULL DEFAULT 0,
+    reference TEXT NOT NULL DEFAULT '',
+    created_by INTEGER,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Unidades de medida: cada produto tem uma unidade base e pode ter unidades alternativas com fator de
+-- conversão e código de barras próprio por nível de embalagem (display, caixa, ...).
+ALTER TABLE products ADD COLUMN IF NOT EXISTS base_unit TEXT NOT NULL DEFAULT 'unit';
+
+CREATE TABLE IF NOT EXISTS product_units (
+    product_id INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    unit TEXT NOT NULL,
+    factor INTEGER NOT NULL CHECK (factor > 0),
+    barcode TEXT UNIQUE,
+    PRIMARY KEY (product_id, unit)
+);
+
+-- Identificadores de produto: vários códigos de barras (o atual e os anteriores do fabricante) e SKUs
+-- internos. products.barcode continua sendo o código principal, exibido no produto.
+CREATE TABLE IF NOT EXISTS product_identifiers (
+    product_id INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    code TEXT PRIMARY KEY,
+    kind TEXT NOT NULL DEFAULT 'barcode' CHECK (kind IN ('barcode', 'sku')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_identifiers_product ON product_identifiers (product_id);
+
+INSERT INTO product_identifiers (product_id, code, kind)
+SELECT id, barcode, 'barcode' FROM products
+ON CONFLICT DO NOTHING;
+
+-- Códigos internos (etiquetas próprias, códigos de balança) dispensam a validação do dígito verificador do GTIN
+ALTER TABLE products ADD COLUMN IF NOT EXISTS internal_code BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Regras de decodificação dos EAN-13 de medida variável (prefixo 2) impressos por balanças: posição do
+-- código do item e do valor embutido, que é quantidade em unidades base ou preço
+CREATE TABLE IF NOT EXISTS barcode_rules (
+    id SERIAL PRIMARY KEY,
+    prefix TEXT NOT NULL UNIQUE,
+    item_digits INTEGER NOT NULL CHECK (item_digits > 0),
+    value_digits INTEGER NOT NULL CHECK (value_digits > 0),
+    value_type TEXT NOT NULL CHECK (value_type IN ('quantity', 'price')),
+    decimals INTEGER NOT NULL DEFAULT 0 CHECK (decimals BETWEEN 0 AND 4),
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Categorias de produtos em árvore: parent_id vazio é uma categoria raiz
+CREATE TABLE IF NOT EXISTS categories (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    parent_id INTEGER REFERENCES categories (id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_categories_parent ON categories (parent_id);
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS category_id INTEGER REFERENCES categories (id);
+
+CREATE INDEX IF NOT EXISTS idx_products_category ON products (category_id);
+
+-- Itens de devolução em quarentena ficam fora do estoque até a liberação, que os devolve ao estoque
+-- ou os descarta; released marca os recebimentos da liberação. Descartes entram e saem do estoque
+-- por um ajuste de baixa, ligado ao recebimento.
+ALTER TABLE return_receipts ADD COLUMN IF NOT EXISTS released BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE return_receipts ADD COLUMN IF NOT EXISTS adjustment_id INTEGER REFERENCES adjustments (id);
+
+INSERT INTO adjustment_reasons (code, description) VALUES ('return_scrap', 'Scrapped customer returns')
+ON CONFLICT (code) DO NOTHING;
+
+-- Produtos cadastrados antes da validação do GTIN com código sem dígito verificador válido (tamanho
+-- diferente de 8, 12, 13 ou 14 dígitos, ou verificador incorreto) passam a ser códigos internos; novos
+-- códigos inválidos só entram como internos, então repetir a atualização não muda nada
+UPDATE products SET internal_code = TRUE
+WHERE NOT internal_code AND NOT (
+    barcode ~ '^([0-9]{8}|[0-9]{12,14})$'
+    AND (SELECT SUM(substr(reverse(barcode), i, 1)::int * CASE WHEN i % 2 = 0 THEN 3 ELSE 1 END)
+         FROM generate_series(1, length(barcode)) AS i) % 10 = 0
+);
+
+-- Preço por unidade base com seis casas decimais: o preço por grama de um produto pesado não cabe em
+-- centavos, e a quantidade derivada do preço embutido em etiquetas de balança depende dele
+DO $$
+BEGIN
+    IF (SELECT numeric_scale FROM information_schema.columns
+        WHERE table_name = 'products' AND column_name = 'price') < 6 THEN
+        ALTER TABLE products ALTER COLUMN price TYPE NUMERIC(14, 6);
+    END IF;
+END $$;
+
+-- Estoque anterior ao custeio não tem custo: produtos com saldo e sem nenhuma movimentação custeada
+-- recebem o preço como custo médio inicial, para que as saídas não saiam com custo zero
+UPDATE products p SET average_cost = p.price
+WHERE p.average_cost = 0 AND p.price > 0 AND p.quantity > 0
+    AND NOT EXISTS (SELECT 1 FROM stock_movements m WHERE m.product_id = p.id AND m.unit_cost IS NOT NULL);
+
+-- Data de cadastro dos produtos, para que consultas as_of não listem produtos que ainda não existiam.
+-- Os já cadastrados recebem a data da primeira movimentação; sem nenhuma, contam como sempre existentes
+ALTER TABLE products ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ;
+UPDATE products p SET created_at = COALESCE(
+    (SELECT MIN(m.created_at) FROM stock_movements m WHERE m.product_id = p.id), '-infinity')
+WHERE p.created_at IS NULL;
+ALTER TABLE products ALTER COLUMN created_at SET DEFAULT NOW();
+ALTER TABLE products ALTER COLUMN created_at SET NOT NULL;
+
+-- Séries de cada transferência de produto serializado: o recebimento e o cancelamento movem as mesmas
+-- unidades que saíram da origem, e não as que estão há mais tempo no trânsito
+ALTER TABLE transfers ADD COLUMN IF NOT EXISTS serials TEXT[] NOT NULL DEFAULT '{}';
+
+-- Séries dos ajustes de produtos serializados, aplicadas na criação ou na aprovação
+ALTER TABLE adjustments ADD COLUMN IF NOT EXISTS serials TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,204 @@
+package kits
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"inventory-system/internal"
+	"inventory-system/internal/products"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var validate = validator.New()
+
+func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(payload)
+}
+
+func respondError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, map[string]string{"error": message})
+}
+
+func RegisterRoutes(r chi.Router, db *pgxpool.Pool) {
+	service := NewService(NewRepository(db))
+
+	r.Route("/kits", func(r chi.Router) {
+		r.Use(internal.AuthMiddleware)
+		r.Get("/", getKitsHandler(service))
+		r.Get("/{barcode}", getKitHandler(service))
+		r.Put("/{barcode}", setBOMHandler(service))
+		r.Delete("/{barcode}", deleteBOMHandler(service))
+		r.Post("/{barcode}/assemble", buildHandler(service.Assemble))
+		r.Post("/{barcode}/disassemble", buildHandler(service.Disassemble))
+	})
+}
+
+func respondKitError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		respondError(w, http.StatusNotFound, "Kit not found")
+	case errors.Is(err, products.ErrProductNotFound):
+		respondError(w, http.StatusNotFound, "Product not found")
+	case errors.Is(err, products.ErrLocationNotFound):
+		respondError(w, http.StatusNotFound, "Location not found")
+	case errors.Is(err, ErrSelfComponent), errors.Is(err, ErrDuplicateComponent), errors.Is(err, ErrCycle), errors.Is(err, ErrSerialComponent),
+		errors.Is(err, products.ErrInsufficientStock), errors.Is(err, products.ErrReservedStock), errors.Is(err, products.ErrLotNotFound),
+		errors.Is(err, products.ErrSerialsRequired), errors.Is(err, products.ErrSerialCount), errors.Is(err, products.ErrSerialNotInStock),
+		errors.Is(err, products.ErrNotSerialized), errors.Is(err, products.ErrSystemLocation):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, products.ErrSerialConflict):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary List kits
+// @Description Products that have a bill of materials, with their components and buildable quantity.
+// @Tags kits
+// @Produce json
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 20, max: 100)"
+// @Success 200 {array} Kit "List of kits by name"
+// @Header 200 {int} X-Total-Count "Total number of kits"
+// @Router /kits [get]
+func getKitsHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 1 {
+			page = 1
+		}
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit < 1 || limit > 100 {
+			limit = 20
+		}
+		list, total, err := s.GetKits(r.Context(), KitsQuery{Page: page, Limit: limit})
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		respondJSON(w, http.StatusOK, list)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Get a kit
+// @Description buildable is how many kits the available stock of the components (quantity minus reservations and
+// @Description sales order allocations, across all locations) allows to assemble.
+// @Tags kits
+// @Produce json
+// @Param barcode path string true "Kit product barcode"
+// @Success 200 {object} Kit "Kit with components and buildable quantity"
+// @Failure 404 {object} map[string]string "Product not found or without bill of materials"
+// @Router /kits/{barcode} [get]
+func getKitHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		k, err := s.GetKit(r.Context(), chi.URLParam(r, "barcode"))
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if k == nil {
+			respondError(w, http.StatusNotFound, "Kit not found")
+			return
+		}
+		respondJSON(w, http.StatusOK, k)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Set the bill of materials of a product
+// @Description Replaces the components of the product, which becomes a kit. Components may be other kits, as long
+// @Description as the product does not appear below itself.
+// @Tags kits
+// @Accept json
+// @Produce json
+// @Param barcode path string true "Kit product barcode"
+// @Param bom body BOMRequest true "Components and quantity per kit" example({"components":[{"barcode":"7891234567901","quantity":2},{"barcode":"7891234567918","quantity":1}]})
+// @Success 200 {object} Kit "Kit with components and buildable quantity"
+// @Failure 400 {object} map[string]string "Invalid data, duplicate component or cycle"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Router /kits/{barcode} [put]
+func setBOMHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req BOMRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		k, err := s.SetBOM(r.Context(), chi.URLParam(r, "barcode"), req)
+		if err != nil {
+			respondKitError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, k)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Remove the bill of materials of a product
+// @Description The product stays, with its stock, but is no longer a kit.
+// @Tags kits
+// @Param barcode path string true "Kit product barcode"
+// @Success 204 {object} map[string]string "Deleted"
+// @Failure 404 {object} map[string]string "Kit not found"
+// @Router /kits/{barcode} [delete]
+func deleteBOMHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.DeleteBOM(r.Context(), chi.URLParam(r, "barcode")); err != nil {
+			respondKitError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusNoContent, nil)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Assemble or disassemble kits
+// @Description assemble takes the components out of stock at the location like stock exits (reserved or allocated
+// @Description units cannot be used) and enters the kits at the summed cost of the components; disassemble exits
+// @Description the kits and enters the components, splitting the kits' cost by their average cost. Movements
+// @Description use reason assembly or disassembly and reference "kit build #id", all in one transaction.
+// @Tags kits
+// @Accept json
+// @Produce json
+// @Param barcode path string true "Kit product barcode"
+// @Param build body BuildRequest true "Quantity of kits, location (default location when omitted) and serial numbers for serialized kits" example({"quantity":5,"location_id":1,"reference":"WO-88"})
+// @Success 201 {object} Build "Recorded build"
+// @Failure 400 {object} map[string]string "Invalid data or insufficient stock"
+// @Failure 404 {object} map[string]string "Kit or location not found"
+// @Router /kits/{barcode}/assemble [post]
+// @Router /kits/{barcode}/disassemble [post]
+func buildHandler(action func(ctx context.Context, barcode string, req BuildRequest) (*Build, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req BuildRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		b, err := action(r.Context(), chi.URLParam(r, "barcode"), req)
+		if err != nil {
+			respondKitError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusCreated, b)
+	}
+}
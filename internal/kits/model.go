@@ -0,0 +1,72 @@
+package kits
+
+import "time"
+
+// Motivos das movimentações geradas por montagens e desmontagens
+const (
+	ReasonAssembly    = "assembly"
+	ReasonDisassembly = "disassembly"
+)
+
+// Tipos de registro de montagem
+const (
+	KindAssemble    = "assemble"
+	KindDisassemble = "disassemble"
+)
+
+// Kit é um produto com lista de materiais. Buildable é quantos kits dá para montar com o estoque
+// disponível dos componentes (saldo menos reservas e alocações).
+type Kit struct {
+	ProductID  int         `json:"product_id"`
+	Barcode    string      `json:"barcode"`
+	Name       string      `json:"name"`
+	Quantity   int         `json:"quantity"`
+	Buildable  int         `json:"buildable"`
+	Components []Component `json:"components"`
+}
+
+// Component é um item da lista de materiais; Quantity é o consumo por kit.
+type Component struct {
+	ProductID int    `json:"product_id"`
+	Barcode   string `json:"barcode"`
+	Name      string `json:"name"`
+	Quantity  int    `json:"quantity"`
+	Available int    `json:"available"`
+}
+
+// Build registra uma montagem ou desmontagem. Value é o custo que passou dos componentes para os
+// kits (ou o contrário).
+type Build struct {
+	ID         int       `json:"id"`
+	KitID      int       `json:"kit_id"`
+	Kind       string    `json:"kind"`
+	Quantity   int       `json:"quantity"`
+	LocationID int       `json:"location_id"`
+	Value      float64   `json:"value"`
+	Reference  string    `json:"reference"`
+	CreatedBy  *int      `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type BOMRequest struct {
+	Components []ComponentRequest `json:"components" validate:"required,min=1,dive"`
+}
+
+type ComponentRequest struct {
+	Barcode  string `json:"barcode" validate:"required"`
+	Quantity int    `json:"quantity" validate:"required,gt=0"`
+}
+
+type BuildRequest struct {
+	Quantity int `json:"quantity" validate:"required_without=Serials,gte=0"`
+	// Local onde componentes e kits são movimentados; sem ele vale o padrão
+	LocationID int    `json:"location_id"`
+	Reference  string `json:"reference"`
+	// Números de série dos kits montados ou desmontados, para kits serializados
+	Serials []string `json:"serials" validate:"omitempty,unique,dive,required"`
+}
+
+type KitsQuery struct {
+	Page  int
+	Limit int
+}
@@ -0,0 +1,347 @@
+package kits
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+
+	"inventory-system/internal/products"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrNotFound           = errors.New("kit not found")
+	ErrSelfComponent      = errors.New("a kit cannot be its own component")
+	ErrDuplicateComponent = errors.New("component appears more than once in the bill of materials")
+	ErrCycle              = errors.New("bill of materials would contain the kit itself through a component")
+	ErrSerialComponent    = errors.New("serialized products cannot be kit components")
+)
+
+type Repository struct {
+	DB *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{DB: db}
+}
+
+// querier é atendido tanto pelo pool quanto por uma transação.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// loadComponents preenche os componentes e a quantidade montável de cada kit.
+func loadComponents(ctx context.Context, q querier, list []Kit) error {
+	ids := make([]int, len(list))
+	index := make(map[int]int, len(list))
+	for i, k := range list {
+		ids[i] = k.ProductID
+		index[k.ProductID] = i
+	}
+	query := `SELECT b.kit_id, b.component_id, products.barcode, products.name, b.quantity, ` + products.AvailableQuantity + `
+		FROM bom_components b JOIN products ON products.id = b.component_id
+		WHERE b.kit_id = ANY($1) ORDER BY b.kit_id, products.name`
+	rows, err := q.Query(ctx, query, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var kitID int
+		var c Component
+		if err := rows.Scan(&kitID, &c.ProductID, &c.Barcode, &c.Name, &c.Quantity, &c.Available); err != nil {
+			return err
+		}
+		k := &list[index[kitID]]
+		k.Components = append(k.Components, c)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for i := range list {
+		list[i].Buildable = buildable(list[i].Components)
+	}
+	return nil
+}
+
+func (r *Repository) GetKits(ctx context.Context, q KitsQuery) ([]Kit, int, error) {
+	limit := q.Limit
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	page := q.Page
+	if page < 1 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+	query := `SELECT p.id, p.barcode, p.name, p.quantity FROM products p
+		WHERE EXISTS (SELECT 1 FROM bom_components b WHERE b.kit_id = p.id)
+		ORDER BY p.name LIMIT $1 OFFSET $2`
+	rows, err := r.DB.Query(ctx, query, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	list := []Kit{}
+	for rows.Next() {
+		var k Kit
+		if err := rows.Scan(&k.ProductID, &k.Barcode, &k.Name, &k.Quantity); err != nil {
+			return nil, 0, err
+		}
+		list = append(list, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if err := loadComponents(ctx, r.DB, list); err != nil {
+		return nil, 0, err
+	}
+	total := 0
+	if err := r.DB.QueryRow(ctx, `SELECT COUNT(DISTINCT kit_id) FROM bom_components`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+// GetKit devolve nil quando o produto não existe ou não tem lista de materiais.
+func (r *Repository) GetKit(ctx context.Context, barcode string) (*Kit, error) {
+	return getKit(ctx, r.DB, barcode)
+}
+
+func getKit(ctx context.Context, q querier, barcode string) (*Kit, error) {
+	var k Kit
+	err := q.QueryRow(ctx, `SELECT id, barcode, name, quantity FROM products WHERE `+products.ByCode("id", "$1"), barcode).Scan(&k.ProductID, &k.Barcode, &k.Name, &k.Quantity)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	list := []Kit{k}
+	if err := loadComponents(ctx, q, list); err != nil {
+		return nil, err
+	}
+	if len(list[0].Components) == 0 {
+		return nil, nil
+	}
+	return &list[0], nil
+}
+
+// SetBOM substitui a lista de materiais do produto. Componentes podem ser outros kits, desde que o
+// próprio produto não apareça em nenhum nível abaixo dele.
+func (r *Repository) SetBOM(ctx context.Context, barcode string, components []ComponentRequest) (*Kit, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	var kitID int
+	if err := tx.QueryRow(ctx, `SELECT id FROM products WHERE `+products.ByCode("id", "$1")+` FOR UPDATE`, barcode).Scan(&kitID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, products.ErrProductNotFound
+		}
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM bom_components WHERE kit_id = $1`, kitID); err != nil {
+		return nil, err
+	}
+	for _, c := range components {
+		var componentID int
+		var serialized bool
+		query := `SELECT id, serialized FROM products WHERE ` + products.ByCode("id", "$1")
+		if err := tx.QueryRow(ctx, query, c.Barcode).Scan(&componentID, &serialized); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, products.ErrProductNotFound
+			}
+			return nil, err
+		}
+		if componentID == kitID {
+			return nil, ErrSelfComponent
+		}
+		// Montagens e desmontagens não nomeiam as unidades dos componentes
+		if serialized {
+			return nil, ErrSerialComponent
+		}
+		_, err := tx.Exec(ctx, `INSERT INTO bom_components (kit_id, component_id, quantity) VALUES ($1, $2, $3)`, kitID, componentID, c.Quantity)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return nil, ErrDuplicateComponent
+			}
+			return nil, err
+		}
+	}
+	// UNION (e não UNION ALL) faz a recursão parar mesmo que já exista um ciclo
+	query := `WITH RECURSIVE reach (id) AS (
+			SELECT component_id FROM bom_components WHERE kit_id = $1
+			UNION
+			SELECT b.component_id FROM bom_components b JOIN reach ON b.kit_id = reach.id
+		)
+		SELECT EXISTS (SELECT 1 FROM reach WHERE id = $1)`
+	var cycle bool
+	if err := tx.QueryRow(ctx, query, kitID).Scan(&cycle); err != nil {
+		return nil, err
+	}
+	if cycle {
+		return nil, ErrCycle
+	}
+	k, err := getKit(ctx, tx, barcode)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (r *Repository) DeleteBOM(ctx context.Context, barcode string) error {
+	cmd, err := r.DB.Exec(ctx, `DELETE FROM bom_components WHERE `+products.ByCode("kit_id", "$1"), barcode)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type bomLine struct {
+	productID   int
+	quantity    int
+	averageCost float64
+}
+
+// Build monta (b.Kind assemble) ou desmonta b.Quantity kits no local b.LocationID, em uma única
+// transação. Na montagem os componentes saem com a mesma semântica de uma saída de estoque (sem
+// consumir unidades reservadas ou alocadas) e os kits entram custando a soma do que saiu; na
+// desmontagem é o inverso, e o custo dos kits é repartido entre os componentes pelo custo médio de
+// cada um. Componentes serializados são recusados, já que as suas unidades não são nomeadas; o kit
+// pode ser serializado, com serials. Kit e componentes ficam bloqueados em ordem de ID para não
+// haver deadlock.
+func (r *Repository) Build(ctx context.Context, barcode string, b *Build, serials []string) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if err := tx.QueryRow(ctx, `SELECT id FROM products WHERE `+products.ByCode("id", "$1"), barcode).Scan(&b.KitID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	rows, err := tx.Query(ctx, `SELECT component_id, quantity FROM bom_components WHERE kit_id = $1`, b.KitID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var lines []bomLine
+	for rows.Next() {
+		var l bomLine
+		if err := rows.Scan(&l.productID, &l.quantity); err != nil {
+			return err
+		}
+		lines = append(lines, l)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return ErrNotFound
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].productID < lines[j].productID })
+	ids := []int{b.KitID}
+	for _, l := range lines {
+		ids = append(ids, l.productID)
+	}
+	if _, err := tx.Exec(ctx, `SELECT id FROM products WHERE id = ANY($1) ORDER BY id FOR UPDATE`, ids); err != nil {
+		return err
+	}
+	for i := range lines {
+		var serialized bool
+		query := `SELECT average_cost, serialized FROM products WHERE id = $1`
+		if err := tx.QueryRow(ctx, query, lines[i].productID).Scan(&lines[i].averageCost, &serialized); err != nil {
+			return err
+		}
+		// Listas gravadas antes da restrição, ou componentes que passaram a ser serializados
+		if serialized {
+			return ErrSerialComponent
+		}
+	}
+	err = tx.QueryRow(ctx, `SELECT id FROM locations WHERE id = $1 OR ($1 = 0 AND is_default)`, b.LocationID).Scan(&b.LocationID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return products.ErrLocationNotFound
+		}
+		return err
+	}
+	query := `INSERT INTO kit_builds (kit_id, kind, quantity, location_id, reference, created_by) VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+	if err := tx.QueryRow(ctx, query, b.KitID, b.Kind, b.Quantity, b.LocationID, b.Reference, b.CreatedBy).Scan(&b.ID, &b.CreatedAt); err != nil {
+		return err
+	}
+	reason, reference := ReasonAssembly, "kit build #"+strconv.Itoa(b.ID)
+	kit := &products.StockMovement{ProductID: b.KitID, LocationID: b.LocationID, Delta: b.Quantity, Reference: reference, Serials: serials}
+	if b.Kind == KindAssemble {
+		for _, l := range lines {
+			m := &products.StockMovement{ProductID: l.productID, LocationID: b.LocationID, Delta: -l.quantity * b.Quantity, Reason: reason, Reference: reference}
+			if err := exit(ctx, tx, m); err != nil {
+				return err
+			}
+			b.Value -= m.Value
+		}
+		unitCost := b.Value / float64(b.Quantity)
+		kit.Reason, kit.UnitCost = reason, &unitCost
+		if err := products.ApplyMovement(ctx, tx, kit); err != nil {
+			return err
+		}
+	} else {
+		reason = ReasonDisassembly
+		kit.Reason, kit.Delta = reason, -b.Quantity
+		if err := exit(ctx, tx, kit); err != nil {
+			return err
+		}
+		b.Value = -kit.Value
+		weights := make([]float64, len(lines))
+		quantities := make([]float64, len(lines))
+		for i, l := range lines {
+			weights[i] = l.averageCost * float64(l.quantity)
+			quantities[i] = float64(l.quantity)
+		}
+		shares := splitCost(b.Value, weights, quantities)
+		for i, l := range lines {
+			unitCost := shares[i] / float64(l.quantity*b.Quantity)
+			m := &products.StockMovement{ProductID: l.productID, LocationID: b.LocationID, Delta: l.quantity * b.Quantity, Reason: reason,
+				Reference: reference, UnitCost: &unitCost}
+			if err := products.ApplyMovement(ctx, tx, m); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := tx.Exec(ctx, `UPDATE kit_builds SET value = $1 WHERE id = $2`, b.Value, b.ID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// exit aplica uma saída com a semântica de StockExit: recusa consumir unidades reservadas ou alocadas.
+func exit(ctx context.Context, tx pgx.Tx, m *products.StockMovement) error {
+	if err := products.ApplyMovement(ctx, tx, m); err != nil {
+		return err
+	}
+	return products.CheckHeldStock(ctx, tx, m)
+}
+
+type RepositoryInterface interface {
+	GetKits(ctx context.Context, q KitsQuery) ([]Kit, int, error)
+	GetKit(ctx context.Context, barcode string) (*Kit, error)
+	SetBOM(ctx context.Context, barcode string, components []ComponentRequest) (*Kit, error)
+	DeleteBOM(ctx context.Context, barcode string) error
+	Build(ctx context.Context, barcode string, b *Build, serials []string) error
+}
@@ -0,0 +1,103 @@
+package kits
+
+import (
+	"context"
+
+	"inventory-system/internal"
+	"inventory-system/internal/products"
+)
+
+type Service struct {
+	Repo RepositoryInterface
+}
+
+func NewService(repo RepositoryInterface) *Service {
+	return &Service{Repo: repo}
+}
+
+func (s *Service) GetKits(ctx context.Context, q KitsQuery) ([]Kit, int, error) {
+	return s.Repo.GetKits(ctx, q)
+}
+
+func (s *Service) GetKit(ctx context.Context, barcode string) (*Kit, error) {
+	return s.Repo.GetKit(ctx, barcode)
+}
+
+func (s *Service) SetBOM(ctx context.Context, barcode string, req BOMRequest) (*Kit, error) {
+	return s.Repo.SetBOM(ctx, barcode, req.Components)
+}
+
+func (s *Service) DeleteBOM(ctx context.Context, barcode string) error {
+	return s.Repo.DeleteBOM(ctx, barcode)
+}
+
+func (s *Service) Assemble(ctx context.Context, barcode string, req BuildRequest) (*Build, error) {
+	return s.build(ctx, barcode, KindAssemble, req)
+}
+
+func (s *Service) Disassemble(ctx context.Context, barcode string, req BuildRequest) (*Build, error) {
+	return s.build(ctx, barcode, KindDisassemble, req)
+}
+
+// build deriva a quantidade dos números de série dos kits, quando informados.
+func (s *Service) build(ctx context.Context, barcode, kind string, req BuildRequest) (*Build, error) {
+	if len(req.Serials) > 0 {
+		if req.Quantity == 0 {
+			req.Quantity = len(req.Serials)
+		}
+		if req.Quantity != len(req.Serials) {
+			return nil, products.ErrSerialCount
+		}
+	}
+	b := &Build{Kind: kind, Quantity: req.Quantity, LocationID: req.LocationID, Reference: req.Reference, CreatedBy: currentUser(ctx)}
+	if err := s.Repo.Build(ctx, barcode, b, req.Serials); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// buildable é quantos kits os componentes disponíveis permitem montar: o menor quociente entre o
+// disponível e o consumo por kit.
+func buildable(components []Component) int {
+	if len(components) == 0 {
+		return 0
+	}
+	n := -1
+	for _, c := range components {
+		k := max(c.Available, 0) / c.Quantity
+		if n < 0 || k < n {
+			n = k
+		}
+	}
+	return n
+}
+
+// splitCost reparte total proporcionalmente a weights; se todos os pesos forem zero (componentes
+// ainda sem custo), reparte por fallback.
+func splitCost(total float64, weights, fallback []float64) []float64 {
+	sum := 0.0
+	for _, w := range weights {
+		sum += w
+	}
+	if sum == 0 {
+		weights = fallback
+		for _, w := range weights {
+			sum += w
+		}
+	}
+	shares := make([]float64, len(weights))
+	if sum == 0 {
+		return shares
+	}
+	for i, w := range weights {
+		shares[i] = total * w / sum
+	}
+	return shares
+}
+
+func currentUser(ctx context.Context) *int {
+	if userID, ok := internal.UserIDFromContext(ctx); ok {
+		return &userID
+	}
+	return nil
+}
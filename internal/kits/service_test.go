@@ -0,0 +1,147 @@
+package kits
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"inventory-system/internal/products"
+)
+
+type mockKitRepo struct {
+	kits       map[string]*Kit
+	builds     []Build
+	serialized map[string]bool
+}
+
+func (m *mockKitRepo) GetKits(ctx context.Context, q KitsQuery) ([]Kit, int, error) {
+	list := []Kit{}
+	for _, k := range m.kits {
+		list = append(list, *k)
+	}
+	return list, len(list), nil
+}
+func (m *mockKitRepo) GetKit(ctx context.Context, barcode string) (*Kit, error) {
+	return m.kits[barcode], nil
+}
+func (m *mockKitRepo) SetBOM(ctx context.Context, barcode string, components []ComponentRequest) (*Kit, error) {
+	k := &Kit{Barcode: barcode}
+	for _, c := range components {
+		if c.Barcode == barcode {
+			return nil, ErrSelfComponent
+		}
+		if m.serialized[c.Barcode] {
+			return nil, ErrSerialComponent
+		}
+		k.Components = append(k.Components, Component{Barcode: c.Barcode, Quantity: c.Quantity, Available: 10})
+	}
+	k.Buildable = buildable(k.Components)
+	m.kits[barcode] = k
+	return k, nil
+}
+func (m *mockKitRepo) DeleteBOM(ctx context.Context, barcode string) error {
+	if m.kits[barcode] == nil {
+		return ErrNotFound
+	}
+	delete(m.kits, barcode)
+	return nil
+}
+
+// Build consome o disponível dos componentes, sem tocar no banco
+func (m *mockKitRepo) Build(ctx context.Context, barcode string, b *Build, serials []string) error {
+	k := m.kits[barcode]
+	if k == nil {
+		return ErrNotFound
+	}
+	if b.Kind == KindAssemble && b.Quantity > k.Buildable {
+		return products.ErrInsufficientStock
+	}
+	sign := -1
+	if b.Kind == KindDisassemble {
+		sign = 1
+	}
+	for i := range k.Components {
+		k.Components[i].Available += sign * k.Components[i].Quantity * b.Quantity
+	}
+	k.Quantity -= sign * b.Quantity
+	k.Buildable = buildable(k.Components)
+	b.ID = len(m.builds) + 1
+	m.builds = append(m.builds, *b)
+	return nil
+}
+
+func TestBuildable(t *testing.T) {
+	cases := []struct {
+		components []Component
+		want       int
+	}{
+		{nil, 0},
+		{[]Component{{Quantity: 2, Available: 7}}, 3},
+		{[]Component{{Quantity: 2, Available: 7}, {Quantity: 1, Available: 1}}, 1},
+		{[]Component{{Quantity: 1, Available: -4}, {Quantity: 1, Available: 5}}, 0},
+	}
+	for i, c := range cases {
+		if got := buildable(c.components); got != c.want {
+			t.Errorf("caso %d: esperado %d, veio %d", i, c.want, got)
+		}
+	}
+}
+
+func TestSplitCost(t *testing.T) {
+	shares := splitCost(30, []float64{10, 20}, []float64{1, 1})
+	if math.Abs(shares[0]-10) > 1e-9 || math.Abs(shares[1]-20) > 1e-9 {
+		t.Errorf("esperado [10 20], veio %v", shares)
+	}
+	// Sem custo médio nos componentes, reparte pelas quantidades
+	shares = splitCost(30, []float64{0, 0}, []float64{2, 1})
+	if math.Abs(shares[0]-20) > 1e-9 || math.Abs(shares[1]-10) > 1e-9 {
+		t.Errorf("esperado [20 10], veio %v", shares)
+	}
+}
+
+func TestService_Kit_Mock(t *testing.T) {
+	repo := &mockKitRepo{kits: map[string]*Kit{}, serialized: map[string]bool{"s": true}}
+	svc := NewService(repo)
+	ctx := context.Background()
+	if _, err := svc.SetBOM(ctx, "kit", BOMRequest{Components: []ComponentRequest{{Barcode: "kit", Quantity: 1}}}); err != ErrSelfComponent {
+		t.Errorf("esperado ErrSelfComponent, veio %v", err)
+	}
+	// Componentes serializados não entram na lista, já que montagens não nomeiam as suas unidades
+	if _, err := svc.SetBOM(ctx, "kit", BOMRequest{Components: []ComponentRequest{{Barcode: "a", Quantity: 1}, {Barcode: "s", Quantity: 1}}}); err != ErrSerialComponent {
+		t.Errorf("esperado ErrSerialComponent, veio %v", err)
+	}
+	k, err := svc.SetBOM(ctx, "kit", BOMRequest{Components: []ComponentRequest{{Barcode: "a", Quantity: 2}, {Barcode: "b", Quantity: 3}}})
+	if err != nil || k.Buildable != 3 {
+		t.Fatalf("esperado 3 kits montáveis, veio %v %+v", err, k)
+	}
+
+	if _, err := svc.Assemble(ctx, "kit", BuildRequest{Quantity: 4}); err != products.ErrInsufficientStock {
+		t.Errorf("esperado ErrInsufficientStock, veio %v", err)
+	}
+	if _, err := svc.Assemble(ctx, "kit", BuildRequest{Quantity: 2, Serials: []string{"K1"}}); err != products.ErrSerialCount {
+		t.Errorf("esperado ErrSerialCount, veio %v", err)
+	}
+	b, err := svc.Assemble(ctx, "kit", BuildRequest{Serials: []string{"K1", "K2"}})
+	if err != nil || b.Quantity != 2 || b.Kind != KindAssemble {
+		t.Fatalf("erro ao montar: %v %+v", err, b)
+	}
+	k, _ = svc.GetKit(ctx, "kit")
+	if k.Quantity != 2 || k.Buildable != 1 || k.Components[0].Available != 6 {
+		t.Errorf("montagem não refletida: %+v", k)
+	}
+
+	b, err = svc.Disassemble(ctx, "kit", BuildRequest{Quantity: 1})
+	if err != nil || b.Kind != KindDisassemble {
+		t.Fatalf("erro ao desmontar: %v %+v", err, b)
+	}
+	if k, _ = svc.GetKit(ctx, "kit"); k.Quantity != 1 || k.Components[1].Available != 7 {
+		t.Errorf("desmontagem não refletida: %+v", k)
+	}
+
+	if err := svc.DeleteBOM(ctx, "kit"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Assemble(ctx, "kit", BuildRequest{Quantity: 1}); err != ErrNotFound {
+		t.Errorf("esperado ErrNotFound, veio %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+// Package label desenha etiquetas de gôndola com o nome, o código de barras e o preço do produto,
+// em PNG, SVG, ZPL (impressoras Zebra) ou PDF com várias etiquetas.
+package label
+
+import (
+	"errors"
+	"io"
+
+	"inventory-system/internal/barcode"
+)
+
+// Formatos de saída
+const (
+	PNG = "png"
+	SVG = "svg"
+	ZPL = "zpl"
+	PDF = "pdf"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown label format, expected png, svg, zpl or pdf")
+	ErrSingleLabel   = errors.New("png and svg hold a single label, use pdf or zpl for batches")
+)
+
+// Label é o conteúdo de uma etiqueta. Symbology vazia escolhe a simbologia pelo código.
+type Label struct {
+	Name      string
+	Price     string
+	Code      string
+	Symbology string
+}
+
+// ContentType devolve o tipo MIME do formato.
+func ContentType(format string) string {
+	switch format {
+	case PNG:
+		return "image/png"
+	case SVG:
+		return "image/svg+xml"
+	case ZPL:
+		return "application/zpl"
+	case PDF:
+		return "application/pdf"
+	}
+	return "application/octet-stream"
+}
+
+// Medidas da etiqueta, em unidades: um módulo dos códigos lineares. Cada unidade vira scale pixels
+// no PNG e no SVG, scale pontos da impressora no ZPL e um ponto tipográfico no PDF.
+const (
+	margin     = 4
+	minWidth   = 120
+	barHeight  = 50
+	qrModule   = 3
+	quietBars  = 10
+	quietQR    = 4
+	gap        = 3
+	priceScale = 2
+)
+
+type rect struct{ X, Y, W, H int }
+
+// text é uma linha de texto com o canto superior esquerdo da célula em X, Y e Size unidades por ponto
+// da fonte.
+type text struct {
+	X, Y, Size int
+	S          string
+}
+
+// page é a etiqueta diagramada: retângulos escuros do símbolo e linhas de texto, em unidades.
+type page struct {
+	W, H   int
+	Symbol barcode.Symbol
+	// Posição e tamanho do módulo do símbolo, para as impressoras que o desenham sozinhas
+	SymbolX, SymbolY, Module int
+	Rects                    []rect
+	Texts                    []text
+	Label                    Label
+}
+
+// layout diagrama a etiqueta: nome no topo, símbolo centralizado, código legível e preço embaixo.
+func layout(l Label) (*page, error) {
+	sym, err := barcode.Encode(l.Code, l.Symbology)
+	if err != nil {
+		return nil, err
+	}
+	module, quiet, height := 1, quietBars, barHeight
+	if !sym.Linear() {
+		module, quiet, height = qrModule, quietQR, sym.Width()*qrModule
+	}
+	p := &page{W: max(minWidth, (sym.Width()+2*quiet)*module+2*margin), Symbol: sym, Module: module, Label: l}
+	p.SymbolX = (p.W - sym.Width()*module) / 2
+	y := margin
+	p.addText(fold(l.Name), y, 1)
+	y += cellHeight + gap
+	p.SymbolY = y
+	for r, row := range sym.Rows {
+		rowHeight := module
+		if sym.Linear() {
+			rowHeight = height
+		}
+		for x := 0; x < len(row); {
+			if !row[x] {
+				x++
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			p.Rects = append(p.Rects, rect{p.SymbolX + start*module, y + r*module, (x - start) * module, rowHeight})
+		}
+	}
+	y += height + gap
+	p.addText(fold(l.Code), y, 1)
+	y += cellHeight + gap
+	if l.Price != "" {
+		p.addText(fold(l.Price), y, priceScale)
+		y += cellHeight * priceScale
+	}
+	p.H = y + margin
+	return p, nil
+}
+
+// addText centraliza uma linha, cortada para caber entre as margens.
+func (p *page) addText(s string, y, size int) {
+	s = fit(s, (p.W-2*margin)/size)
+	p.Texts = append(p.Texts, text{X: (p.W - len(s)*cellWidth*size) / 2, Y: y, Size: size, S: s})
+}
+
+// Encoder escreve etiquetas em sequência no formato escolhido. PNG e SVG aceitam uma só etiqueta;
+// ZPL e PDF aceitam lotes e só são concluídos em Close.
+type Encoder struct {
+	w      io.Writer
+	format string
+	scale  int
+	count  int
+	pdf    *pdfWriter
+}
+
+// NewEncoder prepara a escrita; scale é o número de pixels ou pontos da impressora por unidade.
+func NewEncoder(w io.Writer, format string, scale int) (*Encoder, error) {
+	switch format {
+	case PNG, SVG, ZPL, PDF:
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if scale < 1 {
+		scale = 1
+	}
+	return &Encoder{w: w, format: format, scale: scale}, nil
+}
+
+// Encode diagrama e escreve uma etiqueta. Erros de código (simbologia incompatível, caracteres que
+// ela não codifica) acontecem antes de qualquer escrita.
+func (e *Encoder) Encode(l Label) error {
+	if e.count > 0 && (e.format == PNG || e.format == SVG) {
+		return ErrSingleLabel
+	}
+	p, err := layout(l)
+	if err != nil {
+		return err
+	}
+	e.count++
+	switch e.format {
+	case PNG:
+		return writePNG(e.w, p, e.scale)
+	case SVG:
+		return writeSVG(e.w, p, e.scale)
+	case ZPL:
+		return writeZPL(e.w, p, e.scale)
+	}
+	if e.pdf == nil {
+		e.pdf = newPDFWriter(e.w)
+	}
+	return e.pdf.page(p)
+}
+
+// Close conclui o PDF. Um PDF sem etiquetas sai com uma página em branco, para continuar válido.
+func (e *Encoder) Close() error {
+	if e.format != PDF {
+		return nil
+	}
+	if e.pdf == nil {
+		e.pdf = newPDFWriter(e.w)
+		if err := e.pdf.page(&page{W: minWidth, H: minWidth / 2}); err != nil {
+			return err
+		}
+	}
+	return e.pdf.close()
+}
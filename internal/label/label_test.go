@@ -0,0 +1,99 @@
+package label
+
+import (
+	"bytes"
+	"image/png"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLayout(t *testing.T) {
+	p, err := layout(Label{Name: "Café torrado e moído tradicional 500g", Price: "R$ 12,90", Code: "7891234567895"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 95 módulos, zonas de silêncio de 10 e margens de 4
+	if p.W != 123 || p.Symbol.Symbology != "ean13" {
+		t.Errorf("diagramação incorreta: %d %s", p.W, p.Symbol.Symbology)
+	}
+	name := p.Texts[0].S
+	if !strings.HasPrefix(name, "Cafe torrado") || !strings.HasSuffix(name, "...") || len(name)*cellWidth > p.W-2*margin {
+		t.Errorf("nome incorreto: %q", name)
+	}
+	if len(p.Texts) != 3 || p.Texts[2].Size != priceScale {
+		t.Errorf("textos incorretos: %+v", p.Texts)
+	}
+	if _, err := layout(Label{Code: "CAF-01", Symbology: "ean13"}); err == nil {
+		t.Error("esperado erro: código não é EAN-13")
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	l := Label{Name: "Queijo", Price: "R$ 5,00", Code: "CAF-01"}
+
+	var buf bytes.Buffer
+	e, _ := NewEncoder(&buf, PNG, 2)
+	if err := e.Encode(l); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Encode(l); err != ErrSingleLabel {
+		t.Errorf("esperado ErrSingleLabel, veio %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil || img.Bounds().Dx()%2 != 0 {
+		t.Fatalf("PNG inválido: %v", err)
+	}
+
+	buf.Reset()
+	e, _ = NewEncoder(&buf, SVG, 1)
+	_ = e.Encode(l)
+	if s := buf.String(); !strings.HasPrefix(s, "<svg") || !strings.Contains(s, ">Queijo</text>") {
+		t.Errorf("SVG incorreto: %s", s)
+	}
+
+	buf.Reset()
+	e, _ = NewEncoder(&buf, ZPL, 2)
+	_ = e.Encode(l)
+	_ = e.Encode(Label{Name: "Leite", Code: "7891234567895", Symbology: "qr"})
+	if s := buf.String(); strings.Count(s, "^XA") != 2 || !strings.Contains(s, "^BCN") || !strings.Contains(s, "^FDMA,7891234567895^FS") {
+		t.Errorf("ZPL incorreto: %s", s)
+	}
+
+	if _, err := NewEncoder(&buf, "gif", 1); err != ErrUnknownFormat {
+		t.Errorf("esperado ErrUnknownFormat, veio %v", err)
+	}
+}
+
+func TestPDF(t *testing.T) {
+	var buf bytes.Buffer
+	e, _ := NewEncoder(&buf, PDF, 1)
+	for _, code := range []string{"7891234567895", "CAF-01", "Pão (francês)"} {
+		if err := e.Encode(Label{Name: code, Code: code}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "%PDF-1.4") || !strings.HasSuffix(out, "%%EOF\n") || !strings.Contains(out, "/Count 3") {
+		t.Fatalf("PDF incorreto: %s", out)
+	}
+	if !strings.Contains(out, `(Pao \(frances\))`) {
+		t.Error("texto do PDF sem escape dos parênteses")
+	}
+	// Cada entrada da tabela de referências aponta para o início do seu objeto
+	xref := out[strings.Index(out, "\nxref\n"):]
+	entries := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllStringSubmatch(xref, -1)
+	if len(entries) != 9 {
+		t.Fatalf("esperados 9 objetos, veio %d", len(entries))
+	}
+	for i, m := range entries {
+		offset, _ := strconv.Atoi(m[1])
+		if !strings.HasPrefix(out[offset:], strconv.Itoa(i+1)+" 0 obj") {
+			t.Errorf("objeto %d fora da posição %d", i+1, offset)
+		}
+	}
+}
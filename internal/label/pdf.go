@@ -0,0 +1,88 @@
+package label
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// pdfWriter escreve um PDF página a página, sem guardar as etiquetas: cada página tem o tamanho da
+// sua etiqueta, em pontos, e usa a fonte Courier padrão dos leitores, que dispensa incorporação. O
+// catálogo, a árvore de páginas e a tabela de referências saem no fim.
+type pdfWriter struct {
+	w       *bufio.Writer
+	offset  int
+	objects map[int]int // número do objeto -> posição no arquivo
+	pages   []int
+	next    int
+	err     error
+}
+
+// Objetos de número fixo; as páginas e seus conteúdos vêm a partir do 4
+const (
+	pdfCatalog = 1
+	pdfPages   = 2
+	pdfFont    = 3
+)
+
+var pdfEscape = strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`)
+
+func newPDFWriter(w io.Writer) *pdfWriter {
+	p := &pdfWriter{w: bufio.NewWriter(w), objects: map[int]int{}, next: 4}
+	p.printf("%%PDF-1.4\n")
+	p.object(pdfFont, "<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>")
+	return p
+}
+
+func (p *pdfWriter) printf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(p.w, format, args...)
+	p.offset += n
+	p.err = err
+}
+
+func (p *pdfWriter) object(id int, body string) {
+	p.objects[id] = p.offset
+	p.printf("%d 0 obj\n%s\nendobj\n", id, body)
+}
+
+func (p *pdfWriter) page(pg *page) error {
+	var c strings.Builder
+	c.WriteString("0 g\n")
+	for _, r := range pg.Rects {
+		fmt.Fprintf(&c, "%d %d %d %d re\n", r.X, pg.H-r.Y-r.H, r.W, r.H)
+	}
+	c.WriteString("f\n")
+	for _, t := range pg.Texts {
+		fmt.Fprintf(&c, "BT /F1 %d Tf %d %d Td (%s) Tj ET\n", 10*t.Size, t.X, pg.H-t.Y-glyphRows*t.Size, pdfEscape.Replace(t.S))
+	}
+	pageID, contentID := p.next, p.next+1
+	p.next += 2
+	p.pages = append(p.pages, pageID)
+	p.object(pageID, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>",
+		pdfPages, pg.W, pg.H, pdfFont, contentID))
+	p.object(contentID, fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", c.Len(), c.String()))
+	return p.err
+}
+
+func (p *pdfWriter) close() error {
+	kids := make([]string, len(p.pages))
+	for i, id := range p.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", id)
+	}
+	p.object(pdfPages, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+	p.object(pdfCatalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pdfPages))
+	xref := p.offset
+	p.printf("xref\n0 %d\n0000000000 65535 f \n", p.next)
+	for id := 1; id < p.next; id++ {
+		p.printf("%010d 00000 n \n", p.objects[id])
+	}
+	p.printf("trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", p.next, pdfCatalog, xref)
+	if p.err != nil {
+		return p.err
+	}
+	return p.w.Flush()
+}
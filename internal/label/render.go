@@ -0,0 +1,90 @@
+package label
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+
+	"inventory-system/internal/barcode"
+)
+
+func writePNG(w io.Writer, p *page, scale int) error {
+	img := image.NewPaletted(image.Rect(0, 0, p.W*scale, p.H*scale), color.Palette{color.White, color.Black})
+	fill := func(x, y, w, h int) {
+		for yy := y * scale; yy < (y+h)*scale; yy++ {
+			for xx := x * scale; xx < (x+w)*scale; xx++ {
+				img.SetColorIndex(xx, yy, 1)
+			}
+		}
+	}
+	for _, r := range p.Rects {
+		fill(r.X, r.Y, r.W, r.H)
+	}
+	for _, t := range p.Texts {
+		for i := 0; i < len(t.S); i++ {
+			glyph := font5x7[t.S[i]-32]
+			for col, bits := range glyph {
+				for row := 0; row < glyphRows; row++ {
+					if bits>>row&1 == 1 {
+						fill(t.X+(i*cellWidth+col)*t.Size, t.Y+row*t.Size, t.Size, t.Size)
+					}
+				}
+			}
+		}
+	}
+	return png.Encode(w, img)
+}
+
+// writeSVG usa fonte monoespaçada com a largura de cada linha fixada na da diagramação.
+func writeSVG(w io.Writer, p *page, scale int) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, p.W*scale, p.H*scale, p.W, p.H)
+	fmt.Fprintf(b, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, p.W, p.H)
+	for _, r := range p.Rects {
+		fmt.Fprintf(b, "M%d %dh%dv%dh-%dz", r.X, r.Y, r.W, r.H, r.W)
+	}
+	b.WriteString(`"/>`)
+	for _, t := range p.Texts {
+		fmt.Fprintf(b, `<text x="%d" y="%d" font-family="monospace" font-size="%d" textLength="%d" lengthAdjust="spacingAndGlyphs">`,
+			t.X, t.Y+glyphRows*t.Size, 10*t.Size, len(t.S)*cellWidth*t.Size)
+		if err := xml.EscapeText(b, []byte(t.S)); err != nil {
+			return err
+		}
+		b.WriteString("</text>")
+	}
+	b.WriteString("</svg>\n")
+	return b.Flush()
+}
+
+var zplEscape = strings.NewReplacer("_", "_5F", "^", "_5E", "~", "_7E")
+
+// writeZPL deixa o desenho do código com a impressora (^BE, ^BC e ^BQ), na posição da diagramação;
+// scale é o número de pontos da impressora por unidade.
+func writeZPL(w io.Writer, p *page, scale int) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "^XA\n^PW%d\n^LL%d\n", p.W*scale, p.H*scale)
+	for _, t := range p.Texts {
+		h := cellHeight * t.Size * scale
+		fmt.Fprintf(b, "^FO0,%d^FB%d,1,0,C^A0N,%d,%d^FH^FD%s^FS\n", t.Y*scale, p.W*scale, h, h, zplEscape.Replace(t.S))
+	}
+	x, y := p.SymbolX*scale, p.SymbolY*scale
+	code := zplEscape.Replace(p.Label.Code)
+	switch p.Symbol.Symbology {
+	case barcode.EAN13:
+		if len(code) == 12 {
+			code = "0" + code
+		}
+		fmt.Fprintf(b, "^FO%d,%d^BY%d^BEN,%d,N,N^FD%s^FS\n", x, y, scale, barHeight*scale, code[:12])
+	case barcode.Code128:
+		fmt.Fprintf(b, "^FO%d,%d^BY%d^BCN,%d,N,N,N,A^FH^FD%s^FS\n", x, y, scale, barHeight*scale, code)
+	case barcode.QR:
+		fmt.Fprintf(b, "^FO%d,%d^BQN,2,%d^FH^FDMA,%s^FS\n", x, y, min(qrModule*scale, 10), code)
+	}
+	b.WriteString("^XZ\n")
+	return b.Flush()
+}
@@ -0,0 +1,205 @@
+package locations
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"inventory-system/internal"
+	"inventory-system/internal/users"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var validate = validator.New()
+
+func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(payload)
+}
+
+func respondError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, map[string]string{"error": message})
+}
+
+func RegisterRoutes(r chi.Router, db *pgxpool.Pool) {
+	service := NewService(NewRepository(db))
+
+	r.Route("/locations", func(r chi.Router) {
+		r.Use(internal.AuthMiddleware)
+		r.Post("/", createLocationHandler(service))
+		r.Get("/", getLocationsHandler(service))
+		r.Get("/{id}", getLocationHandler(service))
+		r.Put("/{id}", updateLocationHandler(service))
+		r.With(users.RequireRole("admin", []byte("changeme"))).Post("/{id}/default", setDefaultLocationHandler(service))
+		r.With(users.RequireRole("admin", []byte("changeme"))).Delete("/{id}", deleteLocationHandler(service))
+	})
+}
+
+// @Security ApiKeyAuth
+// @Summary Create a location
+// @Tags locations
+// @Accept json
+// @Produce json
+// @Param location body Location true "Location data" example({"code":"WH2","name":"Warehouse 2"})
+// @Success 201 {object} Location "Created location"
+// @Failure 400 {object} map[string]string "Invalid data"
+// @Router /locations [post]
+func createLocationHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var l Location
+		if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&l); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		if err := s.CreateLocation(r.Context(), &l); err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respondJSON(w, http.StatusCreated, l)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary List locations
+// @Tags locations
+// @Produce json
+// @Success 200 {array} Location "List of locations"
+// @Router /locations [get]
+func getLocationsHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		locations, err := s.GetLocations(r.Context())
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, locations)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Get a location
+// @Tags locations
+// @Produce json
+// @Param id path int true "Location ID"
+// @Success 200 {object} Location "Location data"
+// @Failure 404 {object} map[string]string "Location not found"
+// @Router /locations/{id} [get]
+func getLocationHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		l, err := s.GetLocation(r.Context(), id)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if l == nil {
+			respondError(w, http.StatusNotFound, "Location not found")
+			return
+		}
+		respondJSON(w, http.StatusOK, l)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Update a location
+// @Tags locations
+// @Accept json
+// @Param id path int true "Location ID"
+// @Param location body Location true "Location data" example({"code":"WH2","name":"Warehouse 2"})
+// @Success 200 {object} map[string]string "Updated"
+// @Failure 400 {object} map[string]string "Invalid data"
+// @Failure 404 {object} map[string]string "Location not found"
+// @Router /locations/{id} [put]
+func updateLocationHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		var l Location
+		if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&l); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		if err := s.UpdateLocation(r.Context(), id, &l); err != nil {
+			respondLocationError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, nil)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Make a location the default one
+// @Description The default location receives stock entries and exits that do not name a location.
+// @Tags locations
+// @Param id path int true "Location ID"
+// @Success 200 {object} map[string]string "Updated"
+// @Failure 404 {object} map[string]string "Location not found"
+// @Router /locations/{id}/default [post]
+func setDefaultLocationHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		if err := s.SetDefault(r.Context(), id); err != nil {
+			respondLocationError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, nil)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Delete a location
+// @Tags locations
+// @Param id path int true "Location ID"
+// @Success 204 {object} map[string]string "Deleted"
+// @Failure 404 {object} map[string]string "Location not found"
+// @Failure 409 {object} map[string]string "Default location or location in use"
+// @Router /locations/{id} [delete]
+func deleteLocationHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		if err := s.DeleteLocation(r.Context(), id); err != nil {
+			respondLocationError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusNoContent, nil)
+	}
+}
+
+func respondLocationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		respondError(w, http.StatusNotFound, "Location not found")
+	case errors.Is(err, ErrDefaultLocation), errors.Is(err, ErrInUse), errors.Is(err, ErrSystemLocation):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
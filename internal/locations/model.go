@@ -0,0 +1,18 @@
+package locations
+
+type Location struct {
+	ID        int    `json:"id"`
+	Code      string `json:"code" validate:"required"`
+	Name      string `json:"name" validate:"required"`
+	IsDefault bool   `json:"is_default"`
+	Kind      string `json:"kind"`
+}
+
+// Tipos de local. Locais de trânsito são criados pelo sistema e recebem as mercadorias
+// despachadas em transferências até que sejam recebidas no destino. A quarentena guarda
+// devoluções em inspeção, que não são alocadas a pedidos de venda.
+const (
+	KindStorage    = "storage"
+	KindTransit    = "transit"
+	KindQuarantine = "quarantine"
+)
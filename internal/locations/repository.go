@@ -0,0 +1,123 @@
+package locations
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrNotFound        = errors.New("location not found")
+	ErrDefaultLocation = errors.New("the default location cannot be deleted")
+	ErrInUse           = errors.New("location still holds stock or has stock movements")
+	ErrSystemLocation  = errors.New("system locations cannot be deleted")
+)
+
+type Repository struct {
+	DB *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{DB: db}
+}
+
+func (r *Repository) CreateLocation(ctx context.Context, l *Location) error {
+	query := `INSERT INTO locations (code, name) VALUES ($1, $2) RETURNING id, is_default, kind`
+	return r.DB.QueryRow(ctx, query, l.Code, l.Name).Scan(&l.ID, &l.IsDefault, &l.Kind)
+}
+
+func (r *Repository) GetLocations(ctx context.Context) ([]Location, error) {
+	rows, err := r.DB.Query(ctx, `SELECT id, code, name, is_default, kind FROM locations ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	locations := []Location{}
+	for rows.Next() {
+		var l Location
+		if err := rows.Scan(&l.ID, &l.Code, &l.Name, &l.IsDefault, &l.Kind); err != nil {
+			return nil, err
+		}
+		locations = append(locations, l)
+	}
+	return locations, rows.Err()
+}
+
+func (r *Repository) GetLocation(ctx context.Context, id int) (*Location, error) {
+	var l Location
+	err := r.DB.QueryRow(ctx, `SELECT id, code, name, is_default, kind FROM locations WHERE id=$1`, id).Scan(&l.ID, &l.Code, &l.Name, &l.IsDefault, &l.Kind)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (r *Repository) UpdateLocation(ctx context.Context, id int, l *Location) error {
+	cmd, err := r.DB.Exec(ctx, `UPDATE locations SET code=$1, name=$2 WHERE id=$3`, l.Code, l.Name, id)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetDefault torna o local indicado o padrão, desmarcando o anterior na mesma transação.
+func (r *Repository) SetDefault(ctx context.Context, id int) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `UPDATE locations SET is_default = FALSE WHERE is_default AND id <> $1`, id); err != nil {
+		return err
+	}
+	cmd, err := tx.Exec(ctx, `UPDATE locations SET is_default = TRUE WHERE id = $1 AND kind = 'storage'`, id)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *Repository) DeleteLocation(ctx context.Context, id int) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	// Níveis zerados não impedem a remoção; qualquer saldo ou histórico restante sim
+	if _, err := tx.Exec(ctx, `DELETE FROM stock_levels WHERE location_id=$1 AND quantity = 0`, id); err != nil {
+		return err
+	}
+	cmd, err := tx.Exec(ctx, `DELETE FROM locations WHERE id=$1`, id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return ErrInUse
+		}
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit(ctx)
+}
+
+type RepositoryInterface interface {
+	CreateLocation(ctx context.Context, l *Location) error
+	GetLocations(ctx context.Context) ([]Location, error)
+	GetLocation(ctx context.Context, id int) (*Location, error)
+	UpdateLocation(ctx context.Context, id int, l *Location) error
+	SetDefault(ctx context.Context, id int) error
+	DeleteLocation(ctx context.Context, id int) error
+}
@@ -0,0 +1,48 @@
+package locations
+
+import "context"
+
+type Service struct {
+	Repo RepositoryInterface
+}
+
+func NewService(repo RepositoryInterface) *Service {
+	return &Service{Repo: repo}
+}
+
+func (s *Service) CreateLocation(ctx context.Context, l *Location) error {
+	return s.Repo.CreateLocation(ctx, l)
+}
+
+func (s *Service) GetLocations(ctx context.Context) ([]Location, error) {
+	return s.Repo.GetLocations(ctx)
+}
+
+func (s *Service) GetLocation(ctx context.Context, id int) (*Location, error) {
+	return s.Repo.GetLocation(ctx, id)
+}
+
+func (s *Service) UpdateLocation(ctx context.Context, id int, l *Location) error {
+	return s.Repo.UpdateLocation(ctx, id, l)
+}
+
+func (s *Service) SetDefault(ctx context.Context, id int) error {
+	return s.Repo.SetDefault(ctx, id)
+}
+
+func (s *Service) DeleteLocation(ctx context.Context, id int) error {
+	l, err := s.Repo.GetLocation(ctx, id)
+	if err != nil {
+		return err
+	}
+	if l == nil {
+		return ErrNotFound
+	}
+	if l.IsDefault {
+		return ErrDefaultLocation
+	}
+	if l.Kind != KindStorage {
+		return ErrSystemLocation
+	}
+	return s.Repo.DeleteLocation(ctx, id)
+}
@@ -0,0 +1,125 @@
+package locations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type mockLocationRepo struct {
+	locations map[int]*Location
+	fail      bool
+}
+
+func (m *mockLocationRepo) CreateLocation(ctx context.Context, l *Location) error {
+	if m.fail {
+		return fmt.Errorf("db error")
+	}
+	l.ID = len(m.locations) + 1
+	l.Kind = KindStorage
+	m.locations[l.ID] = l
+	return nil
+}
+func (m *mockLocationRepo) GetLocations(ctx context.Context) ([]Location, error) {
+	if m.fail {
+		return nil, fmt.Errorf("db error")
+	}
+	var result []Location
+	for _, l := range m.locations {
+		result = append(result, *l)
+	}
+	return result, nil
+}
+func (m *mockLocationRepo) GetLocation(ctx context.Context, id int) (*Location, error) {
+	if m.fail {
+		return nil, fmt.Errorf("db error")
+	}
+	return m.locations[id], nil
+}
+func (m *mockLocationRepo) UpdateLocation(ctx context.Context, id int, l *Location) error {
+	if m.fail {
+		return fmt.Errorf("db error")
+	}
+	existing, ok := m.locations[id]
+	if !ok {
+		return ErrNotFound
+	}
+	existing.Code, existing.Name = l.Code, l.Name
+	return nil
+}
+func (m *mockLocationRepo) SetDefault(ctx context.Context, id int) error {
+	if _, ok := m.locations[id]; !ok {
+		return ErrNotFound
+	}
+	for _, l := range m.locations {
+		l.IsDefault = l.ID == id
+	}
+	return nil
+}
+func (m *mockLocationRepo) DeleteLocation(ctx context.Context, id int) error {
+	if m.fail {
+		return fmt.Errorf("db error")
+	}
+	delete(m.locations, id)
+	return nil
+}
+
+func TestService_DeleteLocation_Mock(t *testing.T) {
+	repo := &mockLocationRepo{locations: map[int]*Location{1: {ID: 1, Code: "MAIN", Name: "Main", IsDefault: true, Kind: KindStorage}}}
+	svc := NewService(repo)
+	wh := &Location{Code: "WH2", Name: "Warehouse 2"}
+	if err := svc.CreateLocation(context.Background(), wh); err != nil {
+		t.Fatalf("erro ao criar local: %v", err)
+	}
+	// Local padrão não pode ser removido
+	if err := svc.DeleteLocation(context.Background(), 1); !errors.Is(err, ErrDefaultLocation) {
+		t.Errorf("esperado ErrDefaultLocation, veio %v", err)
+	}
+	// Local inexistente
+	if err := svc.DeleteLocation(context.Background(), 99); !errors.Is(err, ErrNotFound) {
+		t.Errorf("esperado ErrNotFound, veio %v", err)
+	}
+	// Locais de sistema também não
+	repo.locations[50] = &Location{ID: 50, Code: "TRANSIT", Name: "In transit", Kind: KindTransit}
+	if err := svc.DeleteLocation(context.Background(), 50); !errors.Is(err, ErrSystemLocation) {
+		t.Errorf("esperado ErrSystemLocation, veio %v", err)
+	}
+	if err := svc.DeleteLocation(context.Background(), wh.ID); err != nil {
+		t.Fatalf("erro ao remover local: %v", err)
+	}
+	if l, _ := svc.GetLocation(context.Background(), wh.ID); l != nil {
+		t.Error("local não foi removido")
+	}
+}
+
+func TestService_SetDefault_Mock(t *testing.T) {
+	repo := &mockLocationRepo{locations: map[int]*Location{1: {ID: 1, Code: "MAIN", Name: "Main", IsDefault: true, Kind: KindStorage}}}
+	svc := NewService(repo)
+	wh := &Location{Code: "WH2", Name: "Warehouse 2"}
+	_ = svc.CreateLocation(context.Background(), wh)
+	if err := svc.SetDefault(context.Background(), wh.ID); err != nil {
+		t.Fatalf("erro ao definir local padrão: %v", err)
+	}
+	if main, _ := svc.GetLocation(context.Background(), 1); main.IsDefault {
+		t.Error("local anterior continua como padrão")
+	}
+	// Agora o antigo padrão pode ser removido
+	if err := svc.DeleteLocation(context.Background(), 1); err != nil {
+		t.Errorf("erro ao remover antigo padrão: %v", err)
+	}
+}
+
+func TestService_Failures_Mock(t *testing.T) {
+	repo := &mockLocationRepo{locations: map[int]*Location{}, fail: true}
+	svc := NewService(repo)
+	if err := svc.CreateLocation(context.Background(), &Location{Code: "X", Name: "X"}); err == nil {
+		t.Error("esperado erro de banco")
+	}
+	if _, err := svc.GetLocations(context.Background()); err == nil {
+		t.Error("esperado erro de banco")
+	}
+	if err := svc.DeleteLocation(context.Background(), 1); err == nil {
+		t.Error("esperado erro de banco")
+	}
+}
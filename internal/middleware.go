@@ -1,8 +1,10 @@
 package internal
 
 import (
+	"context"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -10,6 +12,10 @@ import (
 
 var jwtSecret = []byte(getEnv("JWT_SECRET", "changeme"))
 
+type contextKey string
+
+const claimsKey contextKey = "claims"
+
 func getEnv(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -37,10 +43,29 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			w.Write([]byte(`{"error":"Token inválido"}`))
 			return
 		}
+		if claims, ok := token.Claims.(jwt.MapClaims); ok {
+			r = r.WithContext(context.WithValue(r.Context(), claimsKey, claims))
+		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// UserIDFromContext retorna o ID do usuário (claim "sub") do token validado pelo AuthMiddleware
+func UserIDFromContext(ctx context.Context) (int, bool) {
+	claims, ok := ctx.Value(claimsKey).(jwt.MapClaims)
+	if !ok {
+		return 0, false
+	}
+	switch sub := claims["sub"].(type) {
+	case float64:
+		return int(sub), true
+	case string:
+		id, err := strconv.Atoi(sub)
+		return id, err == nil
+	}
+	return 0, false
+}
+
 // CORS middleware
 func CORSMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -1,16 +1,18 @@
 package products
 
 type ProductRequest struct {
-	Name     string `json:"name"`
-	Barcode  string `json:"barcode"`
-	Quantity int    `json:"quantity"`
-	MinStock int    `json:"min_stock"`
+	Name       string `json:"name"`
+	Barcode    string `json:"barcode"`
+	Quantity   int    `json:"quantity"`
+	MinStock   int    `json:"min_stock"`
+	Serialized bool   `json:"serialized"`
 }
 
 type ProductResponse struct {
-	ID       int    `json:"id"`
-	Name     string `json:"name"`
-	Barcode  string `json:"barcode"`
-	Quantity int    `json:"quantity"`
-	MinStock int    `json:"min_stock"`
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Barcode    string `json:"barcode"`
+	Quantity   int    `json:"quantity"`
+	MinStock   int    `json:"min_stock"`
+	Serialized bool   `json:"serialized"`
 }
@@ -1,12 +1,21 @@
 package products
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"inventory-system/internal"
+	"inventory-system/internal/barcode"
+	"inventory-system/internal/label"
 	"inventory-system/internal/notifications"
+	"inventory-system/internal/spreadsheet"
 	"inventory-system/internal/users"
 	"os"
 
@@ -15,7 +24,30 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-var validate = validator.New()
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterStructValidation(validateProductBarcode, Product{})
+	v.RegisterStructValidation(validateIdentifierCode, IdentifierRequest{})
+	return v
+}
+
+// validateProductBarcode exige um GTIN válido como código principal, exceto em códigos internos.
+func validateProductBarcode(sl validator.StructLevel) {
+	p := sl.Current().Interface().(Product)
+	if p.Barcode != "" && !p.InternalCode && barcode.Validate(p.Barcode) != nil {
+		sl.ReportError(p.Barcode, "Barcode", "Barcode", "gtin", "")
+	}
+}
+
+// validateIdentifierCode exige um GTIN válido nos códigos de barras adicionais, exceto em códigos internos.
+func validateIdentifierCode(sl validator.StructLevel) {
+	req := sl.Current().Interface().(IdentifierRequest)
+	if req.Code != "" && req.Kind != IdentifierSKU && !req.InternalCode && barcode.Validate(req.Code) != nil {
+		sl.ReportError(req.Code, "Code", "Code", "gtin", "")
+	}
+}
 
 func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -27,25 +59,145 @@ func respondError(w http.ResponseWriter, status int, message string) {
 	respondJSON(w, status, map[string]string{"error": message})
 }
 
-func RegisterRoutes(r chi.Router, db *pgxpool.Pool) {
-	repo := NewRepository(db)
+func respondStockError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrProductNotFound):
+		respondError(w, http.StatusNotFound, "Product not found")
+	case errors.Is(err, ErrLocationNotFound):
+		respondError(w, http.StatusNotFound, "Location not found")
+	case errors.Is(err, ErrSerialNotFound):
+		respondError(w, http.StatusNotFound, "Serial number not found")
+	case errors.Is(err, ErrReservationNotFound):
+		respondError(w, http.StatusNotFound, "Reservation not found")
+	case errors.Is(err, ErrUnitNotFound):
+		respondError(w, http.StatusNotFound, "Unit not found")
+	case errors.Is(err, ErrIdentifierNotFound):
+		respondError(w, http.StatusNotFound, "Identifier not found")
+	case errors.Is(err, ErrRuleNotFound):
+		respondError(w, http.StatusNotFound, "Barcode rule not found")
+	case errors.Is(err, ErrCategoryNotFound):
+		respondError(w, http.StatusNotFound, "Category not found")
+	case errors.Is(err, ErrReservationClosed):
+		respondError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrInsufficientStock), errors.Is(err, ErrLotNotFound), errors.Is(err, ErrLotQuantity), errors.Is(err, ErrInvalidExpiryDate),
+		errors.Is(err, ErrSerialsRequired), errors.Is(err, ErrSerialCount), errors.Is(err, ErrSerialNotInStock), errors.Is(err, ErrNotSerialized),
+		errors.Is(err, ErrReservedStock), errors.Is(err, ErrUnitMismatch), errors.Is(err, ErrBaseUnit), errors.Is(err, ErrSystemLocation),
+		errors.Is(err, ErrPrimaryIdentifier), errors.Is(err, ErrPrimarySKU), errors.Is(err, ErrQuantityChange),
+		errors.Is(err, barcode.ErrInvalidGS1), errors.Is(err, barcode.ErrUnsupportedAI), errors.Is(err, barcode.ErrInvalidExpiry),
+		errors.Is(err, barcode.ErrInvalidGS1Qty), errors.Is(err, barcode.ErrInvalidRule), errors.Is(err, ErrNoPrice), errors.Is(err, ErrEmbeddedPrice),
+		errors.Is(err, barcode.ErrUnknownSymbology), errors.Is(err, barcode.ErrNotEAN13), errors.Is(err, barcode.ErrUnencodable),
+		errors.Is(err, barcode.ErrTooLong), errors.Is(err, label.ErrUnknownFormat), errors.Is(err, label.ErrSingleLabel),
+		errors.Is(err, ErrImportFile), errors.Is(err, ErrImportColumns), errors.Is(err, ErrImportTooLarge), errors.Is(err, spreadsheet.ErrUnknownFormat):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrSerialConflict), errors.Is(err, ErrSerializedChange), errors.Is(err, ErrBaseUnitChange), errors.Is(err, ErrBarcodeInUse), errors.Is(err, ErrRuleExists),
+		errors.Is(err, ErrProductInUse):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func parseTimeParam(r *http.Request, name string) (*time.Time, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, errors.New("Invalid '" + name + "' date, expected RFC3339")
+	}
+	return &t, nil
+}
+
+func newNotifier() *notifications.NotificationService {
 	waToken := os.Getenv("WHATSAPP_TOKEN")
 	waPhoneID := os.Getenv("WHATSAPP_PHONE_ID")
-	notifier := notifications.NewNotificationService(
+	return notifications.NewNotificationService(
 		&notifications.LogSender{},
 		&notifications.WhatsAppSender{APIToken: waToken, PhoneID: waPhoneID},
 	)
-	service := NewService(repo, notifier)
+}
+
+// reservationTTL lê RESERVATION_TTL (duração Go, ex.: "15m"); zero usa o padrão do serviço.
+func reservationTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("RESERVATION_TTL"))
+	if err != nil || ttl < 0 {
+		return 0
+	}
+	return ttl
+}
+
+// stockoutWarningDays lê STOCKOUT_ALERT_DAYS; inválido ou ausente usa o padrão do serviço.
+func stockoutWarningDays() int {
+	days, err := strconv.Atoi(os.Getenv("STOCKOUT_ALERT_DAYS"))
+	if err != nil || days < 0 {
+		return 0
+	}
+	return days
+}
+
+func RegisterRoutes(r chi.Router, db *pgxpool.Pool) {
+	repo := NewRepository(db)
+	service := NewService(repo, newNotifier())
+	service.ReservationTTL = reservationTTL()
+	service.LabelCurrency = os.Getenv("LABEL_CURRENCY")
+	service.StockoutWarningDays = stockoutWarningDays()
 
 	r.Route("/products", func(r chi.Router) {
 		r.Use(internal.AuthMiddleware)
 		r.Post("/", createProductHandler(service))
 		r.Get("/", getAllProductsHandler(service))
+		r.Get("/labels", getLabelsHandler(service))
+		r.Post("/import", importProductsHandler(service))
+		r.Get("/export", exportProductsHandler(service))
 		r.Get("/{barcode}", getProductByBarcodeHandler(service))
 		r.Put("/{id}", updateProductHandler(service))
 		r.With(users.RequireRole("admin", []byte("changeme"))).Delete("/{id}", deleteProductHandler(service))
 		r.Post("/{barcode}/entry", stockEntryHandler(service))
 		r.Post("/{barcode}/exit", stockExitHandler(service))
+		r.Get("/{barcode}/movements", getMovementsHandler(service))
+		r.Get("/{barcode}/stock", getStockLevelsHandler(service))
+		r.Put("/{barcode}/stock/{locationID}", setLocationMinStockHandler(service))
+		r.Get("/{barcode}/units", getUnitsHandler(service))
+		r.Put("/{barcode}/units/{unit}", setUnitHandler(service))
+		r.Delete("/{barcode}/units/{unit}", deleteUnitHandler(service))
+		r.Get("/{barcode}/identifiers", getIdentifiersHandler(service))
+		r.Post("/{barcode}/identifiers", addIdentifierHandler(service))
+		r.Delete("/{barcode}/identifiers/{code}", deleteIdentifierHandler(service))
+		r.Put("/{barcode}/identifiers/{code}/primary", setPrimaryBarcodeHandler(service))
+		r.Get("/{barcode}/label", getLabelHandler(service))
+		r.Get("/{barcode}/lots", getLotsHandler(service))
+		r.Get("/{barcode}/forecast", getForecastHandler(service))
+		r.Post("/{barcode}/reservations", createReservationHandler(service))
+		r.Get("/{barcode}/reservations", getReservationsHandler(service))
+	})
+
+	r.Route("/movements", func(r chi.Router) {
+		r.Use(internal.AuthMiddleware)
+		r.Get("/export", exportMovementsHandler(service))
+	})
+
+	r.Route("/barcode-rules", func(r chi.Router) {
+		r.Use(internal.AuthMiddleware)
+		r.Get("/", getBarcodeRulesHandler(service))
+		r.Post("/", createBarcodeRuleHandler(service))
+		r.Delete("/{id}", deleteBarcodeRuleHandler(service))
+	})
+
+	r.Route("/reservations", func(r chi.Router) {
+		r.Use(internal.AuthMiddleware)
+		r.Post("/{id}/commit", commitReservationHandler(service))
+		r.Post("/{id}/release", releaseReservationHandler(service))
+	})
+
+	r.Route("/lots", func(r chi.Router) {
+		r.Use(internal.AuthMiddleware)
+		r.Get("/expiring", getExpiringLotsHandler(service))
+	})
+
+	r.Route("/serials", func(r chi.Router) {
+		r.Use(internal.AuthMiddleware)
+		r.Get("/{serial}", getSerialHandler(service))
 	})
 }
 
@@ -54,9 +206,10 @@ func RegisterRoutes(r chi.Router, db *pgxpool.Pool) {
 // @Tags products
 // @Accept json
 // @Produce json
-// @Param product body Product true "Product data" example({"name":"Apple","barcode":"123456","quantity":10,"min_stock":2})
+// @Param product body Product true "Product data (serialized products start with quantity 0 and receive stock by serial number)" example({"name":"Apple","barcode":"7891234567895","quantity":10,"min_stock":2,"serialized":false,"category_id":3})
 // @Success 201 {object} map[string]string "Created"
 // @Failure 400 {object} map[string]string "Invalid data or duplicate barcode"
+// @Failure 404 {object} map[string]string "Category not found"
 // @Router /products [post]
 func createProductHandler(s *Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -70,7 +223,7 @@ func createProductHandler(s *Service) http.HandlerFunc {
 			return
 		}
 		if err := s.CreateProduct(r.Context(), &p); err != nil {
-			respondError(w, http.StatusInternalServerError, err.Error())
+			respondStockError(w, err)
 			return
 		}
 		respondJSON(w, http.StatusCreated, nil)
@@ -79,6 +232,7 @@ func createProductHandler(s *Service) http.HandlerFunc {
 
 // @Security ApiKeyAuth
 // @Summary List all products
+// @Description quantity is the total across all locations; see /products/{barcode}/stock for the breakdown.
 // @Tags products
 // @Produce json
 // @Param page query int false "Page number (default: 1)"
@@ -86,8 +240,10 @@ func createProductHandler(s *Service) http.HandlerFunc {
 // @Param name query string false "Filter by name (partial match)"
 // @Param barcode query string false "Filter by barcode (exact match)"
 // @Param min_stock query int false "Filter by minimum stock"
+// @Param category query int false "Filter by category ID, including its subcategories"
 // @Param sort query string false "Sort field (id, name, quantity, min_stock)"
 // @Param order query string false "Sort order (asc, desc)"
+// @Param as_of query string false "Return quantities as they were at this instant (RFC3339)"
 // @Success 200 {array} Product "List of products" example([{...}])
 // @Header 200 {int} X-Total-Count "Total number of products"
 // @Router /products [get]
@@ -106,20 +262,28 @@ func getAllProductsHandler(s *Service) http.HandlerFunc {
 		name := r.URL.Query().Get("name")
 		barcode := r.URL.Query().Get("barcode")
 		minStock, _ := strconv.Atoi(r.URL.Query().Get("min_stock"))
+		category, _ := strconv.Atoi(r.URL.Query().Get("category"))
 		// Ordenação
 		sort := r.URL.Query().Get("sort")
 		order := r.URL.Query().Get("order")
 		if order != "desc" {
 			order = "asc"
 		}
+		asOf, err := parseTimeParam(r, "as_of")
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		products, total, err := s.GetProducts(r.Context(), ProductsQuery{
 			Page:     page,
 			Limit:    limit,
 			Name:     name,
 			Barcode:  barcode,
 			MinStock: minStock,
+			Category: category,
 			Sort:     sort,
 			Order:    order,
+			AsOf:     asOf,
 		})
 		if err != nil {
 			respondError(w, http.StatusInternalServerError, err.Error())
@@ -135,13 +299,25 @@ func getAllProductsHandler(s *Service) http.HandlerFunc {
 // @Tags products
 // @Produce json
 // @Param barcode path string true "Barcode"
+// @Param as_of query string false "Return the quantity as it was at this instant (RFC3339)"
 // @Success 200 {object} Product "Product data"
+// @Failure 400 {object} map[string]string "Invalid date"
 // @Failure 404 {object} map[string]string "Product not found"
 // @Router /products/{barcode} [get]
 func getProductByBarcodeHandler(s *Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		barcode := chi.URLParam(r, "barcode")
-		product, err := s.GetProductByBarcode(r.Context(), barcode)
+		asOf, err := parseTimeParam(r, "as_of")
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		var product *Product
+		if asOf != nil {
+			product, err = s.GetProductAsOf(r.Context(), barcode, *asOf)
+		} else {
+			product, err = s.GetProductByBarcode(r.Context(), barcode)
+		}
 		if err != nil {
 			respondError(w, http.StatusInternalServerError, err.Error())
 			return
@@ -156,12 +332,17 @@ func getProductByBarcodeHandler(s *Service) http.HandlerFunc {
 
 // @Security ApiKeyAuth
 // @Summary Update a product
+// @Description The body is applied over the stored product: omitted fields keep their current values, and
+// @Description empty strings take the same defaults as on creation. quantity cannot change here (400 when it
+// @Description differs from the stock); stock changes go through entries, exits and /adjustments.
 // @Tags products
 // @Accept json
 // @Param id path int true "Product ID"
-// @Param product body Product true "Product data" example({"name":"Apple","barcode":"123456","quantity":10,"min_stock":2})
+// @Param product body Product true "Product data" example({"name":"Apple","barcode":"7891234567895","min_stock":2,"serialized":false,"price":1.99,"costing_method":"fifo","reorder_point":5,"reorder_qty":24,"max_stock":48})
 // @Success 200 {object} map[string]string "Updated"
-// @Failure 400 {object} map[string]string "Invalid data"
+// @Failure 400 {object} map[string]string "Invalid data or quantity changed"
+// @Failure 404 {object} map[string]string "Product or category not found"
+// @Failure 409 {object} map[string]string "Serialized flag or base unit changed while the product has stock"
 // @Router /products/{id} [put]
 func updateProductHandler(s *Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -171,17 +352,26 @@ func updateProductHandler(s *Service) http.HandlerFunc {
 			respondError(w, http.StatusBadRequest, "Invalid ID")
 			return
 		}
-		var p Product
-		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		// O corpo é decodificado sobre o produto gravado, então todo campo omitido mantém o valor atual
+		p, err := s.GetProduct(r.Context(), id)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if p == nil {
+			respondError(w, http.StatusNotFound, "Product not found")
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(p); err != nil {
 			respondError(w, http.StatusBadRequest, "Invalid data")
 			return
 		}
-		if err := validate.Struct(&p); err != nil {
+		if err := validate.Struct(p); err != nil {
 			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
 			return
 		}
-		if err := s.UpdateProduct(r.Context(), id, &p); err != nil {
-			respondError(w, http.StatusInternalServerError, err.Error())
+		if err := s.UpdateProduct(r.Context(), id, p); err != nil {
+			respondStockError(w, err)
 			return
 		}
 		respondJSON(w, http.StatusOK, nil)
@@ -190,9 +380,12 @@ func updateProductHandler(s *Service) http.HandlerFunc {
 
 // @Security ApiKeyAuth
 // @Summary Delete a product
+// @Description Only products without stock movements or orders can be deleted; the movement history is permanent.
 // @Tags products
 // @Param id path int true "Product ID"
 // @Success 204 {object} map[string]string "Deleted"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Failure 409 {object} map[string]string "Product has stock movements or orders"
 // @Router /products/{id} [delete]
 func deleteProductHandler(s *Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -203,7 +396,7 @@ func deleteProductHandler(s *Service) http.HandlerFunc {
 			return
 		}
 		if err := s.DeleteProduct(r.Context(), id); err != nil {
-			respondError(w, http.StatusInternalServerError, err.Error())
+			respondStockError(w, err)
 			return
 		}
 		respondJSON(w, http.StatusNoContent, nil)
@@ -212,11 +405,18 @@ func deleteProductHandler(s *Service) http.HandlerFunc {
 
 // @Security ApiKeyAuth
 // @Summary Stock entry
+// @Description barcode may be the product's or a pack barcode; quantity and unit_cost are in the pack's unit, or in
+// @Description unit (the base unit when omitted), and are converted to base units. It may also be a whole GS1-128 or
+// @Description GS1 DataMatrix scan (URL-encoded): the GTIN finds the product and AIs 30, 10, 17 and 21 fill quantity,
+// @Description lot_number, expiry_date and serials when the body leaves them empty. Variable-measure EAN-13 codes
+// @Description (prefix 2) matching a barcode rule move the base product, with the embedded quantity or price.
 // @Tags stock
 // @Accept json
 // @Param barcode path string true "Barcode"
-// @Param body body StockRequest true "Quantity" example({"quantity":5})
+// @Param body body StockRequest true "Quantity, unit, location (default location when omitted), reason, reference, unit cost (current average cost when omitted), optional lot with expiry date and serial numbers (required for serialized products)" example({"quantity":5,"location_id":1,"reason":"purchase","reference":"NF 1234","unit_cost":2.35,"lot_number":"L2026-10","expiry_date":"2026-12-31"})
 // @Success 200 {object} map[string]string "Stock updated"
+// @Failure 400 {object} map[string]string "Invalid lot or expiry date"
+// @Failure 404 {object} map[string]string "Product or location not found"
 // @Router /products/{barcode}/entry [post]
 func stockEntryHandler(s *Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -226,12 +426,17 @@ func stockEntryHandler(s *Service) http.HandlerFunc {
 			respondError(w, http.StatusBadRequest, "Invalid data")
 			return
 		}
+		barcode, err := s.ReadScan(r.Context(), barcode, &req)
+		if err != nil {
+			respondStockError(w, err)
+			return
+		}
 		if err := validate.Struct(&req); err != nil {
 			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
 			return
 		}
-		if err := s.StockEntry(r.Context(), barcode, req.Quantity); err != nil {
-			respondError(w, http.StatusInternalServerError, err.Error())
+		if err := s.StockEntry(r.Context(), barcode, req); err != nil {
+			respondStockError(w, err)
 			return
 		}
 		respondJSON(w, http.StatusOK, nil)
@@ -240,12 +445,18 @@ func stockEntryHandler(s *Service) http.HandlerFunc {
 
 // @Security ApiKeyAuth
 // @Summary Stock exit
+// @Description barcode may be the product's or a pack barcode; quantity is in the pack's unit, or in unit (the base
+// @Description unit when omitted), and is converted to base units. It may also be a whole GS1-128 or GS1 DataMatrix
+// @Description scan (URL-encoded): the GTIN finds the product and AIs 30, 10 and 21 fill quantity, lot_number and
+// @Description serials when the body leaves them empty. Variable-measure EAN-13 codes (prefix 2) matching a barcode
+// @Description rule move the base product, with the embedded quantity or price.
 // @Tags stock
 // @Accept json
 // @Param barcode path string true "Barcode"
-// @Param body body StockRequest true "Quantity" example({"quantity":5})
+// @Param body body StockRequest true "Quantity, unit, location (default location when omitted), reason, reference, optional lot (first-expiry-first-out when omitted) and serial numbers (required for serialized products)" example({"quantity":5,"location_id":1,"reason":"sale","reference":"order 42"})
 // @Success 200 {object} map[string]string "Stock updated"
-// @Failure 400 {object} map[string]string "Insufficient stock"
+// @Failure 400 {object} map[string]string "Insufficient stock in the location or lot, or units reserved"
+// @Failure 404 {object} map[string]string "Location not found"
 // @Router /products/{barcode}/exit [post]
 func stockExitHandler(s *Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -255,14 +466,850 @@ func stockExitHandler(s *Service) http.HandlerFunc {
 			respondError(w, http.StatusBadRequest, "Invalid data")
 			return
 		}
+		barcode, err := s.ReadScan(r.Context(), barcode, &req)
+		if err != nil {
+			respondStockError(w, err)
+			return
+		}
 		if err := validate.Struct(&req); err != nil {
 			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
 			return
 		}
-		if err := s.StockExit(r.Context(), barcode, req.Quantity); err != nil {
-			respondError(w, http.StatusInternalServerError, err.Error())
+		if err := s.StockExit(r.Context(), barcode, req); err != nil {
+			respondStockError(w, err)
 			return
 		}
 		respondJSON(w, http.StatusOK, nil)
 	}
 }
+
+// @Security ApiKeyAuth
+// @Summary List stock movements of a product
+// @Tags stock
+// @Produce json
+// @Param barcode path string true "Barcode"
+// @Param from query string false "Start date (RFC3339)"
+// @Param to query string false "End date (RFC3339)"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 20, max: 100)"
+// @Success 200 {array} StockMovement "Stock movements, newest first"
+// @Header 200 {int} X-Total-Count "Total number of movements"
+// @Failure 400 {object} map[string]string "Invalid date"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Router /products/{barcode}/movements [get]
+func getMovementsHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		barcode := chi.URLParam(r, "barcode")
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 1 {
+			page = 1
+		}
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit < 1 || limit > 100 {
+			limit = 20
+		}
+		from, err := parseTimeParam(r, "from")
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		to, err := parseTimeParam(r, "to")
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		q := MovementsQuery{From: from, To: to, Page: page, Limit: limit}
+		movements, total, err := s.GetMovements(r.Context(), barcode, q)
+		if err != nil {
+			respondStockError(w, err)
+			return
+		}
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		respondJSON(w, http.StatusOK, movements)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Stock levels of a product per location
+// @Tags stock
+// @Produce json
+// @Param barcode path string true "Barcode"
+// @Param as_of query string false "Return the quantities as they were at this instant (RFC3339)"
+// @Success 200 {array} StockLevel "Quantity and minimum stock per location"
+// @Failure 400 {object} map[string]string "Invalid date"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Router /products/{barcode}/stock [get]
+func getStockLevelsHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		asOf, err := parseTimeParam(r, "as_of")
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		var levels []StockLevel
+		if asOf != nil {
+			levels, err = s.GetStockLevelsAsOf(r.Context(), chi.URLParam(r, "barcode"), *asOf)
+		} else {
+			levels, err = s.GetStockLevels(r.Context(), chi.URLParam(r, "barcode"))
+		}
+		if err != nil {
+			respondStockError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, levels)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Set the minimum stock of a product at a location
+// @Tags stock
+// @Accept json
+// @Param barcode path string true "Barcode"
+// @Param locationID path int true "Location ID"
+// @Param body body LocationMinStockRequest true "Minimum stock" example({"min_stock":3})
+// @Success 200 {object} map[string]string "Updated"
+// @Failure 404 {object} map[string]string "Product or location not found"
+// @Router /products/{barcode}/stock/{locationID} [put]
+func setLocationMinStockHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		locationID, err := strconv.Atoi(chi.URLParam(r, "locationID"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid location ID")
+			return
+		}
+		var req LocationMinStockRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		if err := s.SetLocationMinStock(r.Context(), chi.URLParam(r, "barcode"), locationID, req.MinStock); err != nil {
+			respondStockError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, nil)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Lots of a product with stock
+// @Tags lots
+// @Produce json
+// @Param barcode path string true "Barcode"
+// @Success 200 {array} Lot "Lots in consumption order (first expiry first)"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Router /products/{barcode}/lots [get]
+func getLotsHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lots, err := s.GetLots(r.Context(), chi.URLParam(r, "barcode"))
+		if err != nil {
+			respondStockError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, lots)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Demand forecast of a product
+// @Description Average daily demand from past exits (entries, adjustments and transfers are ignored), adjusted by weekday seasonality, and the projected stock-out date.
+// @Tags stock
+// @Produce json
+// @Param barcode path string true "Barcode"
+// @Param days query int false "Days to forecast (default: 30, max: 365)"
+// @Param history query int false "Days of exit history to use (default: 90, max: 730)"
+// @Success 200 {object} Forecast "Demand forecast"
+// @Failure 400 {object} map[string]string "Invalid days or history"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Router /products/{barcode}/forecast [get]
+func getForecastHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		horizon := defaultForecastHorizon
+		if v := r.URL.Query().Get("days"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxForecastHorizon {
+				respondError(w, http.StatusBadRequest, "Invalid days")
+				return
+			}
+			horizon = n
+		}
+		history := defaultForecastHistory
+		if v := r.URL.Query().Get("history"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxForecastHistory {
+				respondError(w, http.StatusBadRequest, "Invalid history")
+				return
+			}
+			history = n
+		}
+		f, err := s.GetForecast(r.Context(), chi.URLParam(r, "barcode"), history, horizon)
+		if err != nil {
+			respondStockError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, f)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Lots expiring within N days
+// @Tags lots
+// @Produce json
+// @Param days query int false "Days ahead (default: 30), expired lots are always included"
+// @Success 200 {array} Lot "Expiring lots, soonest first"
+// @Failure 400 {object} map[string]string "Invalid days"
+// @Router /lots/expiring [get]
+func getExpiringLotsHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		days := 30
+		if v := r.URL.Query().Get("days"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				respondError(w, http.StatusBadRequest, "Invalid days")
+				return
+			}
+			days = n
+		}
+		lots, err := s.GetExpiringLots(r.Context(), days)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, lots)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Current status and history of a serial number
+// @Tags serials
+// @Produce json
+// @Param serial path string true "Serial number"
+// @Success 200 {object} SerialNumber "Serial number with its movements, oldest first"
+// @Failure 404 {object} map[string]string "Serial number not found"
+// @Router /serials/{serial} [get]
+func getSerialHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sn, err := s.GetSerial(r.Context(), chi.URLParam(r, "serial"))
+		if err != nil {
+			respondStockError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, sn)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Reserve stock of a product
+// @Description Holds the quantity out of "available" until the reservation expires, is committed or released.
+// @Tags reservations
+// @Accept json
+// @Produce json
+// @Param barcode path string true "Barcode"
+// @Param body body ReservationRequest true "Quantity, time to live in seconds (default RESERVATION_TTL) and reference" example({"quantity":2,"ttl_seconds":900,"reference":"cart 981"})
+// @Success 201 {object} Reservation "Created reservation"
+// @Failure 400 {object} map[string]string "Invalid data or insufficient available stock"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Router /products/{barcode}/reservations [post]
+func createReservationHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req ReservationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		res, err := s.CreateReservation(r.Context(), chi.URLParam(r, "barcode"), req)
+		if err != nil {
+			respondStockError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusCreated, res)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Active reservations of a product
+// @Tags reservations
+// @Produce json
+// @Param barcode path string true "Barcode"
+// @Success 200 {array} Reservation "Active reservations, soonest to expire first"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Router /products/{barcode}/reservations [get]
+func getReservationsHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reservations, err := s.GetReservations(r.Context(), chi.URLParam(r, "barcode"))
+		if err != nil {
+			respondStockError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, reservations)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Commit a reservation
+// @Description Performs the stock exit of the reserved quantity.
+// @Tags reservations
+// @Accept json
+// @Produce json
+// @Param id path int true "Reservation ID"
+// @Param body body CommitReservationRequest false "Location to take the stock from (default location when omitted)"
+// @Success 200 {object} Reservation "Committed reservation"
+// @Failure 400 {object} map[string]string "Insufficient stock"
+// @Failure 404 {object} map[string]string "Reservation not found"
+// @Failure 409 {object} map[string]string "Reservation no longer active"
+// @Router /reservations/{id}/commit [post]
+func commitReservationHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		var req CommitReservationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		res, err := s.CommitReservation(r.Context(), id, req.LocationID)
+		if err != nil {
+			respondStockError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, res)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Release a reservation
+// @Tags reservations
+// @Produce json
+// @Param id path int true "Reservation ID"
+// @Success 200 {object} Reservation "Released reservation"
+// @Failure 404 {object} map[string]string "Reservation not found"
+// @Failure 409 {object} map[string]string "Reservation no longer active"
+// @Router /reservations/{id}/release [post]
+func releaseReservationHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		res, err := s.ReleaseReservation(r.Context(), id)
+		if err != nil {
+			respondStockError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, res)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Alternative units of a product
+// @Description Units other than the base unit, with how many base units each contains and its pack barcode.
+// @Tags units
+// @Produce json
+// @Param barcode path string true "Barcode"
+// @Success 200 {array} ProductUnit "Units by factor"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Router /products/{barcode}/units [get]
+func getUnitsHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		units, err := s.GetUnits(r.Context(), chi.URLParam(r, "barcode"))
+		if err != nil {
+			respondStockError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, units)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Create or update an alternative unit
+// @Description factor is how many base units the unit contains. A pack barcode makes entries and exits scanned with
+// @Description it move factor base units per pack; it cannot be a product barcode or another pack's.
+// @Tags units
+// @Accept json
+// @Produce json
+// @Param barcode path string true "Barcode"
+// @Param unit path string true "Unit name, e.g. case"
+// @Param body body UnitRequest true "Conversion factor and optional pack barcode" example({"factor":12,"barcode":"17891234567895"})
+// @Success 200 {object} ProductUnit "Saved unit"
+// @Failure 400 {object} map[string]string "Invalid data or unit equal to the base unit"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Failure 409 {object} map[string]string "Barcode already in use"
+// @Router /products/{barcode}/units/{unit} [put]
+func setUnitHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req UnitRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		u, err := s.SetUnit(r.Context(), chi.URLParam(r, "barcode"), chi.URLParam(r, "unit"), req)
+		if err != nil {
+			respondStockError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, u)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Delete an alternative unit
+// @Tags units
+// @Param barcode path string true "Barcode"
+// @Param unit path string true "Unit name"
+// @Success 204 {object} map[string]string "Deleted"
+// @Failure 404 {object} map[string]string "Product or unit not found"
+// @Router /products/{barcode}/units/{unit} [delete]
+func deleteUnitHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.DeleteUnit(r.Context(), chi.URLParam(r, "barcode"), chi.URLParam(r, "unit")); err != nil {
+			respondStockError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusNoContent, nil)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Identifiers of a product
+// @Description Barcodes (current and previous) and internal SKUs that find the product in every /products/{barcode}
+// @Description route, primary barcode first.
+// @Tags identifiers
+// @Produce json
+// @Param barcode path string true "Any identifier of the product"
+// @Success 200 {array} Identifier "Identifiers"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Router /products/{barcode}/identifiers [get]
+func getIdentifiersHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, err := s.GetIdentifiers(r.Context(), chi.URLParam(r, "barcode"))
+		if err != nil {
+			respondStockError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, ids)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Add a barcode or SKU to a product
+// @Tags identifiers
+// @Accept json
+// @Produce json
+// @Param barcode path string true "Any identifier of the product"
+// @Param body body IdentifierRequest true "Code and kind (barcode when omitted)" example({"code":"SKU-00042","kind":"sku"})
+// @Success 201 {object} Identifier "Added identifier"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Failure 409 {object} map[string]string "Code already identifies a product or pack"
+// @Router /products/{barcode}/identifiers [post]
+func addIdentifierHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req IdentifierRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		id, err := s.AddIdentifier(r.Context(), chi.URLParam(r, "barcode"), req)
+		if err != nil {
+			respondStockError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusCreated, id)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Remove a barcode or SKU from a product
+// @Tags identifiers
+// @Param barcode path string true "Any identifier of the product"
+// @Param code path string true "Identifier to remove"
+// @Success 204 {object} map[string]string "Deleted"
+// @Failure 400 {object} map[string]string "Code is the primary barcode"
+// @Failure 404 {object} map[string]string "Product or identifier not found"
+// @Router /products/{barcode}/identifiers/{code} [delete]
+func deleteIdentifierHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.DeleteIdentifier(r.Context(), chi.URLParam(r, "barcode"), chi.URLParam(r, "code")); err != nil {
+			respondStockError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusNoContent, nil)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Set the primary barcode of a product
+// @Description The code must be a barcode already associated with the product; the previous primary stays as an identifier.
+// @Tags identifiers
+// @Produce json
+// @Param barcode path string true "Any identifier of the product"
+// @Param code path string true "New primary barcode"
+// @Success 200 {object} Product "Updated product"
+// @Failure 400 {object} map[string]string "Code is an SKU"
+// @Failure 404 {object} map[string]string "Product or identifier not found"
+// @Router /products/{barcode}/identifiers/{code}/primary [put]
+func setPrimaryBarcodeHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, err := s.SetPrimaryBarcode(r.Context(), chi.URLParam(r, "barcode"), chi.URLParam(r, "code"))
+		if err != nil {
+			respondStockError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, p)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Variable-measure barcode rules
+// @Description Rules that decode in-store EAN-13 codes (prefix 2) with an embedded weight, quantity or price.
+// @Tags barcode-rules
+// @Produce json
+// @Success 200 {array} BarcodeRule "Rules by prefix"
+// @Router /barcode-rules [get]
+func getBarcodeRulesHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rules, err := s.GetBarcodeRules(r.Context())
+		if err != nil {
+			respondStockError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, rules)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Create a variable-measure barcode rule
+// @Description After prefix come item_digits digits of the item, and the value_digits digits before the check digit
+// @Description hold the value: a quantity in base units (value_type quantity) or a price with decimals decimal places
+// @Description (value_type price), divided by the product price. The base product is registered with the same code,
+// @Description the digits after the item zeroed and the check digit recomputed. The longest matching prefix wins.
+// @Tags barcode-rules
+// @Accept json
+// @Produce json
+// @Param body body BarcodeRule true "Rule" example({"prefix":"20","item_digits":5,"value_digits":5,"value_type":"quantity","description":"Deli scale, weight in grams"})
+// @Success 201 {object} BarcodeRule "Created rule"
+// @Failure 400 {object} map[string]string "Invalid data or digits do not fit an EAN-13"
+// @Failure 409 {object} map[string]string "A rule for this prefix already exists"
+// @Router /barcode-rules [post]
+func createBarcodeRuleHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var rule BarcodeRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&rule); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		if err := s.CreateBarcodeRule(r.Context(), &rule); err != nil {
+			respondStockError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusCreated, rule)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Delete a variable-measure barcode rule
+// @Tags barcode-rules
+// @Param id path int true "Rule ID"
+// @Success 204 {object} map[string]string "Deleted"
+// @Failure 404 {object} map[string]string "Rule not found"
+// @Router /barcode-rules/{id} [delete]
+func deleteBarcodeRuleHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		if err := s.DeleteBarcodeRule(r.Context(), id); err != nil {
+			respondStockError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusNoContent, nil)
+	}
+}
+
+// labelOptions lê format (padrão def), symbology, scale (1 a 10, padrão 2) e copies (1 a 100, padrão 1).
+func labelOptions(r *http.Request, def string) LabelOptions {
+	opts := LabelOptions{Format: r.URL.Query().Get("format"), Symbology: r.URL.Query().Get("symbology")}
+	if opts.Format == "" {
+		opts.Format = def
+	}
+	opts.Scale, _ = strconv.Atoi(r.URL.Query().Get("scale"))
+	if opts.Scale < 1 || opts.Scale > 10 {
+		opts.Scale = 2
+	}
+	opts.Copies, _ = strconv.Atoi(r.URL.Query().Get("copies"))
+	if opts.Copies < 1 || opts.Copies > 100 {
+		opts.Copies = 1
+	}
+	return opts
+}
+
+// streamWriter só envia os cabeçalhos no primeiro byte, para que um erro antes dele ainda possa ser
+// respondido em JSON.
+type streamWriter struct {
+	w           http.ResponseWriter
+	contentType string
+	filename    string
+	started     bool
+}
+
+func (sw *streamWriter) Write(b []byte) (int, error) {
+	if !sw.started {
+		sw.started = true
+		sw.w.Header().Set("Content-Type", sw.contentType)
+		sw.w.Header().Set("Content-Disposition", `attachment; filename="`+sw.filename+`"`)
+		sw.w.WriteHeader(http.StatusOK)
+	}
+	return sw.w.Write(b)
+}
+
+// @Security ApiKeyAuth
+// @Summary Shelf label of a product
+// @Description Renders the product's barcode with its name and price. symbology defaults to ean13 for EAN-13 and UPC-A
+// @Description barcodes and code128 otherwise; zpl leaves the barcode to the printer's own ^BE, ^BC or ^BQ commands.
+// @Description scale is pixels (png, svg) or printer dots (zpl) per module. The price is prefixed with LABEL_CURRENCY.
+// @Tags labels
+// @Produce png,image/svg+xml,application/zpl,application/pdf
+// @Param barcode path string true "Any identifier of the product"
+// @Param format query string false "png (default), svg, zpl or pdf"
+// @Param symbology query string false "ean13, code128 or qr"
+// @Param scale query int false "Pixels or dots per module (default: 2, max: 10)"
+// @Success 200 {file} file "Label"
+// @Failure 400 {object} map[string]string "Unknown format or symbology, or a barcode it cannot encode"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Router /products/{barcode}/label [get]
+func getLabelHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		opts := labelOptions(r, label.PNG)
+		var buf bytes.Buffer
+		if err := s.WriteLabel(r.Context(), chi.URLParam(r, "barcode"), opts, &buf); err != nil {
+			respondStockError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", label.ContentType(opts.Format))
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf.Bytes())
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Shelf labels of many products
+// @Description Streams one label per product matching the filters, copies times each, as a PDF with one label-sized
+// @Description page per label or as a ZPL stream. Barcodes the chosen symbology cannot encode fall back to the default.
+// @Tags labels
+// @Produce application/pdf,application/zpl
+// @Param format query string false "pdf (default) or zpl"
+// @Param symbology query string false "ean13, code128 or qr"
+// @Param scale query int false "Printer dots per module for zpl (default: 2, max: 10)"
+// @Param copies query int false "Labels per product (default: 1, max: 100)"
+// @Param name query string false "Filter by name (partial match)"
+// @Param barcode query string false "Filter by barcode (exact match)"
+// @Param min_stock query int false "Filter by minimum stock"
+// @Param category query int false "Filter by category ID, including its subcategories"
+// @Param sort query string false "Sort field (id, name, quantity, min_stock)"
+// @Param order query string false "Sort order (asc, desc)"
+// @Success 200 {file} file "Labels"
+// @Failure 400 {object} map[string]string "Unknown format or symbology"
+// @Router /products/labels [get]
+func getLabelsHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		opts := labelOptions(r, label.PDF)
+		minStock, _ := strconv.Atoi(r.URL.Query().Get("min_stock"))
+		category, _ := strconv.Atoi(r.URL.Query().Get("category"))
+		q := ProductsQuery{
+			Name:     r.URL.Query().Get("name"),
+			Barcode:  r.URL.Query().Get("barcode"),
+			MinStock: minStock,
+			Category: category,
+			Sort:     r.URL.Query().Get("sort"),
+			Order:    r.URL.Query().Get("order"),
+		}
+		sw := &streamWriter{w: w, contentType: label.ContentType(opts.Format), filename: "labels." + opts.Format}
+		if err := s.WriteLabels(r.Context(), q, opts, sw); err != nil {
+			if !sw.started {
+				respondStockError(w, err)
+				return
+			}
+			// Os cabeçalhos já foram enviados; resta interromper o arquivo
+			log.Printf("labels: %v", err)
+		}
+	}
+}
+
+// Tamanho máximo da planilha de importação
+const maxImportSize = 32 << 20
+
+// @Security ApiKeyAuth
+// @Summary Import products from a spreadsheet
+// @Description Creates or updates one product per row, found by barcode (any identifier). The first row holds the
+// @Description column titles; each product field is read from the column with its name, or with the title given in
+// @Description columns. Empty cells keep the current value; quantity sets the total stock through an import movement.
+// @Description Rows are validated with the same rules as POST /products. By default the import is all or nothing;
+// @Description mode=partial commits the valid rows, and dry_run=true only reports.
+// @Tags products
+// @Accept text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Produce json
+// @Param format query string false "csv or xlsx (default: from Content-Type, else csv)"
+// @Param columns query string false "Field to column title mapping, e.g. barcode:EAN,name:Descrição,price:Preço"
+// @Param mode query string false "atomic (default) or partial"
+// @Param dry_run query bool false "Validate without saving"
+// @Success 200 {object} ImportReport "Import report"
+// @Failure 400 {object} map[string]string "Unreadable file or invalid column mapping"
+// @Failure 422 {object} ImportReport "Rows failed and nothing was saved"
+// @Router /products/import [post]
+func importProductsHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		opts := ImportOptions{
+			Format:  r.URL.Query().Get("format"),
+			Columns: map[string]string{},
+			DryRun:  r.URL.Query().Get("dry_run") == "true",
+			Partial: r.URL.Query().Get("mode") == "partial",
+		}
+		if opts.Format == "" {
+			opts.Format = spreadsheet.CSV
+			if strings.Contains(r.Header.Get("Content-Type"), "spreadsheetml") {
+				opts.Format = spreadsheet.XLSX
+			}
+		}
+		if mode := r.URL.Query().Get("mode"); mode != "" && mode != "atomic" && mode != "partial" {
+			respondError(w, http.StatusBadRequest, "Invalid mode, expected atomic or partial")
+			return
+		}
+		if columns := r.URL.Query().Get("columns"); columns != "" {
+			for _, pair := range strings.Split(columns, ",") {
+				field, title, ok := strings.Cut(pair, ":")
+				if !ok {
+					respondError(w, http.StatusBadRequest, "Invalid columns, expected field:title pairs")
+					return
+				}
+				opts.Columns[strings.TrimSpace(field)] = title
+			}
+		}
+		report, err := s.ImportProducts(r.Context(), http.MaxBytesReader(w, r.Body, maxImportSize), opts)
+		if err != nil {
+			respondStockError(w, err)
+			return
+		}
+		status := http.StatusOK
+		if report.Failed > 0 && !report.Committed && !report.DryRun {
+			status = http.StatusUnprocessableEntity
+		}
+		respondJSON(w, status, report)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Export products
+// @Description Streams every product matching the filters, without pagination, as CSV (default), XLSX or NDJSON. The
+// @Description columns are the product fields, so an export can be edited and sent back to POST /products/import.
+// @Tags products
+// @Produce text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,application/x-ndjson
+// @Param format query string false "csv (default), xlsx or ndjson"
+// @Param name query string false "Filter by name (partial match)"
+// @Param barcode query string false "Filter by barcode (exact match)"
+// @Param min_stock query int false "Filter by minimum stock"
+// @Param category query int false "Filter by category ID, including its subcategories"
+// @Param sort query string false "Sort field (id, name, quantity, min_stock)"
+// @Param order query string false "Sort order (asc, desc)"
+// @Param as_of query string false "Export the quantities as they were at this instant (RFC3339)"
+// @Success 200 {file} file "Products"
+// @Failure 400 {object} map[string]string "Unknown format or invalid date"
+// @Router /products/export [get]
+func exportProductsHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		asOf, err := parseTimeParam(r, "as_of")
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		minStock, _ := strconv.Atoi(r.URL.Query().Get("min_stock"))
+		category, _ := strconv.Atoi(r.URL.Query().Get("category"))
+		q := ProductsQuery{
+			Name:     r.URL.Query().Get("name"),
+			Barcode:  r.URL.Query().Get("barcode"),
+			MinStock: minStock,
+			Category: category,
+			Sort:     r.URL.Query().Get("sort"),
+			Order:    r.URL.Query().Get("order"),
+			AsOf:     asOf,
+		}
+		format := exportFormat(r)
+		sw := &streamWriter{w: w, contentType: spreadsheet.ContentType(format), filename: "products." + format}
+		if err := s.ExportProducts(r.Context(), q, format, sw); err != nil {
+			if !sw.started {
+				respondStockError(w, err)
+				return
+			}
+			log.Printf("products export: %v", err)
+		}
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Export stock movements
+// @Description Streams the movements of the period, oldest first and without pagination, of one product or of all
+// @Description of them, as CSV (default), XLSX or NDJSON. Lots and serial numbers are not included.
+// @Tags stock
+// @Produce text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,application/x-ndjson
+// @Param format query string false "csv (default), xlsx or ndjson"
+// @Param barcode query string false "Only the movements of this product"
+// @Param from query string false "Start date (RFC3339)"
+// @Param to query string false "End date (RFC3339)"
+// @Success 200 {file} file "Movements"
+// @Failure 400 {object} map[string]string "Unknown format or invalid date"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Router /movements/export [get]
+func exportMovementsHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		from, err := parseTimeParam(r, "from")
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		to, err := parseTimeParam(r, "to")
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		format := exportFormat(r)
+		sw := &streamWriter{w: w, contentType: spreadsheet.ContentType(format), filename: "movements." + format}
+		err = s.ExportMovements(r.Context(), r.URL.Query().Get("barcode"), MovementsQuery{From: from, To: to}, format, sw)
+		if err != nil {
+			if !sw.started {
+				respondStockError(w, err)
+				return
+			}
+			log.Printf("movements export: %v", err)
+		}
+	}
+}
+
+func exportFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format
+	}
+	return spreadsheet.CSV
+}
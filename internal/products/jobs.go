@@ -0,0 +1,68 @@
+package products
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Intervalos das rotinas periódicas de estoque
+const (
+	lotExpiryInterval   = time.Hour
+	reservationInterval = time.Minute
+	snapshotInterval    = time.Hour
+)
+
+// StartBackgroundJobs inicia as rotinas periódicas do estoque até ctx ser cancelado: o aviso
+// expiring_soon para lotes que vencem nos próximos LOT_EXPIRY_ALERT_DAYS dias (padrão 30) e a
+// liberação das reservas vencidas e as fotografias diárias do estoque.
+func StartBackgroundJobs(ctx context.Context, db *pgxpool.Pool) {
+	service := NewService(NewRepository(db), newNotifier())
+	days := 30
+	if v, err := strconv.Atoi(os.Getenv("LOT_EXPIRY_ALERT_DAYS")); err == nil && v >= 0 {
+		days = v
+	}
+	go every(ctx, lotExpiryInterval, func() {
+		if err := service.NotifyExpiringLots(ctx, days); err != nil {
+			log.Printf("Erro ao verificar lotes a vencer: %v", err)
+		}
+	})
+	go every(ctx, reservationInterval, func() {
+		n, err := service.ExpireReservations(ctx)
+		if err != nil {
+			log.Printf("Erro ao liberar reservas vencidas: %v", err)
+			return
+		}
+		if n > 0 {
+			log.Printf("%d reserva(s) vencida(s) liberada(s)", n)
+		}
+	})
+	go every(ctx, snapshotInterval, func() {
+		n, err := service.TakeSnapshots(ctx, time.Now())
+		if err != nil {
+			log.Printf("Erro ao gravar fotografia do estoque: %v", err)
+			return
+		}
+		if n > 0 {
+			log.Printf("%d fotografia(s) diária(s) do estoque gravada(s)", n)
+		}
+	})
+}
+
+// every executa job imediatamente e depois a cada interval, até ctx ser cancelado.
+func every(ctx context.Context, interval time.Duration, job func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		job()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
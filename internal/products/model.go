@@ -1,13 +1,341 @@
 package products
 
+import "time"
+
 type Product struct {
-	ID       int    `json:"id"`
-	Name     string `json:"name" validate:"required"`
+	ID   int    `json:"id"`
+	Name string `json:"name" validate:"required"`
+	// Código de barras principal, exibido no produto; as rotas também aceitam os demais identificadores
 	Barcode  string `json:"barcode" validate:"required"`
 	Quantity int    `json:"quantity"`
 	MinStock int    `json:"min_stock"`
+	// Produtos serializados só movimentam estoque informando os números de série
+	Serialized bool `json:"serialized"`
+	// Available é a quantidade menos as reservas ativas e as alocações de pedidos de venda; somente leitura
+	Available int `json:"available"`
+	// Quanto uma saída pode deixar o saldo de um local abaixo de zero; vazio equivale a forbid
+	NegativeStockPolicy string  `json:"negative_stock_policy" validate:"omitempty,oneof=forbid allow floor"`
+	NegativeStockFloor  int     `json:"negative_stock_floor" validate:"lte=0"`
+	Price               float64 `json:"price" validate:"gte=0"`
+	// Método de custeio do estoque; vazio equivale a average
+	CostingMethod string `json:"costing_method" validate:"omitempty,oneof=fifo average"`
+	// AverageCost é o custo unitário médio do estoque atual; somente leitura
+	AverageCost float64 `json:"average_cost"`
+	// Reposição: abaixo de ReorderPoint (ou de MinStock, se zero) sugere-se comprar ReorderQty
+	// ou completar até MaxStock; zero desativa cada campo
+	ReorderPoint int `json:"reorder_point" validate:"gte=0"`
+	ReorderQty   int `json:"reorder_qty" validate:"gte=0"`
+	MaxStock     int `json:"max_stock" validate:"omitempty,gtefield=ReorderPoint"`
+	// Unidade em que quantidade, estoque e custos são contados; vazio equivale a unit
+	BaseUnit string `json:"base_unit"`
+	// Códigos internos dispensam a validação de GTIN (EAN-8, UPC-A, EAN-13 ou GTIN-14) do código principal
+	InternalCode bool `json:"internal_code"`
+	// Categoria do produto (pacote categories); vazio deixa o produto sem categoria
+	CategoryID *int `json:"category_id"`
+}
+
+// Tipos de identificador de produto
+const (
+	IdentifierBarcode = "barcode"
+	IdentifierSKU     = "sku"
+)
+
+// Identifier é um código que identifica o produto nas leituras e rotas: códigos de barras (o atual
+// do fabricante e os anteriores) e SKUs internos. Primary marca o código de barras exibido no produto.
+type Identifier struct {
+	Code      string    `json:"code"`
+	Kind      string    `json:"kind"`
+	Primary   bool      `json:"primary"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type IdentifierRequest struct {
+	Code string `json:"code" validate:"required"`
+	// barcode ou sku; vazio equivale a barcode
+	Kind string `json:"kind" validate:"omitempty,oneof=barcode sku"`
+	// Aceita um código de barras interno, sem validar o GTIN; SKUs nunca são validados
+	InternalCode bool `json:"internal_code"`
+}
+
+// Tipos de valor embutido nos códigos de medida variável
+const (
+	VariableQuantity = "quantity"
+	VariablePrice    = "price"
+)
+
+// BarcodeRule decodifica os EAN-13 de uso interno (prefixo 2) impressos por balanças e etiquetadoras:
+// depois do prefixo vêm ItemDigits dígitos do item, e os ValueDigits dígitos antes do verificador
+// trazem a quantidade em unidades base (gramas, para produtos com base_unit g) ou o preço com
+// Decimals casas decimais, que vira quantidade dividido pelo preço do produto. O produto base é
+// cadastrado com o mesmo código e os dígitos depois do item zerados.
+type BarcodeRule struct {
+	ID          int       `json:"id"`
+	Prefix      string    `json:"prefix" validate:"required,numeric,startswith=2,max=3"`
+	ItemDigits  int       `json:"item_digits" validate:"required,gt=0"`
+	ValueDigits int       `json:"value_digits" validate:"required,gt=0"`
+	ValueType   string    `json:"value_type" validate:"required,oneof=quantity price"`
+	Decimals    int       `json:"decimals" validate:"gte=0,lte=4"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ImportRow é uma linha da planilha de importação: os valores não vazios das colunas mapeadas, pelo
+// nome do campo do produto. Line é o número da linha na planilha, contando o cabeçalho.
+type ImportRow struct {
+	Line    int
+	Barcode string
+	Values  map[string]string
+}
+
+type ImportError struct {
+	Row     int    `json:"row"`
+	Barcode string `json:"barcode"`
+	Error   string `json:"error"`
+}
+
+// ImportReport resume a importação. Committed diz se as linhas sem erro foram gravadas.
+type ImportReport struct {
+	Rows      int           `json:"rows"`
+	Created   int           `json:"created"`
+	Updated   int           `json:"updated"`
+	Failed    int           `json:"failed"`
+	DryRun    bool          `json:"dry_run"`
+	Committed bool          `json:"committed"`
+	Errors    []ImportError `json:"errors"`
+}
+
+// DefaultUnit é a unidade base dos produtos cadastrados sem base_unit
+const DefaultUnit = "unit"
+
+// ProductUnit é uma unidade alternativa do produto (caixa, fardo, ...) com quantas unidades base
+// ela contém. Barcode, quando informado, identifica a embalagem: ler esse código em uma entrada ou
+// saída movimenta Factor unidades base por embalagem.
+type ProductUnit struct {
+	ProductID int     `json:"product_id"`
+	Unit      string  `json:"unit"`
+	Factor    int     `json:"factor"`
+	Barcode   *string `json:"barcode"`
 }
 
+type UnitRequest struct {
+	Factor  int    `json:"factor" validate:"required,gt=0"`
+	Barcode string `json:"barcode"`
+}
+
+// Métodos de custeio: primeiro a entrar, primeiro a sair ou média ponderada móvel
+const (
+	CostingFIFO    = "fifo"
+	CostingAverage = "average"
+)
+
+// Políticas de estoque negativo
+const (
+	PolicyForbid = "forbid"
+	PolicyAllow  = "allow"
+	PolicyFloor  = "floor"
+)
+
 type StockRequest struct {
+	// Pode ser omitida quando Serials é informado
+	Quantity   int    `json:"quantity" validate:"required_without=Serials,gte=0"`
+	LocationID int    `json:"location_id"`
+	Reason     string `json:"reason"`
+	Reference  string `json:"reference"`
+	// Em entradas identifica o lote recebido; em saídas, o lote a consumir (FEFO quando vazio)
+	LotNumber  string `json:"lot_number" validate:"required_with=ExpiryDate"`
+	ExpiryDate string `json:"expiry_date" validate:"omitempty,datetime=2006-01-02"`
+	// Números de série que entram ou saem, obrigatórios para produtos serializados
+	Serials []string `json:"serials" validate:"omitempty,unique,dive,required"`
+	// Custo unitário da entrada; sem ele, a entrada é valorada pelo custo médio atual
+	UnitCost *float64 `json:"unit_cost" validate:"omitempty,gte=0"`
+	// Unidade de Quantity e UnitCost; vazia vale a unidade base ou, lendo o código de uma embalagem,
+	// a unidade dela. Números de série são sempre de unidades base.
+	Unit string `json:"unit"`
+}
+
+// Motivos gravados automaticamente no histórico de movimentações
+const (
+	ReasonEntry       = "entry"
+	ReasonExit        = "exit"
+	ReasonCreate      = "create"
+	ReasonUpdate      = "update"
+	ReasonOpening     = "opening"
+	ReasonTransfer    = "transfer"
+	ReasonReservation = "reservation"
+	ReasonSale        = "sale"
+	ReasonImport      = "import"
+)
+
+type StockMovement struct {
+	ID              int       `json:"id"`
+	ProductID       int       `json:"product_id"`
+	LocationID      int       `json:"location_id"`
+	Delta           int       `json:"delta"`
+	Balance         int       `json:"balance"`
+	LocationBalance int       `json:"location_balance"`
+	UserID          *int      `json:"user_id"`
+	Reason          string    `json:"reason"`
+	Reference       string    `json:"reference"`
+	CreatedAt       time.Time `json:"created_at"`
+	// Lots detalha os lotes afetados pela movimentação, sempre com quantidades positivas
+	Lots []LotQuantity `json:"lots,omitempty"`
+	// Serials lista os números de série que entraram ou saíram
+	Serials []string `json:"serials,omitempty"`
+	// Custo unitário da movimentação: informado nas entradas (ou o médio atual) e calculado nas
+	// saídas pelo método de custeio. Value é o efeito no valor do estoque; em saídas, -Value é o CMV.
+	UnitCost *float64 `json:"unit_cost"`
+	Value    float64  `json:"value"`
+	// System libera locais que não são de armazenagem (trânsito, quarentena); só movimentações
+	// internas, como as pernas das transferências, podem usá-los
+	System bool `json:"-"`
+
+	limits stockLimits
+}
+
+// stockLimits guarda os dados do produto e do local lidos (e bloqueados) na mesma transação da
+// movimentação, usados na política de estoque negativo e nos alertas de estoque baixo.
+type stockLimits struct {
+	productName      string
+	minStock         int
+	reorderPoint     int
+	policy           string
+	floor            int
+	locationCode     string
+	locationName     string
+	locationMinStock int
+}
+
+// allows informa se a política do produto aceita o saldo informado.
+func (l stockLimits) allows(balance int) bool {
+	switch l.policy {
+	case PolicyAllow:
+		return true
+	case PolicyFloor:
+		return balance >= l.floor
+	default:
+		return balance >= 0
+	}
+}
+
+type LotQuantity struct {
+	LotID      int        `json:"lot_id"`
+	LotNumber  string     `json:"lot_number"`
+	ExpiryDate *time.Time `json:"expiry_date"`
+	Quantity   int        `json:"quantity"`
+}
+
+// Lot é o saldo de um lote em um local. O estoque sem lote é a diferença entre o nível do local e a soma dos lotes.
+type Lot struct {
+	ID           int        `json:"id"`
+	ProductID    int        `json:"product_id"`
+	Barcode      string     `json:"barcode"`
+	ProductName  string     `json:"product_name"`
+	LocationID   int        `json:"location_id"`
+	LocationCode string     `json:"location_code"`
+	LotNumber    string     `json:"lot_number"`
+	ExpiryDate   *time.Time `json:"expiry_date"`
+	Quantity     int        `json:"quantity"`
+	CreatedAt    time.Time  `json:"created_at"`
+	NotifiedAt   *time.Time `json:"-"`
+}
+
+// StockLevel é o saldo de um produto em um local; Product.Quantity é a soma de todos os níveis.
+type StockLevel struct {
+	LocationID   int    `json:"location_id"`
+	LocationCode string `json:"location_code"`
+	LocationName string `json:"location_name"`
+	Quantity     int    `json:"quantity"`
+	MinStock     int    `json:"min_stock"`
+}
+
+type LocationMinStockRequest struct {
+	MinStock int `json:"min_stock" validate:"gte=0"`
+}
+
+// Situação de um número de série
+const (
+	SerialInStock = "in_stock"
+	SerialOut     = "out"
+)
+
+// SerialNumber é uma unidade individual de um produto serializado; History traz suas movimentações em ordem cronológica.
+type SerialNumber struct {
+	ID           int             `json:"id"`
+	Serial       string          `json:"serial"`
+	ProductID    int             `json:"product_id"`
+	Barcode      string          `json:"barcode"`
+	ProductName  string          `json:"product_name"`
+	LocationID   int             `json:"location_id"`
+	LocationCode string          `json:"location_code"`
+	Status       string          `json:"status"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+	History      []StockMovement `json:"history"`
+}
+
+// Situação de uma reserva
+const (
+	ReservationActive    = "active"
+	ReservationCommitted = "committed"
+	ReservationReleased  = "released"
+	ReservationExpired   = "expired"
+)
+
+type Reservation struct {
+	ID        int        `json:"id"`
+	ProductID int        `json:"product_id"`
+	Barcode   string     `json:"barcode"`
+	Quantity  int        `json:"quantity"`
+	Status    string     `json:"status"`
+	Reference string     `json:"reference"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedBy *int       `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	ClosedAt  *time.Time `json:"closed_at"`
+}
+
+type ReservationRequest struct {
 	Quantity int `json:"quantity" validate:"required,gte=1"`
+	// Validade da reserva em segundos; o padrão é RESERVATION_TTL
+	TTLSeconds int    `json:"ttl_seconds" validate:"gte=0"`
+	Reference  string `json:"reference"`
+}
+
+type CommitReservationRequest struct {
+	LocationID int `json:"location_id"`
+}
+
+// NonDemandReasons são os motivos de saída que não representam consumo na previsão de demanda e na
+// reposição:
+// transferências e correções de saldo (edição da quantidade, importação, contagem de inventário e a
+// baixa de devoluções descartadas, que saem na mesma transação em que entraram). Qualquer outra
+// saída, inclusive com motivo próprio, conta como demanda.
+var NonDemandReasons = []string{ReasonTransfer, ReasonUpdate, ReasonImport, "count_correction", "return_scrap"}
+
+// DailyDemand é a quantidade consumida de um produto em um dia (UTC).
+type DailyDemand struct {
+	Date     time.Time `json:"date"`
+	Quantity int       `json:"quantity"`
+}
+
+// Forecast projeta a demanda de um produto a partir do histórico de saídas.
+type Forecast struct {
+	Barcode  string `json:"barcode"`
+	Quantity int    `json:"quantity"`
+	// Dias de histórico usados; menor que o pedido quando o produto é mais novo
+	HistoryDays        int     `json:"history_days"`
+	AverageDailyDemand float64 `json:"average_daily_demand"`
+	// Fator de sazonalidade por dia da semana, começando no domingo; todos 1 com menos de 4 semanas de histórico
+	Seasonality    []float64       `json:"seasonality"`
+	HorizonDays    int             `json:"horizon_days"`
+	ForecastDemand float64         `json:"forecast_demand"`
+	Daily          []DailyForecast `json:"daily"`
+	// Previsão de ruptura, nula quando não há demanda; 0 significa que o estoque já acabou
+	DaysUntilStockout *int       `json:"days_until_stockout"`
+	StockoutDate      *time.Time `json:"stockout_date"`
+}
+
+type DailyForecast struct {
+	Date   time.Time `json:"date"`
+	Demand float64   `json:"demand"`
 }
@@ -4,11 +4,82 @@ import (
 	"context"
 	"errors"
 	"strconv"
+	"time"
+
+	"inventory-system/internal"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+var (
+	ErrProductNotFound     = errors.New("product not found")
+	ErrLocationNotFound    = errors.New("location not found")
+	ErrSystemLocation      = errors.New("stock can only be moved in storage locations")
+	ErrInsufficientStock   = errors.New("Insufficient stock or product not found")
+	ErrLotNotFound         = errors.New("lot not found or with insufficient stock")
+	ErrLotQuantity         = errors.New("lot quantities exceed the moved quantity")
+	ErrInvalidExpiryDate   = errors.New("invalid expiry date, expected YYYY-MM-DD")
+	ErrSerialNotFound      = errors.New("serial number not found")
+	ErrSerialsRequired     = errors.New("serial numbers are required for serialized products")
+	ErrSerialCount         = errors.New("number of serial numbers does not match the quantity")
+	ErrSerialConflict      = errors.New("serial number already in stock or registered to another product")
+	ErrSerialNotInStock    = errors.New("serial number not in stock at this location")
+	ErrNotSerialized       = errors.New("product is not serialized")
+	ErrSerializedChange    = errors.New("serialized flag can only change while the product has no stock")
+	ErrBaseUnitChange      = errors.New("base unit can only change while the product has no stock")
+	ErrQuantityChange      = errors.New("quantity cannot be changed here; use stock entries, exits or /adjustments")
+	ErrReservationNotFound = errors.New("reservation not found")
+	ErrReservationClosed   = errors.New("reservation is no longer active")
+	ErrReservedStock       = errors.New("Insufficient available stock, units are reserved")
+	ErrUnitNotFound        = errors.New("unit not found for this product")
+	ErrUnitMismatch        = errors.New("unit does not match the scanned pack barcode")
+	ErrBaseUnit            = errors.New("the base unit cannot be an alternative unit")
+	ErrBarcodeInUse        = errors.New("barcode already in use by another product or pack")
+	ErrIdentifierNotFound  = errors.New("identifier not found for this product")
+	ErrPrimaryIdentifier   = errors.New("the primary barcode cannot be removed, set another one first")
+	ErrPrimarySKU          = errors.New("only barcodes can be the primary barcode")
+	ErrRuleNotFound        = errors.New("barcode rule not found")
+	ErrCategoryNotFound    = errors.New("category not found")
+	ErrProductInUse        = errors.New("product has stock movements or orders and cannot be deleted")
+	ErrRuleExists          = errors.New("a barcode rule for this prefix already exists")
+	ErrNoPrice             = errors.New("product has no price to derive the quantity from the embedded price")
+	ErrEmbeddedPrice       = errors.New("the embedded price is not a whole number of base units, use a smaller base unit or a quantity rule")
+	ErrImportFile          = errors.New("could not read the spreadsheet")
+	ErrImportColumns       = errors.New("invalid column mapping")
+	ErrImportTooLarge      = errors.New("too many rows, split the import")
+)
+
+// Estoque comprometido do produto da linha corrente de products: reservas ativas e não vencidas
+// mais as alocações de pedidos de venda ainda não expedidas
+const heldStock = `((SELECT COALESCE(SUM(r.quantity), 0) FROM reservations r
+	WHERE r.product_id = products.id AND r.status = 'active' AND r.expires_at > NOW())
+	+ (SELECT COALESCE(SUM(a.quantity), 0) FROM sales_allocations a WHERE a.product_id = products.id))`
+
+// AvailableQuantity é a expressão SQL da quantidade disponível (saldo menos reservas e alocações),
+// para consultas de outros pacotes que leem a tabela products sem apelido.
+const AvailableQuantity = "products.quantity - " + heldStock
+
+// ByCode é a condição que encontra o produto pela coluna de ID column a partir de qualquer um dos seus
+// identificadores (código de barras principal, códigos anteriores ou SKUs) no parâmetro param. Toda
+// busca de produto por código, inclusive em outros pacotes, passa por ela.
+func ByCode(column, param string) string {
+	return column + " = (SELECT product_id FROM product_identifiers WHERE code = " + param + ")"
+}
+
+// Identifiers é a expressão SQL com todos os identificadores do produto da coluna de ID column, para
+// que linhas de pedidos e devoluções sejam encontradas por qualquer código do produto.
+func Identifiers(column string) string {
+	return "ARRAY(SELECT code FROM product_identifiers WHERE product_id = " + column + ")"
+}
+
+const productColumns = "id, name, barcode, quantity, min_stock, serialized, quantity - " + heldStock + ", negative_stock_policy, negative_stock_floor, price, costing_method, average_cost, reorder_point, reorder_qty, max_stock, base_unit, internal_code, category_id"
+
+func scanProduct(row pgx.Row, p *Product) error {
+	return row.Scan(&p.ID, &p.Name, &p.Barcode, &p.Quantity, &p.MinStock, &p.Serialized, &p.Available, &p.NegativeStockPolicy, &p.NegativeStockFloor, &p.Price, &p.CostingMethod, &p.AverageCost, &p.ReorderPoint, &p.ReorderQty, &p.MaxStock, &p.BaseUnit, &p.InternalCode, &p.CategoryID)
+}
+
 type Repository struct {
 	DB *pgxpool.Pool
 }
@@ -18,11 +89,94 @@ func NewRepository(db *pgxpool.Pool) *Repository {
 }
 
 func (r *Repository) CreateProduct(ctx context.Context, p *Product) error {
-	query := `INSERT INTO products (name, barcode, quantity, min_stock) VALUES ($1, $2, $3, $4) RETURNING id`
-	return r.DB.QueryRow(ctx, query, p.Name, p.Barcode, p.Quantity, p.MinStock).Scan(&p.ID)
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if err := createProduct(ctx, tx, p); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func createProduct(ctx context.Context, tx pgx.Tx, p *Product) error {
+	if err := checkPackBarcode(ctx, tx, p.Barcode); err != nil {
+		return err
+	}
+	// O estoque inicial entra no local padrão como uma movimentação comum
+	query := `INSERT INTO products (name, barcode, quantity, min_stock, serialized, negative_stock_policy, negative_stock_floor, price, costing_method,
+			reorder_point, reorder_qty, max_stock, base_unit, internal_code, category_id)
+		VALUES ($1, $2, 0, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING id`
+	if err := tx.QueryRow(ctx, query, p.Name, p.Barcode, p.MinStock, p.Serialized, p.NegativeStockPolicy, p.NegativeStockFloor, p.Price, p.CostingMethod,
+		p.ReorderPoint, p.ReorderQty, p.MaxStock, p.BaseUnit, p.InternalCode, p.CategoryID).Scan(&p.ID); err != nil {
+		return categoryError(err)
+	}
+	if err := insertIdentifier(ctx, tx, p.ID, &Identifier{Code: p.Barcode, Kind: IdentifierBarcode}); err != nil {
+		return err
+	}
+	if p.Quantity != 0 {
+		m := &StockMovement{ProductID: p.ID, Delta: p.Quantity, Reason: ReasonCreate}
+		return ApplyMovement(ctx, tx, m)
+	}
+	return nil
 }
 
 func (r *Repository) GetProducts(ctx context.Context, q ProductsQuery) ([]Product, int, error) {
+	query, where, args, filters := selectProducts(q)
+	limit := q.Limit
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	offset := (q.Page - 1) * limit
+	query += " LIMIT $" + strconv.Itoa(len(args)+1) + " OFFSET $" + strconv.Itoa(len(args)+2)
+	args = append(args, limit, offset)
+	rows, err := r.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var products []Product
+	for rows.Next() {
+		var p Product
+		if err := scanProduct(rows, &p); err != nil {
+			return nil, 0, err
+		}
+		products = append(products, p)
+	}
+	// Total count
+	total := 0
+	countQuery := "SELECT COUNT(*) FROM products WHERE 1=1" + where
+	if err := r.DB.QueryRow(ctx, countQuery, args[:filters]...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	return products, total, nil
+}
+
+// ExportProducts passa a fn, um a um, todos os produtos do filtro, na ordem pedida e sem paginação.
+// As linhas são lidas do banco à medida que fn as consome.
+func (r *Repository) ExportProducts(ctx context.Context, q ProductsQuery, fn func(*Product) error) error {
+	query, _, args, _ := selectProducts(q)
+	rows, err := r.DB.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p Product
+		if err := scanProduct(rows, &p); err != nil {
+			return err
+		}
+		if err := fn(&p); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// selectProducts monta a consulta ordenada e sem paginação dos produtos do filtro. Devolve também a
+// condição e quantos dos argumentos são dos filtros, para a contagem (as_of incluído).
+func selectProducts(q ProductsQuery) (string, string, []interface{}, int) {
 	args := []interface{}{}
 	where := ""
 	idx := 1
@@ -32,7 +186,7 @@ func (r *Repository) GetProducts(ctx context.Context, q ProductsQuery) ([]Produc
 		idx++
 	}
 	if q.Barcode != "" {
-		where += " AND barcode = $" + strconv.Itoa(idx)
+		where += " AND " + ByCode("id", "$"+strconv.Itoa(idx))
 		args = append(args, q.Barcode)
 		idx++
 	}
@@ -41,6 +195,11 @@ func (r *Repository) GetProducts(ctx context.Context, q ProductsQuery) ([]Produc
 		args = append(args, q.MinStock)
 		idx++
 	}
+	if q.Category > 0 {
+		where += " AND category_id IN (" + categoryTree("$"+strconv.Itoa(idx)) + ")"
+		args = append(args, q.Category)
+		idx++
+	}
 	orderBy := "id"
 	if q.Sort == "name" || q.Sort == "quantity" || q.Sort == "min_stock" {
 		orderBy = q.Sort
@@ -49,38 +208,39 @@ func (r *Repository) GetProducts(ctx context.Context, q ProductsQuery) ([]Produc
 	if q.Order == "desc" {
 		order = "DESC"
 	}
-	limit := q.Limit
-	if limit < 1 || limit > 100 {
-		limit = 20
+	columns, from := productColumns, "products"
+	if q.AsOf != nil {
+		// Com as_of a quantidade vem do histórico e ficam de fora os produtos cadastrados depois do
+		// instante; os demais filtros e a ordenação continuam os mesmos
+		columns, from = historicalColumns, historicalProducts("$"+strconv.Itoa(idx))
+		where += " AND created_at <= $" + strconv.Itoa(idx)
+		args = append(args, *q.AsOf)
 	}
-	offset := (q.Page - 1) * limit
-	query := "SELECT id, name, barcode, quantity, min_stock FROM products WHERE 1=1" + where + " ORDER BY " + orderBy + " " + order + " LIMIT $" + strconv.Itoa(idx) + " OFFSET $" + strconv.Itoa(idx+1)
-	args = append(args, limit, offset)
-	rows, err := r.DB.Query(ctx, query, args...)
-	if err != nil {
-		return nil, 0, err
+	filters := len(args)
+	// O id desempata a ordenação, para que a paginação e a exportação sejam estáveis
+	query := "SELECT " + columns + " FROM " + from + " WHERE 1=1" + where + " ORDER BY " + orderBy + " " + order
+	if orderBy != "id" {
+		query += ", id"
 	}
-	defer rows.Close()
-	var products []Product
-	for rows.Next() {
-		var p Product
-		if err := rows.Scan(&p.ID, &p.Name, &p.Barcode, &p.Quantity, &p.MinStock); err != nil {
-			return nil, 0, err
+	return query, where, args, filters
+}
+
+// GetProduct devolve o produto pelo ID, ou nil se ele não existe.
+func (r *Repository) GetProduct(ctx context.Context, id int) (*Product, error) {
+	var p Product
+	err := scanProduct(r.DB.QueryRow(ctx, "SELECT "+productColumns+" FROM products WHERE id = $1", id), &p)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
 		}
-		products = append(products, p)
-	}
-	// Total count
-	total := 0
-	countQuery := "SELECT COUNT(*) FROM products WHERE 1=1" + where
-	if err := r.DB.QueryRow(ctx, countQuery, args[:idx-1]...).Scan(&total); err != nil {
-		return nil, 0, err
+		return nil, err
 	}
-	return products, total, nil
+	return &p, nil
 }
 
 func (r *Repository) GetProductByBarcode(ctx context.Context, barcode string) (*Product, error) {
 	var p Product
-	err := r.DB.QueryRow(ctx, `SELECT id, name, barcode, quantity, min_stock FROM products WHERE barcode=$1`, barcode).Scan(&p.ID, &p.Name, &p.Barcode, &p.Quantity, &p.MinStock)
+	err := scanProduct(r.DB.QueryRow(ctx, "SELECT "+productColumns+" FROM products WHERE "+ByCode("id", "$1"), barcode), &p)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, nil
@@ -90,13 +250,133 @@ func (r *Repository) GetProductByBarcode(ctx context.Context, barcode string) (*
 	return &p, nil
 }
 
+// categoryTree são os IDs da categoria param e de todas as suas descendentes. UNION descarta IDs já
+// visitados, então a recursão termina mesmo que um ciclo chegue a ser gravado.
+func categoryTree(param string) string {
+	return `WITH RECURSIVE tree AS (
+			SELECT id FROM categories WHERE id = ` + param + `
+			UNION
+			SELECT c.id FROM categories c JOIN tree ON c.parent_id = tree.id
+		) SELECT id FROM tree`
+}
+
+// categoryError traduz a chave estrangeira violada por uma categoria inexistente.
+func categoryError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" && pgErr.ConstraintName == "products_category_id_fkey" {
+		return ErrCategoryNotFound
+	}
+	return err
+}
+
+// stockAsOf é o saldo de cada produto e local no instante param: a fotografia diária mais recente
+// até o instante mais as movimentações posteriores a ela. Movimentações anteriores aos locais
+// pertencem ao local padrão.
+func stockAsOf(param string) string {
+	snapshot := "(SELECT MAX(taken_at) FROM stock_snapshot_runs WHERE taken_at <= " + param + "::timestamptz)"
+	return `(SELECT x.product_id, x.location_id, SUM(x.quantity) AS quantity FROM (
+			SELECT s.product_id, s.location_id, s.quantity FROM stock_snapshots s WHERE s.taken_at = ` + snapshot + `
+			UNION ALL
+			SELECT m.product_id, COALESCE(m.location_id, (SELECT id FROM locations WHERE is_default)), m.delta
+			FROM stock_movements m
+			WHERE m.created_at <= ` + param + `::timestamptz AND m.created_at > COALESCE(` + snapshot + `, '-infinity')
+		) x GROUP BY x.product_id, x.location_id)`
+}
+
+// historicalProducts expõe products com a quantidade do instante param. Reservas não têm histórico,
+// então available é igual à quantidade.
+func historicalProducts(param string) string {
+	return `(SELECT p.id, p.name, p.barcode, COALESCE(h.quantity, 0) AS quantity, p.min_stock, p.serialized,
+			p.negative_stock_policy, p.negative_stock_floor, p.price, p.costing_method, p.average_cost,
+			p.reorder_point, p.reorder_qty, p.max_stock, p.base_unit, p.internal_code, p.category_id, p.created_at
+		FROM products p LEFT JOIN (SELECT product_id, SUM(quantity) AS quantity FROM ` + stockAsOf(param) + ` t GROUP BY product_id) h
+		ON h.product_id = p.id) products`
+}
+
+const historicalColumns = "id, name, barcode, quantity, min_stock, serialized, quantity, negative_stock_policy, negative_stock_floor, price, costing_method, average_cost, reorder_point, reorder_qty, max_stock, base_unit, internal_code, category_id"
+
+// GetProductAsOf devolve o produto com a quantidade que tinha no instante asOf, ou nil se ele ainda
+// não estava cadastrado.
+func (r *Repository) GetProductAsOf(ctx context.Context, barcode string, asOf time.Time) (*Product, error) {
+	var p Product
+	query := "SELECT " + historicalColumns + " FROM " + historicalProducts("$2") + " WHERE " + ByCode("id", "$1") + " AND created_at <= $2"
+	if err := scanProduct(r.DB.QueryRow(ctx, query, barcode, asOf), &p); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+// UpdateProduct altera o cadastro do produto. A quantidade não é alterada aqui: correções de
+// estoque passam pelos ajustes (pacote adjustments), que registram motivo e aprovação, e uma
+// quantidade diferente do saldo é recusada com ErrQuantityChange.
 func (r *Repository) UpdateProduct(ctx context.Context, id int, p *Product) error {
-	cmd, err := r.DB.Exec(ctx, `UPDATE products SET name=$1, barcode=$2, quantity=$3, min_stock=$4 WHERE id=$5`, p.Name, p.Barcode, p.Quantity, p.MinStock, id)
+	tx, err := r.DB.Begin(ctx)
 	if err != nil {
 		return err
 	}
-	if cmd.RowsAffected() == 0 {
-		return errors.New("product not found")
+	defer tx.Rollback(ctx)
+	if err := updateProduct(ctx, tx, id, p); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// updateProduct altera o cadastro do produto; p.Quantity precisa ser o saldo atual, que não muda aqui.
+// Como o saldo, os custos e os fatores das embalagens estão na unidade base, ela só muda com o
+// produto sem estoque.
+func updateProduct(ctx context.Context, tx pgx.Tx, id int, p *Product) error {
+	var qty int
+	var serialized bool
+	var method, baseUnit string
+	query := `SELECT quantity, serialized, costing_method, base_unit FROM products WHERE id=$1 FOR UPDATE`
+	err := tx.QueryRow(ctx, query, id).Scan(&qty, &serialized, &method, &baseUnit)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errors.New("product not found")
+		}
+		return err
+	}
+	if p.Quantity != qty {
+		return ErrQuantityChange
+	}
+	if serialized != p.Serialized && qty != 0 {
+		return ErrSerializedChange
+	}
+	if p.BaseUnit != baseUnit && qty != 0 {
+		return ErrBaseUnitChange
+	}
+	// Um novo código principal passa a identificar o produto; o anterior continua valendo nas leituras
+	var owner int
+	err = tx.QueryRow(ctx, `SELECT product_id FROM product_identifiers WHERE code = $1`, p.Barcode).Scan(&owner)
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = insertIdentifier(ctx, tx, id, &Identifier{Code: p.Barcode, Kind: IdentifierBarcode})
+	} else if err == nil && owner != id {
+		err = ErrBarcodeInUse
+	}
+	if err != nil {
+		return err
+	}
+	query = `UPDATE products SET name=$1, barcode=$2, min_stock=$3, serialized=$4, negative_stock_policy=$5, negative_stock_floor=$6, price=$7, costing_method=$8,
+		reorder_point=$9, reorder_qty=$10, max_stock=$11, base_unit=$12, internal_code=$13, category_id=$14 WHERE id=$15`
+	_, err = tx.Exec(ctx, query, p.Name, p.Barcode, p.MinStock, p.Serialized, p.NegativeStockPolicy, p.NegativeStockFloor, p.Price, p.CostingMethod,
+		p.ReorderPoint, p.ReorderQty, p.MaxStock, p.BaseUnit, p.InternalCode, p.CategoryID, id)
+	if err != nil {
+		return categoryError(err)
+	}
+	// Ao trocar o método de custeio, o estoque atual vira uma única camada pelo custo médio
+	if method != p.CostingMethod {
+		if _, err := tx.Exec(ctx, `DELETE FROM cost_layers WHERE product_id = $1`, id); err != nil {
+			return err
+		}
+		if p.CostingMethod == CostingFIFO && qty > 0 {
+			query := `INSERT INTO cost_layers (product_id, unit_cost, remaining) SELECT id, average_cost, quantity FROM products WHERE id = $1`
+			if _, err := tx.Exec(ctx, query, id); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
@@ -104,42 +384,1273 @@ func (r *Repository) UpdateProduct(ctx context.Context, id int, p *Product) erro
 func (r *Repository) DeleteProduct(ctx context.Context, id int) error {
 	cmd, err := r.DB.Exec(ctx, `DELETE FROM products WHERE id=$1`, id)
 	if err != nil {
+		// O histórico de movimentações é permanente; pedidos também referenciam o produto
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return ErrProductInUse
+		}
 		return err
 	}
 	if cmd.RowsAffected() == 0 {
-		return errors.New("product not found")
+		return ErrProductNotFound
 	}
 	return nil
 }
 
-func (r *Repository) StockEntry(ctx context.Context, barcode string, qty int) error {
-	cmd, err := r.DB.Exec(ctx, `UPDATE products SET quantity = quantity + $1 WHERE barcode = $2`, qty, barcode)
+// StockEntry soma m.Delta ao estoque do local e grava a movimentação na mesma transação.
+func (r *Repository) StockEntry(ctx context.Context, barcode string, m *StockMovement) error {
+	return r.moveStock(ctx, barcode, m)
+}
+
+// StockExit aplica m.Delta (negativo) ao estoque do local e grava a movimentação na mesma transação.
+func (r *Repository) StockExit(ctx context.Context, barcode string, m *StockMovement) error {
+	return r.moveStock(ctx, barcode, m)
+}
+
+func (r *Repository) moveStock(ctx context.Context, barcode string, m *StockMovement) error {
+	tx, err := r.DB.Begin(ctx)
 	if err != nil {
 		return err
 	}
-	if cmd.RowsAffected() == 0 {
-		return errors.New("product not found")
+	defer tx.Rollback(ctx)
+	var serialized bool
+	if err := tx.QueryRow(ctx, `SELECT id, serialized FROM products WHERE `+ByCode("id", "$1")+` FOR UPDATE`, barcode).Scan(&m.ProductID, &serialized); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			if m.Delta < 0 {
+				return ErrInsufficientStock
+			}
+			return ErrProductNotFound
+		}
+		return err
+	}
+	// Pela API as unidades que entram ou saem precisam ser nomeadas
+	if serialized && len(m.Serials) == 0 {
+		return ErrSerialsRequired
+	}
+	if err := ApplyMovement(ctx, tx, m); err != nil {
+		return err
+	}
+	if err := CheckHeldStock(ctx, tx, m); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// CheckHeldStock recusa a saída m, já aplicada em tx por ApplyMovement, quando ela consome unidades
+// reservadas ou alocadas a pedidos de venda, que não podem sair por uma saída avulsa. As reservas
+// valem para o total do produto; as alocações, para o local onde foram feitas, então a saída no
+// local alocado é recusada mesmo com estoque livre em outros locais. A política de estoque negativo
+// só vale para o estoque livre: mesmo com allow ou floor, o saldo não pode ficar abaixo do que está
+// comprometido. Os saldos são relidos em tx, então composições de várias movimentações (como as
+// transferências) podem verificar a saída depois de aplicar todas elas.
+func CheckHeldStock(ctx context.Context, tx pgx.Tx, m *StockMovement) error {
+	if m.Delta >= 0 {
+		return nil
+	}
+	var quantity, held, level, allocated int
+	query := `SELECT quantity, ` + heldStock + `,
+			COALESCE((SELECT s.quantity FROM stock_levels s WHERE s.product_id = products.id AND s.location_id = $2), 0),
+			(SELECT COALESCE(SUM(a.quantity), 0) FROM sales_allocations a WHERE a.product_id = products.id AND a.location_id = $2)
+		FROM products WHERE id = $1`
+	if err := tx.QueryRow(ctx, query, m.ProductID, m.LocationID).Scan(&quantity, &held, &level, &allocated); err != nil {
+		return err
+	}
+	if (held > 0 && quantity < held) || (allocated > 0 && level < allocated) {
+		return ErrReservedStock
 	}
 	return nil
 }
 
-func (r *Repository) StockExit(ctx context.Context, barcode string, qty int) error {
-	cmd, err := r.DB.Exec(ctx, `UPDATE products SET quantity = quantity - $1 WHERE barcode = $2 AND quantity >= $1`, qty, barcode)
+// Available bloqueia o produto até o fim de tx e devolve a quantidade disponível: o saldo menos
+// reservas e alocações de pedidos de venda.
+func Available(ctx context.Context, tx pgx.Tx, productID int) (int, error) {
+	var available int
+	err := tx.QueryRow(ctx, "SELECT quantity - "+heldStock+" FROM products WHERE id = $1 FOR UPDATE", productID).Scan(&available)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrProductNotFound
+	}
+	return available, err
+}
+
+// ApplyMovement aplica m.Delta ao total do produto m.ProductID e ao nível do local (o padrão
+// quando m.LocationID é 0) e grava a movimentação, tudo dentro de tx. As linhas do produto e do
+// nível ficam bloqueadas até o fim da transação, e m.Balance e m.LocationBalance são os saldos
+// devolvidos pelas próprias atualizações. Uma saída só deixa o local abaixo de zero se a
+// política de estoque negativo do produto permitir. Em entradas, m.Lots indica os lotes recebidos; em saídas, os lotes
+// nomeados são consumidos primeiro e o restante segue FEFO, e m.Lots passa a conter os lotes
+// efetivamente baixados. Para produtos serializados, m.Serials lista as unidades movimentadas
+// (em saídas sem série, as mais antigas do local são escolhidas) e os saldos são recalculados
+// pela contagem de séries em estoque. Outros pacotes usam esta função para compor várias
+// movimentações em uma única transação. Locais de trânsito e quarentena só aceitam movimentações
+// com m.System.
+func ApplyMovement(ctx context.Context, tx pgx.Tx, m *StockMovement) error {
+	var kind string
+	err := tx.QueryRow(ctx, `SELECT id, code, name, kind FROM locations WHERE id = $1 OR ($1 = 0 AND is_default)`, m.LocationID).
+		Scan(&m.LocationID, &m.limits.locationCode, &m.limits.locationName, &kind)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrLocationNotFound
+		}
 		return err
 	}
-	if cmd.RowsAffected() == 0 {
-		return errors.New("Insufficient stock or product not found")
+	if kind != "storage" && !m.System {
+		return ErrSystemLocation
+	}
+	var serialized bool
+	query := `SELECT name, min_stock, reorder_point, serialized, negative_stock_policy, negative_stock_floor FROM products WHERE id = $1 FOR UPDATE`
+	err = tx.QueryRow(ctx, query, m.ProductID).Scan(&m.limits.productName, &m.limits.minStock, &m.limits.reorderPoint, &serialized,
+		&m.limits.policy, &m.limits.floor)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrProductNotFound
+		}
+		return err
+	}
+	// O nível do local ainda pode não existir; nesse caso o saldo atual é zero
+	levelQty := 0
+	query = `SELECT quantity, min_stock FROM stock_levels WHERE product_id = $1 AND location_id = $2 FOR UPDATE`
+	err = tx.QueryRow(ctx, query, m.ProductID, m.LocationID).Scan(&levelQty, &m.limits.locationMinStock)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	if serialized {
+		if err := applySerials(ctx, tx, m); err != nil {
+			return err
+		}
+		if err := countSerialBalances(ctx, tx, m); err != nil {
+			return err
+		}
+	} else {
+		if len(m.Serials) > 0 {
+			return ErrNotSerialized
+		}
+		if m.Delta < 0 && !m.limits.allows(levelQty+m.Delta) {
+			return ErrInsufficientStock
+		}
+		err = tx.QueryRow(ctx, `UPDATE products SET quantity = quantity + $1 WHERE id = $2 RETURNING quantity`, m.Delta, m.ProductID).Scan(&m.Balance)
+		if err != nil {
+			return err
+		}
+		query := `INSERT INTO stock_levels (product_id, location_id, quantity) VALUES ($1, $2, $3)
+			ON CONFLICT (product_id, location_id) DO UPDATE SET quantity = stock_levels.quantity + EXCLUDED.quantity
+			RETURNING quantity`
+		if err := tx.QueryRow(ctx, query, m.ProductID, m.LocationID, m.Delta).Scan(&m.LocationBalance); err != nil {
+			return err
+		}
+	}
+	if err := applyLots(ctx, tx, m); err != nil {
+		return err
+	}
+	if err := applyCost(ctx, tx, m); err != nil {
+		return err
+	}
+	return insertMovement(ctx, tx, m)
+}
+
+func applySerials(ctx context.Context, tx pgx.Tx, m *StockMovement) error {
+	count := m.Delta
+	if count < 0 {
+		count = -count
+	}
+	if m.Delta < 0 && len(m.Serials) == 0 {
+		// Movimentações internas (como transferências) levam as unidades há mais tempo no local
+		rows, err := tx.Query(ctx, `SELECT serial FROM serial_numbers
+			WHERE product_id = $1 AND location_id = $2 AND status = 'in_stock'
+			ORDER BY updated_at, id LIMIT $3 FOR UPDATE`, m.ProductID, m.LocationID, count)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var serial string
+			if err := rows.Scan(&serial); err != nil {
+				rows.Close()
+				return err
+			}
+			m.Serials = append(m.Serials, serial)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(m.Serials) < count {
+			return ErrInsufficientStock
+		}
+	}
+	if len(m.Serials) == 0 {
+		return ErrSerialsRequired
+	}
+	if len(m.Serials) != count {
+		return ErrSerialCount
+	}
+	if m.Delta > 0 {
+		// Uma série que já saiu pode voltar (devolução), mas nunca duplicar uma unidade em estoque
+		query := `INSERT INTO serial_numbers (product_id, serial, location_id, status) VALUES ($1, $2, $3, 'in_stock')
+			ON CONFLICT (serial) DO UPDATE SET location_id = EXCLUDED.location_id, status = 'in_stock', updated_at = NOW()
+			WHERE serial_numbers.status <> 'in_stock' AND serial_numbers.product_id = EXCLUDED.product_id
+			RETURNING id`
+		for _, serial := range m.Serials {
+			var id int
+			if err := tx.QueryRow(ctx, query, m.ProductID, serial, m.LocationID).Scan(&id); err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					return ErrSerialConflict
+				}
+				return err
+			}
+		}
+		return nil
+	}
+	cmd, err := tx.Exec(ctx, `UPDATE serial_numbers SET status = 'out', updated_at = NOW()
+		WHERE serial = ANY($1) AND product_id = $2 AND location_id = $3 AND status = 'in_stock'`, m.Serials, m.ProductID, m.LocationID)
+	if err != nil {
+		return err
+	}
+	if int(cmd.RowsAffected()) != len(m.Serials) {
+		return ErrSerialNotInStock
+	}
+	return nil
+}
+
+// countSerialBalances grava como saldo do produto e do local a contagem de séries em estoque.
+func countSerialBalances(ctx context.Context, tx pgx.Tx, m *StockMovement) error {
+	query := `UPDATE products SET quantity = (
+			SELECT COUNT(*) FROM serial_numbers WHERE product_id = $1 AND status = 'in_stock'
+		) WHERE id = $1 RETURNING quantity`
+	if err := tx.QueryRow(ctx, query, m.ProductID).Scan(&m.Balance); err != nil {
+		return err
+	}
+	query = `INSERT INTO stock_levels (product_id, location_id, quantity) VALUES ($1, $2, (
+			SELECT COUNT(*) FROM serial_numbers WHERE product_id = $1 AND location_id = $2 AND status = 'in_stock'
+		)) ON CONFLICT (product_id, location_id) DO UPDATE SET quantity = EXCLUDED.quantity
+		RETURNING quantity`
+	return tx.QueryRow(ctx, query, m.ProductID, m.LocationID).Scan(&m.LocationBalance)
+}
+
+func applyLots(ctx context.Context, tx pgx.Tx, m *StockMovement) error {
+	if m.Delta > 0 {
+		total := 0
+		for i := range m.Lots {
+			l := &m.Lots[i]
+			total += l.Quantity
+			query := `INSERT INTO lots (product_id, location_id, lot_number, expiry_date, quantity) VALUES ($1, $2, $3, $4, $5)
+				ON CONFLICT (product_id, location_id, lot_number) DO UPDATE
+				SET quantity = lots.quantity + EXCLUDED.quantity, expiry_date = COALESCE(EXCLUDED.expiry_date, lots.expiry_date)
+				RETURNING id, expiry_date`
+			if err := tx.QueryRow(ctx, query, m.ProductID, m.LocationID, l.LotNumber, l.ExpiryDate, l.Quantity).Scan(&l.LotID, &l.ExpiryDate); err != nil {
+				return err
+			}
+		}
+		if total > m.Delta {
+			return ErrLotQuantity
+		}
+		return nil
+	}
+	remaining := -m.Delta
+	named := m.Lots
+	m.Lots = nil
+	for _, l := range named {
+		if l.Quantity > remaining {
+			return ErrLotQuantity
+		}
+		query := `UPDATE lots SET quantity = quantity - $1
+			WHERE product_id = $2 AND location_id = $3 AND lot_number = $4 AND quantity >= $1
+			RETURNING id, expiry_date`
+		if err := tx.QueryRow(ctx, query, l.Quantity, m.ProductID, m.LocationID, l.LotNumber).Scan(&l.LotID, &l.ExpiryDate); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrLotNotFound
+			}
+			return err
+		}
+		m.Lots = append(m.Lots, l)
+		remaining -= l.Quantity
+	}
+	if remaining == 0 {
+		return nil
 	}
+	// FEFO: o lote que vence primeiro sai primeiro; lotes sem validade ficam por último
+	rows, err := tx.Query(ctx, `SELECT id, lot_number, expiry_date, quantity FROM lots
+		WHERE product_id = $1 AND location_id = $2 AND quantity > 0
+		ORDER BY expiry_date NULLS LAST, id FOR UPDATE`, m.ProductID, m.LocationID)
+	if err != nil {
+		return err
+	}
+	var available []LotQuantity
+	for rows.Next() {
+		var l LotQuantity
+		if err := rows.Scan(&l.LotID, &l.LotNumber, &l.ExpiryDate, &l.Quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		available = append(available, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, l := range available {
+		if remaining == 0 {
+			break
+		}
+		if l.Quantity > remaining {
+			l.Quantity = remaining
+		}
+		if _, err := tx.Exec(ctx, `UPDATE lots SET quantity = quantity - $1 WHERE id = $2`, l.Quantity, l.LotID); err != nil {
+			return err
+		}
+		m.Lots = append(m.Lots, l)
+		remaining -= l.Quantity
+	}
+	// O que sobrar sai do estoque sem lote, que só fica negativo se a política do produto permitir
 	return nil
 }
 
+// applyCost valora a movimentação pelo método de custeio do produto e atualiza o custo médio.
+// Entradas sem custo informado usam o custo médio atual; saídas custam o médio (average) ou as
+// camadas mais antigas (fifo). Transferências só mudam o estoque de lugar e não têm valor.
+func applyCost(ctx context.Context, tx pgx.Tx, m *StockMovement) error {
+	if m.Reason == ReasonTransfer || m.Delta == 0 {
+		return nil
+	}
+	var method string
+	var average float64
+	if err := tx.QueryRow(ctx, `SELECT costing_method, average_cost FROM products WHERE id = $1`, m.ProductID).Scan(&method, &average); err != nil {
+		return err
+	}
+	if m.Delta > 0 {
+		unitCost := average
+		if m.UnitCost != nil {
+			unitCost = *m.UnitCost
+		}
+		m.UnitCost = &unitCost
+		m.Value = float64(m.Delta) * unitCost
+		average = movingAverage(m.Balance-m.Delta, average, m.Delta, unitCost)
+		if method == CostingFIFO {
+			query := `INSERT INTO cost_layers (product_id, unit_cost, remaining) VALUES ($1, $2, $3)`
+			if _, err := tx.Exec(ctx, query, m.ProductID, unitCost, m.Delta); err != nil {
+				return err
+			}
+		}
+	} else {
+		cost := float64(-m.Delta) * average
+		if method == CostingFIFO {
+			var err error
+			if cost, err = consumeLayers(ctx, tx, m.ProductID, -m.Delta, average); err != nil {
+				return err
+			}
+		}
+		unitCost := cost / float64(-m.Delta)
+		m.UnitCost = &unitCost
+		m.Value = -cost
+	}
+	if method == CostingFIFO {
+		// No FIFO o custo médio é o das camadas que sobraram
+		query := `SELECT COALESCE(SUM(remaining * unit_cost) / NULLIF(SUM(remaining), 0), $2) FROM cost_layers WHERE product_id = $1`
+		if err := tx.QueryRow(ctx, query, m.ProductID, average).Scan(&average); err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec(ctx, `UPDATE products SET average_cost = $1 WHERE id = $2`, average, m.ProductID)
+	return err
+}
+
+// movingAverage devolve o custo médio após a entrada de in unidades a cost sobre qty unidades a
+// average. Sem saldo positivo antes da entrada, o médio passa a ser o custo da entrada.
+func movingAverage(qty int, average float64, in int, cost float64) float64 {
+	if qty <= 0 {
+		return cost
+	}
+	return (float64(qty)*average + float64(in)*cost) / float64(qty+in)
+}
+
+type costLayer struct {
+	id        int
+	unitCost  float64
+	remaining int
+}
+
+// consumeLayers baixa quantity das camadas de custo mais antigas do produto e devolve o custo total.
+func consumeLayers(ctx context.Context, tx pgx.Tx, productID, quantity int, fallback float64) (float64, error) {
+	rows, err := tx.Query(ctx, `SELECT id, unit_cost, remaining FROM cost_layers WHERE product_id = $1 AND remaining > 0 ORDER BY id FOR UPDATE`, productID)
+	if err != nil {
+		return 0, err
+	}
+	var layers []costLayer
+	for rows.Next() {
+		var l costLayer
+		if err := rows.Scan(&l.id, &l.unitCost, &l.remaining); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		layers = append(layers, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	cost, taken := fifoCost(layers, quantity, fallback)
+	for i, qty := range taken {
+		if _, err := tx.Exec(ctx, `UPDATE cost_layers SET remaining = remaining - $1 WHERE id = $2`, qty, layers[i].id); err != nil {
+			return 0, err
+		}
+	}
+	return cost, nil
+}
+
+// fifoCost custeia quantity unidades pelas camadas na ordem dada e devolve o custo e quanto sai de
+// cada camada. O que faltar nas camadas (estoque negativo ou anterior ao custeio) custa fallback.
+func fifoCost(layers []costLayer, quantity int, fallback float64) (float64, []int) {
+	cost := 0.0
+	taken := []int{}
+	for _, l := range layers {
+		if quantity == 0 {
+			break
+		}
+		qty := l.remaining
+		if qty > quantity {
+			qty = quantity
+		}
+		cost += float64(qty) * l.unitCost
+		taken = append(taken, qty)
+		quantity -= qty
+	}
+	return cost + float64(quantity)*fallback, taken
+}
+
+func insertMovement(ctx context.Context, tx pgx.Tx, m *StockMovement) error {
+	if userID, ok := internal.UserIDFromContext(ctx); ok && m.UserID == nil {
+		m.UserID = &userID
+	}
+	query := `INSERT INTO stock_movements (product_id, location_id, delta, balance, location_balance, user_id, reason, reference, unit_cost, value)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id, created_at`
+	if err := tx.QueryRow(ctx, query, m.ProductID, m.LocationID, m.Delta, m.Balance, m.LocationBalance, m.UserID, m.Reason, m.Reference, m.UnitCost, m.Value).Scan(&m.ID, &m.CreatedAt); err != nil {
+		return err
+	}
+	for _, l := range m.Lots {
+		if _, err := tx.Exec(ctx, `INSERT INTO stock_movement_lots (movement_id, lot_id, quantity) VALUES ($1, $2, $3)`, m.ID, l.LotID, l.Quantity); err != nil {
+			return err
+		}
+	}
+	if len(m.Serials) > 0 {
+		query := `INSERT INTO stock_movement_serials (movement_id, serial_id) SELECT $1, id FROM serial_numbers WHERE serial = ANY($2)`
+		if _, err := tx.Exec(ctx, query, m.ID, m.Serials); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const movementColumns = "m.id, m.product_id, COALESCE(m.location_id, 0), m.delta, m.balance, COALESCE(m.location_balance, m.balance), m.user_id, m.reason, m.reference, m.created_at, m.unit_cost, m.value"
+
+func (r *Repository) queryMovements(ctx context.Context, query string, args ...interface{}) ([]StockMovement, error) {
+	rows, err := r.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	movements := []StockMovement{}
+	ids := []int{}
+	for rows.Next() {
+		var m StockMovement
+		if err := rows.Scan(&m.ID, &m.ProductID, &m.LocationID, &m.Delta, &m.Balance, &m.LocationBalance, &m.UserID, &m.Reason, &m.Reference, &m.CreatedAt, &m.UnitCost, &m.Value); err != nil {
+			return nil, err
+		}
+		movements = append(movements, m)
+		ids = append(ids, m.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	if err := r.loadMovementLots(ctx, ids, movements); err != nil {
+		return nil, err
+	}
+	if err := r.loadMovementSerials(ctx, ids, movements); err != nil {
+		return nil, err
+	}
+	return movements, nil
+}
+
+func (r *Repository) GetMovements(ctx context.Context, q MovementsQuery) ([]StockMovement, int, error) {
+	args := []interface{}{q.ProductID}
+	where := " WHERE product_id = $1"
+	idx := 2
+	if q.From != nil {
+		where += " AND created_at >= $" + strconv.Itoa(idx)
+		args = append(args, *q.From)
+		idx++
+	}
+	if q.To != nil {
+		where += " AND created_at <= $" + strconv.Itoa(idx)
+		args = append(args, *q.To)
+		idx++
+	}
+	limit := q.Limit
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	page := q.Page
+	if page < 1 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+	query := "SELECT " + movementColumns + " FROM stock_movements m" + where + " ORDER BY created_at DESC, id DESC LIMIT $" + strconv.Itoa(idx) + " OFFSET $" + strconv.Itoa(idx+1)
+	movements, err := r.queryMovements(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	total := 0
+	if err := r.DB.QueryRow(ctx, "SELECT COUNT(*) FROM stock_movements"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	return movements, total, nil
+}
+
+// ExportMovements passa a fn, em ordem cronológica, as movimentações do período de um produto, ou de
+// todos quando q.ProductID é zero, sem lotes nem números de série.
+func (r *Repository) ExportMovements(ctx context.Context, q MovementsQuery, fn func(*StockMovement) error) error {
+	args := []interface{}{}
+	where := " WHERE 1=1"
+	if q.ProductID != 0 {
+		args = append(args, q.ProductID)
+		where += " AND product_id = $" + strconv.Itoa(len(args))
+	}
+	if q.From != nil {
+		args = append(args, *q.From)
+		where += " AND created_at >= $" + strconv.Itoa(len(args))
+	}
+	if q.To != nil {
+		args = append(args, *q.To)
+		where += " AND created_at <= $" + strconv.Itoa(len(args))
+	}
+	rows, err := r.DB.Query(ctx, "SELECT "+movementColumns+" FROM stock_movements m"+where+" ORDER BY created_at, id", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m StockMovement
+		if err := rows.Scan(&m.ID, &m.ProductID, &m.LocationID, &m.Delta, &m.Balance, &m.LocationBalance, &m.UserID, &m.Reason, &m.Reference, &m.CreatedAt, &m.UnitCost, &m.Value); err != nil {
+			return err
+		}
+		if err := fn(&m); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *Repository) loadMovementLots(ctx context.Context, ids []int, movements []StockMovement) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	query := `SELECT ml.movement_id, l.id, l.lot_number, l.expiry_date, ml.quantity
+		FROM stock_movement_lots ml JOIN lots l ON l.id = ml.lot_id
+		WHERE ml.movement_id = ANY($1) ORDER BY l.expiry_date NULLS LAST, l.id`
+	rows, err := r.DB.Query(ctx, query, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	index := make(map[int]int, len(movements))
+	for i, m := range movements {
+		index[m.ID] = i
+	}
+	for rows.Next() {
+		var movementID int
+		var l LotQuantity
+		if err := rows.Scan(&movementID, &l.LotID, &l.LotNumber, &l.ExpiryDate, &l.Quantity); err != nil {
+			return err
+		}
+		m := &movements[index[movementID]]
+		m.Lots = append(m.Lots, l)
+	}
+	return rows.Err()
+}
+
+func (r *Repository) loadMovementSerials(ctx context.Context, ids []int, movements []StockMovement) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	query := `SELECT ms.movement_id, s.serial FROM stock_movement_serials ms JOIN serial_numbers s ON s.id = ms.serial_id
+		WHERE ms.movement_id = ANY($1) ORDER BY s.serial`
+	rows, err := r.DB.Query(ctx, query, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	index := make(map[int]int, len(movements))
+	for i, m := range movements {
+		index[m.ID] = i
+	}
+	for rows.Next() {
+		var movementID int
+		var serial string
+		if err := rows.Scan(&movementID, &serial); err != nil {
+			return err
+		}
+		m := &movements[index[movementID]]
+		m.Serials = append(m.Serials, serial)
+	}
+	return rows.Err()
+}
+
+// GetSerial busca um número de série com seu histórico completo de movimentações.
+func (r *Repository) GetSerial(ctx context.Context, serial string) (*SerialNumber, error) {
+	var sn SerialNumber
+	query := `SELECT s.id, s.serial, s.product_id, p.barcode, p.name, s.location_id, l.code, s.status, s.created_at, s.updated_at
+		FROM serial_numbers s JOIN products p ON p.id = s.product_id JOIN locations l ON l.id = s.location_id
+		WHERE s.serial = $1`
+	err := r.DB.QueryRow(ctx, query, serial).Scan(&sn.ID, &sn.Serial, &sn.ProductID, &sn.Barcode, &sn.ProductName, &sn.LocationID, &sn.LocationCode, &sn.Status, &sn.CreatedAt, &sn.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	query = "SELECT " + movementColumns + ` FROM stock_movements m JOIN stock_movement_serials ms ON ms.movement_id = m.id
+		WHERE ms.serial_id = $1 ORDER BY m.created_at, m.id`
+	sn.History, err = r.queryMovements(ctx, query, sn.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &sn, nil
+}
+
+func (r *Repository) GetStockLevels(ctx context.Context, productID int) ([]StockLevel, error) {
+	query := `SELECT l.id, l.code, l.name, s.quantity, s.min_stock
+		FROM stock_levels s JOIN locations l ON l.id = s.location_id
+		WHERE s.product_id = $1 ORDER BY l.id`
+	rows, err := r.DB.Query(ctx, query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	levels := []StockLevel{}
+	for rows.Next() {
+		var l StockLevel
+		if err := rows.Scan(&l.LocationID, &l.LocationCode, &l.LocationName, &l.Quantity, &l.MinStock); err != nil {
+			return nil, err
+		}
+		levels = append(levels, l)
+	}
+	return levels, rows.Err()
+}
+
+// GetStockLevelsAsOf devolve o saldo do produto por local no instante asOf; o estoque mínimo é o atual.
+func (r *Repository) GetStockLevelsAsOf(ctx context.Context, productID int, asOf time.Time) ([]StockLevel, error) {
+	query := `SELECT l.id, l.code, l.name, h.quantity, COALESCE(s.min_stock, 0)
+		FROM ` + stockAsOf("$2") + ` h JOIN locations l ON l.id = h.location_id
+		LEFT JOIN stock_levels s ON s.product_id = h.product_id AND s.location_id = h.location_id
+		WHERE h.product_id = $1 AND h.quantity <> 0 ORDER BY l.id`
+	rows, err := r.DB.Query(ctx, query, productID, asOf)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	levels := []StockLevel{}
+	for rows.Next() {
+		var l StockLevel
+		if err := rows.Scan(&l.LocationID, &l.LocationCode, &l.LocationName, &l.Quantity, &l.MinStock); err != nil {
+			return nil, err
+		}
+		levels = append(levels, l)
+	}
+	return levels, rows.Err()
+}
+
+// TakeSnapshots grava a fotografia diária do estoque para cada fim de dia (meia-noite UTC) ainda
+// sem fotografia até until, partindo da anterior e somando as movimentações do dia. Sem nenhuma
+// fotografia anterior, só o último dia é gravado, a partir do histórico completo.
+func (r *Repository) TakeSnapshots(ctx context.Context, until time.Time) (int, error) {
+	var last *time.Time
+	if err := r.DB.QueryRow(ctx, `SELECT MAX(taken_at) FROM stock_snapshot_runs`).Scan(&last); err != nil {
+		return 0, err
+	}
+	next := until
+	if last != nil {
+		next = last.Add(24 * time.Hour)
+	}
+	taken := 0
+	for ; !next.After(until); next = next.Add(24 * time.Hour) {
+		if err := r.takeSnapshot(ctx, next); err != nil {
+			return taken, err
+		}
+		taken++
+	}
+	return taken, nil
+}
+
+func (r *Repository) takeSnapshot(ctx context.Context, at time.Time) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	// A fotografia é calculada antes de registrar a execução, senão stockAsOf partiria dela mesma
+	query := `INSERT INTO stock_snapshots (taken_at, product_id, location_id, quantity)
+		SELECT $1, product_id, location_id, quantity FROM ` + stockAsOf("$1") + ` h
+		WHERE quantity <> 0 ON CONFLICT DO NOTHING`
+	if _, err := tx.Exec(ctx, query, at); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO stock_snapshot_runs (taken_at) VALUES ($1) ON CONFLICT DO NOTHING`, at); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *Repository) SetLocationMinStock(ctx context.Context, productID, locationID, minStock int) error {
+	query := `INSERT INTO stock_levels (product_id, location_id, min_stock) VALUES ($1, $2, $3)
+		ON CONFLICT (product_id, location_id) DO UPDATE SET min_stock = EXCLUDED.min_stock`
+	_, err := r.DB.Exec(ctx, query, productID, locationID, minStock)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return ErrLocationNotFound
+	}
+	return err
+}
+
+const lotColumns = `l.id, l.product_id, p.barcode, p.name, l.location_id, loc.code, l.lot_number, l.expiry_date, l.quantity, l.created_at, l.expiry_notified_at`
+
+func (r *Repository) queryLots(ctx context.Context, where string, args ...interface{}) ([]Lot, error) {
+	query := "SELECT " + lotColumns + ` FROM lots l
+		JOIN products p ON p.id = l.product_id
+		JOIN locations loc ON loc.id = l.location_id
+		WHERE l.quantity > 0` + where + " ORDER BY l.expiry_date NULLS LAST, l.id"
+	rows, err := r.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	lots := []Lot{}
+	for rows.Next() {
+		var l Lot
+		if err := rows.Scan(&l.ID, &l.ProductID, &l.Barcode, &l.ProductName, &l.LocationID, &l.LocationCode, &l.LotNumber, &l.ExpiryDate, &l.Quantity, &l.CreatedAt, &l.NotifiedAt); err != nil {
+			return nil, err
+		}
+		lots = append(lots, l)
+	}
+	return lots, rows.Err()
+}
+
+// GetLots lista os lotes com saldo de um produto, na ordem em que seriam consumidos.
+func (r *Repository) GetLots(ctx context.Context, productID int) ([]Lot, error) {
+	return r.queryLots(ctx, " AND l.product_id = $1", productID)
+}
+
+// GetExpiringLots lista os lotes com saldo que vencem até a data informada, incluindo os já vencidos.
+func (r *Repository) GetExpiringLots(ctx context.Context, until time.Time) ([]Lot, error) {
+	return r.queryLots(ctx, " AND l.expiry_date <= $1", until)
+}
+
+func (r *Repository) MarkLotsNotified(ctx context.Context, ids []int) error {
+	_, err := r.DB.Exec(ctx, `UPDATE lots SET expiry_notified_at = NOW() WHERE id = ANY($1)`, ids)
+	return err
+}
+
+const reservationColumns = "r.id, r.product_id, p.barcode, r.quantity, r.status, r.reference, r.expires_at, r.created_by, r.created_at, r.closed_at"
+
+func scanReservation(row pgx.Row, res *Reservation) error {
+	return row.Scan(&res.ID, &res.ProductID, &res.Barcode, &res.Quantity, &res.Status, &res.Reference, &res.ExpiresAt, &res.CreatedBy, &res.CreatedAt, &res.ClosedAt)
+}
+
+// CreateReservation reserva res.Quantity do produto res.Barcode se houver quantidade disponível.
+// A linha do produto fica bloqueada para que reservas simultâneas não ultrapassem o estoque.
+func (r *Repository) CreateReservation(ctx context.Context, res *Reservation) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	var available int
+	err = tx.QueryRow(ctx, "SELECT id, barcode, quantity - "+heldStock+" FROM products WHERE "+ByCode("id", "$1")+" FOR UPDATE", res.Barcode).
+		Scan(&res.ProductID, &res.Barcode, &available)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrProductNotFound
+		}
+		return err
+	}
+	if available < res.Quantity {
+		return ErrReservedStock
+	}
+	query := `INSERT INTO reservations (product_id, quantity, reference, expires_at, created_by) VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, status, created_at`
+	if err := tx.QueryRow(ctx, query, res.ProductID, res.Quantity, res.Reference, res.ExpiresAt, res.CreatedBy).Scan(&res.ID, &res.Status, &res.CreatedAt); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// GetReservations lista as reservas ativas de um produto, das que vencem primeiro para as últimas.
+func (r *Repository) GetReservations(ctx context.Context, productID int) ([]Reservation, error) {
+	query := "SELECT " + reservationColumns + ` FROM reservations r JOIN products p ON p.id = r.product_id
+		WHERE r.product_id = $1 AND r.status = 'active' AND r.expires_at > NOW() ORDER BY r.expires_at, r.id`
+	rows, err := r.DB.Query(ctx, query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	reservations := []Reservation{}
+	for rows.Next() {
+		var res Reservation
+		if err := scanReservation(rows, &res); err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, res)
+	}
+	return reservations, rows.Err()
+}
+
+// CloseReservation encerra uma reserva ativa com o status informado. Ao confirmar (committed) a
+// quantidade reservada sai do estoque do local, na mesma transação, e a movimentação é devolvida.
+func (r *Repository) CloseReservation(ctx context.Context, id int, status string, locationID int) (*Reservation, *StockMovement, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+	var res Reservation
+	query := "SELECT " + reservationColumns + " FROM reservations r JOIN products p ON p.id = r.product_id WHERE r.id = $1 FOR UPDATE OF r"
+	if err := scanReservation(tx.QueryRow(ctx, query, id), &res); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, ErrReservationNotFound
+		}
+		return nil, nil, err
+	}
+	if res.Status != ReservationActive || !res.ExpiresAt.After(time.Now()) {
+		return nil, nil, ErrReservationClosed
+	}
+	err = tx.QueryRow(ctx, `UPDATE reservations SET status = $1, closed_at = NOW() WHERE id = $2 RETURNING status, closed_at`, status, id).Scan(&res.Status, &res.ClosedAt)
+	if err != nil {
+		return nil, nil, err
+	}
+	var m *StockMovement
+	if status == ReservationCommitted {
+		m = &StockMovement{
+			ProductID:  res.ProductID,
+			LocationID: locationID,
+			Delta:      -res.Quantity,
+			Reason:     ReasonReservation,
+			Reference:  "reservation #" + strconv.Itoa(res.ID),
+		}
+		if err := ApplyMovement(ctx, tx, m); err != nil {
+			return nil, nil, err
+		}
+		// A reserva já está fechada, então só as demais reservas e as alocações do local contam
+		if err := CheckHeldStock(ctx, tx, m); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, err
+	}
+	return &res, m, nil
+}
+
+// ExpireReservations marca como expiradas as reservas ativas vencidas e devolve quantas foram liberadas.
+func (r *Repository) ExpireReservations(ctx context.Context) (int64, error) {
+	cmd, err := r.DB.Exec(ctx, `UPDATE reservations SET status = 'expired', closed_at = NOW() WHERE status = 'active' AND expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+// GetLeadTime devolve o prazo de entrega, em dias, do fornecedor que a reposição escolheria para o
+// produto (o preferido ou, na falta dele, o de menor custo); zero sem fornecedor vinculado.
+func (r *Repository) GetLeadTime(ctx context.Context, productID int) (int, error) {
+	query := `SELECT lead_time_days FROM supplier_products WHERE product_id = $1
+		ORDER BY preferred DESC, unit_cost, supplier_id LIMIT 1`
+	var days int
+	if err := r.DB.QueryRow(ctx, query, productID).Scan(&days); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, err
+	}
+	return days, nil
+}
+
+// GetDailyDemand soma por dia (UTC) as saídas de consumo do produto entre from (inclusive) e to, e
+// devolve também a data da primeira movimentação do produto, nula se ele nunca movimentou.
+func (r *Repository) GetDailyDemand(ctx context.Context, productID int, from, to time.Time) ([]DailyDemand, *time.Time, error) {
+	var first *time.Time
+	if err := r.DB.QueryRow(ctx, `SELECT MIN(created_at) FROM stock_movements WHERE product_id = $1`, productID).Scan(&first); err != nil {
+		return nil, nil, err
+	}
+	query := `SELECT (created_at AT TIME ZONE 'UTC')::date, SUM(-delta) FROM stock_movements
+		WHERE product_id = $1 AND delta < 0 AND reason <> ALL($2) AND created_at >= $3 AND created_at < $4
+		GROUP BY 1 ORDER BY 1`
+	rows, err := r.DB.Query(ctx, query, productID, NonDemandReasons, from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	days := []DailyDemand{}
+	for rows.Next() {
+		var d DailyDemand
+		if err := rows.Scan(&d.Date, &d.Quantity); err != nil {
+			return nil, nil, err
+		}
+		days = append(days, d)
+	}
+	return days, first, rows.Err()
+}
+
+// checkPackBarcode recusa como identificador de produto um código que já identifica uma embalagem.
+func checkPackBarcode(ctx context.Context, tx pgx.Tx, barcode string) error {
+	var used bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM product_units WHERE barcode = $1)`, barcode).Scan(&used); err != nil {
+		return err
+	}
+	if used {
+		return ErrBarcodeInUse
+	}
+	return nil
+}
+
+func (r *Repository) GetUnits(ctx context.Context, productID int) ([]ProductUnit, error) {
+	rows, err := r.DB.Query(ctx, `SELECT product_id, unit, factor, barcode FROM product_units WHERE product_id = $1 ORDER BY factor, unit`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	units := []ProductUnit{}
+	for rows.Next() {
+		var u ProductUnit
+		if err := rows.Scan(&u.ProductID, &u.Unit, &u.Factor, &u.Barcode); err != nil {
+			return nil, err
+		}
+		units = append(units, u)
+	}
+	return units, rows.Err()
+}
+
+// SetUnit cria ou altera uma unidade alternativa. O código da embalagem não pode identificar um
+// produto nem outra embalagem.
+func (r *Repository) SetUnit(ctx context.Context, u *ProductUnit) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if u.Barcode != nil {
+		var used bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM product_identifiers WHERE code = $1)`, *u.Barcode).Scan(&used); err != nil {
+			return err
+		}
+		if used {
+			return ErrBarcodeInUse
+		}
+	}
+	query := `INSERT INTO product_units (product_id, unit, factor, barcode) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (product_id, unit) DO UPDATE SET factor = EXCLUDED.factor, barcode = EXCLUDED.barcode`
+	if _, err := tx.Exec(ctx, query, u.ProductID, u.Unit, u.Factor, u.Barcode); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrBarcodeInUse
+		}
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *Repository) DeleteUnit(ctx context.Context, productID int, unit string) error {
+	cmd, err := r.DB.Exec(ctx, `DELETE FROM product_units WHERE product_id = $1 AND unit = $2`, productID, unit)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrUnitNotFound
+	}
+	return nil
+}
+
+// ResolveUnit traduz o código lido e a unidade informada no código do produto e no fator para a
+// unidade base. O código de uma embalagem define a unidade; o do produto usa a unidade informada,
+// ou a base quando vazia. Códigos desconhecidos voltam como vieram, com fator 1.
+func (r *Repository) ResolveUnit(ctx context.Context, barcode, unit string) (string, int, error) {
+	query := `SELECT p.barcode, p.base_unit, NULL::text, 1 FROM products p WHERE ` + ByCode("p.id", "$1") + `
+		UNION ALL
+		SELECT p.barcode, p.base_unit, u.unit, u.factor FROM product_units u JOIN products p ON p.id = u.product_id WHERE u.barcode = $1
+		LIMIT 1`
+	var productBarcode, baseUnit string
+	var packUnit *string
+	factor := 1
+	if err := r.DB.QueryRow(ctx, query, barcode).Scan(&productBarcode, &baseUnit, &packUnit, &factor); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return barcode, 1, nil
+		}
+		return "", 0, err
+	}
+	if packUnit != nil {
+		if unit != "" && unit != *packUnit {
+			return "", 0, ErrUnitMismatch
+		}
+		return productBarcode, factor, nil
+	}
+	if unit == "" || unit == baseUnit {
+		return productBarcode, 1, nil
+	}
+	query = `SELECT factor FROM product_units WHERE ` + ByCode("product_id", "$1") + ` AND unit = $2`
+	if err := r.DB.QueryRow(ctx, query, productBarcode, unit).Scan(&factor); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", 0, ErrUnitNotFound
+		}
+		return "", 0, err
+	}
+	return productBarcode, factor, nil
+}
+
+// insertIdentifier associa o código ao produto; códigos são únicos entre todos os produtos e
+// embalagens.
+func insertIdentifier(ctx context.Context, tx pgx.Tx, productID int, id *Identifier) error {
+	if err := checkPackBarcode(ctx, tx, id.Code); err != nil {
+		return err
+	}
+	query := `INSERT INTO product_identifiers (product_id, code, kind) VALUES ($1, $2, $3) RETURNING created_at`
+	if err := tx.QueryRow(ctx, query, productID, id.Code, id.Kind).Scan(&id.CreatedAt); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrBarcodeInUse
+		}
+		return err
+	}
+	return nil
+}
+
+// GetIdentifiers lista os códigos do produto, o principal primeiro.
+func (r *Repository) GetIdentifiers(ctx context.Context, productID int) ([]Identifier, error) {
+	query := `SELECT i.code, i.kind, i.code = p.barcode, i.created_at
+		FROM product_identifiers i JOIN products p ON p.id = i.product_id
+		WHERE i.product_id = $1 ORDER BY i.code = p.barcode DESC, i.created_at, i.code`
+	rows, err := r.DB.Query(ctx, query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []Identifier{}
+	for rows.Next() {
+		var id Identifier
+		if err := rows.Scan(&id.Code, &id.Kind, &id.Primary, &id.CreatedAt); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *Repository) AddIdentifier(ctx context.Context, productID int, id *Identifier) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if err := insertIdentifier(ctx, tx, productID, id); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// DeleteIdentifier remove um código do produto; o código principal só sai depois de outro assumir.
+func (r *Repository) DeleteIdentifier(ctx context.Context, productID int, code string) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	var primary bool
+	query := `SELECT i.code = p.barcode FROM product_identifiers i JOIN products p ON p.id = i.product_id
+		WHERE i.product_id = $1 AND i.code = $2 FOR UPDATE`
+	if err := tx.QueryRow(ctx, query, productID, code).Scan(&primary); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrIdentifierNotFound
+		}
+		return err
+	}
+	if primary {
+		return ErrPrimaryIdentifier
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM product_identifiers WHERE product_id = $1 AND code = $2`, productID, code); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// SetPrimaryBarcode torna code, um código de barras já associado ao produto, o código principal.
+func (r *Repository) SetPrimaryBarcode(ctx context.Context, productID int, code string) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	var kind string
+	err = tx.QueryRow(ctx, `SELECT kind FROM product_identifiers WHERE product_id = $1 AND code = $2`, productID, code).Scan(&kind)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrIdentifierNotFound
+		}
+		return err
+	}
+	if kind != IdentifierBarcode {
+		return ErrPrimarySKU
+	}
+	if _, err := tx.Exec(ctx, `UPDATE products SET barcode = $1 WHERE id = $2`, code, productID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *Repository) GetBarcodeRules(ctx context.Context) ([]BarcodeRule, error) {
+	rows, err := r.DB.Query(ctx, `SELECT id, prefix, item_digits, value_digits, value_type, decimals, description, created_at
+		FROM barcode_rules ORDER BY prefix`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	rules := []BarcodeRule{}
+	for rows.Next() {
+		var b BarcodeRule
+		if err := rows.Scan(&b.ID, &b.Prefix, &b.ItemDigits, &b.ValueDigits, &b.ValueType, &b.Decimals, &b.Description, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		rules = append(rules, b)
+	}
+	return rules, rows.Err()
+}
+
+func (r *Repository) CreateBarcodeRule(ctx context.Context, b *BarcodeRule) error {
+	query := `INSERT INTO barcode_rules (prefix, item_digits, value_digits, value_type, decimals, description)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+	err := r.DB.QueryRow(ctx, query, b.Prefix, b.ItemDigits, b.ValueDigits, b.ValueType, b.Decimals, b.Description).Scan(&b.ID, &b.CreatedAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrRuleExists
+	}
+	return err
+}
+
+func (r *Repository) DeleteBarcodeRule(ctx context.Context, id int) error {
+	cmd, err := r.DB.Exec(ctx, `DELETE FROM barcode_rules WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrRuleNotFound
+	}
+	return nil
+}
+
+// ImportProducts grava as linhas em uma única transação, cada uma no seu savepoint: a linha com erro
+// é desfeita e entra no relatório. Produtos são encontrados por qualquer identificador e, quando não
+// existem, criados; prepare aplica os valores da linha e valida o produto. Em uma simulação, ou quando
+// alguma linha falha sem partial, nada é gravado.
+func (r *Repository) ImportProducts(ctx context.Context, rows []ImportRow, dryRun, partial bool, prepare func(*Product, ImportRow) error) (*ImportReport, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	report := &ImportReport{Rows: len(rows), DryRun: dryRun, Errors: []ImportError{}}
+	for _, row := range rows {
+		created, err := importRow(ctx, tx, row, prepare)
+		switch {
+		case err != nil:
+			report.Failed++
+			report.Errors = append(report.Errors, ImportError{Row: row.Line, Barcode: row.Barcode, Error: err.Error()})
+		case created:
+			report.Created++
+		default:
+			report.Updated++
+		}
+	}
+	if dryRun || (report.Failed > 0 && !partial) {
+		return report, nil
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	report.Committed = true
+	return report, nil
+}
+
+// importRow cria ou altera o produto da linha. Uma quantidade diferente do saldo atual vira uma
+// movimentação de importação no local padrão; uma redução não pode tirar unidades reservadas ou alocadas.
+func importRow(ctx context.Context, tx pgx.Tx, row ImportRow, prepare func(*Product, ImportRow) error) (bool, error) {
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer sp.Rollback(ctx)
+	var p Product
+	err = scanProduct(sp.QueryRow(ctx, "SELECT "+productColumns+" FROM products WHERE "+ByCode("id", "$1"), row.Barcode), &p)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return false, err
+	}
+	created := err != nil
+	if created {
+		p = Product{Barcode: row.Barcode}
+	}
+	current := p.Quantity
+	if err := prepare(&p, row); err != nil {
+		return false, err
+	}
+	if created {
+		err = createProduct(ctx, sp, &p)
+	} else {
+		// A diferença de quantidade vira movimentação, não alteração de cadastro
+		target := p.Quantity
+		p.Quantity = current
+		err = updateProduct(ctx, sp, p.ID, &p)
+		if err == nil && target != current {
+			m := &StockMovement{ProductID: p.ID, Delta: target - current, Reason: ReasonImport}
+			err = ApplyMovement(ctx, sp, m)
+			// Reduzir a quantidade não pode tirar unidades reservadas ou alocadas
+			if err == nil && m.Delta < 0 {
+				err = CheckHeldStock(ctx, sp, m)
+			}
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+	return created, sp.Commit(ctx)
+}
+
 type RepositoryInterface interface {
 	CreateProduct(ctx context.Context, p *Product) error
 	GetProducts(ctx context.Context, q ProductsQuery) ([]Product, int, error)
+	GetProduct(ctx context.Context, id int) (*Product, error)
 	GetProductByBarcode(ctx context.Context, barcode string) (*Product, error)
 	UpdateProduct(ctx context.Context, id int, p *Product) error
 	DeleteProduct(ctx context.Context, id int) error
-	StockEntry(ctx context.Context, barcode string, qty int) error
-	StockExit(ctx context.Context, barcode string, qty int) error
+	StockEntry(ctx context.Context, barcode string, m *StockMovement) error
+	StockExit(ctx context.Context, barcode string, m *StockMovement) error
+	GetMovements(ctx context.Context, q MovementsQuery) ([]StockMovement, int, error)
+	GetStockLevels(ctx context.Context, productID int) ([]StockLevel, error)
+	GetProductAsOf(ctx context.Context, barcode string, asOf time.Time) (*Product, error)
+	GetStockLevelsAsOf(ctx context.Context, productID int, asOf time.Time) ([]StockLevel, error)
+	TakeSnapshots(ctx context.Context, until time.Time) (int, error)
+	SetLocationMinStock(ctx context.Context, productID, locationID, minStock int) error
+	GetLots(ctx context.Context, productID int) ([]Lot, error)
+	GetExpiringLots(ctx context.Context, until time.Time) ([]Lot, error)
+	MarkLotsNotified(ctx context.Context, ids []int) error
+	GetSerial(ctx context.Context, serial string) (*SerialNumber, error)
+	CreateReservation(ctx context.Context, res *Reservation) error
+	GetReservations(ctx context.Context, productID int) ([]Reservation, error)
+	CloseReservation(ctx context.Context, id int, status string, locationID int) (*Reservation, *StockMovement, error)
+	ExpireReservations(ctx context.Context) (int64, error)
+	GetDailyDemand(ctx context.Context, productID int, from, to time.Time) ([]DailyDemand, *time.Time, error)
+	GetLeadTime(ctx context.Context, productID int) (int, error)
+	GetUnits(ctx context.Context, productID int) ([]ProductUnit, error)
+	SetUnit(ctx context.Context, u *ProductUnit) error
+	DeleteUnit(ctx context.Context, productID int, unit string) error
+	ResolveUnit(ctx context.Context, barcode, unit string) (string, int, error)
+	GetIdentifiers(ctx context.Context, productID int) ([]Identifier, error)
+	AddIdentifier(ctx context.Context, productID int, id *Identifier) error
+	DeleteIdentifier(ctx context.Context, productID int, code string) error
+	SetPrimaryBarcode(ctx context.Context, productID int, code string) error
+	GetBarcodeRules(ctx context.Context) ([]BarcodeRule, error)
+	CreateBarcodeRule(ctx context.Context, rule *BarcodeRule) error
+	DeleteBarcodeRule(ctx context.Context, id int) error
+	ExportProducts(ctx context.Context, q ProductsQuery, fn func(*Product) error) error
+	ExportMovements(ctx context.Context, q MovementsQuery, fn func(*StockMovement) error) error
+	ImportProducts(ctx context.Context, rows []ImportRow, dryRun, partial bool, prepare func(*Product, ImportRow) error) (*ImportReport, error)
 }
@@ -2,12 +2,49 @@ package products
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"inventory-system/internal"
+	"inventory-system/internal/barcode"
+	"inventory-system/internal/label"
 	"inventory-system/internal/notifications"
+	"inventory-system/internal/spreadsheet"
+	"io"
+	"math"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// Destinatário dos alertas de estoque
+const notifyTo = "5586998277053"
+
+// Janelas da previsão de demanda: histórico e horizonte padrão e máximos, em dias
+const (
+	defaultForecastHistory = 90
+	maxForecastHistory     = 730
+	defaultForecastHorizon = 30
+	maxForecastHorizon     = 365
+)
+
+// Antecedência padrão, em dias, do aviso de ruptura prevista quando STOCKOUT_ALERT_DAYS não a define
+const defaultStockoutWarningDays = 7
+
+// Validade padrão de uma reserva quando nem a requisição nem RESERVATION_TTL a definem
+const defaultReservationTTL = 15 * time.Minute
+
 type Service struct {
 	Repo     RepositoryInterface
 	Notifier *notifications.NotificationService
+	// ReservationTTL é a validade das reservas criadas sem ttl_seconds
+	ReservationTTL time.Duration
+	// LabelCurrency é o símbolo impresso antes do preço nas etiquetas; vazio usa R$
+	LabelCurrency string
+	// StockoutWarningDays é a antecedência do aviso de ruptura prevista; o prazo de entrega do
+	// fornecedor prevalece quando é maior. Zero usa o padrão
+	StockoutWarningDays int
 }
 
 func NewService(repo RepositoryInterface, notifier *notifications.NotificationService) *Service {
@@ -15,9 +52,23 @@ func NewService(repo RepositoryInterface, notifier *notifications.NotificationSe
 }
 
 func (s *Service) CreateProduct(ctx context.Context, p *Product) error {
+	productDefaults(p)
 	return s.Repo.CreateProduct(ctx, p)
 }
 
+// productDefaults preenche os campos vazios que têm padrão documentado no modelo.
+func productDefaults(p *Product) {
+	if p.NegativeStockPolicy == "" {
+		p.NegativeStockPolicy = PolicyForbid
+	}
+	if p.CostingMethod == "" {
+		p.CostingMethod = CostingAverage
+	}
+	if p.BaseUnit == "" {
+		p.BaseUnit = DefaultUnit
+	}
+}
+
 type ProductsQuery struct {
 	Page     int
 	Limit    int
@@ -26,6 +77,40 @@ type ProductsQuery struct {
 	MinStock int
 	Sort     string
 	Order    string
+	// Categoria, incluindo as subcategorias; zero não filtra
+	Category int
+	// Quando informado, as quantidades são as do instante AsOf
+	AsOf *time.Time
+}
+
+// LabelOptions escolhe o formato (png, svg, zpl ou pdf), a simbologia (vazia escolhe pelo código),
+// os pixels ou pontos da impressora por módulo e, nos lotes, as cópias de cada etiqueta.
+type LabelOptions struct {
+	Format    string
+	Symbology string
+	Scale     int
+	Copies    int
+}
+
+// ImportOptions descreve a planilha (csv ou xlsx) e como gravá-la. Columns liga campos do produto a
+// títulos de coluna; campos fora dele são lidos da coluna com o próprio nome. DryRun só valida, e
+// Partial grava as linhas válidas mesmo quando outras falham.
+type ImportOptions struct {
+	Format  string
+	Columns map[string]string
+	DryRun  bool
+	Partial bool
+}
+
+// Limite de linhas por importação, para manter a transação em um tamanho razoável
+const maxImportRows = 20000
+
+type MovementsQuery struct {
+	ProductID int
+	From      *time.Time
+	To        *time.Time
+	Page      int
+	Limit     int
 }
 
 func (s *Service) GetProducts(ctx context.Context, q ProductsQuery) ([]Product, int, error) {
@@ -36,7 +121,15 @@ func (s *Service) GetProductByBarcode(ctx context.Context, barcode string) (*Pro
 	return s.Repo.GetProductByBarcode(ctx, barcode)
 }
 
+// GetProduct devolve o produto pelo ID, ou nil se ele não existe.
+func (s *Service) GetProduct(ctx context.Context, id int) (*Product, error) {
+	return s.Repo.GetProduct(ctx, id)
+}
+
+// UpdateProduct grava o cadastro completo p; campos vazios assumem o padrão, como na criação. O
+// PUT parte do produto gravado (veja updateProductHandler), então o que o corpo omite é mantido.
 func (s *Service) UpdateProduct(ctx context.Context, id int, p *Product) error {
+	productDefaults(p)
 	return s.Repo.UpdateProduct(ctx, id, p)
 }
 
@@ -44,23 +137,941 @@ func (s *Service) DeleteProduct(ctx context.Context, id int) error {
 	return s.Repo.DeleteProduct(ctx, id)
 }
 
-func (s *Service) StockEntry(ctx context.Context, barcode string, qty int) error {
-	return s.Repo.StockEntry(ctx, barcode, qty)
+func (s *Service) StockEntry(ctx context.Context, barcode string, req StockRequest) error {
+	barcode, err := s.toBaseUnits(ctx, barcode, &req)
+	if err != nil {
+		return err
+	}
+	if err := serialQuantity(&req); err != nil {
+		return err
+	}
+	m := &StockMovement{LocationID: req.LocationID, Delta: req.Quantity, Reason: reasonOrDefault(req.Reason, ReasonEntry), Reference: req.Reference, Serials: req.Serials, UnitCost: req.UnitCost}
+	lots, err := requestLots(req)
+	if err != nil {
+		return err
+	}
+	m.Lots = lots
+	return s.Repo.StockEntry(ctx, barcode, m)
 }
 
-func (s *Service) StockExit(ctx context.Context, barcode string, qty int) error {
-	err := s.Repo.StockExit(ctx, barcode, qty)
+func (s *Service) StockExit(ctx context.Context, barcode string, req StockRequest) error {
+	barcode, err := s.toBaseUnits(ctx, barcode, &req)
+	if err != nil {
+		return err
+	}
+	if err := serialQuantity(&req); err != nil {
+		return err
+	}
+	m := &StockMovement{LocationID: req.LocationID, Delta: -req.Quantity, Reason: reasonOrDefault(req.Reason, ReasonExit), Reference: req.Reference, Serials: req.Serials}
+	lots, err := requestLots(req)
+	if err != nil {
+		return err
+	}
+	m.Lots = lots
+	err = s.Repo.StockExit(ctx, barcode, m)
 	if err != nil {
 		return err
 	}
-	p, _ := s.Repo.GetProductByBarcode(ctx, barcode)
-	if p != nil && p.Quantity < p.MinStock && s.Notifier != nil {
+	s.notifyLowStock(ctx, barcode, m)
+	return nil
+}
+
+// notifyLowStock avisa quando a saída deixa o produto abaixo do mínimo no total ou no local. Usa os
+// saldos e mínimos lidos na própria transação da movimentação, sem consultar o produto de novo. A
+// previsão de ruptura lê o histórico de demanda, então só é calculada quando a saída cruza o ponto
+// de pedido ou o mínimo: o aviso de estoque baixo a inclui e, acima do mínimo, ela gera um aviso
+// stockout_forecast quando a ruptura vem antes do limite de stockoutThreshold.
+func (s *Service) notifyLowStock(ctx context.Context, barcode string, m *StockMovement) {
+	if s.Notifier == nil {
+		return
+	}
+	var days int
+	if crosses(m, m.limits.reorderPoint) || crosses(m, m.limits.minStock) {
+		f, err := s.forecastFor(ctx, m.ProductID, m.Balance, defaultForecastHistory, defaultForecastHorizon)
+		if err == nil && f.DaysUntilStockout != nil {
+			days = *f.DaysUntilStockout
+		}
+	}
+	if m.Balance < m.limits.minStock {
+		message := "Product '" + m.limits.productName + "' is below minimum stock!"
+		data := map[string]interface{}{"barcode": barcode, "quantity": m.Balance, "min_stock": m.limits.minStock}
+		if days > 0 {
+			message += " It will run out in " + strconv.Itoa(days) + " days."
+			data["days_until_stockout"] = days
+		}
 		s.Notifier.Notify(notifications.NotificationEvent{
 			Type:    "low_stock",
-			To:      "5586998277053",
-			Message: "Product '" + p.Name + "' is below minimum stock!",
-			Data:    map[string]interface{}{"barcode": p.Barcode, "quantity": p.Quantity, "min_stock": p.MinStock},
+			To:      notifyTo,
+			Message: message,
+			Data:    data,
 		})
+	} else if days > 0 {
+		if threshold := s.stockoutThreshold(ctx, m.ProductID); days < threshold {
+			s.Notifier.Notify(notifications.NotificationEvent{
+				Type:    "stockout_forecast",
+				To:      notifyTo,
+				Message: "Product '" + m.limits.productName + "' will run out in " + strconv.Itoa(days) + " days!",
+				Data:    map[string]interface{}{"barcode": barcode, "quantity": m.Balance, "days_until_stockout": days, "threshold_days": threshold},
+			})
+		}
+	}
+	if m.LocationBalance < m.limits.locationMinStock {
+		s.Notifier.Notify(notifications.NotificationEvent{
+			Type:    "low_stock",
+			To:      notifyTo,
+			Message: "Product '" + m.limits.productName + "' is below minimum stock at " + m.limits.locationName + "!",
+			Data:    map[string]interface{}{"barcode": barcode, "location": m.limits.locationCode, "quantity": m.LocationBalance, "min_stock": m.limits.locationMinStock},
+		})
+	}
+}
+
+// crosses informa se a movimentação m levou o saldo total de level ou mais para abaixo de level;
+// level zero (limite desativado) nunca é cruzado.
+func crosses(m *StockMovement, level int) bool {
+	return level > 0 && m.Balance < level && m.Balance-m.Delta >= level
+}
+
+// stockoutThreshold é a antecedência do aviso de ruptura do produto: StockoutWarningDays (ou o
+// padrão) ou o prazo de entrega do fornecedor, o que for maior, para que ainda dê tempo de comprar.
+func (s *Service) stockoutThreshold(ctx context.Context, productID int) int {
+	threshold := s.StockoutWarningDays
+	if threshold <= 0 {
+		threshold = defaultStockoutWarningDays
+	}
+	if lead, err := s.Repo.GetLeadTime(ctx, productID); err == nil && lead > threshold {
+		threshold = lead
+	}
+	return threshold
+}
+
+func (s *Service) GetStockLevels(ctx context.Context, barcode string) ([]StockLevel, error) {
+	p, err := s.Repo.GetProductByBarcode(ctx, barcode)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrProductNotFound
+	}
+	return s.Repo.GetStockLevels(ctx, p.ID)
+}
+
+// GetProductAsOf devolve o produto com a quantidade que tinha no instante asOf.
+func (s *Service) GetProductAsOf(ctx context.Context, barcode string, asOf time.Time) (*Product, error) {
+	return s.Repo.GetProductAsOf(ctx, barcode, asOf)
+}
+
+// GetStockLevelsAsOf devolve o saldo do produto por local no instante asOf.
+func (s *Service) GetStockLevelsAsOf(ctx context.Context, barcode string, asOf time.Time) ([]StockLevel, error) {
+	p, err := s.Repo.GetProductByBarcode(ctx, barcode)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrProductNotFound
+	}
+	return s.Repo.GetStockLevelsAsOf(ctx, p.ID, asOf)
+}
+
+// snapshotGrace é a folga após a meia-noite antes de fotografar o dia anterior, para que
+// transações iniciadas antes da virada já tenham sido gravadas.
+const snapshotGrace = time.Hour
+
+// TakeSnapshots grava as fotografias diárias pendentes até o último fim de dia já consolidado.
+func (s *Service) TakeSnapshots(ctx context.Context, now time.Time) (int, error) {
+	return s.Repo.TakeSnapshots(ctx, snapshotBoundary(now))
+}
+
+// snapshotBoundary devolve a meia-noite UTC mais recente anterior a now menos snapshotGrace.
+func snapshotBoundary(now time.Time) time.Time {
+	return now.Add(-snapshotGrace).UTC().Truncate(24 * time.Hour)
+}
+
+// SetLocationMinStock define o estoque mínimo do produto em um local específico.
+func (s *Service) SetLocationMinStock(ctx context.Context, barcode string, locationID, minStock int) error {
+	p, err := s.Repo.GetProductByBarcode(ctx, barcode)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return ErrProductNotFound
+	}
+	return s.Repo.SetLocationMinStock(ctx, p.ID, locationID, minStock)
+}
+
+// GetMovements lista o histórico de movimentações de um produto, do mais recente ao mais antigo.
+func (s *Service) GetMovements(ctx context.Context, barcode string, q MovementsQuery) ([]StockMovement, int, error) {
+	p, err := s.Repo.GetProductByBarcode(ctx, barcode)
+	if err != nil {
+		return nil, 0, err
+	}
+	if p == nil {
+		return nil, 0, ErrProductNotFound
+	}
+	q.ProductID = p.ID
+	return s.Repo.GetMovements(ctx, q)
+}
+
+// GetForecast projeta a demanda do produto nos próximos horizon dias a partir das saídas dos
+// últimos history dias.
+func (s *Service) GetForecast(ctx context.Context, barcode string, history, horizon int) (*Forecast, error) {
+	p, err := s.Repo.GetProductByBarcode(ctx, barcode)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrProductNotFound
+	}
+	f, err := s.forecastFor(ctx, p.ID, p.Quantity, history, horizon)
+	if err != nil {
+		return nil, err
+	}
+	f.Barcode = p.Barcode
+	return f, nil
+}
+
+func (s *Service) forecastFor(ctx context.Context, productID, quantity, history, horizon int) (*Forecast, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from := today.AddDate(0, 0, -history)
+	days, first, err := s.Repo.GetDailyDemand(ctx, productID, from, today)
+	if err != nil {
+		return nil, err
+	}
+	// Produto mais novo que a janela: os dias anteriores à primeira movimentação não contam como demanda zero
+	if first != nil && first.After(from) {
+		from = first.UTC().Truncate(24 * time.Hour)
+	}
+	return forecast(demandSeries(days, from, today), from, today, quantity, horizon), nil
+}
+
+// demandSeries expande a demanda diária em uma série de from até o dia anterior a to, com zero nos dias sem saídas.
+func demandSeries(days []DailyDemand, from, to time.Time) []float64 {
+	n := int(to.Sub(from).Hours() / 24)
+	if n < 0 {
+		n = 0
+	}
+	series := make([]float64, n)
+	for _, d := range days {
+		i := int(d.Date.Sub(from).Hours() / 24)
+		if i >= 0 && i < n {
+			series[i] += float64(d.Quantity)
+		}
+	}
+	return series
+}
+
+// forecast calcula a média diária da série (que começa em from), os fatores de sazonalidade por
+// dia da semana e a demanda prevista dia a dia a partir de today. A ruptura é o primeiro dia em que
+// a demanda acumulada alcança quantity, procurada até maxForecastHorizon dias à frente.
+func forecast(series []float64, from, today time.Time, quantity, horizon int) *Forecast {
+	f := &Forecast{Quantity: quantity, HistoryDays: len(series), HorizonDays: horizon, Seasonality: make([]float64, 7), Daily: []DailyForecast{}}
+	total := 0.0
+	for _, q := range series {
+		total += q
+	}
+	avg := 0.0
+	if len(series) > 0 {
+		avg = total / float64(len(series))
+	}
+	for i := range f.Seasonality {
+		f.Seasonality[i] = 1
+	}
+	// Sazonalidade semanal só com pelo menos quatro ocorrências de cada dia da semana
+	if len(series) >= 28 && total > 0 {
+		var sums, counts [7]float64
+		for i, q := range series {
+			w := from.AddDate(0, 0, i).Weekday()
+			sums[w] += q
+			counts[w]++
+		}
+		for w := range f.Seasonality {
+			f.Seasonality[w] = roundTo(sums[w]/counts[w]/avg, 4)
+		}
+	}
+	demandOn := func(d time.Time) float64 {
+		return avg * f.Seasonality[d.Weekday()]
+	}
+	for i := 0; i < horizon; i++ {
+		d := today.AddDate(0, 0, i)
+		f.ForecastDemand += demandOn(d)
+		f.Daily = append(f.Daily, DailyForecast{Date: d, Demand: roundTo(demandOn(d), 2)})
+	}
+	f.AverageDailyDemand = roundTo(avg, 4)
+	f.ForecastDemand = roundTo(f.ForecastDemand, 2)
+	stockout := -1
+	if quantity <= 0 {
+		stockout = 0
+	} else if avg > 0 {
+		remaining := float64(quantity)
+		for i := 0; i < maxForecastHorizon; i++ {
+			remaining -= demandOn(today.AddDate(0, 0, i))
+			if remaining <= 0 {
+				stockout = i
+				break
+			}
+		}
+	}
+	if stockout >= 0 {
+		date := today.AddDate(0, 0, stockout)
+		f.DaysUntilStockout, f.StockoutDate = &stockout, &date
+	}
+	return f
+}
+
+func roundTo(x float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(x*scale) / scale
+}
+
+// GetLots lista os lotes com saldo de um produto em todos os locais.
+func (s *Service) GetLots(ctx context.Context, barcode string) ([]Lot, error) {
+	p, err := s.Repo.GetProductByBarcode(ctx, barcode)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrProductNotFound
+	}
+	return s.Repo.GetLots(ctx, p.ID)
+}
+
+// GetExpiringLots lista os lotes que vencem nos próximos days dias, incluindo os já vencidos.
+func (s *Service) GetExpiringLots(ctx context.Context, days int) ([]Lot, error) {
+	return s.Repo.GetExpiringLots(ctx, expiryLimit(time.Now(), days))
+}
+
+// NotifyExpiringLots dispara um evento expiring_soon para cada lote que vence nos próximos days
+// dias e ainda não foi avisado.
+func (s *Service) NotifyExpiringLots(ctx context.Context, days int) error {
+	lots, err := s.GetExpiringLots(ctx, days)
+	if err != nil {
+		return err
+	}
+	ids := []int{}
+	for _, l := range lots {
+		if l.NotifiedAt != nil {
+			continue
+		}
+		if s.Notifier != nil {
+			s.Notifier.Notify(notifications.NotificationEvent{
+				Type:    "expiring_soon",
+				To:      notifyTo,
+				Message: "Lot '" + l.LotNumber + "' of product '" + l.ProductName + "' expires on " + l.ExpiryDate.Format(dateLayout) + "!",
+				Data:    map[string]interface{}{"barcode": l.Barcode, "lot_number": l.LotNumber, "location": l.LocationCode, "quantity": l.Quantity, "expiry_date": l.ExpiryDate.Format(dateLayout)},
+			})
+		}
+		ids = append(ids, l.ID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.Repo.MarkLotsNotified(ctx, ids)
+}
+
+// GetSerial devolve a situação atual de um número de série e seu histórico.
+func (s *Service) GetSerial(ctx context.Context, serial string) (*SerialNumber, error) {
+	sn, err := s.Repo.GetSerial(ctx, serial)
+	if err != nil {
+		return nil, err
+	}
+	if sn == nil {
+		return nil, ErrSerialNotFound
+	}
+	return sn, nil
+}
+
+// CreateReservation segura a quantidade pedida do produto até a reserva vencer, ser confirmada ou liberada.
+func (s *Service) CreateReservation(ctx context.Context, barcode string, req ReservationRequest) (*Reservation, error) {
+	ttl := time.Duration(req.TTLSeconds) * time.Second
+	if ttl == 0 {
+		ttl = s.ReservationTTL
+	}
+	if ttl == 0 {
+		ttl = defaultReservationTTL
+	}
+	res := &Reservation{Barcode: barcode, Quantity: req.Quantity, Reference: req.Reference, ExpiresAt: time.Now().Add(ttl)}
+	if userID, ok := internal.UserIDFromContext(ctx); ok {
+		res.CreatedBy = &userID
+	}
+	if err := s.Repo.CreateReservation(ctx, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetReservations lista as reservas ativas de um produto.
+func (s *Service) GetReservations(ctx context.Context, barcode string) ([]Reservation, error) {
+	p, err := s.Repo.GetProductByBarcode(ctx, barcode)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrProductNotFound
+	}
+	return s.Repo.GetReservations(ctx, p.ID)
+}
+
+// CommitReservation dá saída da quantidade reservada no local informado (o padrão quando 0).
+func (s *Service) CommitReservation(ctx context.Context, id, locationID int) (*Reservation, error) {
+	res, m, err := s.Repo.CloseReservation(ctx, id, ReservationCommitted, locationID)
+	if err != nil {
+		return nil, err
+	}
+	s.notifyLowStock(ctx, res.Barcode, m)
+	return res, nil
+}
+
+// ReleaseReservation devolve a quantidade reservada ao disponível sem movimentar estoque.
+func (s *Service) ReleaseReservation(ctx context.Context, id int) (*Reservation, error) {
+	res, _, err := s.Repo.CloseReservation(ctx, id, ReservationReleased, 0)
+	return res, err
+}
+
+// ExpireReservations libera as reservas vencidas.
+func (s *Service) ExpireReservations(ctx context.Context) (int64, error) {
+	return s.Repo.ExpireReservations(ctx)
+}
+
+// ReadScan interpreta uma leitura GS1-128 ou GS1 DataMatrix recebida no lugar do código de barras:
+// preenche na requisição o que ela ainda não traz (quantidade do AI 30, lote do 10 com a validade do
+// 17 e número de série do 21) e devolve o código do produto com aquele GTIN, procurado também nas
+// formas EAN-13, UPC-A e EAN-8 (um GTIN sem produto volta como foi lido). Os demais códigos passam
+// por readVariable, que só altera os de medida variável.
+func (s *Service) ReadScan(ctx context.Context, code string, req *StockRequest) (string, error) {
+	// O roteador entrega o parâmetro ainda codificado quando a URL traz escapes (%1D, %28...).
+	scan := code
+	if c, err := url.PathUnescape(code); err == nil {
+		scan = c
+	}
+	if !barcode.IsGS1(scan) {
+		return s.readVariable(ctx, code, req)
+	}
+	g, err := barcode.ParseGS1(scan)
+	if err != nil {
+		return "", err
+	}
+	applyGS1(g, req)
+	for _, c := range barcode.Candidates(g.GTIN) {
+		p, err := s.Repo.GetProductByBarcode(ctx, c)
+		if err != nil {
+			return "", err
+		}
+		if p != nil {
+			return p.Barcode, nil
+		}
+	}
+	return g.GTIN, nil
+}
+
+// readVariable resolve os EAN-13 de medida variável pelas regras cadastradas: devolve o código do
+// produto base e, sem quantidade na requisição, usa o peso ou a quantidade embutida, ou o preço
+// embutido dividido pelo preço do produto. Um código cadastrado tal como foi lido tem precedência.
+func (s *Service) readVariable(ctx context.Context, code string, req *StockRequest) (string, error) {
+	if len(code) != 13 || code[0] != '2' {
+		return code, nil
+	}
+	rules, err := s.Repo.GetBarcodeRules(ctx)
+	if err != nil {
+		return "", err
+	}
+	variable := make([]barcode.VariableRule, len(rules))
+	for i, r := range rules {
+		variable[i] = barcode.VariableRule{Prefix: r.Prefix, ItemDigits: r.ItemDigits, ValueDigits: r.ValueDigits}
+	}
+	i := barcode.MatchVariable(code, variable)
+	if i < 0 {
+		return code, nil
+	}
+	if p, err := s.Repo.GetProductByBarcode(ctx, code); err != nil || p != nil {
+		return code, err
+	}
+	base, value := barcode.DecodeVariable(code, variable[i])
+	p, err := s.Repo.GetProductByBarcode(ctx, base)
+	if err != nil {
+		return "", err
+	}
+	if p == nil {
+		return base, nil
+	}
+	if req.Quantity == 0 {
+		q, err := embeddedQuantity(rules[i], value, p.Price)
+		if err != nil {
+			return "", err
+		}
+		req.Quantity = q
+	}
+	return p.Barcode, nil
+}
+
+// embeddedQuantity converte o valor embutido em quantidade na unidade base. Preços viram quantidade
+// dividindo pelo preço do produto por unidade base, arredondado para a unidade mais próxima. Se a
+// quantidade arredondada não reproduz o preço impresso (com meio centavo de folga), a unidade base é
+// grande demais para o peso, como kg ou unit em uma balança que pesa gramas, e a leitura é recusada
+// em vez de arredondar a quantidade.
+func embeddedQuantity(rule BarcodeRule, value int, price float64) (int, error) {
+	if rule.ValueType != VariablePrice {
+		return value, nil
+	}
+	if price <= 0 {
+		return 0, ErrNoPrice
+	}
+	scale := math.Pow10(rule.Decimals)
+	amount := float64(value) / scale
+	q := math.Round(amount / price)
+	if q < 1 || math.Abs(q*price-amount) > 0.5/scale+1e-9 {
+		return 0, ErrEmbeddedPrice
+	}
+	return int(q), nil
+}
+
+func (s *Service) GetBarcodeRules(ctx context.Context) ([]BarcodeRule, error) {
+	return s.Repo.GetBarcodeRules(ctx)
+}
+
+func (s *Service) CreateBarcodeRule(ctx context.Context, rule *BarcodeRule) error {
+	check := barcode.VariableRule{Prefix: rule.Prefix, ItemDigits: rule.ItemDigits, ValueDigits: rule.ValueDigits}
+	if err := check.Check(); err != nil {
+		return err
+	}
+	return s.Repo.CreateBarcodeRule(ctx, rule)
+}
+
+func (s *Service) DeleteBarcodeRule(ctx context.Context, id int) error {
+	return s.Repo.DeleteBarcodeRule(ctx, id)
+}
+
+func applyGS1(g *barcode.GS1, req *StockRequest) {
+	if req.Quantity == 0 && len(req.Serials) == 0 {
+		req.Quantity = g.Quantity
+	}
+	if g.Serial != "" && len(req.Serials) == 0 {
+		req.Serials = []string{g.Serial}
+	}
+	if g.Lot != "" && req.LotNumber == "" {
+		req.LotNumber = g.Lot
+	}
+	if g.Expiry != nil && req.ExpiryDate == "" && req.LotNumber == g.Lot && g.Lot != "" {
+		req.ExpiryDate = g.Expiry.Format("2006-01-02")
+	}
+}
+
+// toBaseUnits resolve o código lido (do produto ou de uma embalagem) e converte a quantidade e o
+// custo unitário da requisição para a unidade base. Devolve o código do produto.
+func (s *Service) toBaseUnits(ctx context.Context, barcode string, req *StockRequest) (string, error) {
+	productBarcode, factor, err := s.Repo.ResolveUnit(ctx, barcode, req.Unit)
+	if err != nil {
+		return "", err
+	}
+	req.Quantity *= factor
+	if req.UnitCost != nil && factor != 1 {
+		cost := *req.UnitCost / float64(factor)
+		req.UnitCost = &cost
+	}
+	return productBarcode, nil
+}
+
+func (s *Service) GetUnits(ctx context.Context, barcode string) ([]ProductUnit, error) {
+	p, err := s.Repo.GetProductByBarcode(ctx, barcode)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrProductNotFound
+	}
+	return s.Repo.GetUnits(ctx, p.ID)
+}
+
+// SetUnit cria ou altera a unidade alternativa unit do produto.
+func (s *Service) SetUnit(ctx context.Context, barcode, unit string, req UnitRequest) (*ProductUnit, error) {
+	p, err := s.Repo.GetProductByBarcode(ctx, barcode)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrProductNotFound
+	}
+	if unit == p.BaseUnit {
+		return nil, ErrBaseUnit
+	}
+	u := &ProductUnit{ProductID: p.ID, Unit: unit, Factor: req.Factor}
+	if req.Barcode != "" {
+		u.Barcode = &req.Barcode
+	}
+	if err := s.Repo.SetUnit(ctx, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (s *Service) DeleteUnit(ctx context.Context, barcode, unit string) error {
+	p, err := s.Repo.GetProductByBarcode(ctx, barcode)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return ErrProductNotFound
+	}
+	return s.Repo.DeleteUnit(ctx, p.ID, unit)
+}
+
+func (s *Service) GetIdentifiers(ctx context.Context, barcode string) ([]Identifier, error) {
+	p, err := s.Repo.GetProductByBarcode(ctx, barcode)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrProductNotFound
+	}
+	return s.Repo.GetIdentifiers(ctx, p.ID)
+}
+
+func (s *Service) AddIdentifier(ctx context.Context, barcode string, req IdentifierRequest) (*Identifier, error) {
+	p, err := s.Repo.GetProductByBarcode(ctx, barcode)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrProductNotFound
+	}
+	id := &Identifier{Code: req.Code, Kind: req.Kind}
+	if id.Kind == "" {
+		id.Kind = IdentifierBarcode
+	}
+	if err := s.Repo.AddIdentifier(ctx, p.ID, id); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+func (s *Service) DeleteIdentifier(ctx context.Context, barcode, code string) error {
+	p, err := s.Repo.GetProductByBarcode(ctx, barcode)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return ErrProductNotFound
+	}
+	return s.Repo.DeleteIdentifier(ctx, p.ID, code)
+}
+
+// SetPrimaryBarcode troca o código de barras principal do produto e devolve o produto atualizado.
+func (s *Service) SetPrimaryBarcode(ctx context.Context, barcode, code string) (*Product, error) {
+	p, err := s.Repo.GetProductByBarcode(ctx, barcode)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrProductNotFound
+	}
+	if err := s.Repo.SetPrimaryBarcode(ctx, p.ID, code); err != nil {
+		return nil, err
+	}
+	return s.Repo.GetProductByBarcode(ctx, code)
+}
+
+// serialQuantity completa a quantidade a partir dos números de série, que devem corresponder a ela quando ambos são informados.
+func serialQuantity(req *StockRequest) error {
+	if len(req.Serials) == 0 {
+		return nil
+	}
+	if req.Quantity == 0 {
+		req.Quantity = len(req.Serials)
+	}
+	if req.Quantity != len(req.Serials) {
+		return ErrSerialCount
 	}
 	return nil
 }
+
+const dateLayout = "2006-01-02"
+
+// expiryLimit devolve o último dia (inclusive) da janela de days dias a partir de now.
+func expiryLimit(now time.Time, days int) time.Time {
+	y, m, d := now.Date()
+	return time.Date(y, m, d+days, 0, 0, 0, 0, time.UTC)
+}
+
+// requestLots converte o lote informado na requisição; sem lote, a movimentação usa estoque sem lote (entrada) ou FEFO (saída).
+func requestLots(req StockRequest) ([]LotQuantity, error) {
+	if req.LotNumber == "" {
+		return nil, nil
+	}
+	l := LotQuantity{LotNumber: req.LotNumber, Quantity: req.Quantity}
+	if req.ExpiryDate != "" {
+		expiry, err := time.Parse(dateLayout, req.ExpiryDate)
+		if err != nil {
+			return nil, ErrInvalidExpiryDate
+		}
+		l.ExpiryDate = &expiry
+	}
+	return []LotQuantity{l}, nil
+}
+
+func reasonOrDefault(reason, fallback string) string {
+	if reason == "" {
+		return fallback
+	}
+	return reason
+}
+
+// WriteLabel escreve a etiqueta de gôndola do produto.
+func (s *Service) WriteLabel(ctx context.Context, barcode string, opts LabelOptions, w io.Writer) error {
+	p, err := s.Repo.GetProductByBarcode(ctx, barcode)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return ErrProductNotFound
+	}
+	e, err := label.NewEncoder(w, opts.Format, opts.Scale)
+	if err != nil {
+		return err
+	}
+	if err := e.Encode(s.productLabel(p, opts.Symbology)); err != nil {
+		return err
+	}
+	return e.Close()
+}
+
+// WriteLabels escreve em PDF ou ZPL as etiquetas de todos os produtos do filtro, página a página, sem
+// carregar o lote inteiro. Produtos cujo código a simbologia pedida não codifica saem na simbologia
+// escolhida pelo código, para não interromper o lote.
+func (s *Service) WriteLabels(ctx context.Context, q ProductsQuery, opts LabelOptions, w io.Writer) error {
+	if opts.Format != label.PDF && opts.Format != label.ZPL {
+		return label.ErrSingleLabel
+	}
+	if err := barcode.CheckSymbology(opts.Symbology); err != nil {
+		return err
+	}
+	e, err := label.NewEncoder(w, opts.Format, opts.Scale)
+	if err != nil {
+		return err
+	}
+	q.Limit, q.AsOf = 100, nil
+	for q.Page = 1; ; q.Page++ {
+		products, total, err := s.Repo.GetProducts(ctx, q)
+		if err != nil {
+			return err
+		}
+		for i := range products {
+			l := s.productLabel(&products[i], opts.Symbology)
+			for c := 0; c < max(opts.Copies, 1); c++ {
+				err := e.Encode(l)
+				if errors.Is(err, barcode.ErrNotEAN13) || errors.Is(err, barcode.ErrUnencodable) || errors.Is(err, barcode.ErrTooLong) {
+					l.Symbology = ""
+					err = e.Encode(l)
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if q.Page*q.Limit >= total {
+			break
+		}
+	}
+	return e.Close()
+}
+
+func (s *Service) productLabel(p *Product, symbology string) label.Label {
+	currency := s.LabelCurrency
+	if currency == "" {
+		currency = "R$"
+	}
+	price := strings.Replace(strconv.FormatFloat(p.Price, 'f', 2, 64), ".", ",", 1)
+	return label.Label{Name: p.Name, Price: currency + " " + price, Code: p.Barcode, Symbology: symbology}
+}
+
+// Campos do produto que a importação aceita
+var importFields = []string{"barcode", "name", "quantity", "min_stock", "serialized", "negative_stock_policy", "negative_stock_floor",
+	"price", "costing_method", "reorder_point", "reorder_qty", "max_stock", "base_unit", "internal_code", "category_id"}
+
+// ImportProducts lê a planilha, com cabeçalho na primeira linha, e cria ou altera um produto por
+// linha, pelo código de barras. Só os campos das colunas presentes e preenchidas mudam nos produtos
+// existentes; quantity define o saldo total.
+func (s *Service) ImportProducts(ctx context.Context, r io.Reader, opts ImportOptions) (*ImportReport, error) {
+	table, err := spreadsheet.Read(r, opts.Format)
+	if errors.Is(err, spreadsheet.ErrUnknownFormat) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrImportFile, err)
+	}
+	if len(table) == 0 {
+		return nil, fmt.Errorf("%w: missing header row", ErrImportFile)
+	}
+	if len(table)-1 > maxImportRows {
+		return nil, ErrImportTooLarge
+	}
+	columns, err := importColumns(table[0], opts.Columns)
+	if err != nil {
+		return nil, err
+	}
+	rows := []ImportRow{}
+	for i, cells := range table[1:] {
+		row := ImportRow{Line: i + 2, Values: map[string]string{}}
+		for field, col := range columns {
+			if col < len(cells) && strings.TrimSpace(cells[col]) != "" {
+				row.Values[field] = strings.TrimSpace(cells[col])
+			}
+		}
+		if len(row.Values) == 0 {
+			continue
+		}
+		row.Barcode = row.Values["barcode"]
+		rows = append(rows, row)
+	}
+	return s.Repo.ImportProducts(ctx, rows, opts.DryRun, opts.Partial, prepareImport)
+}
+
+// importColumns encontra a coluna de cada campo pelo título, sem diferenciar maiúsculas. A coluna do
+// código de barras é obrigatória.
+func importColumns(header []string, mapping map[string]string) (map[string]int, error) {
+	for field := range mapping {
+		if !slices.Contains(importFields, field) {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrImportColumns, field)
+		}
+	}
+	columns := map[string]int{}
+	for _, field := range importFields {
+		title, mapped := mapping[field]
+		if !mapped {
+			title = field
+		}
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), strings.TrimSpace(title)) {
+				columns[field] = i
+				break
+			}
+		}
+		if _, ok := columns[field]; !ok && mapped {
+			return nil, fmt.Errorf("%w: column %q not found", ErrImportColumns, title)
+		}
+	}
+	if _, ok := columns["barcode"]; !ok {
+		return nil, fmt.Errorf("%w: no barcode column", ErrImportColumns)
+	}
+	return columns, nil
+}
+
+// prepareImport aplica os valores da linha ao produto, completa os padrões do cadastro e valida com
+// as mesmas regras de POST /products. O código de barras de um produto existente não muda: ele é a
+// chave da linha.
+func prepareImport(p *Product, row ImportRow) error {
+	for _, field := range importFields {
+		v, ok := row.Values[field]
+		if !ok || (field == "barcode" && p.ID != 0) {
+			continue
+		}
+		if err := setImportField(p, field, v); err != nil {
+			return err
+		}
+	}
+	if p.NegativeStockPolicy == "" {
+		p.NegativeStockPolicy = PolicyForbid
+	}
+	if p.CostingMethod == "" {
+		p.CostingMethod = CostingAverage
+	}
+	if p.BaseUnit == "" {
+		p.BaseUnit = DefaultUnit
+	}
+	return validate.Struct(p)
+}
+
+func setImportField(p *Product, field, v string) error {
+	var err error
+	switch field {
+	case "barcode":
+		p.Barcode = v
+	case "name":
+		p.Name = v
+	case "negative_stock_policy":
+		p.NegativeStockPolicy = v
+	case "costing_method":
+		p.CostingMethod = v
+	case "base_unit":
+		p.BaseUnit = v
+	case "quantity":
+		p.Quantity, err = strconv.Atoi(v)
+	case "min_stock":
+		p.MinStock, err = strconv.Atoi(v)
+	case "negative_stock_floor":
+		p.NegativeStockFloor, err = strconv.Atoi(v)
+	case "reorder_point":
+		p.ReorderPoint, err = strconv.Atoi(v)
+	case "reorder_qty":
+		p.ReorderQty, err = strconv.Atoi(v)
+	case "max_stock":
+		p.MaxStock, err = strconv.Atoi(v)
+	case "price":
+		// Aceita a vírgula decimal das planilhas em português
+		if !strings.Contains(v, ".") {
+			v = strings.Replace(v, ",", ".", 1)
+		}
+		p.Price, err = strconv.ParseFloat(v, 64)
+	case "serialized":
+		p.Serialized, err = strconv.ParseBool(v)
+	case "internal_code":
+		p.InternalCode, err = strconv.ParseBool(v)
+	case "category_id":
+		var id int
+		if id, err = strconv.Atoi(v); err == nil {
+			p.CategoryID = &id
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s %q", field, v)
+	}
+	return nil
+}
+
+// Colunas das exportações; as de produtos são os campos aceitos pela importação, mais os somente leitura
+var (
+	productExportColumns = []string{"id", "barcode", "name", "quantity", "available", "min_stock", "serialized", "negative_stock_policy",
+		"negative_stock_floor", "price", "costing_method", "average_cost", "reorder_point", "reorder_qty", "max_stock", "base_unit", "internal_code", "category_id"}
+	movementExportColumns = []string{"id", "product_id", "location_id", "delta", "balance", "location_balance", "user_id", "reason",
+		"reference", "created_at", "unit_cost", "value"}
+)
+
+// ExportProducts escreve todos os produtos do filtro em CSV, XLSX ou NDJSON, à medida que são lidos.
+func (s *Service) ExportProducts(ctx context.Context, q ProductsQuery, format string, w io.Writer) error {
+	sw, err := spreadsheet.NewWriter(w, format, productExportColumns)
+	if err != nil {
+		return err
+	}
+	err = s.Repo.ExportProducts(ctx, q, func(p *Product) error {
+		return sw.Write([]interface{}{p.ID, p.Barcode, p.Name, p.Quantity, p.Available, p.MinStock, p.Serialized, p.NegativeStockPolicy,
+			p.NegativeStockFloor, p.Price, p.CostingMethod, p.AverageCost, p.ReorderPoint, p.ReorderQty, p.MaxStock, p.BaseUnit, p.InternalCode, p.CategoryID})
+	})
+	if err != nil {
+		return err
+	}
+	return sw.Close()
+}
+
+// ExportMovements escreve em ordem cronológica as movimentações do período, de um produto quando
+// barcode é informado ou de todos.
+func (s *Service) ExportMovements(ctx context.Context, barcode string, q MovementsQuery, format string, w io.Writer) error {
+	if barcode != "" {
+		p, err := s.Repo.GetProductByBarcode(ctx, barcode)
+		if err != nil {
+			return err
+		}
+		if p == nil {
+			return ErrProductNotFound
+		}
+		q.ProductID = p.ID
+	}
+	sw, err := spreadsheet.NewWriter(w, format, movementExportColumns)
+	if err != nil {
+		return err
+	}
+	err = s.Repo.ExportMovements(ctx, q, func(m *StockMovement) error {
+		return sw.Write([]interface{}{m.ID, m.ProductID, m.LocationID, m.Delta, m.Balance, m.LocationBalance, m.UserID, m.Reason,
+			m.Reference, m.CreatedAt, m.UnitCost, m.Value})
+	})
+	if err != nil {
+		return err
+	}
+	return sw.Close()
+}
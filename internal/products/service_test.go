@@ -2,8 +2,10 @@ package products
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 	"testing"
 
@@ -13,6 +15,8 @@ import (
 
 	"time"
 
+	"inventory-system/internal/notifications"
+
 	"github.com/go-chi/chi/v5"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -97,22 +101,36 @@ func TestUpdateProduct(t *testing.T) {
 	p := &Product{Name: "P", Barcode: "b", Quantity: 1, MinStock: 1}
 	_ = svc.CreateProduct(context.Background(), p)
 	p.Name = "Novo Nome"
+	// A quantidade só muda por movimentações e ajustes, e a alteração é recusada em vez de ignorada
 	p.Quantity = 99
+	if err := svc.UpdateProduct(context.Background(), p.ID, p); err != ErrQuantityChange {
+		t.Errorf("esperado ErrQuantityChange, veio %v", err)
+	}
+	p.Quantity = 1
 	err := svc.UpdateProduct(context.Background(), p.ID, p)
 	if err != nil {
 		t.Fatalf("erro ao atualizar: %v", err)
 	}
 	prod, _ := svc.GetProductByBarcode(context.Background(), "b")
-	if prod.Name != "Novo Nome" || prod.Quantity != 99 {
+	if prod.Name != "Novo Nome" || prod.Quantity != 1 {
 		t.Errorf("update não refletiu: %+v", prod)
 	}
+	// Com estoque, a unidade base não muda; vazia, ela é a padrão
+	p.BaseUnit = "g"
+	if err := svc.UpdateProduct(context.Background(), p.ID, p); err != ErrBaseUnitChange {
+		t.Errorf("esperado ErrBaseUnitChange, veio %v", err)
+	}
+	p.BaseUnit = ""
+	if err := svc.UpdateProduct(context.Background(), p.ID, p); err != nil || p.BaseUnit != DefaultUnit {
+		t.Errorf("unidade base padrão deveria ser aceita: %v %q", err, p.BaseUnit)
+	}
 }
 
 func TestDeleteProduct(t *testing.T) {
 	cleanTable(t)
 	repo := NewRepository(testDB)
 	svc := NewService(repo, nil)
-	p := &Product{Name: "P", Barcode: "b", Quantity: 1, MinStock: 1}
+	p := &Product{Name: "P", Barcode: "b", MinStock: 1}
 	_ = svc.CreateProduct(context.Background(), p)
 	err := svc.DeleteProduct(context.Background(), p.ID)
 	if err != nil {
@@ -122,6 +140,15 @@ func TestDeleteProduct(t *testing.T) {
 	if prod != nil {
 		t.Error("produto não foi deletado")
 	}
+	// Com movimentações o histórico é preservado e o produto fica
+	moved := &Product{Name: "M", Barcode: "m", Quantity: 1}
+	_ = svc.CreateProduct(context.Background(), moved)
+	if err := svc.DeleteProduct(context.Background(), moved.ID); err != ErrProductInUse {
+		t.Errorf("esperado ErrProductInUse, veio %v", err)
+	}
+	if _, err := testDB.Exec(context.Background(), "DELETE FROM stock_movements WHERE product_id = $1", moved.ID); err == nil {
+		t.Error("movimentações não deveriam ser removidas")
+	}
 }
 
 func TestStockEntryAndExit(t *testing.T) {
@@ -130,7 +157,7 @@ func TestStockEntryAndExit(t *testing.T) {
 	svc := NewService(repo, nil)
 	p := &Product{Name: "P", Barcode: "b", Quantity: 10, MinStock: 1}
 	_ = svc.CreateProduct(context.Background(), p)
-	err := svc.StockEntry(context.Background(), "b", 5)
+	err := svc.StockEntry(context.Background(), "b", StockRequest{Quantity: 5})
 	if err != nil {
 		t.Fatalf("erro ao dar entrada: %v", err)
 	}
@@ -138,7 +165,7 @@ func TestStockEntryAndExit(t *testing.T) {
 	if prod.Quantity != 15 {
 		t.Errorf("entrada não refletiu: %d", prod.Quantity)
 	}
-	err = svc.StockExit(context.Background(), "b", 10)
+	err = svc.StockExit(context.Background(), "b", StockRequest{Quantity: 10})
 	if err != nil {
 		t.Fatalf("erro ao dar saída: %v", err)
 	}
@@ -147,12 +174,404 @@ func TestStockEntryAndExit(t *testing.T) {
 		t.Errorf("saída não refletiu: %d", prod.Quantity)
 	}
 	// Testar saída maior que estoque
-	err = svc.StockExit(context.Background(), "b", 99)
+	err = svc.StockExit(context.Background(), "b", StockRequest{Quantity: 99})
 	if err == nil {
 		t.Error("esperava erro de estoque insuficiente")
 	}
 }
 
+func TestStockMovementsLedger(t *testing.T) {
+	cleanTable(t)
+	repo := NewRepository(testDB)
+	svc := NewService(repo, nil)
+	p := &Product{Name: "P", Barcode: "b", Quantity: 10, MinStock: 1}
+	_ = svc.CreateProduct(context.Background(), p)
+	if err := svc.StockEntry(context.Background(), "b", StockRequest{Quantity: 5, Reference: "NF 1"}); err != nil {
+		t.Fatalf("erro ao dar entrada: %v", err)
+	}
+	if err := svc.StockExit(context.Background(), "b", StockRequest{Quantity: 3, Reason: "sale"}); err != nil {
+		t.Fatalf("erro ao dar saída: %v", err)
+	}
+	movements, total, err := svc.GetMovements(context.Background(), "b", MovementsQuery{})
+	if err != nil {
+		t.Fatalf("erro ao listar movimentações: %v", err)
+	}
+	if total != 3 || len(movements) != 3 {
+		t.Fatalf("esperado 3 movimentações, veio %d", total)
+	}
+	// Mais recente primeiro
+	if movements[0].Delta != -3 || movements[0].Balance != 12 || movements[0].Reason != "sale" {
+		t.Errorf("saída registrada incorretamente: %+v", movements[0])
+	}
+	if movements[1].Delta != 5 || movements[1].Balance != 15 || movements[1].Reference != "NF 1" {
+		t.Errorf("entrada registrada incorretamente: %+v", movements[1])
+	}
+	if movements[2].Reason != ReasonCreate || movements[2].Balance != 10 {
+		t.Errorf("estoque inicial registrado incorretamente: %+v", movements[2])
+	}
+	// Saída recusada não gera movimentação
+	_ = svc.StockExit(context.Background(), "b", StockRequest{Quantity: 99})
+	future := time.Now().Add(time.Hour)
+	_, total, _ = svc.GetMovements(context.Background(), "b", MovementsQuery{})
+	if total != 3 {
+		t.Errorf("saída recusada não deveria ser registrada, total %d", total)
+	}
+	_, total, _ = svc.GetMovements(context.Background(), "b", MovementsQuery{From: &future})
+	if total != 0 {
+		t.Errorf("filtro de data não aplicado, total %d", total)
+	}
+}
+
+func TestStockPerLocation(t *testing.T) {
+	cleanTable(t)
+	ctx := context.Background()
+	repo := NewRepository(testDB)
+	svc := NewService(repo, nil)
+	_, _ = testDB.Exec(ctx, "DELETE FROM locations WHERE code = 'TEST-WH'")
+	var whID int
+	if err := testDB.QueryRow(ctx, "INSERT INTO locations (code, name) VALUES ('TEST-WH', 'Test warehouse') RETURNING id").Scan(&whID); err != nil {
+		t.Fatalf("erro ao criar local: %v", err)
+	}
+	defer testDB.Exec(ctx, "DELETE FROM locations WHERE id = $1", whID)
+	_ = svc.CreateProduct(ctx, &Product{Name: "P", Barcode: "b", Quantity: 10, MinStock: 1})
+	if err := svc.StockEntry(ctx, "b", StockRequest{Quantity: 4, LocationID: whID}); err != nil {
+		t.Fatalf("erro ao dar entrada no local: %v", err)
+	}
+	prod, _ := svc.GetProductByBarcode(ctx, "b")
+	if prod.Quantity != 14 {
+		t.Errorf("total agregado incorreto: %d", prod.Quantity)
+	}
+	// O local só possui 4 unidades, mesmo com 14 no total
+	if err := svc.StockExit(ctx, "b", StockRequest{Quantity: 5, LocationID: whID}); err == nil {
+		t.Error("esperava erro de estoque insuficiente no local")
+	}
+	if err := svc.StockExit(ctx, "b", StockRequest{Quantity: 1, LocationID: 999999}); err != ErrLocationNotFound {
+		t.Errorf("esperado ErrLocationNotFound, veio %v", err)
+	}
+	levels, err := svc.GetStockLevels(ctx, "b")
+	if err != nil {
+		t.Fatalf("erro ao listar níveis: %v", err)
+	}
+	if len(levels) != 2 {
+		t.Fatalf("esperado 2 níveis, veio %d", len(levels))
+	}
+	for _, l := range levels {
+		if l.LocationID == whID && l.Quantity != 4 {
+			t.Errorf("nível do local incorreto: %+v", l)
+		}
+	}
+	// Com o histórico gravado o local não pode ser removido
+	if _, err := testDB.Exec(ctx, "DELETE FROM locations WHERE id = $1", whID); err == nil {
+		t.Error("local com histórico não deveria ser removido")
+	}
+	cleanTable(t)
+}
+
+func TestStockAsOf(t *testing.T) {
+	cleanTable(t)
+	ctx := context.Background()
+	repo := NewRepository(testDB)
+	svc := NewService(repo, nil)
+	_ = svc.CreateProduct(ctx, &Product{Name: "P", Barcode: "b", Quantity: 10})
+	_ = svc.StockEntry(ctx, "b", StockRequest{Quantity: 5})
+	movements, _, _ := svc.GetMovements(ctx, "b", MovementsQuery{})
+	created, entered := movements[1].CreatedAt, movements[0].CreatedAt
+	_ = svc.StockExit(ctx, "b", StockRequest{Quantity: 3})
+	// Antes do cadastro o produto não existia, nem na listagem
+	before := created.Add(-time.Second)
+	if p, err := svc.GetProductAsOf(ctx, "b", before); err != nil || p != nil {
+		t.Errorf("produto não deveria existir antes do cadastro: %+v %v", p, err)
+	}
+	if products, total, _ := svc.GetProducts(ctx, ProductsQuery{Page: 1, Limit: 10, AsOf: &before}); len(products) != 0 || total != 0 {
+		t.Errorf("esperado nenhum produto, veio %d (total %d)", len(products), total)
+	}
+	cases := []struct {
+		asOf time.Time
+		want int
+	}{
+		{created, 10},
+		{entered, 15},
+		{time.Now().Add(time.Hour), 12},
+	}
+	for _, c := range cases {
+		p, err := svc.GetProductAsOf(ctx, "b", c.asOf)
+		if err != nil {
+			t.Fatalf("erro ao consultar histórico: %v", err)
+		}
+		if p.Quantity != c.want {
+			t.Errorf("em %v: esperado %d, veio %d", c.asOf, c.want, p.Quantity)
+		}
+	}
+	// A partir de uma fotografia o resultado é o mesmo
+	if err := repo.takeSnapshot(ctx, entered); err != nil {
+		t.Fatalf("erro ao gravar fotografia: %v", err)
+	}
+	defer testDB.Exec(ctx, "DELETE FROM stock_snapshot_runs WHERE taken_at = $1", entered)
+	now := time.Now().Add(time.Hour)
+	products, _, _ := svc.GetProducts(ctx, ProductsQuery{Page: 1, Limit: 10, AsOf: &now})
+	if len(products) != 1 || products[0].Quantity != 12 {
+		t.Errorf("listagem histórica incorreta: %+v", products)
+	}
+	levels, _ := svc.GetStockLevelsAsOf(ctx, "b", entered)
+	if len(levels) != 1 || levels[0].Quantity != 15 {
+		t.Errorf("níveis históricos incorretos: %+v", levels)
+	}
+	cleanTable(t)
+}
+
+func TestStockCosting(t *testing.T) {
+	cleanTable(t)
+	ctx := context.Background()
+	svc := NewService(NewRepository(testDB), nil)
+	cost := func(v float64) *float64 { return &v }
+	_ = svc.CreateProduct(ctx, &Product{Name: "Média", Barcode: "avg"})
+	_ = svc.CreateProduct(ctx, &Product{Name: "FIFO", Barcode: "fifo", CostingMethod: CostingFIFO})
+	for _, barcode := range []string{"avg", "fifo"} {
+		_ = svc.StockEntry(ctx, barcode, StockRequest{Quantity: 10, UnitCost: cost(2)})
+		_ = svc.StockEntry(ctx, barcode, StockRequest{Quantity: 10, UnitCost: cost(4)})
+		if err := svc.StockExit(ctx, barcode, StockRequest{Quantity: 15}); err != nil {
+			t.Fatalf("erro ao dar saída: %v", err)
+		}
+	}
+	// Média: 15 x 3 = 45; FIFO: 10 x 2 + 5 x 4 = 40
+	for barcode, want := range map[string]float64{"avg": -45, "fifo": -40} {
+		movements, _, _ := svc.GetMovements(ctx, barcode, MovementsQuery{})
+		if movements[0].Value != want {
+			t.Errorf("%s: custo da saída esperado %.2f, veio %.2f", barcode, want, movements[0].Value)
+		}
+	}
+	p, _ := svc.GetProductByBarcode(ctx, "fifo")
+	if p.AverageCost != 4 {
+		t.Errorf("custo médio das camadas restantes deveria ser 4, veio %.4f", p.AverageCost)
+	}
+	// Entrada sem custo usa o custo médio atual
+	_ = svc.StockEntry(ctx, "avg", StockRequest{Quantity: 5})
+	p, _ = svc.GetProductByBarcode(ctx, "avg")
+	if p.AverageCost != 3 {
+		t.Errorf("custo médio deveria continuar 3, veio %.4f", p.AverageCost)
+	}
+	// Atualizar sem trocar o método (o PUT parte do produto gravado) mantém o FIFO e as camadas
+	p, _ = svc.GetProductByBarcode(ctx, "fifo")
+	p.Name = "FIFO renomeado"
+	if err := svc.UpdateProduct(ctx, p.ID, p); err != nil {
+		t.Fatalf("erro ao atualizar: %v", err)
+	}
+	_ = svc.StockExit(ctx, "fifo", StockRequest{Quantity: 1})
+	p, _ = svc.GetProductByBarcode(ctx, "fifo")
+	movements, _, _ := svc.GetMovements(ctx, "fifo", MovementsQuery{})
+	if p.CostingMethod != CostingFIFO || movements[0].Value != -4 {
+		t.Errorf("método de custeio alterado sem ser informado: %s %+v", p.CostingMethod, movements[0])
+	}
+	cleanTable(t)
+}
+
+func TestStockLotsFEFO(t *testing.T) {
+	cleanTable(t)
+	ctx := context.Background()
+	svc := NewService(NewRepository(testDB), nil)
+	_ = svc.CreateProduct(ctx, &Product{Name: "Leite", Barcode: "b", Quantity: 2, MinStock: 0})
+	_ = svc.StockEntry(ctx, "b", StockRequest{Quantity: 5, LotNumber: "L2", ExpiryDate: "2099-02-01"})
+	_ = svc.StockEntry(ctx, "b", StockRequest{Quantity: 3, LotNumber: "L1", ExpiryDate: "2099-01-01"})
+	// Sem lote informado, o lote que vence primeiro sai primeiro
+	if err := svc.StockExit(ctx, "b", StockRequest{Quantity: 4}); err != nil {
+		t.Fatalf("erro ao dar saída: %v", err)
+	}
+	lots, err := svc.GetLots(ctx, "b")
+	if err != nil {
+		t.Fatalf("erro ao listar lotes: %v", err)
+	}
+	if len(lots) != 1 || lots[0].LotNumber != "L2" || lots[0].Quantity != 4 {
+		t.Fatalf("lotes incorretos após FEFO: %+v", lots)
+	}
+	if err := svc.StockExit(ctx, "b", StockRequest{Quantity: 5, LotNumber: "L2"}); err != ErrLotNotFound {
+		t.Errorf("esperado ErrLotNotFound, veio %v", err)
+	}
+	// Lotes esgotados: o restante sai do estoque sem lote
+	if err := svc.StockExit(ctx, "b", StockRequest{Quantity: 6}); err != nil {
+		t.Fatalf("erro ao dar saída: %v", err)
+	}
+	prod, _ := svc.GetProductByBarcode(ctx, "b")
+	if prod.Quantity != 0 {
+		t.Errorf("esperado 0, veio %d", prod.Quantity)
+	}
+	movements, _, _ := svc.GetMovements(ctx, "b", MovementsQuery{})
+	if len(movements[1].Lots) != 2 || movements[1].Lots[0].LotNumber != "L1" || movements[1].Lots[0].Quantity != 3 {
+		t.Errorf("lotes da movimentação incorretos: %+v", movements[1].Lots)
+	}
+	cleanTable(t)
+}
+
+func TestSerializedProduct(t *testing.T) {
+	cleanTable(t)
+	ctx := context.Background()
+	svc := NewService(NewRepository(testDB), nil)
+	if err := svc.CreateProduct(ctx, &Product{Name: "Notebook", Barcode: "nb", Quantity: 1, Serialized: true}); err != ErrSerialsRequired {
+		t.Errorf("esperado ErrSerialsRequired, veio %v", err)
+	}
+	_ = svc.CreateProduct(ctx, &Product{Name: "Notebook", Barcode: "nb", Serialized: true})
+	if err := svc.StockEntry(ctx, "nb", StockRequest{Quantity: 2}); err != ErrSerialsRequired {
+		t.Errorf("esperado ErrSerialsRequired, veio %v", err)
+	}
+	if err := svc.StockEntry(ctx, "nb", StockRequest{Serials: []string{"SN1", "SN2", "SN3"}}); err != nil {
+		t.Fatalf("erro ao dar entrada: %v", err)
+	}
+	if err := svc.StockEntry(ctx, "nb", StockRequest{Serials: []string{"SN1"}}); err != ErrSerialConflict {
+		t.Errorf("esperado ErrSerialConflict, veio %v", err)
+	}
+	if err := svc.StockExit(ctx, "nb", StockRequest{Serials: []string{"SN9"}}); err != ErrSerialNotInStock {
+		t.Errorf("esperado ErrSerialNotInStock, veio %v", err)
+	}
+	if err := svc.StockExit(ctx, "nb", StockRequest{Serials: []string{"SN2"}}); err != nil {
+		t.Fatalf("erro ao dar saída: %v", err)
+	}
+	prod, _ := svc.GetProductByBarcode(ctx, "nb")
+	if prod.Quantity != 2 {
+		t.Errorf("esperado 2, veio %d", prod.Quantity)
+	}
+	sn, err := svc.GetSerial(ctx, "SN2")
+	if err != nil {
+		t.Fatalf("erro ao buscar série: %v", err)
+	}
+	if sn.Status != SerialOut || len(sn.History) != 2 || sn.History[1].Delta != -1 {
+		t.Errorf("série incorreta: %+v", sn)
+	}
+	if _, err := svc.GetSerial(ctx, "SN9"); err != ErrSerialNotFound {
+		t.Errorf("esperado ErrSerialNotFound, veio %v", err)
+	}
+	cleanTable(t)
+}
+
+func TestReservations(t *testing.T) {
+	cleanTable(t)
+	ctx := context.Background()
+	svc := NewService(NewRepository(testDB), nil)
+	_ = svc.CreateProduct(ctx, &Product{Name: "P", Barcode: "b", Quantity: 5, MinStock: 0})
+	res, err := svc.CreateReservation(ctx, "b", ReservationRequest{Quantity: 3, TTLSeconds: 60})
+	if err != nil {
+		t.Fatalf("erro ao reservar: %v", err)
+	}
+	prod, _ := svc.GetProductByBarcode(ctx, "b")
+	if prod.Quantity != 5 || prod.Available != 2 {
+		t.Errorf("esperado quantidade 5 e disponível 2, veio %d e %d", prod.Quantity, prod.Available)
+	}
+	// A saída avulsa não pode consumir unidades reservadas
+	if err := svc.StockExit(ctx, "b", StockRequest{Quantity: 3}); err != ErrReservedStock {
+		t.Errorf("esperado ErrReservedStock, veio %v", err)
+	}
+	if _, err := svc.CommitReservation(ctx, res.ID, 0); err != nil {
+		t.Fatalf("erro ao confirmar reserva: %v", err)
+	}
+	prod, _ = svc.GetProductByBarcode(ctx, "b")
+	if prod.Quantity != 2 || prod.Available != 2 {
+		t.Errorf("esperado quantidade 2 e disponível 2, veio %d e %d", prod.Quantity, prod.Available)
+	}
+	cleanTable(t)
+}
+
+func TestNegativeStockPolicy(t *testing.T) {
+	cleanTable(t)
+	ctx := context.Background()
+	svc := NewService(NewRepository(testDB), nil)
+	_ = svc.CreateProduct(ctx, &Product{Name: "P", Barcode: "b", Quantity: 1, NegativeStockPolicy: PolicyFloor, NegativeStockFloor: -2})
+	if err := svc.StockExit(ctx, "b", StockRequest{Quantity: 3}); err != nil {
+		t.Fatalf("saída até o piso deveria ser aceita: %v", err)
+	}
+	if err := svc.StockExit(ctx, "b", StockRequest{Quantity: 1}); err != ErrInsufficientStock {
+		t.Errorf("esperado ErrInsufficientStock, veio %v", err)
+	}
+	prod, _ := svc.GetProductByBarcode(ctx, "b")
+	if prod.Quantity != -2 || prod.NegativeStockPolicy != PolicyFloor {
+		t.Errorf("produto incorreto: %+v", prod)
+	}
+	movements, _, _ := svc.GetMovements(ctx, "b", MovementsQuery{})
+	if movements[0].Balance != -2 || movements[0].LocationBalance != -2 {
+		t.Errorf("saldo da movimentação incorreto: %+v", movements[0])
+	}
+	cleanTable(t)
+}
+
+func TestNegativeStockPolicy_Reservation(t *testing.T) {
+	cleanTable(t)
+	ctx := context.Background()
+	svc := NewService(NewRepository(testDB), nil)
+	_ = svc.CreateProduct(ctx, &Product{Name: "P", Barcode: "b", Quantity: 5, NegativeStockPolicy: PolicyAllow})
+	if _, err := svc.CreateReservation(ctx, "b", ReservationRequest{Quantity: 3, TTLSeconds: 60}); err != nil {
+		t.Fatalf("erro ao reservar: %v", err)
+	}
+	// A política allow não libera as unidades reservadas para a saída avulsa
+	if err := svc.StockExit(ctx, "b", StockRequest{Quantity: 3}); err != ErrReservedStock {
+		t.Errorf("esperado ErrReservedStock, veio %v", err)
+	}
+	if err := svc.StockExit(ctx, "b", StockRequest{Quantity: 2}); err != nil {
+		t.Errorf("saída do estoque livre deveria ser aceita: %v", err)
+	}
+	prod, _ := svc.GetProductByBarcode(ctx, "b")
+	if prod.Quantity != 3 || prod.Available != 0 {
+		t.Errorf("esperado quantidade 3 e disponível 0, veio %d e %d", prod.Quantity, prod.Available)
+	}
+	cleanTable(t)
+}
+
+func TestHeldStock_Allocations(t *testing.T) {
+	cleanTable(t)
+	ctx := context.Background()
+	svc := NewService(NewRepository(testDB), nil)
+	_, _ = testDB.Exec(ctx, "DELETE FROM locations WHERE code = 'TEST-WH'")
+	var whID int
+	if err := testDB.QueryRow(ctx, "INSERT INTO locations (code, name) VALUES ('TEST-WH', 'Test warehouse') RETURNING id").Scan(&whID); err != nil {
+		t.Fatalf("erro ao criar local: %v", err)
+	}
+	p := &Product{Name: "P", Barcode: "b", Quantity: 5}
+	_ = svc.CreateProduct(ctx, p)
+	_ = svc.StockEntry(ctx, "b", StockRequest{Quantity: 5, LocationID: whID})
+	// 4 unidades alocadas no local padrão; o total tem 6 livres, mas o local padrão só 1
+	var orderID int
+	_ = testDB.QueryRow(ctx, "INSERT INTO sales_orders (customer) VALUES ('C') RETURNING id").Scan(&orderID)
+	defer testDB.Exec(ctx, "DELETE FROM sales_orders WHERE id = $1", orderID)
+	_, err := testDB.Exec(ctx, `WITH l AS (INSERT INTO sales_order_lines (sales_order_id, product_id, quantity, allocated) VALUES ($1, $2, 4, 4) RETURNING id)
+		INSERT INTO sales_allocations (line_id, product_id, location_id, quantity) SELECT l.id, $2, (SELECT id FROM locations WHERE is_default), 4 FROM l`, orderID, p.ID)
+	if err != nil {
+		t.Fatalf("erro ao alocar: %v", err)
+	}
+	if err := svc.StockExit(ctx, "b", StockRequest{Quantity: 2}); err != ErrReservedStock {
+		t.Errorf("esperado ErrReservedStock no local alocado, veio %v", err)
+	}
+	if err := svc.StockExit(ctx, "b", StockRequest{Quantity: 2, LocationID: whID}); err != nil {
+		t.Errorf("saída em outro local deveria ser aceita: %v", err)
+	}
+	// A baixa de uma reserva também não consome as unidades alocadas
+	res, err := svc.CreateReservation(ctx, "b", ReservationRequest{Quantity: 2})
+	if err != nil {
+		t.Fatalf("erro ao reservar: %v", err)
+	}
+	if _, err := svc.CommitReservation(ctx, res.ID, 0); err != ErrReservedStock {
+		t.Errorf("esperado ErrReservedStock ao baixar a reserva no local alocado, veio %v", err)
+	}
+	if _, err := svc.CommitReservation(ctx, res.ID, whID); err != nil {
+		t.Errorf("baixa em outro local deveria ser aceita: %v", err)
+	}
+	cleanTable(t)
+	_, _ = testDB.Exec(ctx, "DELETE FROM locations WHERE id = $1", whID)
+}
+
+func TestSystemLocation(t *testing.T) {
+	cleanTable(t)
+	ctx := context.Background()
+	svc := NewService(NewRepository(testDB), nil)
+	p := &Product{Name: "P", Barcode: "b", Quantity: 5}
+	_ = svc.CreateProduct(ctx, p)
+	for _, code := range []string{"TRANSIT", "QUARANTINE"} {
+		var id int
+		if err := testDB.QueryRow(ctx, "SELECT id FROM locations WHERE code = $1", code).Scan(&id); err != nil {
+			t.Fatalf("local %s não encontrado: %v", code, err)
+		}
+		if err := svc.StockEntry(ctx, "b", StockRequest{Quantity: 1, LocationID: id}); err != ErrSystemLocation {
+			t.Errorf("esperado ErrSystemLocation em %s, veio %v", code, err)
+		}
+	}
+	cleanTable(t)
+}
+
 func TestCreateProductValidation(t *testing.T) {
 	r := chi.NewRouter()
 	RegisterRoutes(r, testDB)
@@ -203,6 +622,16 @@ func TestAuthMiddleware_Required(t *testing.T) {
 		{"DELETE", "/products/1", ""},
 		{"POST", "/products/abc/entry", `{"quantity":1}`},
 		{"POST", "/products/abc/exit", `{"quantity":1}`},
+		{"GET", "/products/abc/movements", ""},
+		{"GET", "/products/abc/stock", ""},
+		{"PUT", "/products/abc/stock/1", `{"min_stock":1}`},
+		{"GET", "/products/abc/lots", ""},
+		{"GET", "/lots/expiring", ""},
+		{"GET", "/serials/abc", ""},
+		{"POST", "/products/abc/reservations", `{"quantity":1}`},
+		{"GET", "/products/abc/reservations", ""},
+		{"POST", "/reservations/1/commit", ""},
+		{"POST", "/reservations/1/release", ""},
 	}
 
 	for _, ep := range endpoints {
@@ -520,7 +949,7 @@ func TestDatabaseErrorCases(t *testing.T) {
 	}
 
 	// Teste saída de estoque de produto inexistente
-	err = svc.StockExit(context.Background(), "inexistent", 1)
+	err = svc.StockExit(context.Background(), "inexistent", StockRequest{Quantity: 1})
 	if err == nil {
 		t.Error("saída de estoque de produto inexistente deveria retornar erro")
 	}
@@ -612,9 +1041,44 @@ func TestFilterEdgeCases(t *testing.T) {
 	}
 }
 
+type mockLevel struct {
+	productID int
+	StockLevel
+}
+
 type mockProductRepo struct {
-	products map[string]*Product
-	fail     bool
+	products     map[string]*Product
+	movements    []StockMovement
+	levels       []mockLevel
+	lots         []Lot
+	reservations []Reservation
+	units        []ProductUnit
+	identifiers  []mockIdentifier
+	rules        []BarcodeRule
+	leadTimes    map[int]int
+	fail         bool
+}
+
+type mockIdentifier struct {
+	productID int
+	Identifier
+}
+
+// byCode encontra o produto pelo código principal ou por um identificador adicional
+func (m *mockProductRepo) byCode(code string) *Product {
+	if p, ok := m.products[code]; ok {
+		return p
+	}
+	for _, id := range m.identifiers {
+		if id.Code == code {
+			for _, p := range m.products {
+				if p.ID == id.productID {
+					return p
+				}
+			}
+		}
+	}
+	return nil
 }
 
 func (m *mockProductRepo) CreateProduct(ctx context.Context, p *Product) error {
@@ -638,15 +1102,23 @@ func (m *mockProductRepo) GetProducts(ctx context.Context, q ProductsQuery) ([]P
 	}
 	return result, len(result), nil
 }
-func (m *mockProductRepo) GetProductByBarcode(ctx context.Context, barcode string) (*Product, error) {
+func (m *mockProductRepo) GetProduct(ctx context.Context, id int) (*Product, error) {
 	if m.fail {
 		return nil, fmt.Errorf("db error")
 	}
-	p, ok := m.products[barcode]
-	if !ok {
-		return nil, nil
+	for _, p := range m.products {
+		if p.ID == id {
+			c := *p
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+func (m *mockProductRepo) GetProductByBarcode(ctx context.Context, barcode string) (*Product, error) {
+	if m.fail {
+		return nil, fmt.Errorf("db error")
 	}
-	return p, nil
+	return m.byCode(barcode), nil
 }
 func (m *mockProductRepo) UpdateProduct(ctx context.Context, id int, p *Product) error {
 	if m.fail {
@@ -654,6 +1126,9 @@ func (m *mockProductRepo) UpdateProduct(ctx context.Context, id int, p *Product)
 	}
 	for _, prod := range m.products {
 		if prod.ID == id {
+			if p.Quantity != prod.Quantity {
+				return ErrQuantityChange
+			}
 			*prod = *p
 			prod.ID = id
 			return nil
@@ -673,7 +1148,7 @@ func (m *mockProductRepo) DeleteProduct(ctx context.Context, id int) error {
 	}
 	return fmt.Errorf("not found")
 }
-func (m *mockProductRepo) StockEntry(ctx context.Context, barcode string, qty int) error {
+func (m *mockProductRepo) StockEntry(ctx context.Context, barcode string, mv *StockMovement) error {
 	if m.fail {
 		return fmt.Errorf("db error")
 	}
@@ -681,96 +1156,862 @@ func (m *mockProductRepo) StockEntry(ctx context.Context, barcode string, qty in
 	if !ok {
 		return fmt.Errorf("not found")
 	}
-	p.Quantity += qty
+	p.Quantity += mv.Delta
+	m.record(p, mv)
 	return nil
 }
-func (m *mockProductRepo) StockExit(ctx context.Context, barcode string, qty int) error {
+func (m *mockProductRepo) StockExit(ctx context.Context, barcode string, mv *StockMovement) error {
 	if m.fail {
 		return fmt.Errorf("db error")
 	}
 	p, ok := m.products[barcode]
-	if !ok || p.Quantity < qty {
+	if !ok || !(stockLimits{policy: p.NegativeStockPolicy, floor: p.NegativeStockFloor}).allows(p.Quantity+mv.Delta) {
 		return fmt.Errorf("insufficient stock or not found")
 	}
-	p.Quantity -= qty
+	p.Quantity += mv.Delta
+	m.record(p, mv)
 	return nil
 }
-
-func TestService_CreateProduct_Mock(t *testing.T) {
-	repo := &mockProductRepo{products: make(map[string]*Product)}
-	svc := NewService(repo, nil)
-	p := &Product{Name: "Produto Teste", Barcode: "123", Quantity: 10, MinStock: 2}
-	err := svc.CreateProduct(context.Background(), p)
-	if err != nil {
-		t.Fatalf("erro ao criar produto: %v", err)
-	}
-	if p.ID == 0 {
-		t.Error("ID não foi preenchido")
+func (m *mockProductRepo) GetStockLevels(ctx context.Context, productID int) ([]StockLevel, error) {
+	if m.fail {
+		return nil, fmt.Errorf("db error")
 	}
-	// Duplicidade
-	err = svc.CreateProduct(context.Background(), &Product{Name: "Produto Teste", Barcode: "123", Quantity: 1, MinStock: 1})
-	if err == nil {
-		t.Error("esperado erro de duplicidade")
+	levels := []StockLevel{}
+	for _, l := range m.levels {
+		if l.productID == productID {
+			levels = append(levels, l.StockLevel)
+		}
 	}
+	return levels, nil
 }
-
-func TestService_GetProductByBarcode_Mock(t *testing.T) {
-	repo := &mockProductRepo{products: make(map[string]*Product)}
-	svc := NewService(repo, nil)
-	p := &Product{Name: "Produto Teste", Barcode: "123", Quantity: 10, MinStock: 2}
-	_ = svc.CreateProduct(context.Background(), p)
-	prod, err := svc.GetProductByBarcode(context.Background(), "123")
-	if err != nil || prod == nil {
-		t.Fatalf("erro ao buscar produto: %v", err)
+func (m *mockProductRepo) GetProductAsOf(ctx context.Context, barcode string, asOf time.Time) (*Product, error) {
+	p, ok := m.products[barcode]
+	if !ok {
+		return nil, nil
 	}
-	if prod.Barcode != "123" {
-		t.Errorf("barcode incorreto: %v", prod.Barcode)
+	historical := *p
+	historical.Quantity = 0
+	for _, mv := range m.movements {
+		if mv.ProductID == p.ID && !mv.CreatedAt.After(asOf) {
+			historical.Quantity = mv.Balance
+		}
 	}
-	// Não encontrado
-	prod, err = svc.GetProductByBarcode(context.Background(), "999")
-	if err != nil {
-		t.Fatalf("erro inesperado: %v", err)
+	historical.Available = historical.Quantity
+	return &historical, nil
+}
+func (m *mockProductRepo) GetStockLevelsAsOf(ctx context.Context, productID int, asOf time.Time) ([]StockLevel, error) {
+	return m.GetStockLevels(ctx, productID)
+}
+func (m *mockProductRepo) TakeSnapshots(ctx context.Context, until time.Time) (int, error) {
+	return 0, nil
+}
+func (m *mockProductRepo) SetLocationMinStock(ctx context.Context, productID, locationID, minStock int) error {
+	if m.fail {
+		return fmt.Errorf("db error")
 	}
-	if prod != nil {
-		t.Error("esperado nil para produto inexistente")
+	for i := range m.levels {
+		if m.levels[i].productID == productID && m.levels[i].LocationID == locationID {
+			m.levels[i].MinStock = minStock
+			return nil
+		}
 	}
+	m.levels = append(m.levels, mockLevel{productID: productID, StockLevel: StockLevel{LocationID: locationID, MinStock: minStock}})
+	return nil
 }
-
-func TestService_StockEntryExit_Mock(t *testing.T) {
-	repo := &mockProductRepo{products: make(map[string]*Product)}
-	svc := NewService(repo, nil)
-	p := &Product{Name: "Produto Teste", Barcode: "123", Quantity: 10, MinStock: 2}
-	_ = svc.CreateProduct(context.Background(), p)
-	err := svc.StockEntry(context.Background(), "123", 5)
-	if err != nil {
-		t.Fatalf("erro ao dar entrada: %v", err)
+func (m *mockProductRepo) record(p *Product, mv *StockMovement) {
+	mv.ID = len(m.movements) + 1
+	mv.ProductID = p.ID
+	mv.Balance = p.Quantity
+	mv.CreatedAt = time.Now()
+	mv.limits = stockLimits{productName: p.Name, minStock: p.MinStock, reorderPoint: p.ReorderPoint, policy: p.NegativeStockPolicy, floor: p.NegativeStockFloor}
+	m.movements = append(m.movements, *mv)
+}
+func (m *mockProductRepo) GetMovements(ctx context.Context, q MovementsQuery) ([]StockMovement, int, error) {
+	if m.fail {
+		return nil, 0, fmt.Errorf("db error")
 	}
-	if p.Quantity != 15 {
-		t.Errorf("esperado 15, veio %d", p.Quantity)
+	var result []StockMovement
+	for _, mv := range m.movements {
+		if mv.ProductID == q.ProductID {
+			result = append(result, mv)
+		}
 	}
-	err = svc.StockExit(context.Background(), "123", 10)
-	if err != nil {
-		t.Fatalf("erro ao dar saída: %v", err)
+	return result, len(result), nil
+}
+func (m *mockProductRepo) GetLots(ctx context.Context, productID int) ([]Lot, error) {
+	if m.fail {
+		return nil, fmt.Errorf("db error")
 	}
-	if p.Quantity != 5 {
-		t.Errorf("esperado 5, veio %d", p.Quantity)
+	lots := []Lot{}
+	for _, l := range m.lots {
+		if l.ProductID == productID {
+			lots = append(lots, l)
+		}
 	}
-	// Estoque insuficiente
-	err = svc.StockExit(context.Background(), "123", 99)
-	if err == nil {
-		t.Error("esperado erro de estoque insuficiente")
+	return lots, nil
+}
+func (m *mockProductRepo) GetExpiringLots(ctx context.Context, until time.Time) ([]Lot, error) {
+	if m.fail {
+		return nil, fmt.Errorf("db error")
+	}
+	lots := []Lot{}
+	for _, l := range m.lots {
+		if l.ExpiryDate != nil && !l.ExpiryDate.After(until) {
+			lots = append(lots, l)
+		}
 	}
+	return lots, nil
 }
-
-func TestService_Failures_Mock(t *testing.T) {
-	repo := &mockProductRepo{products: make(map[string]*Product), fail: true}
-	svc := NewService(repo, nil)
-	p := &Product{Name: "Produto Teste", Barcode: "123", Quantity: 10, MinStock: 2}
-	if err := svc.CreateProduct(context.Background(), p); err == nil {
-		t.Error("esperado erro de banco")
+func (m *mockProductRepo) MarkLotsNotified(ctx context.Context, ids []int) error {
+	if m.fail {
+		return fmt.Errorf("db error")
 	}
-	if _, _, err := svc.GetProducts(context.Background(), ProductsQuery{}); err == nil {
-		t.Error("esperado erro de banco")
+	now := time.Now()
+	for i := range m.lots {
+		for _, id := range ids {
+			if m.lots[i].ID == id {
+				m.lots[i].NotifiedAt = &now
+			}
+		}
+	}
+	return nil
+}
+
+func (m *mockProductRepo) GetSerial(ctx context.Context, serial string) (*SerialNumber, error) {
+	if m.fail {
+		return nil, fmt.Errorf("db error")
+	}
+	sn := &SerialNumber{Serial: serial, History: []StockMovement{}}
+	for _, mv := range m.movements {
+		for _, s := range mv.Serials {
+			if s == serial {
+				sn.ProductID = mv.ProductID
+				sn.Status = SerialInStock
+				if mv.Delta < 0 {
+					sn.Status = SerialOut
+				}
+				sn.History = append(sn.History, mv)
+			}
+		}
+	}
+	if len(sn.History) == 0 {
+		return nil, nil
+	}
+	return sn, nil
+}
+
+func (m *mockProductRepo) reserved(productID int) int {
+	total := 0
+	for _, res := range m.reservations {
+		if res.ProductID == productID && res.Status == ReservationActive && res.ExpiresAt.After(time.Now()) {
+			total += res.Quantity
+		}
+	}
+	return total
+}
+func (m *mockProductRepo) CreateReservation(ctx context.Context, res *Reservation) error {
+	if m.fail {
+		return fmt.Errorf("db error")
+	}
+	p, ok := m.products[res.Barcode]
+	if !ok {
+		return ErrProductNotFound
+	}
+	if p.Quantity-m.reserved(p.ID) < res.Quantity {
+		return ErrReservedStock
+	}
+	res.ID = len(m.reservations) + 1
+	res.ProductID = p.ID
+	res.Status = ReservationActive
+	m.reservations = append(m.reservations, *res)
+	return nil
+}
+func (m *mockProductRepo) GetReservations(ctx context.Context, productID int) ([]Reservation, error) {
+	if m.fail {
+		return nil, fmt.Errorf("db error")
+	}
+	result := []Reservation{}
+	for _, res := range m.reservations {
+		if res.ProductID == productID && res.Status == ReservationActive {
+			result = append(result, res)
+		}
+	}
+	return result, nil
+}
+func (m *mockProductRepo) CloseReservation(ctx context.Context, id int, status string, locationID int) (*Reservation, *StockMovement, error) {
+	if m.fail {
+		return nil, nil, fmt.Errorf("db error")
+	}
+	if id < 1 || id > len(m.reservations) {
+		return nil, nil, ErrReservationNotFound
+	}
+	res := &m.reservations[id-1]
+	if res.Status != ReservationActive || !res.ExpiresAt.After(time.Now()) {
+		return nil, nil, ErrReservationClosed
+	}
+	res.Status = status
+	var mv *StockMovement
+	if status == ReservationCommitted {
+		p := m.products[res.Barcode]
+		p.Quantity -= res.Quantity
+		mv = &StockMovement{LocationID: locationID, Delta: -res.Quantity, Reason: ReasonReservation}
+		m.record(p, mv)
+	}
+	closed := *res
+	return &closed, mv, nil
+}
+func (m *mockProductRepo) ExpireReservations(ctx context.Context) (int64, error) {
+	if m.fail {
+		return 0, fmt.Errorf("db error")
+	}
+	var n int64
+	for i := range m.reservations {
+		if m.reservations[i].Status == ReservationActive && !m.reservations[i].ExpiresAt.After(time.Now()) {
+			m.reservations[i].Status = ReservationExpired
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *mockProductRepo) GetDailyDemand(ctx context.Context, productID int, from, to time.Time) ([]DailyDemand, *time.Time, error) {
+	if m.fail {
+		return nil, nil, fmt.Errorf("db error")
+	}
+	var first *time.Time
+	byDay := map[time.Time]int{}
+	for _, mv := range m.movements {
+		if mv.ProductID != productID {
+			continue
+		}
+		if first == nil || mv.CreatedAt.Before(*first) {
+			at := mv.CreatedAt
+			first = &at
+		}
+		if !slices.Contains(NonDemandReasons, mv.Reason) && mv.Delta < 0 && !mv.CreatedAt.Before(from) && mv.CreatedAt.Before(to) {
+			byDay[mv.CreatedAt.UTC().Truncate(24*time.Hour)] -= mv.Delta
+		}
+	}
+	days := []DailyDemand{}
+	for d, q := range byDay {
+		days = append(days, DailyDemand{Date: d, Quantity: q})
+	}
+	return days, first, nil
+}
+
+func (m *mockProductRepo) GetLeadTime(ctx context.Context, productID int) (int, error) {
+	return m.leadTimes[productID], nil
+}
+
+func (m *mockProductRepo) GetUnits(ctx context.Context, productID int) ([]ProductUnit, error) {
+	var units []ProductUnit
+	for _, u := range m.units {
+		if u.ProductID == productID {
+			units = append(units, u)
+		}
+	}
+	return units, nil
+}
+func (m *mockProductRepo) SetUnit(ctx context.Context, u *ProductUnit) error {
+	if u.Barcode != nil && m.products[*u.Barcode] != nil {
+		return ErrBarcodeInUse
+	}
+	for i := range m.units {
+		if m.units[i].ProductID == u.ProductID && m.units[i].Unit == u.Unit {
+			m.units[i] = *u
+			return nil
+		}
+	}
+	m.units = append(m.units, *u)
+	return nil
+}
+func (m *mockProductRepo) DeleteUnit(ctx context.Context, productID int, unit string) error {
+	for i, u := range m.units {
+		if u.ProductID == productID && u.Unit == unit {
+			m.units = slices.Delete(m.units, i, i+1)
+			return nil
+		}
+	}
+	return ErrUnitNotFound
+}
+func (m *mockProductRepo) ResolveUnit(ctx context.Context, barcode, unit string) (string, int, error) {
+	byID := func(id int) *Product {
+		for _, p := range m.products {
+			if p.ID == id {
+				return p
+			}
+		}
+		return nil
+	}
+	for _, u := range m.units {
+		if u.Barcode != nil && *u.Barcode == barcode {
+			if unit != "" && unit != u.Unit {
+				return "", 0, ErrUnitMismatch
+			}
+			return byID(u.ProductID).Barcode, u.Factor, nil
+		}
+	}
+	p := m.byCode(barcode)
+	if p == nil {
+		return barcode, 1, nil
+	}
+	if unit == "" || unit == p.BaseUnit {
+		return p.Barcode, 1, nil
+	}
+	for _, u := range m.units {
+		if u.ProductID == p.ID && u.Unit == unit {
+			return p.Barcode, u.Factor, nil
+		}
+	}
+	return "", 0, ErrUnitNotFound
+}
+
+func (m *mockProductRepo) GetIdentifiers(ctx context.Context, productID int) ([]Identifier, error) {
+	var ids []Identifier
+	for _, p := range m.products {
+		if p.ID == productID {
+			ids = append(ids, Identifier{Code: p.Barcode, Kind: IdentifierBarcode, Primary: true})
+		}
+	}
+	for _, id := range m.identifiers {
+		if id.productID == productID {
+			ids = append(ids, id.Identifier)
+		}
+	}
+	return ids, nil
+}
+func (m *mockProductRepo) AddIdentifier(ctx context.Context, productID int, id *Identifier) error {
+	if m.byCode(id.Code) != nil {
+		return ErrBarcodeInUse
+	}
+	m.identifiers = append(m.identifiers, mockIdentifier{productID, *id})
+	return nil
+}
+func (m *mockProductRepo) DeleteIdentifier(ctx context.Context, productID int, code string) error {
+	if p := m.products[code]; p != nil && p.ID == productID {
+		return ErrPrimaryIdentifier
+	}
+	for i, id := range m.identifiers {
+		if id.productID == productID && id.Code == code {
+			m.identifiers = slices.Delete(m.identifiers, i, i+1)
+			return nil
+		}
+	}
+	return ErrIdentifierNotFound
+}
+
+// SetPrimaryBarcode troca o código principal, que passa a ser a chave do mapa; o anterior vira identificador
+func (m *mockProductRepo) SetPrimaryBarcode(ctx context.Context, productID int, code string) error {
+	for i, id := range m.identifiers {
+		if id.productID != productID || id.Code != code {
+			continue
+		}
+		if id.Kind != IdentifierBarcode {
+			return ErrPrimarySKU
+		}
+		p := m.byCode(code)
+		m.identifiers[i].Identifier = Identifier{Code: p.Barcode, Kind: IdentifierBarcode}
+		delete(m.products, p.Barcode)
+		p.Barcode = code
+		m.products[code] = p
+		return nil
+	}
+	return ErrIdentifierNotFound
+}
+func (m *mockProductRepo) GetBarcodeRules(ctx context.Context) ([]BarcodeRule, error) {
+	return m.rules, nil
+}
+func (m *mockProductRepo) CreateBarcodeRule(ctx context.Context, rule *BarcodeRule) error {
+	for _, r := range m.rules {
+		if r.Prefix == rule.Prefix {
+			return ErrRuleExists
+		}
+	}
+	rule.ID = len(m.rules) + 1
+	m.rules = append(m.rules, *rule)
+	return nil
+}
+func (m *mockProductRepo) DeleteBarcodeRule(ctx context.Context, id int) error {
+	for i, r := range m.rules {
+		if r.ID == id {
+			m.rules = append(m.rules[:i], m.rules[i+1:]...)
+			return nil
+		}
+	}
+	return ErrRuleNotFound
+}
+func (m *mockProductRepo) ExportProducts(ctx context.Context, q ProductsQuery, fn func(*Product) error) error {
+	if m.fail {
+		return fmt.Errorf("db error")
+	}
+	var products []*Product
+	for _, p := range m.products {
+		if q.Name == "" || strings.Contains(strings.ToLower(p.Name), strings.ToLower(q.Name)) {
+			products = append(products, p)
+		}
+	}
+	slices.SortFunc(products, func(a, b *Product) int { return a.ID - b.ID })
+	for _, p := range products {
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (m *mockProductRepo) ExportMovements(ctx context.Context, q MovementsQuery, fn func(*StockMovement) error) error {
+	for i := range m.movements {
+		if q.ProductID == 0 || m.movements[i].ProductID == q.ProductID {
+			if err := fn(&m.movements[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+func (m *mockProductRepo) ImportProducts(ctx context.Context, rows []ImportRow, dryRun, partial bool, prepare func(*Product, ImportRow) error) (*ImportReport, error) {
+	report := &ImportReport{Rows: len(rows), DryRun: dryRun, Errors: []ImportError{}}
+	var valid []Product
+	for _, row := range rows {
+		var p Product
+		if existing := m.byCode(row.Barcode); existing != nil {
+			p = *existing
+		} else {
+			p.Barcode = row.Barcode
+		}
+		if err := prepare(&p, row); err != nil {
+			report.Failed++
+			report.Errors = append(report.Errors, ImportError{Row: row.Line, Barcode: row.Barcode, Error: err.Error()})
+			continue
+		}
+		if p.ID == 0 {
+			report.Created++
+		} else {
+			report.Updated++
+		}
+		valid = append(valid, p)
+	}
+	if dryRun || (report.Failed > 0 && !partial) {
+		return report, nil
+	}
+	for _, p := range valid {
+		p := p
+		if p.ID == 0 {
+			p.ID = len(m.products) + 1
+		}
+		m.products[p.Barcode] = &p
+	}
+	report.Committed = true
+	return report, nil
+}
+
+type recordingSender struct {
+	events []notifications.NotificationEvent
+}
+
+func (r *recordingSender) Send(event notifications.NotificationEvent) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+func TestService_CreateProduct_Mock(t *testing.T) {
+	repo := &mockProductRepo{products: make(map[string]*Product)}
+	svc := NewService(repo, nil)
+	p := &Product{Name: "Produto Teste", Barcode: "123", Quantity: 10, MinStock: 2}
+	err := svc.CreateProduct(context.Background(), p)
+	if err != nil {
+		t.Fatalf("erro ao criar produto: %v", err)
+	}
+	if p.ID == 0 {
+		t.Error("ID não foi preenchido")
+	}
+	// Duplicidade
+	err = svc.CreateProduct(context.Background(), &Product{Name: "Produto Teste", Barcode: "123", Quantity: 1, MinStock: 1})
+	if err == nil {
+		t.Error("esperado erro de duplicidade")
+	}
+}
+
+func TestUpdateProductHandler_Mock(t *testing.T) {
+	repo := &mockProductRepo{products: make(map[string]*Product)}
+	svc := NewService(repo, nil)
+	category := 3
+	_ = svc.CreateProduct(context.Background(), &Product{Name: "P", Barcode: "7891234567895", MinStock: 2, Price: 1.5, CostingMethod: CostingFIFO,
+		ReorderPoint: 4, ReorderQty: 10, BaseUnit: "g", InternalCode: true, CategoryID: &category})
+	r := chi.NewRouter()
+	r.Put("/products/{id}", updateProductHandler(svc))
+	put := func(path, body string) int {
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, httptest.NewRequest("PUT", path, bytes.NewBufferString(body)))
+		return resp.Code
+	}
+	// Campos omitidos mantêm o valor gravado
+	if code := put("/products/1", `{"name":"Novo"}`); code != http.StatusOK {
+		t.Fatalf("esperado 200, veio %d", code)
+	}
+	p := repo.products["7891234567895"]
+	if p.Name != "Novo" || p.MinStock != 2 || p.Price != 1.5 || p.CostingMethod != CostingFIFO || p.ReorderPoint != 4 ||
+		p.ReorderQty != 10 || p.BaseUnit != "g" || !p.InternalCode || p.CategoryID == nil || *p.CategoryID != 3 {
+		t.Errorf("campos omitidos foram alterados: %+v", p)
+	}
+	// Campos informados substituem o valor, inclusive null
+	if code := put("/products/1", `{"category_id":null,"internal_code":false}`); code != http.StatusOK {
+		t.Fatalf("esperado 200, veio %d", code)
+	}
+	if p := repo.products["7891234567895"]; p.CategoryID != nil || p.InternalCode || p.Name != "Novo" {
+		t.Errorf("campos informados não foram aplicados: %+v", p)
+	}
+	if code := put("/products/1", `{"quantity":5}`); code != http.StatusBadRequest {
+		t.Errorf("alterar a quantidade deveria dar 400, veio %d", code)
+	}
+	if code := put("/products/99", `{"name":"X"}`); code != http.StatusNotFound {
+		t.Errorf("esperado 404, veio %d", code)
+	}
+}
+
+func TestService_GetProductByBarcode_Mock(t *testing.T) {
+	repo := &mockProductRepo{products: make(map[string]*Product)}
+	svc := NewService(repo, nil)
+	p := &Product{Name: "Produto Teste", Barcode: "123", Quantity: 10, MinStock: 2}
+	_ = svc.CreateProduct(context.Background(), p)
+	prod, err := svc.GetProductByBarcode(context.Background(), "123")
+	if err != nil || prod == nil {
+		t.Fatalf("erro ao buscar produto: %v", err)
+	}
+	if prod.Barcode != "123" {
+		t.Errorf("barcode incorreto: %v", prod.Barcode)
+	}
+	// Não encontrado
+	prod, err = svc.GetProductByBarcode(context.Background(), "999")
+	if err != nil {
+		t.Fatalf("erro inesperado: %v", err)
+	}
+	if prod != nil {
+		t.Error("esperado nil para produto inexistente")
+	}
+}
+
+func TestService_StockEntryExit_Mock(t *testing.T) {
+	repo := &mockProductRepo{products: make(map[string]*Product)}
+	svc := NewService(repo, nil)
+	p := &Product{Name: "Produto Teste", Barcode: "123", Quantity: 10, MinStock: 2}
+	_ = svc.CreateProduct(context.Background(), p)
+	err := svc.StockEntry(context.Background(), "123", StockRequest{Quantity: 5})
+	if err != nil {
+		t.Fatalf("erro ao dar entrada: %v", err)
+	}
+	if p.Quantity != 15 {
+		t.Errorf("esperado 15, veio %d", p.Quantity)
+	}
+	err = svc.StockExit(context.Background(), "123", StockRequest{Quantity: 10})
+	if err != nil {
+		t.Fatalf("erro ao dar saída: %v", err)
+	}
+	if p.Quantity != 5 {
+		t.Errorf("esperado 5, veio %d", p.Quantity)
+	}
+	// Estoque insuficiente
+	err = svc.StockExit(context.Background(), "123", StockRequest{Quantity: 99})
+	if err == nil {
+		t.Error("esperado erro de estoque insuficiente")
+	}
+}
+
+func TestService_GetMovements_Mock(t *testing.T) {
+	repo := &mockProductRepo{products: make(map[string]*Product)}
+	svc := NewService(repo, nil)
+	_ = svc.CreateProduct(context.Background(), &Product{Name: "Produto Teste", Barcode: "123", Quantity: 10, MinStock: 2})
+	_ = svc.StockEntry(context.Background(), "123", StockRequest{Quantity: 5})
+	_ = svc.StockExit(context.Background(), "123", StockRequest{Quantity: 2, Reference: "pedido 42"})
+	movements, total, err := svc.GetMovements(context.Background(), "123", MovementsQuery{})
+	if err != nil {
+		t.Fatalf("erro ao listar movimentações: %v", err)
+	}
+	if total != 2 {
+		t.Fatalf("esperado 2 movimentações, veio %d", total)
+	}
+	if movements[0].Reason != ReasonEntry || movements[0].Balance != 15 {
+		t.Errorf("entrada incorreta: %+v", movements[0])
+	}
+	if movements[1].Reason != ReasonExit || movements[1].Delta != -2 || movements[1].Reference != "pedido 42" {
+		t.Errorf("saída incorreta: %+v", movements[1])
+	}
+	// Produto inexistente
+	if _, _, err := svc.GetMovements(context.Background(), "999", MovementsQuery{}); err != ErrProductNotFound {
+		t.Errorf("esperado ErrProductNotFound, veio %v", err)
+	}
+}
+
+func TestService_GetProductAsOf_Mock(t *testing.T) {
+	repo := &mockProductRepo{products: make(map[string]*Product)}
+	svc := NewService(repo, nil)
+	_ = svc.CreateProduct(context.Background(), &Product{Name: "Produto Teste", Barcode: "123", Quantity: 10})
+	before := time.Now()
+	_ = svc.StockEntry(context.Background(), "123", StockRequest{Quantity: 5})
+	p, err := svc.GetProductAsOf(context.Background(), "123", before)
+	if err != nil {
+		t.Fatalf("erro ao consultar histórico: %v", err)
+	}
+	if p.Quantity != 0 || p.Available != 0 {
+		t.Errorf("esperado 0 antes de qualquer movimentação, veio %+v", p)
+	}
+	p, _ = svc.GetProductAsOf(context.Background(), "123", time.Now())
+	if p.Quantity != 15 {
+		t.Errorf("esperado 15, veio %d", p.Quantity)
+	}
+	if _, err := svc.GetStockLevelsAsOf(context.Background(), "999", before); err != ErrProductNotFound {
+		t.Errorf("esperado ErrProductNotFound, veio %v", err)
+	}
+}
+
+func TestMovingAverage(t *testing.T) {
+	cases := []struct {
+		qty     int
+		average float64
+		in      int
+		cost    float64
+		want    float64
+	}{
+		{0, 0, 10, 2, 2},
+		{10, 2, 10, 4, 3},
+		{30, 1, 10, 5, 2},
+		{-5, 3, 10, 6, 6},
+	}
+	for _, c := range cases {
+		if got := movingAverage(c.qty, c.average, c.in, c.cost); got != c.want {
+			t.Errorf("%+v: esperado %.4f, veio %.4f", c, c.want, got)
+		}
+	}
+}
+
+func TestFifoCost(t *testing.T) {
+	layers := []costLayer{{id: 1, unitCost: 2, remaining: 10}, {id: 2, unitCost: 4, remaining: 10}}
+	cost, taken := fifoCost(layers, 15, 0)
+	if cost != 40 || len(taken) != 2 || taken[0] != 10 || taken[1] != 5 {
+		t.Errorf("esperado 40 de [10 5], veio %.2f de %v", cost, taken)
+	}
+	cost, taken = fifoCost(layers, 5, 0)
+	if cost != 10 || len(taken) != 1 || taken[0] != 5 {
+		t.Errorf("esperado 10 de [5], veio %.2f de %v", cost, taken)
+	}
+	// Sem camadas suficientes, o restante sai pelo custo de reserva
+	cost, _ = fifoCost(layers, 25, 3)
+	if cost != 75 {
+		t.Errorf("esperado 75, veio %.2f", cost)
+	}
+}
+
+func TestSnapshotBoundary(t *testing.T) {
+	cases := []struct {
+		now  string
+		want string
+	}{
+		{"2026-12-31T23:59:59Z", "2026-12-31T00:00:00Z"},
+		{"2027-01-01T00:30:00Z", "2026-12-31T00:00:00Z"},
+		{"2027-01-01T01:00:00Z", "2027-01-01T00:00:00Z"},
+		{"2027-01-01T02:00:00-03:00", "2027-01-01T00:00:00Z"},
+	}
+	for _, c := range cases {
+		now, _ := time.Parse(time.RFC3339, c.now)
+		if got := snapshotBoundary(now).Format(time.RFC3339); got != c.want {
+			t.Errorf("%s: esperado %s, veio %s", c.now, c.want, got)
+		}
+	}
+}
+
+func TestService_LocationMinStock_Mock(t *testing.T) {
+	repo := &mockProductRepo{products: make(map[string]*Product)}
+	svc := NewService(repo, nil)
+	_ = svc.CreateProduct(context.Background(), &Product{Name: "Produto Teste", Barcode: "123", Quantity: 10, MinStock: 2})
+	if err := svc.SetLocationMinStock(context.Background(), "123", 2, 4); err != nil {
+		t.Fatalf("erro ao definir mínimo por local: %v", err)
+	}
+	levels, err := svc.GetStockLevels(context.Background(), "123")
+	if err != nil {
+		t.Fatalf("erro ao listar níveis: %v", err)
+	}
+	if len(levels) != 1 || levels[0].LocationID != 2 || levels[0].MinStock != 4 {
+		t.Errorf("nível por local incorreto: %+v", levels)
+	}
+	if err := svc.SetLocationMinStock(context.Background(), "999", 2, 4); err != ErrProductNotFound {
+		t.Errorf("esperado ErrProductNotFound, veio %v", err)
+	}
+}
+
+func TestService_StockLots_Mock(t *testing.T) {
+	repo := &mockProductRepo{products: make(map[string]*Product)}
+	svc := NewService(repo, nil)
+	_ = svc.CreateProduct(context.Background(), &Product{Name: "Produto Teste", Barcode: "123", Quantity: 0, MinStock: 0})
+	if err := svc.StockEntry(context.Background(), "123", StockRequest{Quantity: 5, LotNumber: "L1", ExpiryDate: "2026-12-31"}); err != nil {
+		t.Fatalf("erro ao dar entrada: %v", err)
+	}
+	lots := repo.movements[0].Lots
+	if len(lots) != 1 || lots[0].LotNumber != "L1" || lots[0].Quantity != 5 || lots[0].ExpiryDate.Format("2006-01-02") != "2026-12-31" {
+		t.Errorf("lote da entrada incorreto: %+v", lots)
+	}
+	if err := svc.StockExit(context.Background(), "123", StockRequest{Quantity: 2, LotNumber: "L1"}); err != nil {
+		t.Fatalf("erro ao dar saída: %v", err)
+	}
+	if lots := repo.movements[1].Lots; len(lots) != 1 || lots[0].Quantity != 2 {
+		t.Errorf("lote da saída incorreto: %+v", lots)
+	}
+	// Saída sem lote fica a cargo do FEFO no repositório
+	_ = svc.StockExit(context.Background(), "123", StockRequest{Quantity: 1})
+	if repo.movements[2].Lots != nil {
+		t.Errorf("saída sem lote não deveria nomear lotes: %+v", repo.movements[2].Lots)
+	}
+	if err := svc.StockEntry(context.Background(), "123", StockRequest{Quantity: 1, LotNumber: "L2", ExpiryDate: "31/12/2026"}); err != ErrInvalidExpiryDate {
+		t.Errorf("esperado ErrInvalidExpiryDate, veio %v", err)
+	}
+}
+
+func TestService_NotifyExpiringLots_Mock(t *testing.T) {
+	soon := time.Now().AddDate(0, 0, 3)
+	later := time.Now().AddDate(0, 0, 90)
+	repo := &mockProductRepo{products: make(map[string]*Product), lots: []Lot{
+		{ID: 1, ProductID: 1, ProductName: "Leite", Barcode: "123", LotNumber: "L1", ExpiryDate: &soon, Quantity: 4},
+		{ID: 2, ProductID: 1, ProductName: "Leite", Barcode: "123", LotNumber: "L2", ExpiryDate: &later, Quantity: 6},
+	}}
+	sender := &recordingSender{}
+	svc := NewService(repo, notifications.NewNotificationService(sender))
+	if err := svc.NotifyExpiringLots(context.Background(), 30); err != nil {
+		t.Fatalf("erro ao notificar lotes: %v", err)
+	}
+	if len(sender.events) != 1 || sender.events[0].Type != "expiring_soon" || sender.events[0].Data["lot_number"] != "L1" {
+		t.Fatalf("eventos incorretos: %+v", sender.events)
+	}
+	// Lotes já avisados não geram um novo evento
+	_ = svc.NotifyExpiringLots(context.Background(), 30)
+	if len(sender.events) != 1 {
+		t.Errorf("esperado 1 evento, veio %d", len(sender.events))
+	}
+	expiring, err := svc.GetExpiringLots(context.Background(), 100)
+	if err != nil || len(expiring) != 2 {
+		t.Errorf("esperado 2 lotes em 100 dias, veio %d (%v)", len(expiring), err)
+	}
+}
+
+func TestService_Serials_Mock(t *testing.T) {
+	repo := &mockProductRepo{products: make(map[string]*Product)}
+	svc := NewService(repo, nil)
+	_ = svc.CreateProduct(context.Background(), &Product{Name: "Notebook", Barcode: "123", Serialized: true})
+	// A quantidade vem da lista de séries quando omitida
+	if err := svc.StockEntry(context.Background(), "123", StockRequest{Serials: []string{"SN1", "SN2"}}); err != nil {
+		t.Fatalf("erro ao dar entrada: %v", err)
+	}
+	if repo.movements[0].Delta != 2 {
+		t.Errorf("esperado delta 2, veio %d", repo.movements[0].Delta)
+	}
+	if err := svc.StockExit(context.Background(), "123", StockRequest{Quantity: 2, Serials: []string{"SN1"}}); err != ErrSerialCount {
+		t.Errorf("esperado ErrSerialCount, veio %v", err)
+	}
+	if err := svc.StockExit(context.Background(), "123", StockRequest{Serials: []string{"SN1"}}); err != nil {
+		t.Fatalf("erro ao dar saída: %v", err)
+	}
+	sn, err := svc.GetSerial(context.Background(), "SN1")
+	if err != nil {
+		t.Fatalf("erro ao buscar série: %v", err)
+	}
+	if sn.Status != SerialOut || len(sn.History) != 2 {
+		t.Errorf("série incorreta: %+v", sn)
+	}
+	if _, err := svc.GetSerial(context.Background(), "SN9"); err != ErrSerialNotFound {
+		t.Errorf("esperado ErrSerialNotFound, veio %v", err)
+	}
+}
+
+func TestService_Reservations_Mock(t *testing.T) {
+	repo := &mockProductRepo{products: make(map[string]*Product)}
+	svc := NewService(repo, nil)
+	_ = svc.CreateProduct(context.Background(), &Product{Name: "Produto Teste", Barcode: "123", Quantity: 5, MinStock: 0})
+	res, err := svc.CreateReservation(context.Background(), "123", ReservationRequest{Quantity: 3})
+	if err != nil {
+		t.Fatalf("erro ao reservar: %v", err)
+	}
+	// Sem ttl_seconds a reserva usa a validade padrão
+	if ttl := time.Until(res.ExpiresAt); ttl < defaultReservationTTL-time.Minute || ttl > defaultReservationTTL {
+		t.Errorf("validade incorreta: %v", ttl)
+	}
+	if _, err := svc.CreateReservation(context.Background(), "123", ReservationRequest{Quantity: 3}); err != ErrReservedStock {
+		t.Errorf("esperado ErrReservedStock, veio %v", err)
+	}
+	if _, err := svc.CreateReservation(context.Background(), "999", ReservationRequest{Quantity: 1}); err != ErrProductNotFound {
+		t.Errorf("esperado ErrProductNotFound, veio %v", err)
+	}
+	short, _ := svc.CreateReservation(context.Background(), "123", ReservationRequest{Quantity: 2, TTLSeconds: 1})
+	repo.reservations[short.ID-1].ExpiresAt = time.Now().Add(-time.Second)
+	if n, _ := svc.ExpireReservations(context.Background()); n != 1 {
+		t.Errorf("esperado 1 reserva expirada, veio %d", n)
+	}
+	if _, err := svc.CommitReservation(context.Background(), short.ID, 0); err != ErrReservationClosed {
+		t.Errorf("esperado ErrReservationClosed, veio %v", err)
+	}
+	committed, err := svc.CommitReservation(context.Background(), res.ID, 0)
+	if err != nil {
+		t.Fatalf("erro ao confirmar reserva: %v", err)
+	}
+	if committed.Status != ReservationCommitted || repo.products["123"].Quantity != 2 {
+		t.Errorf("confirmação incorreta: %+v, quantidade %d", committed, repo.products["123"].Quantity)
+	}
+	if _, err := svc.ReleaseReservation(context.Background(), res.ID); err != ErrReservationClosed {
+		t.Errorf("esperado ErrReservationClosed, veio %v", err)
+	}
+	if _, err := svc.ReleaseReservation(context.Background(), 99); err != ErrReservationNotFound {
+		t.Errorf("esperado ErrReservationNotFound, veio %v", err)
+	}
+}
+
+func TestService_NegativeStockPolicy_Mock(t *testing.T) {
+	repo := &mockProductRepo{products: make(map[string]*Product)}
+	svc := NewService(repo, nil)
+	p := &Product{Name: "Produto Teste", Barcode: "123", Quantity: 2}
+	_ = svc.CreateProduct(context.Background(), p)
+	if p.NegativeStockPolicy != PolicyForbid {
+		t.Errorf("esperado política forbid por padrão, veio %q", p.NegativeStockPolicy)
+	}
+	if err := svc.StockExit(context.Background(), "123", StockRequest{Quantity: 3}); err == nil {
+		t.Error("esperado erro de estoque insuficiente")
+	}
+	p.NegativeStockPolicy, p.NegativeStockFloor = PolicyFloor, -3
+	if err := svc.StockExit(context.Background(), "123", StockRequest{Quantity: 5}); err != nil {
+		t.Fatalf("saída até o piso deveria ser aceita: %v", err)
+	}
+	if err := svc.StockExit(context.Background(), "123", StockRequest{Quantity: 1}); err == nil {
+		t.Error("saída abaixo do piso deveria ser recusada")
+	}
+	p.NegativeStockPolicy = PolicyAllow
+	if err := svc.StockExit(context.Background(), "123", StockRequest{Quantity: 10}); err != nil {
+		t.Fatalf("saída com estoque negativo liberado deveria ser aceita: %v", err)
+	}
+	if p.Quantity != -13 {
+		t.Errorf("esperado -13, veio %d", p.Quantity)
+	}
+}
+
+func TestService_LowStockNotification_Mock(t *testing.T) {
+	repo := &mockProductRepo{products: make(map[string]*Product)}
+	sender := &recordingSender{}
+	svc := NewService(repo, notifications.NewNotificationService(sender))
+	_ = svc.CreateProduct(context.Background(), &Product{Name: "Produto Teste", Barcode: "123", Quantity: 5, MinStock: 3})
+	_ = svc.StockExit(context.Background(), "123", StockRequest{Quantity: 1})
+	if len(sender.events) != 0 {
+		t.Fatalf("nenhum alerta esperado, veio %+v", sender.events)
+	}
+	_ = svc.StockExit(context.Background(), "123", StockRequest{Quantity: 2})
+	// O alerta usa o saldo devolvido pela própria saída
+	if len(sender.events) != 1 || sender.events[0].Type != "low_stock" || sender.events[0].Data["quantity"] != 2 {
+		t.Errorf("alerta incorreto: %+v", sender.events)
+	}
+}
+
+func TestService_Failures_Mock(t *testing.T) {
+	repo := &mockProductRepo{products: make(map[string]*Product), fail: true}
+	svc := NewService(repo, nil)
+	p := &Product{Name: "Produto Teste", Barcode: "123", Quantity: 10, MinStock: 2}
+	if err := svc.CreateProduct(context.Background(), p); err == nil {
+		t.Error("esperado erro de banco")
+	}
+	if _, _, err := svc.GetProducts(context.Background(), ProductsQuery{}); err == nil {
+		t.Error("esperado erro de banco")
 	}
 	if _, err := svc.GetProductByBarcode(context.Background(), "123"); err == nil {
 		t.Error("esperado erro de banco")
@@ -781,10 +2022,447 @@ func TestService_Failures_Mock(t *testing.T) {
 	if err := svc.DeleteProduct(context.Background(), 1); err == nil {
 		t.Error("esperado erro de banco")
 	}
-	if err := svc.StockEntry(context.Background(), "123", 1); err == nil {
+	if err := svc.StockEntry(context.Background(), "123", StockRequest{Quantity: 1}); err == nil {
 		t.Error("esperado erro de banco")
 	}
-	if err := svc.StockExit(context.Background(), "123", 1); err == nil {
+	if err := svc.StockExit(context.Background(), "123", StockRequest{Quantity: 1}); err == nil {
 		t.Error("esperado erro de banco")
 	}
 }
+
+func TestDemandSeries(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	days := []DailyDemand{{Date: from, Quantity: 4}, {Date: from.AddDate(0, 0, 2), Quantity: 6}, {Date: from.AddDate(0, 0, 5), Quantity: 9}}
+	series := demandSeries(days, from, from.AddDate(0, 0, 4))
+	if len(series) != 4 || series[0] != 4 || series[1] != 0 || series[2] != 6 || series[3] != 0 {
+		t.Errorf("série incorreta: %v", series)
+	}
+}
+
+func TestForecast(t *testing.T) {
+	// Quatro semanas começando num domingo: 10 por dia útil e nada no fim de semana
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	series := make([]float64, 28)
+	for i := range series {
+		if w := from.AddDate(0, 0, i).Weekday(); w != time.Saturday && w != time.Sunday {
+			series[i] = 10
+		}
+	}
+	today := from.AddDate(0, 0, 28)
+	f := forecast(series, from, today, 35, 7)
+	if f.HistoryDays != 28 || f.AverageDailyDemand != 7.1429 {
+		t.Errorf("média incorreta: %d dias, %.4f", f.HistoryDays, f.AverageDailyDemand)
+	}
+	if f.Seasonality[time.Sunday] != 0 || f.Seasonality[time.Monday] != 1.4 {
+		t.Errorf("sazonalidade incorreta: %v", f.Seasonality)
+	}
+	if f.ForecastDemand != 50 || len(f.Daily) != 7 || f.Daily[0].Demand != 0 || f.Daily[1].Demand != 10 {
+		t.Errorf("previsão incorreta: %.2f %+v", f.ForecastDemand, f.Daily)
+	}
+	// Domingo sem demanda, depois 10 por dia: os 35 acabam na quinta-feira
+	if f.DaysUntilStockout == nil || *f.DaysUntilStockout != 4 || f.StockoutDate.Weekday() != time.Thursday {
+		t.Errorf("ruptura incorreta: %v", f.DaysUntilStockout)
+	}
+
+	// Histórico curto: sem sazonalidade, demanda constante
+	f = forecast([]float64{2, 4}, from, from.AddDate(0, 0, 2), 5, 3)
+	if f.Seasonality[time.Sunday] != 1 || f.ForecastDemand != 9 || *f.DaysUntilStockout != 1 {
+		t.Errorf("previsão sem sazonalidade incorreta: %+v", f)
+	}
+	// Sem demanda não há ruptura; sem estoque, a ruptura é hoje
+	if f = forecast([]float64{0, 0}, from, today, 5, 3); f.DaysUntilStockout != nil {
+		t.Errorf("ruptura inesperada: %d", *f.DaysUntilStockout)
+	}
+	if f = forecast(nil, from, today, 0, 3); f.DaysUntilStockout == nil || *f.DaysUntilStockout != 0 {
+		t.Errorf("esperada ruptura hoje, veio %v", f.DaysUntilStockout)
+	}
+}
+
+func TestService_GetForecast_Mock(t *testing.T) {
+	repo := &mockProductRepo{products: make(map[string]*Product)}
+	sender := &recordingSender{}
+	svc := NewService(repo, notifications.NewNotificationService(sender))
+	_ = svc.CreateProduct(context.Background(), &Product{Name: "Produto Teste", Barcode: "123", Quantity: 40, MinStock: 15})
+	// Produto criado há 10 dias, com saídas de 3 por dia, metade com motivo próprio; transferências e
+	// correções não são demanda
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	id := repo.products["123"].ID
+	repo.movements = append(repo.movements, StockMovement{ProductID: id, Delta: 75, Reason: ReasonCreate, CreatedAt: today.AddDate(0, 0, -10)})
+	for i := 1; i <= 10; i++ {
+		reason := ReasonExit
+		if i%2 == 0 {
+			reason = "store use"
+		}
+		repo.movements = append(repo.movements, StockMovement{ProductID: id, Delta: -3, Reason: reason, CreatedAt: today.AddDate(0, 0, -i).Add(time.Hour)})
+	}
+	repo.movements = append(repo.movements, StockMovement{ProductID: id, Delta: -5, Reason: ReasonTransfer, CreatedAt: today.AddDate(0, 0, -1)})
+	repo.movements = append(repo.movements, StockMovement{ProductID: id, Delta: -2, Reason: "count_correction", CreatedAt: today.AddDate(0, 0, -2)})
+	f, err := svc.GetForecast(context.Background(), "123", 90, 30)
+	if err != nil {
+		t.Fatalf("erro na previsão: %v", err)
+	}
+	if f.Barcode != "123" || f.HistoryDays != 10 || f.AverageDailyDemand != 3 || f.ForecastDemand != 90 {
+		t.Errorf("previsão incorreta: %+v", f)
+	}
+	if f.DaysUntilStockout == nil || *f.DaysUntilStockout != 13 {
+		t.Errorf("esperada ruptura em 13 dias, veio %v", f.DaysUntilStockout)
+	}
+	if _, err := svc.GetForecast(context.Background(), "999", 90, 30); err != ErrProductNotFound {
+		t.Errorf("esperado ErrProductNotFound, veio %v", err)
+	}
+	// O alerta de estoque baixo traz a previsão de ruptura
+	_ = svc.StockExit(context.Background(), "123", StockRequest{Quantity: 28})
+	if len(sender.events) != 1 || sender.events[0].Data["days_until_stockout"] != 3 || !strings.Contains(sender.events[0].Message, "run out in 3 days") {
+		t.Errorf("alerta sem previsão de ruptura: %+v", sender.events)
+	}
+
+	// Sem mínimo, a previsão roda quando a saída cruza o ponto de pedido, e o aviso de ruptura
+	// prevista sai quando ela vem antes do prazo de entrega
+	_ = svc.CreateProduct(context.Background(), &Product{Name: "Sem mínimo", Barcode: "456", Quantity: 60, ReorderPoint: 40})
+	id = repo.products["456"].ID
+	repo.leadTimes = map[int]int{id: 10}
+	for i := 1; i <= 10; i++ {
+		repo.movements = append(repo.movements, StockMovement{ProductID: id, Delta: -3, Reason: ReasonExit, CreatedAt: today.AddDate(0, 0, -i).Add(time.Hour)})
+	}
+	sender.events = nil
+	_ = svc.StockExit(context.Background(), "456", StockRequest{Quantity: 10})
+	if len(sender.events) != 0 {
+		t.Errorf("saída acima do ponto de pedido não deveria avisar: %+v", sender.events)
+	}
+	_ = svc.StockExit(context.Background(), "456", StockRequest{Quantity: 25})
+	if len(sender.events) != 1 || sender.events[0].Type != "stockout_forecast" || sender.events[0].Data["days_until_stockout"] != 8 ||
+		sender.events[0].Data["threshold_days"] != 10 {
+		t.Errorf("esperado aviso de ruptura prevista em 8 dias, veio %+v", sender.events)
+	}
+	// Abaixo do ponto de pedido, as saídas seguintes não refazem a previsão
+	sender.events = nil
+	_ = svc.StockExit(context.Background(), "456", StockRequest{Quantity: 1})
+	if len(sender.events) != 0 {
+		t.Errorf("saída que não cruza o ponto de pedido não deveria avisar: %+v", sender.events)
+	}
+}
+
+func TestService_Units_Mock(t *testing.T) {
+	repo := &mockProductRepo{products: map[string]*Product{}}
+	svc := NewService(repo, nil)
+	ctx := context.Background()
+	_ = svc.CreateProduct(ctx, &Product{Name: "Refrigerante", Barcode: "789"})
+	if p := repo.products["789"]; p.BaseUnit != DefaultUnit {
+		t.Errorf("esperada unidade base %s, veio %q", DefaultUnit, p.BaseUnit)
+	}
+	if _, err := svc.SetUnit(ctx, "789", DefaultUnit, UnitRequest{Factor: 2}); err != ErrBaseUnit {
+		t.Errorf("esperado ErrBaseUnit, veio %v", err)
+	}
+	if _, err := svc.SetUnit(ctx, "789", "case", UnitRequest{Factor: 12, Barcode: "789"}); err != ErrBarcodeInUse {
+		t.Errorf("esperado ErrBarcodeInUse, veio %v", err)
+	}
+	if _, err := svc.SetUnit(ctx, "789", "case", UnitRequest{Factor: 12, Barcode: "1789"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.SetUnit(ctx, "789", "inner", UnitRequest{Factor: 6}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ler o código da caixa dá entrada em 12 unidades por caixa, com o custo por unidade base
+	cost := 24.0
+	if err := svc.StockEntry(ctx, "1789", StockRequest{Quantity: 2, UnitCost: &cost}); err != nil {
+		t.Fatal(err)
+	}
+	mv := repo.movements[len(repo.movements)-1]
+	if repo.products["789"].Quantity != 24 || mv.Delta != 24 || mv.UnitCost == nil || *mv.UnitCost != 2 {
+		t.Errorf("entrada por caixa incorreta: quantidade %d, movimentação %+v", repo.products["789"].Quantity, mv)
+	}
+	if err := svc.StockExit(ctx, "789", StockRequest{Quantity: 1, Unit: "inner"}); err != nil || repo.products["789"].Quantity != 18 {
+		t.Errorf("saída por display incorreta: %v, quantidade %d", err, repo.products["789"].Quantity)
+	}
+	if err := svc.StockExit(ctx, "1789", StockRequest{Quantity: 1, Unit: "inner"}); err != ErrUnitMismatch {
+		t.Errorf("esperado ErrUnitMismatch, veio %v", err)
+	}
+	if err := svc.StockEntry(ctx, "789", StockRequest{Quantity: 1, Unit: "pallet"}); err != ErrUnitNotFound {
+		t.Errorf("esperado ErrUnitNotFound, veio %v", err)
+	}
+
+	units, _ := svc.GetUnits(ctx, "789")
+	if len(units) != 2 {
+		t.Errorf("esperadas 2 unidades, veio %d", len(units))
+	}
+	if err := svc.DeleteUnit(ctx, "789", "inner"); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.DeleteUnit(ctx, "789", "inner"); err != ErrUnitNotFound {
+		t.Errorf("esperado ErrUnitNotFound, veio %v", err)
+	}
+}
+
+func TestService_Identifiers_Mock(t *testing.T) {
+	repo := &mockProductRepo{products: map[string]*Product{}}
+	svc := NewService(repo, nil)
+	ctx := context.Background()
+	_ = svc.CreateProduct(ctx, &Product{Name: "Café", Barcode: "7890001"})
+	if _, err := svc.AddIdentifier(ctx, "7890001", IdentifierRequest{Code: "7890002"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.AddIdentifier(ctx, "7890002", IdentifierRequest{Code: "CAF-01", Kind: IdentifierSKU}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.AddIdentifier(ctx, "7890001", IdentifierRequest{Code: "CAF-01"}); err != ErrBarcodeInUse {
+		t.Errorf("esperado ErrBarcodeInUse, veio %v", err)
+	}
+
+	// Qualquer identificador encontra o produto e movimenta o mesmo estoque
+	if err := svc.StockEntry(ctx, "CAF-01", StockRequest{Quantity: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := svc.GetProductByBarcode(ctx, "7890002"); p == nil || p.Barcode != "7890001" || p.Quantity != 5 {
+		t.Errorf("produto pelo código antigo incorreto: %+v", p)
+	}
+
+	if _, err := svc.SetPrimaryBarcode(ctx, "7890001", "CAF-01"); err != ErrPrimarySKU {
+		t.Errorf("esperado ErrPrimarySKU, veio %v", err)
+	}
+	p, err := svc.SetPrimaryBarcode(ctx, "CAF-01", "7890002")
+	if err != nil || p.Barcode != "7890002" {
+		t.Fatalf("troca do código principal falhou: %v %+v", err, p)
+	}
+	if err := svc.DeleteIdentifier(ctx, "7890001", "7890002"); err != ErrPrimaryIdentifier {
+		t.Errorf("esperado ErrPrimaryIdentifier, veio %v", err)
+	}
+	if err := svc.DeleteIdentifier(ctx, "7890002", "7890001"); err != nil {
+		t.Fatal(err)
+	}
+	ids, _ := svc.GetIdentifiers(ctx, "CAF-01")
+	if len(ids) != 2 || !ids[0].Primary || ids[0].Code != "7890002" {
+		t.Errorf("identificadores incorretos: %+v", ids)
+	}
+}
+
+func TestService_ReadScan_Mock(t *testing.T) {
+	repo := &mockProductRepo{products: map[string]*Product{}}
+	svc := NewService(repo, nil)
+	ctx := context.Background()
+	_ = svc.CreateProduct(ctx, &Product{Name: "Iogurte", Barcode: "7891234567895"})
+
+	// O GTIN-14 da leitura encontra o produto pelo EAN-13 e os AIs preenchem a requisição
+	var req StockRequest
+	code, err := svc.ReadScan(ctx, "(01)07891234567895(17)261231(10)L42(30)12", &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "7891234567895" || req.Quantity != 12 || req.LotNumber != "L42" || req.ExpiryDate != "2026-12-31" {
+		t.Errorf("leitura incorreta: %s %+v", code, req)
+	}
+
+	// O que veio no corpo prevalece; leituras com FNC1 também são aceitas
+	req = StockRequest{Quantity: 3, LotNumber: "L7"}
+	code, err = svc.ReadScan(ctx, "]d2010789123456789510L42\x1d17261231", &req)
+	if err != nil || code != "7891234567895" || req.Quantity != 3 || req.LotNumber != "L7" || req.ExpiryDate != "" {
+		t.Errorf("leitura incorreta: %v %s %+v", err, code, req)
+	}
+
+	// Códigos simples passam sem alteração
+	req = StockRequest{}
+	if code, err := svc.ReadScan(ctx, "123", &req); err != nil || code != "123" || req.Quantity != 0 {
+		t.Errorf("código simples alterado: %v %s %+v", err, code, req)
+	}
+	if _, err := svc.ReadScan(ctx, "(01)07891234567890", &req); err == nil {
+		t.Error("esperado erro de dígito verificador")
+	}
+}
+
+func TestValidator_GTIN(t *testing.T) {
+	cases := []struct {
+		v     interface{}
+		valid bool
+	}{
+		{&Product{Name: "Café", Barcode: "7891234567895"}, true},
+		{&Product{Name: "Café", Barcode: "7891234567890"}, false},
+		{&Product{Name: "Café", Barcode: "CAF-01", InternalCode: true}, true},
+		{&IdentifierRequest{Code: "96385074"}, true},
+		{&IdentifierRequest{Code: "96385075"}, false},
+		{&IdentifierRequest{Code: "CAF-01", Kind: IdentifierSKU}, true},
+		{&IdentifierRequest{Code: "2001234", InternalCode: true}, true},
+	}
+	for _, c := range cases {
+		if err := validate.Struct(c.v); (err == nil) != c.valid {
+			t.Errorf("%+v: esperado válido=%v, veio %v", c.v, c.valid, err)
+		}
+	}
+}
+
+func TestService_VariableMeasure_Mock(t *testing.T) {
+	repo := &mockProductRepo{products: map[string]*Product{}}
+	svc := NewService(repo, nil)
+	ctx := context.Background()
+	_ = svc.CreateProduct(ctx, &Product{Name: "Queijo", Barcode: "2012345000001", BaseUnit: "g"})
+	_ = svc.CreateProduct(ctx, &Product{Name: "Presunto", Barcode: "2112345000008", BaseUnit: "g", Price: 0.0899})
+	_ = svc.CreateProduct(ctx, &Product{Name: "Mortadela", Barcode: "2112346000007", BaseUnit: "kg", Price: 89.90})
+	if err := svc.CreateBarcodeRule(ctx, &BarcodeRule{Prefix: "20", ItemDigits: 5, ValueDigits: 5, ValueType: VariableQuantity}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.CreateBarcodeRule(ctx, &BarcodeRule{Prefix: "21", ItemDigits: 5, ValueDigits: 5, ValueType: VariablePrice, Decimals: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.CreateBarcodeRule(ctx, &BarcodeRule{Prefix: "22", ItemDigits: 8, ValueDigits: 5, ValueType: VariableQuantity}); err == nil {
+		t.Error("esperado erro: dígitos não cabem no EAN-13")
+	}
+
+	// Peso embutido: 1250 g do produto base
+	var req StockRequest
+	code, err := svc.ReadScan(ctx, "2012345012509", &req)
+	if err != nil || code != "2012345000001" || req.Quantity != 1250 {
+		t.Fatalf("leitura de peso incorreta: %v %s %+v", err, code, req)
+	}
+	if err := svc.StockEntry(ctx, code, req); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := svc.GetProductByBarcode(ctx, "2012345000001"); p.Quantity != 1250 {
+		t.Errorf("esperado 1250, veio %d", p.Quantity)
+	}
+
+	// Preço embutido: R$ 8,99 a R$ 0,0899 por grama
+	req = StockRequest{}
+	code, err = svc.ReadScan(ctx, "2112345008998", &req)
+	if err != nil || code != "2112345000008" || req.Quantity != 100 {
+		t.Errorf("leitura de preço incorreta: %v %s %+v", err, code, req)
+	}
+	// R$ 8,99 a R$ 89,90 o kg são 0,1 kg, que não cabe na unidade base
+	req = StockRequest{}
+	if _, err := svc.ReadScan(ctx, "2112346008997", &req); err != ErrEmbeddedPrice {
+		t.Errorf("esperado ErrEmbeddedPrice, veio %v", err)
+	}
+
+	// Sem regra para o prefixo, o código segue como veio
+	req = StockRequest{}
+	if code, _ := svc.ReadScan(ctx, "2312345000005", &req); code != "2312345000005" || req.Quantity != 0 {
+		t.Errorf("código sem regra alterado: %s %+v", code, req)
+	}
+}
+
+func TestService_Labels_Mock(t *testing.T) {
+	repo := &mockProductRepo{products: map[string]*Product{}}
+	svc := NewService(repo, nil)
+	ctx := context.Background()
+	_ = svc.CreateProduct(ctx, &Product{Name: "Café", Barcode: "7891234567895", Price: 12.9})
+	_ = svc.CreateProduct(ctx, &Product{Name: "Pão", Barcode: "PAO-01", Price: 0.5})
+
+	var buf bytes.Buffer
+	if err := svc.WriteLabel(ctx, "7891234567895", LabelOptions{Format: "svg", Scale: 1}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), ">R$ 12,90</text>") {
+		t.Errorf("preço ausente na etiqueta: %s", buf.String())
+	}
+	if err := svc.WriteLabel(ctx, "PAO-01", LabelOptions{Format: "png", Symbology: "ean13"}, &buf); err == nil {
+		t.Error("esperado erro: código não é EAN-13")
+	}
+	if err := svc.WriteLabel(ctx, "000", LabelOptions{Format: "png"}, &buf); err != ErrProductNotFound {
+		t.Errorf("esperado ErrProductNotFound, veio %v", err)
+	}
+
+	// No lote, o código que não é EAN-13 sai em Code 128 em vez de interromper
+	buf.Reset()
+	if err := svc.WriteLabels(ctx, ProductsQuery{}, LabelOptions{Format: "zpl", Symbology: "ean13", Scale: 2, Copies: 2}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Count(out, "^XA") != 4 || strings.Count(out, "^BEN") != 2 || strings.Count(out, "^BCN") != 2 {
+		t.Errorf("lote ZPL incorreto: %s", out)
+	}
+	if err := svc.WriteLabels(ctx, ProductsQuery{}, LabelOptions{Format: "png"}, &buf); err == nil {
+		t.Error("esperado erro: png não aceita lotes")
+	}
+}
+
+func TestService_ImportProducts_Mock(t *testing.T) {
+	repo := &mockProductRepo{products: map[string]*Product{}}
+	svc := NewService(repo, nil)
+	ctx := context.Background()
+	_ = svc.CreateProduct(ctx, &Product{Name: "Café", Barcode: "7891234567895", Price: 10, MinStock: 3})
+
+	csv := "EAN;Descrição;price;quantity\n" +
+		"7891234567895;;12,90;40\n" +
+		"96385074;Leite;4,50;10\n" +
+		"123;Pão;1;5\n" +
+		";;;\n" +
+		"96385081;;abc;1\n"
+	opts := ImportOptions{Format: "csv", Columns: map[string]string{"barcode": "EAN", "name": "descrição"}}
+
+	// Simulação: valida tudo e não grava nada
+	opts.DryRun = true
+	report, err := svc.ImportProducts(ctx, strings.NewReader(csv), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Rows != 4 || report.Created != 1 || report.Updated != 1 || report.Failed != 2 || report.Committed {
+		t.Errorf("relatório da simulação incorreto: %+v", report)
+	}
+	if report.Errors[0].Row != 4 || !strings.Contains(report.Errors[0].Error, "gtin") || report.Errors[1].Error != `invalid price "abc"` {
+		t.Errorf("erros incorretos: %+v", report.Errors)
+	}
+	if len(repo.products) != 1 {
+		t.Errorf("simulação gravou produtos")
+	}
+
+	// Tudo ou nada: com linhas inválidas, nada é gravado
+	opts.DryRun = false
+	if report, _ := svc.ImportProducts(ctx, strings.NewReader(csv), opts); report.Committed || len(repo.products) != 1 {
+		t.Errorf("importação atômica gravou com erros: %+v", report)
+	}
+
+	// Parcial: grava as válidas, mantendo os campos vazios do produto existente
+	opts.Partial = true
+	report, _ = svc.ImportProducts(ctx, strings.NewReader(csv), opts)
+	if !report.Committed || len(repo.products) != 2 {
+		t.Fatalf("importação parcial incorreta: %+v", report)
+	}
+	if p := repo.products["7891234567895"]; p.Name != "Café" || p.Price != 12.9 || p.MinStock != 3 || p.Quantity != 40 {
+		t.Errorf("produto atualizado incorreto: %+v", p)
+	}
+	if p := repo.products["96385074"]; p == nil || p.Name != "Leite" || p.BaseUnit != DefaultUnit {
+		t.Errorf("produto criado incorreto: %+v", p)
+	}
+
+	if _, err := svc.ImportProducts(ctx, strings.NewReader(csv), ImportOptions{Format: "csv", Columns: map[string]string{"color": "Cor"}}); !errors.Is(err, ErrImportColumns) {
+		t.Errorf("esperado ErrImportColumns, veio %v", err)
+	}
+	if _, err := svc.ImportProducts(ctx, strings.NewReader("name\nCafé\n"), ImportOptions{Format: "csv"}); !errors.Is(err, ErrImportColumns) {
+		t.Errorf("esperado ErrImportColumns sem coluna barcode, veio %v", err)
+	}
+}
+
+func TestService_Export_Mock(t *testing.T) {
+	repo := &mockProductRepo{products: map[string]*Product{}}
+	svc := NewService(repo, nil)
+	ctx := context.Background()
+	_ = svc.CreateProduct(ctx, &Product{Name: "Café", Barcode: "7891234567895", Price: 12.9, Quantity: 5})
+	_ = svc.CreateProduct(ctx, &Product{Name: "Leite", Barcode: "96385074", Price: 4.5})
+	_ = svc.StockEntry(ctx, "96385074", StockRequest{Quantity: 3})
+
+	// A exportação em CSV volta pela importação sem alterar nada
+	var buf bytes.Buffer
+	if err := svc.ExportProducts(ctx, ProductsQuery{}, "csv", &buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "1,7891234567895,Café,5,") {
+		t.Fatalf("exportação incorreta: %s", buf.String())
+	}
+	report, err := svc.ImportProducts(ctx, &buf, ImportOptions{Format: "csv", DryRun: true})
+	if err != nil || report.Updated != 2 || report.Failed != 0 {
+		t.Errorf("exportação não reimportável: %v %+v", err, report)
+	}
+
+	buf.Reset()
+	if err := svc.ExportMovements(ctx, "96385074", MovementsQuery{}, "ndjson", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 1 || !strings.Contains(lines[0], `"delta":3`) {
+		t.Errorf("exportação de movimentações incorreta: %s", buf.String())
+	}
+	if err := svc.ExportMovements(ctx, "000", MovementsQuery{}, "csv", &buf); err != ErrProductNotFound {
+		t.Errorf("esperado ErrProductNotFound, veio %v", err)
+	}
+	if err := svc.ExportProducts(ctx, ProductsQuery{}, "pdf", &buf); err == nil {
+		t.Error("esperado erro de formato")
+	}
+}
@@ -0,0 +1,262 @@
+package purchasing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"inventory-system/internal"
+	"inventory-system/internal/products"
+	"inventory-system/internal/suppliers"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var validate = validator.New()
+
+func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(payload)
+}
+
+func respondError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, map[string]string{"error": message})
+}
+
+func RegisterRoutes(r chi.Router, db *pgxpool.Pool) {
+	service := NewService(NewRepository(db))
+
+	r.Route("/purchase-orders", func(r chi.Router) {
+		r.Use(internal.AuthMiddleware)
+		r.Post("/", createOrderHandler(service))
+		r.Get("/", getOrdersHandler(service))
+		r.Get("/{id}", getOrderHandler(service))
+		r.Put("/{id}", updateOrderHandler(service))
+		r.Post("/{id}/send", transitionHandler(service.Send))
+		r.Post("/{id}/cancel", transitionHandler(service.Cancel))
+		r.Post("/{id}/receive", receiveHandler(service))
+	})
+}
+
+func respondOrderError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		respondError(w, http.StatusNotFound, "Purchase order not found")
+	case errors.Is(err, suppliers.ErrNotFound):
+		respondError(w, http.StatusNotFound, "Supplier not found")
+	case errors.Is(err, products.ErrProductNotFound):
+		respondError(w, http.StatusNotFound, "Product not found")
+	case errors.Is(err, products.ErrLocationNotFound):
+		respondError(w, http.StatusNotFound, "Location not found")
+	case errors.Is(err, ErrDuplicateLine), errors.Is(err, ErrNotOnOrder), errors.Is(err, ErrOverReceipt),
+		errors.Is(err, products.ErrSerialsRequired), errors.Is(err, products.ErrSerialCount), errors.Is(err, products.ErrSerialConflict),
+		errors.Is(err, products.ErrInvalidExpiryDate), errors.Is(err, products.ErrNotSerialized), errors.Is(err, products.ErrSystemLocation):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrInvalidStatus):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Create a purchase order
+// @Description The order starts as a draft. Lines without unit_cost use the supplier link cost, or the product's average cost.
+// @Tags purchase-orders
+// @Accept json
+// @Produce json
+// @Param order body OrderRequest true "Order data" example({"supplier_id":1,"location_id":1,"reference":"PO-2026-001","lines":[{"barcode":"7891234567895","quantity":24}]})
+// @Success 201 {object} PurchaseOrder "Created purchase order"
+// @Failure 400 {object} map[string]string "Invalid data or duplicate product"
+// @Failure 404 {object} map[string]string "Supplier, product or location not found"
+// @Router /purchase-orders [post]
+func createOrderHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req OrderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		po, err := s.CreateOrder(r.Context(), req)
+		if err != nil {
+			respondOrderError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusCreated, po)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary List purchase orders
+// @Tags purchase-orders
+// @Produce json
+// @Param status query string false "Filter by status (draft, sent, partially_received, received, cancelled)"
+// @Param supplier_id query int false "Filter by supplier"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 20, max: 100)"
+// @Success 200 {array} PurchaseOrder "List of purchase orders, newest first, without lines"
+// @Header 200 {int} X-Total-Count "Total number of purchase orders"
+// @Router /purchase-orders [get]
+func getOrdersHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 1 {
+			page = 1
+		}
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit < 1 || limit > 100 {
+			limit = 20
+		}
+		supplierID, _ := strconv.Atoi(r.URL.Query().Get("supplier_id"))
+		q := OrdersQuery{Status: r.URL.Query().Get("status"), SupplierID: supplierID, Page: page, Limit: limit}
+		orders, total, err := s.GetOrders(r.Context(), q)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		respondJSON(w, http.StatusOK, orders)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Get a purchase order
+// @Tags purchase-orders
+// @Produce json
+// @Param id path int true "Purchase order ID"
+// @Success 200 {object} PurchaseOrder "Purchase order with lines"
+// @Failure 404 {object} map[string]string "Purchase order not found"
+// @Router /purchase-orders/{id} [get]
+func getOrderHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		po, err := s.GetOrder(r.Context(), id)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if po == nil {
+			respondError(w, http.StatusNotFound, "Purchase order not found")
+			return
+		}
+		respondJSON(w, http.StatusOK, po)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Update a draft purchase order
+// @Description Replaces location, reference, notes and lines. The supplier cannot change.
+// @Tags purchase-orders
+// @Accept json
+// @Produce json
+// @Param id path int true "Purchase order ID"
+// @Param order body OrderRequest true "Order data (supplier_id is ignored)" example({"supplier_id":1,"lines":[{"barcode":"7891234567895","quantity":36,"unit_cost":2.1}]})
+// @Success 200 {object} PurchaseOrder "Updated purchase order"
+// @Failure 400 {object} map[string]string "Invalid data or duplicate product"
+// @Failure 404 {object} map[string]string "Purchase order, product or location not found"
+// @Failure 409 {object} map[string]string "Purchase order is no longer a draft"
+// @Router /purchase-orders/{id} [put]
+func updateOrderHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		var req OrderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		po, err := s.UpdateOrder(r.Context(), id, req)
+		if err != nil {
+			respondOrderError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, po)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Send or cancel a purchase order
+// @Description send moves a draft to sent and sets expected_at from the longest supplier lead time of its lines;
+// @Description cancel works on drafts and sent orders that have not received anything.
+// @Tags purchase-orders
+// @Produce json
+// @Param id path int true "Purchase order ID"
+// @Success 200 {object} PurchaseOrder "Updated purchase order"
+// @Failure 404 {object} map[string]string "Purchase order not found"
+// @Failure 409 {object} map[string]string "Invalid status change"
+// @Router /purchase-orders/{id}/send [post]
+// @Router /purchase-orders/{id}/cancel [post]
+func transitionHandler(action func(ctx context.Context, id int) (*PurchaseOrder, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		po, err := action(r.Context(), id)
+		if err != nil {
+			respondOrderError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, po)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Receive goods against a purchase order
+// @Description Each line is entered into stock like a stock entry (reason purchase) at the order line's unit cost,
+// @Description with optional lot, expiry date and serial numbers. The order becomes partially_received or received.
+// @Tags purchase-orders
+// @Accept json
+// @Produce json
+// @Param id path int true "Purchase order ID"
+// @Param receipt body ReceiveRequest true "Received lines" example({"location_id":1,"lines":[{"barcode":"7891234567895","quantity":12,"lot_number":"L2026-10","expiry_date":"2026-12-31"}]})
+// @Success 200 {object} PurchaseOrder "Updated purchase order"
+// @Failure 400 {object} map[string]string "Invalid data, product not on the order or quantity above the ordered"
+// @Failure 404 {object} map[string]string "Purchase order or location not found"
+// @Failure 409 {object} map[string]string "Purchase order not sent"
+// @Router /purchase-orders/{id}/receive [post]
+func receiveHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		var req ReceiveRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		po, err := s.Receive(r.Context(), id, req)
+		if err != nil {
+			respondOrderError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, po)
+	}
+}
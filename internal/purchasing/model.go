@@ -0,0 +1,97 @@
+package purchasing
+
+import (
+	"time"
+
+	"inventory-system/internal/products"
+)
+
+// Estados de um pedido de compra
+const (
+	StatusDraft             = "draft"
+	StatusSent              = "sent"
+	StatusPartiallyReceived = "partially_received"
+	StatusReceived          = "received"
+	StatusCancelled         = "cancelled"
+)
+
+// ReasonPurchase é o motivo gravado nas movimentações de recebimento
+const ReasonPurchase = "purchase"
+
+type PurchaseOrder struct {
+	ID           int    `json:"id"`
+	SupplierID   int    `json:"supplier_id"`
+	SupplierName string `json:"supplier_name"`
+	// Local que recebe a mercadoria; nulo equivale ao local padrão
+	LocationID *int   `json:"location_id"`
+	Status     string `json:"status"`
+	Reference  string `json:"reference"`
+	Notes      string `json:"notes"`
+	// Data prevista de entrega: envio mais o maior prazo de entrega dos itens
+	ExpectedAt *time.Time `json:"expected_at"`
+	Total      float64    `json:"total"`
+	CreatedBy  *int       `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	SentAt     *time.Time `json:"sent_at"`
+	ClosedAt   *time.Time `json:"closed_at"`
+	Lines      []Line     `json:"lines,omitempty"`
+}
+
+type Line struct {
+	ID          int     `json:"id"`
+	ProductID   int     `json:"product_id"`
+	Barcode     string  `json:"barcode"`
+	Name        string  `json:"name"`
+	SupplierSKU string  `json:"supplier_sku"`
+	Quantity    int     `json:"quantity"`
+	Received    int     `json:"received"`
+	UnitCost    float64 `json:"unit_cost"`
+
+	serialized bool
+	// codes são todos os identificadores do produto, para achar a linha por qualquer um deles
+	codes []string
+}
+
+type OrderRequest struct {
+	SupplierID int           `json:"supplier_id" validate:"required"`
+	LocationID int           `json:"location_id"`
+	Reference  string        `json:"reference"`
+	Notes      string        `json:"notes"`
+	Lines      []LineRequest `json:"lines" validate:"required,min=1,dive"`
+}
+
+type LineRequest struct {
+	Barcode  string `json:"barcode" validate:"required"`
+	Quantity int    `json:"quantity" validate:"required,gt=0"`
+	// Sem custo, vale o custo do vínculo com o fornecedor ou, na falta dele, o custo médio do produto
+	UnitCost *float64 `json:"unit_cost" validate:"omitempty,gte=0"`
+}
+
+type ReceiveRequest struct {
+	// Local de recebimento; sem ele vale o do pedido ou o padrão
+	LocationID int           `json:"location_id"`
+	Lines      []ReceiveLine `json:"lines" validate:"required,min=1,dive"`
+}
+
+type ReceiveLine struct {
+	Barcode    string   `json:"barcode" validate:"required"`
+	Quantity   int      `json:"quantity" validate:"required_without=Serials,gte=0"`
+	LotNumber  string   `json:"lot_number" validate:"required_with=ExpiryDate"`
+	ExpiryDate string   `json:"expiry_date" validate:"omitempty,datetime=2006-01-02"`
+	Serials    []string `json:"serials" validate:"omitempty,unique,dive,required"`
+}
+
+// Receipt é uma linha de recebimento já convertida para a movimentação de entrada.
+type Receipt struct {
+	Barcode  string
+	Quantity int
+	Lots     []products.LotQuantity
+	Serials  []string
+}
+
+type OrdersQuery struct {
+	Status     string
+	SupplierID int
+	Page       int
+	Limit      int
+}
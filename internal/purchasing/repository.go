@@ -0,0 +1,385 @@
+package purchasing
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+
+	"inventory-system/internal/products"
+	"inventory-system/internal/suppliers"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrNotFound      = errors.New("purchase order not found")
+	ErrInvalidStatus = errors.New("purchase order cannot change in its current status")
+	ErrDuplicateLine = errors.New("product appears more than once in the purchase order")
+	ErrNotOnOrder    = errors.New("product is not on the purchase order")
+	ErrOverReceipt   = errors.New("received quantity exceeds the ordered quantity")
+)
+
+type Repository struct {
+	DB *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{DB: db}
+}
+
+const orderColumns = `o.id, o.supplier_id, s.name, o.location_id, o.status, o.reference, o.notes, o.expected_at,
+	(SELECT COALESCE(SUM(l.quantity * l.unit_cost), 0) FROM purchase_order_lines l WHERE l.purchase_order_id = o.id),
+	o.created_by, o.created_at, o.sent_at, o.closed_at`
+
+func scanOrder(row pgx.Row, po *PurchaseOrder) error {
+	return row.Scan(&po.ID, &po.SupplierID, &po.SupplierName, &po.LocationID, &po.Status, &po.Reference, &po.Notes, &po.ExpectedAt,
+		&po.Total, &po.CreatedBy, &po.CreatedAt, &po.SentAt, &po.ClosedAt)
+}
+
+// querier é atendido tanto pelo pool quanto por uma transação.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
+func queryLines(ctx context.Context, q querier, orderID int) ([]Line, error) {
+	query := `SELECT l.id, l.product_id, p.barcode, ` + products.Identifiers("p.id") + `, p.name, COALESCE(sp.supplier_sku, ''), l.quantity, l.received, l.unit_cost, p.serialized
+		FROM purchase_order_lines l
+		JOIN purchase_orders o ON o.id = l.purchase_order_id
+		JOIN products p ON p.id = l.product_id
+		LEFT JOIN supplier_products sp ON sp.supplier_id = o.supplier_id AND sp.product_id = l.product_id
+		WHERE l.purchase_order_id = $1 ORDER BY l.id`
+	rows, err := q.Query(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	lines := []Line{}
+	for rows.Next() {
+		var l Line
+		if err := rows.Scan(&l.ID, &l.ProductID, &l.Barcode, &l.codes, &l.Name, &l.SupplierSKU, &l.Quantity, &l.Received, &l.UnitCost, &l.serialized); err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
+
+// CreateOrder grava o pedido como rascunho com suas linhas.
+func (r *Repository) CreateOrder(ctx context.Context, po *PurchaseOrder, lines []LineRequest) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if err := Insert(ctx, tx, po, lines); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Insert grava o pedido como rascunho dentro de tx. Outros pacotes, como a reposição automática,
+// usam esta função para gerar pedidos junto com suas próprias consultas.
+func Insert(ctx context.Context, tx pgx.Tx, po *PurchaseOrder, lines []LineRequest) error {
+	if err := tx.QueryRow(ctx, `SELECT name FROM suppliers WHERE id = $1`, po.SupplierID).Scan(&po.SupplierName); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return suppliers.ErrNotFound
+		}
+		return err
+	}
+	query := `INSERT INTO purchase_orders (supplier_id, location_id, reference, notes, created_by)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, status, created_at`
+	err := tx.QueryRow(ctx, query, po.SupplierID, po.LocationID, po.Reference, po.Notes, po.CreatedBy).Scan(&po.ID, &po.Status, &po.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return products.ErrLocationNotFound
+		}
+		return err
+	}
+	return insertLines(ctx, tx, po, lines)
+}
+
+// insertLines grava as linhas do pedido e recarrega po.Lines e po.Total.
+func insertLines(ctx context.Context, tx pgx.Tx, po *PurchaseOrder, lines []LineRequest) error {
+	query := `INSERT INTO purchase_order_lines (purchase_order_id, product_id, quantity, unit_cost)
+		SELECT $1, p.id, $3, COALESCE($4::numeric, sp.unit_cost, p.average_cost)
+		FROM products p LEFT JOIN supplier_products sp ON sp.product_id = p.id AND sp.supplier_id = $5
+		WHERE ` + products.ByCode("p.id", "$2") + ` RETURNING id`
+	for _, l := range lines {
+		var id int
+		if err := tx.QueryRow(ctx, query, po.ID, l.Barcode, l.Quantity, l.UnitCost, po.SupplierID).Scan(&id); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return products.ErrProductNotFound
+			}
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return ErrDuplicateLine
+			}
+			return err
+		}
+	}
+	saved, err := queryLines(ctx, tx, po.ID)
+	if err != nil {
+		return err
+	}
+	po.Lines = saved
+	po.Total = orderTotal(saved)
+	return nil
+}
+
+func (r *Repository) GetOrders(ctx context.Context, q OrdersQuery) ([]PurchaseOrder, int, error) {
+	args := []interface{}{}
+	where := ""
+	idx := 1
+	if q.Status != "" {
+		where += " AND o.status = $" + strconv.Itoa(idx)
+		args = append(args, q.Status)
+		idx++
+	}
+	if q.SupplierID != 0 {
+		where += " AND o.supplier_id = $" + strconv.Itoa(idx)
+		args = append(args, q.SupplierID)
+		idx++
+	}
+	limit := q.Limit
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	page := q.Page
+	if page < 1 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+	query := "SELECT " + orderColumns + " FROM purchase_orders o JOIN suppliers s ON s.id = o.supplier_id WHERE 1=1" + where +
+		" ORDER BY o.id DESC LIMIT $" + strconv.Itoa(idx) + " OFFSET $" + strconv.Itoa(idx+1)
+	rows, err := r.DB.Query(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	orders := []PurchaseOrder{}
+	for rows.Next() {
+		var po PurchaseOrder
+		if err := scanOrder(rows, &po); err != nil {
+			return nil, 0, err
+		}
+		orders = append(orders, po)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	total := 0
+	if err := r.DB.QueryRow(ctx, "SELECT COUNT(*) FROM purchase_orders o WHERE 1=1"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	return orders, total, nil
+}
+
+func (r *Repository) GetOrder(ctx context.Context, id int) (*PurchaseOrder, error) {
+	var po PurchaseOrder
+	query := "SELECT " + orderColumns + " FROM purchase_orders o JOIN suppliers s ON s.id = o.supplier_id WHERE o.id = $1"
+	if err := scanOrder(r.DB.QueryRow(ctx, query, id), &po); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	lines, err := queryLines(ctx, r.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	po.Lines = lines
+	return &po, nil
+}
+
+// lockOrder lê o pedido bloqueando-o até o fim de tx.
+func lockOrder(ctx context.Context, tx pgx.Tx, id int) (*PurchaseOrder, error) {
+	var po PurchaseOrder
+	query := "SELECT " + orderColumns + " FROM purchase_orders o JOIN suppliers s ON s.id = o.supplier_id WHERE o.id = $1 FOR UPDATE OF o"
+	if err := scanOrder(tx.QueryRow(ctx, query, id), &po); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &po, nil
+}
+
+// UpdateOrder substitui cabeçalho e linhas de um pedido ainda em rascunho.
+func (r *Repository) UpdateOrder(ctx context.Context, id int, po *PurchaseOrder, lines []LineRequest) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	current, err := lockOrder(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if current.Status != StatusDraft {
+		return ErrInvalidStatus
+	}
+	query := `UPDATE purchase_orders SET location_id = $1, reference = $2, notes = $3 WHERE id = $4`
+	if _, err := tx.Exec(ctx, query, po.LocationID, po.Reference, po.Notes, id); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return products.ErrLocationNotFound
+		}
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM purchase_order_lines WHERE purchase_order_id = $1`, id); err != nil {
+		return err
+	}
+	po.ID, po.SupplierID, po.SupplierName = id, current.SupplierID, current.SupplierName
+	po.Status, po.CreatedBy, po.CreatedAt = current.Status, current.CreatedBy, current.CreatedAt
+	if err := insertLines(ctx, tx, po, lines); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Send envia o rascunho ao fornecedor e calcula a data prevista pelo maior prazo de entrega dos itens.
+func (r *Repository) Send(ctx context.Context, id int) (*PurchaseOrder, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	po, err := lockOrder(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if po.Status != StatusDraft {
+		return nil, ErrInvalidStatus
+	}
+	query := `UPDATE purchase_orders o SET status = $1, sent_at = NOW(),
+			expected_at = CURRENT_DATE + (SELECT COALESCE(MAX(sp.lead_time_days), 0) FROM purchase_order_lines l
+				JOIN supplier_products sp ON sp.supplier_id = o.supplier_id AND sp.product_id = l.product_id
+				WHERE l.purchase_order_id = o.id)
+		WHERE o.id = $2 RETURNING o.status, o.sent_at, o.expected_at`
+	if err := tx.QueryRow(ctx, query, StatusSent, id).Scan(&po.Status, &po.SentAt, &po.ExpectedAt); err != nil {
+		return nil, err
+	}
+	if po.Lines, err = queryLines(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return po, nil
+}
+
+// Cancel cancela um pedido que ainda não recebeu nada.
+func (r *Repository) Cancel(ctx context.Context, id int) (*PurchaseOrder, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	po, err := lockOrder(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if po.Status != StatusDraft && po.Status != StatusSent {
+		return nil, ErrInvalidStatus
+	}
+	query := `UPDATE purchase_orders SET status = $1, closed_at = NOW() WHERE id = $2 RETURNING status, closed_at`
+	if err := tx.QueryRow(ctx, query, StatusCancelled, id).Scan(&po.Status, &po.ClosedAt); err != nil {
+		return nil, err
+	}
+	if po.Lines, err = queryLines(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return po, nil
+}
+
+// Receive dá entrada das quantidades recebidas pelo mesmo caminho das entradas de estoque
+// (products.ApplyMovement), ao custo da linha do pedido, e atualiza o estado do pedido. Tudo
+// ocorre em uma transação: se uma linha falhar, nada é recebido.
+func (r *Repository) Receive(ctx context.Context, id, locationID int, receipts []Receipt) (*PurchaseOrder, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	po, err := lockOrder(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if po.Status != StatusSent && po.Status != StatusPartiallyReceived {
+		return nil, ErrInvalidStatus
+	}
+	if locationID == 0 && po.LocationID != nil {
+		locationID = *po.LocationID
+	}
+	lines, err := queryLines(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, rc := range receipts {
+		l := findLine(lines, rc.Barcode)
+		if l == nil {
+			return nil, ErrNotOnOrder
+		}
+		if l.Received+rc.Quantity > l.Quantity {
+			return nil, ErrOverReceipt
+		}
+		if l.serialized && len(rc.Serials) == 0 {
+			return nil, products.ErrSerialsRequired
+		}
+		unitCost := l.UnitCost
+		m := &products.StockMovement{
+			ProductID:  l.ProductID,
+			LocationID: locationID,
+			Delta:      rc.Quantity,
+			Reason:     ReasonPurchase,
+			Reference:  "purchase order #" + strconv.Itoa(id),
+			Lots:       rc.Lots,
+			Serials:    rc.Serials,
+			UnitCost:   &unitCost,
+		}
+		if err := products.ApplyMovement(ctx, tx, m); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE purchase_order_lines SET received = received + $1 WHERE id = $2`, rc.Quantity, l.ID); err != nil {
+			return nil, err
+		}
+		l.Received += rc.Quantity
+	}
+	po.Status = receivedStatus(lines)
+	query := `UPDATE purchase_orders SET status = $1, closed_at = CASE WHEN $1 = 'received' THEN NOW() END
+		WHERE id = $2 RETURNING closed_at`
+	if err := tx.QueryRow(ctx, query, po.Status, id).Scan(&po.ClosedAt); err != nil {
+		return nil, err
+	}
+	po.Lines = lines
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return po, nil
+}
+
+func findLine(lines []Line, barcode string) *Line {
+	for i := range lines {
+		if lines[i].Barcode == barcode || slices.Contains(lines[i].codes, barcode) {
+			return &lines[i]
+		}
+	}
+	return nil
+}
+
+type RepositoryInterface interface {
+	CreateOrder(ctx context.Context, po *PurchaseOrder, lines []LineRequest) error
+	GetOrders(ctx context.Context, q OrdersQuery) ([]PurchaseOrder, int, error)
+	GetOrder(ctx context.Context, id int) (*PurchaseOrder, error)
+	UpdateOrder(ctx context.Context, id int, po *PurchaseOrder, lines []LineRequest) error
+	Send(ctx context.Context, id int) (*PurchaseOrder, error)
+	Cancel(ctx context.Context, id int) (*PurchaseOrder, error)
+	Receive(ctx context.Context, id, locationID int, receipts []Receipt) (*PurchaseOrder, error)
+}
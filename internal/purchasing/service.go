@@ -0,0 +1,110 @@
+package purchasing
+
+import (
+	"context"
+	"time"
+
+	"inventory-system/internal"
+	"inventory-system/internal/products"
+)
+
+type Service struct {
+	Repo RepositoryInterface
+}
+
+func NewService(repo RepositoryInterface) *Service {
+	return &Service{Repo: repo}
+}
+
+func (s *Service) CreateOrder(ctx context.Context, req OrderRequest) (*PurchaseOrder, error) {
+	po := &PurchaseOrder{SupplierID: req.SupplierID, Reference: req.Reference, Notes: req.Notes, CreatedBy: currentUser(ctx)}
+	if req.LocationID != 0 {
+		po.LocationID = &req.LocationID
+	}
+	if err := s.Repo.CreateOrder(ctx, po, req.Lines); err != nil {
+		return nil, err
+	}
+	return po, nil
+}
+
+func (s *Service) GetOrders(ctx context.Context, q OrdersQuery) ([]PurchaseOrder, int, error) {
+	return s.Repo.GetOrders(ctx, q)
+}
+
+func (s *Service) GetOrder(ctx context.Context, id int) (*PurchaseOrder, error) {
+	return s.Repo.GetOrder(ctx, id)
+}
+
+// UpdateOrder substitui um rascunho; o fornecedor não muda.
+func (s *Service) UpdateOrder(ctx context.Context, id int, req OrderRequest) (*PurchaseOrder, error) {
+	po := &PurchaseOrder{Reference: req.Reference, Notes: req.Notes}
+	if req.LocationID != 0 {
+		po.LocationID = &req.LocationID
+	}
+	if err := s.Repo.UpdateOrder(ctx, id, po, req.Lines); err != nil {
+		return nil, err
+	}
+	return po, nil
+}
+
+func (s *Service) Send(ctx context.Context, id int) (*PurchaseOrder, error) {
+	return s.Repo.Send(ctx, id)
+}
+
+func (s *Service) Cancel(ctx context.Context, id int) (*PurchaseOrder, error) {
+	return s.Repo.Cancel(ctx, id)
+}
+
+// Receive converte as linhas recebidas (quantidade pelos números de série, lote e validade) e dá entrada no estoque.
+func (s *Service) Receive(ctx context.Context, id int, req ReceiveRequest) (*PurchaseOrder, error) {
+	receipts := make([]Receipt, 0, len(req.Lines))
+	for _, l := range req.Lines {
+		rc := Receipt{Barcode: l.Barcode, Quantity: l.Quantity, Serials: l.Serials}
+		if len(l.Serials) > 0 {
+			if rc.Quantity == 0 {
+				rc.Quantity = len(l.Serials)
+			}
+			if rc.Quantity != len(l.Serials) {
+				return nil, products.ErrSerialCount
+			}
+		}
+		if l.LotNumber != "" {
+			lot := products.LotQuantity{LotNumber: l.LotNumber, Quantity: rc.Quantity}
+			if l.ExpiryDate != "" {
+				expiry, err := time.Parse("2006-01-02", l.ExpiryDate)
+				if err != nil {
+					return nil, products.ErrInvalidExpiryDate
+				}
+				lot.ExpiryDate = &expiry
+			}
+			rc.Lots = []products.LotQuantity{lot}
+		}
+		receipts = append(receipts, rc)
+	}
+	return s.Repo.Receive(ctx, id, req.LocationID, receipts)
+}
+
+// receivedStatus devolve received quando todas as linhas foram recebidas por completo.
+func receivedStatus(lines []Line) string {
+	for _, l := range lines {
+		if l.Received < l.Quantity {
+			return StatusPartiallyReceived
+		}
+	}
+	return StatusReceived
+}
+
+func orderTotal(lines []Line) float64 {
+	total := 0.0
+	for _, l := range lines {
+		total += float64(l.Quantity) * l.UnitCost
+	}
+	return total
+}
+
+func currentUser(ctx context.Context) *int {
+	if userID, ok := internal.UserIDFromContext(ctx); ok {
+		return &userID
+	}
+	return nil
+}
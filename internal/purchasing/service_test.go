@@ -0,0 +1,129 @@
+package purchasing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"inventory-system/internal/products"
+)
+
+type mockOrderRepo struct {
+	orders   []PurchaseOrder
+	receipts []Receipt
+	location int
+}
+
+func (m *mockOrderRepo) CreateOrder(ctx context.Context, po *PurchaseOrder, lines []LineRequest) error {
+	po.ID = len(m.orders) + 1
+	po.Status = StatusDraft
+	for i, l := range lines {
+		line := Line{ID: i + 1, Barcode: l.Barcode, Quantity: l.Quantity}
+		if l.UnitCost != nil {
+			line.UnitCost = *l.UnitCost
+		}
+		po.Lines = append(po.Lines, line)
+	}
+	po.Total = orderTotal(po.Lines)
+	m.orders = append(m.orders, *po)
+	return nil
+}
+func (m *mockOrderRepo) GetOrders(ctx context.Context, q OrdersQuery) ([]PurchaseOrder, int, error) {
+	return m.orders, len(m.orders), nil
+}
+func (m *mockOrderRepo) GetOrder(ctx context.Context, id int) (*PurchaseOrder, error) {
+	if id < 1 || id > len(m.orders) {
+		return nil, nil
+	}
+	return &m.orders[id-1], nil
+}
+func (m *mockOrderRepo) UpdateOrder(ctx context.Context, id int, po *PurchaseOrder, lines []LineRequest) error {
+	return nil
+}
+func (m *mockOrderRepo) Send(ctx context.Context, id int) (*PurchaseOrder, error) {
+	po := &m.orders[id-1]
+	if po.Status != StatusDraft {
+		return nil, ErrInvalidStatus
+	}
+	po.Status = StatusSent
+	return po, nil
+}
+func (m *mockOrderRepo) Cancel(ctx context.Context, id int) (*PurchaseOrder, error) {
+	return nil, nil
+}
+func (m *mockOrderRepo) Receive(ctx context.Context, id, locationID int, receipts []Receipt) (*PurchaseOrder, error) {
+	po := &m.orders[id-1]
+	if po.Status != StatusSent && po.Status != StatusPartiallyReceived {
+		return nil, ErrInvalidStatus
+	}
+	m.receipts, m.location = receipts, locationID
+	for _, rc := range receipts {
+		l := findLine(po.Lines, rc.Barcode)
+		if l == nil {
+			return nil, ErrNotOnOrder
+		}
+		l.Received += rc.Quantity
+	}
+	po.Status = receivedStatus(po.Lines)
+	return po, nil
+}
+
+func TestReceivedStatus(t *testing.T) {
+	partial := []Line{{Quantity: 10, Received: 10}, {Quantity: 5, Received: 2}}
+	if got := receivedStatus(partial); got != StatusPartiallyReceived {
+		t.Errorf("esperado %s, veio %s", StatusPartiallyReceived, got)
+	}
+	full := []Line{{Quantity: 10, Received: 10}, {Quantity: 5, Received: 5}}
+	if got := receivedStatus(full); got != StatusReceived {
+		t.Errorf("esperado %s, veio %s", StatusReceived, got)
+	}
+}
+
+func TestService_PurchaseOrder_Mock(t *testing.T) {
+	repo := &mockOrderRepo{}
+	svc := NewService(repo)
+	cost := 2.5
+	po, err := svc.CreateOrder(context.Background(), OrderRequest{SupplierID: 1, LocationID: 3, Lines: []LineRequest{
+		{Barcode: "123", Quantity: 10, UnitCost: &cost},
+		{Barcode: "456", Quantity: 2},
+	}})
+	if err != nil {
+		t.Fatalf("erro ao criar pedido: %v", err)
+	}
+	if po.Status != StatusDraft || po.Total != 25 || po.LocationID == nil || *po.LocationID != 3 {
+		t.Errorf("pedido criado incorretamente: %+v", po)
+	}
+	// Rascunho não pode ser recebido
+	if _, err := svc.Receive(context.Background(), po.ID, ReceiveRequest{Lines: []ReceiveLine{{Barcode: "123", Quantity: 1}}}); err != ErrInvalidStatus {
+		t.Errorf("esperado ErrInvalidStatus, veio %v", err)
+	}
+	if _, err := svc.Send(context.Background(), po.ID); err != nil {
+		t.Fatalf("erro ao enviar pedido: %v", err)
+	}
+	got, err := svc.Receive(context.Background(), po.ID, ReceiveRequest{Lines: []ReceiveLine{{Barcode: "123", Quantity: 10, LotNumber: "L1", ExpiryDate: "2026-12-31"}}})
+	if err != nil {
+		t.Fatalf("erro ao receber: %v", err)
+	}
+	if got.Status != StatusPartiallyReceived {
+		t.Errorf("esperado recebimento parcial, veio %s", got.Status)
+	}
+	lots := repo.receipts[0].Lots
+	if len(lots) != 1 || lots[0].Quantity != 10 || !lots[0].ExpiryDate.Equal(time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("lote do recebimento incorreto: %+v", lots)
+	}
+	// Quantidade vem dos números de série
+	got, _ = svc.Receive(context.Background(), po.ID, ReceiveRequest{Lines: []ReceiveLine{{Barcode: "456", Serials: []string{"S1", "S2"}}}})
+	if got.Status != StatusReceived || repo.receipts[0].Quantity != 2 {
+		t.Errorf("esperado pedido recebido, veio %s", got.Status)
+	}
+}
+
+func TestService_Receive_Validation_Mock(t *testing.T) {
+	svc := NewService(&mockOrderRepo{})
+	if _, err := svc.Receive(context.Background(), 1, ReceiveRequest{Lines: []ReceiveLine{{Barcode: "123", Quantity: 3, Serials: []string{"S1"}}}}); err != products.ErrSerialCount {
+		t.Errorf("esperado ErrSerialCount, veio %v", err)
+	}
+	if _, err := svc.Receive(context.Background(), 1, ReceiveRequest{Lines: []ReceiveLine{{Barcode: "123", Quantity: 1, LotNumber: "L1", ExpiryDate: "31/12/2026"}}}); err != products.ErrInvalidExpiryDate {
+		t.Errorf("esperado ErrInvalidExpiryDate, veio %v", err)
+	}
+}
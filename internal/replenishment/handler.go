@@ -0,0 +1,103 @@
+package replenishment
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"inventory-system/internal"
+	"inventory-system/internal/products"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var validate = validator.New()
+
+func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(payload)
+}
+
+func respondError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, map[string]string{"error": message})
+}
+
+func RegisterRoutes(r chi.Router, db *pgxpool.Pool) {
+	service := NewService(NewRepository(db))
+
+	r.Route("/replenishment", func(r chi.Router) {
+		r.Use(internal.AuthMiddleware)
+		r.Get("/suggestions", getSuggestionsHandler(service))
+		r.Post("/orders", createOrdersHandler(service))
+	})
+}
+
+// @Security ApiKeyAuth
+// @Summary Replenishment suggestions
+// @Description Products whose stock plus open purchase orders is below reorder_point (or min_stock when it is 0), with the quantity to order, grouped by preferred supplier (or the cheapest one when none is preferred).
+// @Tags replenishment
+// @Produce json
+// @Param supplier_id query int false "Only suggestions for this supplier"
+// @Success 200 {object} Proposal "Suggestions by supplier and products without a supplier"
+// @Failure 400 {object} map[string]string "Invalid supplier ID"
+// @Router /replenishment/suggestions [get]
+func getSuggestionsHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		supplierID := 0
+		if v := r.URL.Query().Get("supplier_id"); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				respondError(w, http.StatusBadRequest, "Invalid supplier ID")
+				return
+			}
+			supplierID = id
+		}
+		proposal, err := s.GetProposal(r.Context(), supplierID)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, proposal)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Create purchase orders from the suggestions
+// @Description Creates one draft purchase order per supplier with the suggested quantities, in a single transaction. Drafts count as on order, so calling it again does not order the same products twice.
+// @Tags replenishment
+// @Accept json
+// @Produce json
+// @Param body body OrdersRequest false "Suppliers to order from (all when empty) and receiving location" example({"supplier_ids":[1,2],"location_id":1})
+// @Success 201 {object} OrdersResult "Created draft purchase orders and products without a supplier"
+// @Failure 400 {object} map[string]string "Invalid data"
+// @Failure 404 {object} map[string]string "Location not found"
+// @Router /replenishment/orders [post]
+func createOrdersHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req OrdersRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				respondError(w, http.StatusBadRequest, "Invalid data")
+				return
+			}
+		}
+		if err := validate.Struct(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		result, err := s.CreateOrders(r.Context(), req)
+		if err != nil {
+			if errors.Is(err, products.ErrLocationNotFound) {
+				respondError(w, http.StatusNotFound, "Location not found")
+				return
+			}
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respondJSON(w, http.StatusCreated, result)
+	}
+}
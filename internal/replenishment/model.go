@@ -0,0 +1,58 @@
+package replenishment
+
+import "inventory-system/internal/purchasing"
+
+// ReferenceReplenishment identifica os pedidos de compra gerados pela reposição automática
+const ReferenceReplenishment = "replenishment"
+
+// Line é um produto abaixo do ponto de pedido com a quantidade sugerida para compra. DailyDemand é a
+// demanda média diária da previsão e LeadTimeDemand o que ela consome durante o prazo de entrega.
+type Line struct {
+	ProductID int    `json:"product_id"`
+	Barcode   string `json:"barcode"`
+	Name      string `json:"name"`
+	Quantity  int    `json:"quantity"`
+	// OnOrder é o que ainda falta receber de pedidos de compra em aberto (inclusive rascunhos)
+	OnOrder        int     `json:"on_order"`
+	MinStock       int     `json:"min_stock"`
+	ReorderPoint   int     `json:"reorder_point"`
+	ReorderQty     int     `json:"reorder_qty"`
+	MaxStock       int     `json:"max_stock"`
+	DailyDemand    float64 `json:"daily_demand"`
+	LeadTimeDemand int     `json:"lead_time_demand"`
+	SuggestedQty   int     `json:"suggested_qty"`
+	// Dados do vínculo com o fornecedor escolhido; o custo cai no custo médio sem vínculo
+	SupplierSKU  string  `json:"supplier_sku"`
+	UnitCost     float64 `json:"unit_cost"`
+	LeadTimeDays int     `json:"lead_time_days"`
+
+	supplierID   *int
+	supplierName string
+}
+
+// SupplierGroup reúne as sugestões de um fornecedor, que viram um pedido de compra.
+type SupplierGroup struct {
+	SupplierID   int     `json:"supplier_id"`
+	SupplierName string  `json:"supplier_name"`
+	Total        float64 `json:"total"`
+	Lines        []Line  `json:"lines"`
+}
+
+// Proposal é a sugestão de reposição; Unassigned lista os produtos sem nenhum fornecedor vinculado.
+type Proposal struct {
+	Suppliers  []SupplierGroup `json:"suppliers"`
+	Unassigned []Line          `json:"unassigned"`
+}
+
+type OrdersRequest struct {
+	// Fornecedores a pedir; vazio gera pedidos para todos os fornecedores da sugestão
+	SupplierIDs []int  `json:"supplier_ids" validate:"omitempty,dive,gt=0"`
+	LocationID  int    `json:"location_id"`
+	Notes       string `json:"notes"`
+}
+
+// OrdersResult traz os rascunhos criados e os produtos que ficaram de fora por não terem fornecedor.
+type OrdersResult struct {
+	Orders     []purchasing.PurchaseOrder `json:"orders"`
+	Unassigned []Line                     `json:"unassigned"`
+}
@@ -0,0 +1,126 @@
+package replenishment
+
+import (
+	"context"
+	"slices"
+
+	"inventory-system/internal/products"
+	"inventory-system/internal/purchasing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repository struct {
+	DB *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{DB: db}
+}
+
+// querier é atendido tanto pelo pool quanto por uma transação.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
+// Quantidade ainda não recebida de pedidos de compra em aberto do produto da linha corrente de p
+const onOrder = `(SELECT COALESCE(SUM(l.quantity - l.received), 0) FROM purchase_order_lines l
+	JOIN purchase_orders o ON o.id = l.purchase_order_id
+	WHERE l.product_id = p.id AND o.status IN ('draft', 'sent', 'partially_received'))`
+
+// Dias de histórico da demanda média, os mesmos da previsão de demanda
+const demandHistoryDays = 90
+
+// Demanda média diária do produto da linha corrente de p nos últimos $2 dias, contados desde a
+// primeira movimentação quando o produto é mais novo, como na previsão de demanda
+const dailyDemand = `(SELECT COALESCE(SUM(-m.delta) FILTER (WHERE m.delta < 0 AND m.reason <> ALL($1)
+		AND m.created_at >= NOW() - make_interval(days => $2)), 0)
+	/ GREATEST(1, EXTRACT(EPOCH FROM NOW() - GREATEST(MIN(m.created_at), NOW() - make_interval(days => $2))) / 86400)
+	FROM stock_movements m WHERE m.product_id = p.id)::float8`
+
+// queryLines lista os produtos cuja posição (saldo mais encomendado) está abaixo do ponto de pedido,
+// ou do estoque mínimo quando não há ponto de pedido, ou ainda do mínimo mais a demanda prevista
+// durante o prazo de entrega (veja suggestedQuantity). O fornecedor é o preferido do produto ou, na
+// falta dele, o de menor custo.
+func queryLines(ctx context.Context, q querier) ([]Line, error) {
+	query := `SELECT * FROM (
+		SELECT p.id, p.barcode, p.name, p.quantity, p.on_order, p.min_stock, p.reorder_point, p.reorder_qty, p.max_stock,
+			sp.supplier_id, COALESCE(sp.name, '') AS supplier_name, COALESCE(sp.supplier_sku, '') AS supplier_sku,
+			COALESCE(sp.unit_cost, p.average_cost) AS unit_cost, COALESCE(sp.lead_time_days, 0) AS lead_time_days,
+			ROUND(p.daily_demand::numeric, 2)::float8 AS daily_demand,
+			CEIL(p.daily_demand * COALESCE(sp.lead_time_days, 0))::int AS lead_time_demand
+		FROM (SELECT p.*, ` + onOrder + ` AS on_order, ` + dailyDemand + ` AS daily_demand FROM products p) p
+		LEFT JOIN LATERAL (
+			SELECT sp.supplier_id, s.name, sp.supplier_sku, sp.unit_cost, sp.lead_time_days
+			FROM supplier_products sp JOIN suppliers s ON s.id = sp.supplier_id
+			WHERE sp.product_id = p.id
+			ORDER BY sp.preferred DESC, sp.unit_cost, sp.supplier_id LIMIT 1
+		) sp ON TRUE
+	) p
+	WHERE p.quantity + p.on_order < CASE WHEN p.reorder_point > 0 THEN p.reorder_point ELSE p.min_stock END
+		OR (p.lead_time_demand > 0 AND p.quantity + p.on_order < p.min_stock + p.lead_time_demand)
+	ORDER BY p.name, p.id`
+	rows, err := q.Query(ctx, query, products.NonDemandReasons, demandHistoryDays)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	lines := []Line{}
+	for rows.Next() {
+		var l Line
+		err := rows.Scan(&l.ProductID, &l.Barcode, &l.Name, &l.Quantity, &l.OnOrder, &l.MinStock, &l.ReorderPoint, &l.ReorderQty, &l.MaxStock,
+			&l.supplierID, &l.supplierName, &l.SupplierSKU, &l.UnitCost, &l.LeadTimeDays, &l.DailyDemand, &l.LeadTimeDemand)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
+
+func (r *Repository) GetLines(ctx context.Context) ([]Line, error) {
+	return queryLines(ctx, r.DB)
+}
+
+// CreateOrders recalcula a sugestão e grava um pedido de compra em rascunho por fornecedor, tudo
+// na mesma transação. Chamadas simultâneas são serializadas, e como os rascunhos contam como
+// encomendados, repetir a chamada não duplica os pedidos.
+func (r *Repository) CreateOrders(ctx context.Context, req OrdersRequest, createdBy *int) (*OrdersResult, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('replenishment'))`); err != nil {
+		return nil, err
+	}
+	lines, err := queryLines(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	proposal := propose(lines)
+	result := &OrdersResult{Orders: []purchasing.PurchaseOrder{}, Unassigned: proposal.Unassigned}
+	for _, g := range proposal.Suppliers {
+		if len(req.SupplierIDs) > 0 && !slices.Contains(req.SupplierIDs, g.SupplierID) {
+			continue
+		}
+		po := purchasing.PurchaseOrder{SupplierID: g.SupplierID, Reference: ReferenceReplenishment, Notes: req.Notes, CreatedBy: createdBy}
+		if req.LocationID != 0 {
+			po.LocationID = &req.LocationID
+		}
+		if err := purchasing.Insert(ctx, tx, &po, orderLines(g)); err != nil {
+			return nil, err
+		}
+		result.Orders = append(result.Orders, po)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+type RepositoryInterface interface {
+	GetLines(ctx context.Context) ([]Line, error)
+	CreateOrders(ctx context.Context, req OrdersRequest, createdBy *int) (*OrdersResult, error)
+}
@@ -0,0 +1,117 @@
+package replenishment
+
+import (
+	"context"
+	"math"
+
+	"inventory-system/internal"
+	"inventory-system/internal/purchasing"
+)
+
+type Service struct {
+	Repo RepositoryInterface
+}
+
+func NewService(repo RepositoryInterface) *Service {
+	return &Service{Repo: repo}
+}
+
+// GetProposal devolve as sugestões de compra agrupadas por fornecedor; supplierID diferente de zero
+// restringe a um fornecedor (e omite os produtos sem fornecedor).
+func (s *Service) GetProposal(ctx context.Context, supplierID int) (*Proposal, error) {
+	lines, err := s.Repo.GetLines(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p := propose(lines)
+	if supplierID != 0 {
+		groups := []SupplierGroup{}
+		for _, g := range p.Suppliers {
+			if g.SupplierID == supplierID {
+				groups = append(groups, g)
+			}
+		}
+		p.Suppliers, p.Unassigned = groups, []Line{}
+	}
+	return p, nil
+}
+
+// CreateOrders transforma a sugestão atual em pedidos de compra em rascunho, um por fornecedor.
+func (s *Service) CreateOrders(ctx context.Context, req OrdersRequest) (*OrdersResult, error) {
+	return s.Repo.CreateOrders(ctx, req, currentUser(ctx))
+}
+
+// suggestedQuantity calcula quanto comprar de um produto. A posição (saldo mais encomendado) é
+// comparada ao ponto de pedido, ou ao estoque mínimo quando ele é zero; com demanda prevista, o
+// nível sobe para o mínimo mais o consumo durante o prazo de entrega, se este for maior, para que
+// o pedido chegue antes da ruptura. Abaixo do nível, completa-se até MaxStock; sem estoque máximo,
+// compram-se múltiplos de ReorderQty até voltar ao nível; sem nenhum dos dois, só o que falta para
+// alcançá-lo.
+func suggestedQuantity(l Line) int {
+	level := l.ReorderPoint
+	if level == 0 {
+		level = l.MinStock
+	}
+	if l.LeadTimeDemand > 0 && l.MinStock+l.LeadTimeDemand > level {
+		level = l.MinStock + l.LeadTimeDemand
+	}
+	position := l.Quantity + l.OnOrder
+	if position >= level {
+		return 0
+	}
+	if l.MaxStock > 0 && l.MaxStock > position {
+		return l.MaxStock - position
+	}
+	missing := level - position
+	if l.ReorderQty > 0 {
+		return (missing + l.ReorderQty - 1) / l.ReorderQty * l.ReorderQty
+	}
+	return missing
+}
+
+// propose calcula as quantidades sugeridas e agrupa as linhas por fornecedor, na ordem em que
+// cada fornecedor aparece.
+func propose(lines []Line) *Proposal {
+	p := &Proposal{Suppliers: []SupplierGroup{}, Unassigned: []Line{}}
+	index := map[int]int{}
+	for _, l := range lines {
+		l.SuggestedQty = suggestedQuantity(l)
+		if l.SuggestedQty <= 0 {
+			continue
+		}
+		if l.supplierID == nil {
+			p.Unassigned = append(p.Unassigned, l)
+			continue
+		}
+		i, ok := index[*l.supplierID]
+		if !ok {
+			i = len(p.Suppliers)
+			index[*l.supplierID] = i
+			p.Suppliers = append(p.Suppliers, SupplierGroup{SupplierID: *l.supplierID, SupplierName: l.supplierName})
+		}
+		g := &p.Suppliers[i]
+		g.Lines = append(g.Lines, l)
+		g.Total += float64(l.SuggestedQty) * l.UnitCost
+	}
+	for i := range p.Suppliers {
+		p.Suppliers[i].Total = math.Round(p.Suppliers[i].Total*100) / 100
+	}
+	return p
+}
+
+// orderLines converte as sugestões de um fornecedor em linhas de pedido; sem custo informado,
+// o pedido usa o custo do vínculo com o fornecedor.
+func orderLines(g SupplierGroup) []purchasing.LineRequest {
+	lines := make([]purchasing.LineRequest, 0, len(g.Lines))
+	for _, l := range g.Lines {
+		lines = append(lines, purchasing.LineRequest{Barcode: l.Barcode, Quantity: l.SuggestedQty})
+	}
+	return lines
+}
+
+func currentUser(ctx context.Context) *int {
+	if userID, ok := internal.UserIDFromContext(ctx); ok {
+		return &userID
+	}
+	return nil
+}
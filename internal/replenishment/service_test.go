@@ -0,0 +1,83 @@
+package replenishment
+
+import (
+	"context"
+	"testing"
+)
+
+type mockReplenishmentRepo struct {
+	lines []Line
+}
+
+func (m *mockReplenishmentRepo) GetLines(ctx context.Context) ([]Line, error) {
+	return m.lines, nil
+}
+func (m *mockReplenishmentRepo) CreateOrders(ctx context.Context, req OrdersRequest, createdBy *int) (*OrdersResult, error) {
+	return &OrdersResult{Unassigned: propose(m.lines).Unassigned}, nil
+}
+
+func TestSuggestedQuantity(t *testing.T) {
+	cases := []struct {
+		name string
+		line Line
+		want int
+	}{
+		{"acima do mínimo", Line{Quantity: 5, MinStock: 5}, 0},
+		{"completa o mínimo", Line{Quantity: 2, MinStock: 5}, 3},
+		{"encomendado conta na posição", Line{Quantity: 2, OnOrder: 3, MinStock: 5}, 0},
+		{"ponto de pedido prevalece", Line{Quantity: 4, MinStock: 2, ReorderPoint: 6}, 2},
+		{"múltiplos do lote de compra", Line{Quantity: 1, ReorderPoint: 10, ReorderQty: 12}, 12},
+		{"vários lotes de compra", Line{Quantity: -5, ReorderPoint: 10, ReorderQty: 12}, 24},
+		{"completa até o máximo", Line{Quantity: 3, OnOrder: 2, ReorderPoint: 10, ReorderQty: 12, MaxStock: 40}, 35},
+		{"demanda no prazo de entrega", Line{Quantity: 8, MinStock: 2, LeadTimeDemand: 10}, 4},
+		{"demanda sem mínimo", Line{Quantity: 8, LeadTimeDemand: 10, ReorderQty: 5}, 5},
+		{"ponto de pedido cobre a demanda", Line{Quantity: 8, ReorderPoint: 15, LeadTimeDemand: 10}, 7},
+		{"sem parâmetros", Line{Quantity: 0}, 0},
+	}
+	for _, c := range cases {
+		if got := suggestedQuantity(c.line); got != c.want {
+			t.Errorf("%s: esperado %d, veio %d", c.name, c.want, got)
+		}
+	}
+}
+
+func TestService_GetProposal_Mock(t *testing.T) {
+	acme, beta := 1, 2
+	repo := &mockReplenishmentRepo{lines: []Line{
+		{ProductID: 1, Barcode: "111", Quantity: 1, MinStock: 5, UnitCost: 2.5, supplierID: &acme, supplierName: "Acme"},
+		{ProductID: 2, Barcode: "222", Quantity: 0, ReorderPoint: 3, ReorderQty: 10, UnitCost: 1.1, supplierID: &beta, supplierName: "Beta"},
+		{ProductID: 3, Barcode: "333", Quantity: 0, MaxStock: 8, MinStock: 2, UnitCost: 0.333, supplierID: &acme, supplierName: "Acme"},
+		{ProductID: 4, Barcode: "444", Quantity: 0, MinStock: 1},
+		// Já coberto por pedidos em aberto
+		{ProductID: 5, Barcode: "555", Quantity: 0, OnOrder: 4, MinStock: 4, supplierID: &beta, supplierName: "Beta"},
+	}}
+	svc := NewService(repo)
+	p, err := svc.GetProposal(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("erro ao sugerir reposição: %v", err)
+	}
+	if len(p.Suppliers) != 2 || p.Suppliers[0].SupplierID != acme || p.Suppliers[1].SupplierID != beta {
+		t.Fatalf("grupos por fornecedor incorretos: %+v", p.Suppliers)
+	}
+	if len(p.Suppliers[0].Lines) != 2 || p.Suppliers[0].Lines[1].SuggestedQty != 8 || p.Suppliers[0].Total != 12.66 {
+		t.Errorf("sugestões da Acme incorretas: %+v", p.Suppliers[0])
+	}
+	if len(p.Suppliers[1].Lines) != 1 || p.Suppliers[1].Lines[0].SuggestedQty != 10 || p.Suppliers[1].Total != 11 {
+		t.Errorf("sugestões da Beta incorretas: %+v", p.Suppliers[1])
+	}
+	if len(p.Unassigned) != 1 || p.Unassigned[0].Barcode != "444" {
+		t.Errorf("produtos sem fornecedor incorretos: %+v", p.Unassigned)
+	}
+	p, _ = svc.GetProposal(context.Background(), beta)
+	if len(p.Suppliers) != 1 || p.Suppliers[0].SupplierID != beta || len(p.Unassigned) != 0 {
+		t.Errorf("filtro por fornecedor incorreto: %+v", p)
+	}
+}
+
+func TestOrderLines(t *testing.T) {
+	g := SupplierGroup{Lines: []Line{{Barcode: "111", SuggestedQty: 4}, {Barcode: "222", SuggestedQty: 12}}}
+	lines := orderLines(g)
+	if len(lines) != 2 || lines[1].Barcode != "222" || lines[1].Quantity != 12 || lines[0].UnitCost != nil {
+		t.Errorf("linhas do pedido incorretas: %+v", lines)
+	}
+}
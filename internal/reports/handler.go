@@ -0,0 +1,60 @@
+package reports
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"inventory-system/internal"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(payload)
+}
+
+func respondError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, map[string]string{"error": message})
+}
+
+func RegisterRoutes(r chi.Router, db *pgxpool.Pool) {
+	service := NewService(NewRepository(db))
+
+	r.Route("/reports", func(r chi.Router) {
+		r.Use(internal.AuthMiddleware)
+		r.Get("/valuation", getValuationHandler(service))
+	})
+}
+
+// @Security ApiKeyAuth
+// @Summary Inventory valuation
+// @Description Total and per-product stock value, using each product's costing method (fifo or average).
+// @Tags reports
+// @Produce json
+// @Param as_of query string false "Value the stock as it was at this instant (RFC3339)"
+// @Success 200 {object} Valuation "Stock valuation"
+// @Failure 400 {object} map[string]string "Invalid date"
+// @Router /reports/valuation [get]
+func getValuationHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var asOf *time.Time
+		if v := r.URL.Query().Get("as_of"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				respondError(w, http.StatusBadRequest, "Invalid 'as_of' date, expected RFC3339")
+				return
+			}
+			asOf = &t
+		}
+		valuation, err := s.GetValuation(r.Context(), asOf)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, valuation)
+	}
+}
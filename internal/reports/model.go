@@ -0,0 +1,21 @@
+package reports
+
+import "time"
+
+// Valuation é o valor do estoque em um instante: o total e a abertura por produto.
+type Valuation struct {
+	AsOf          *time.Time         `json:"as_of"`
+	TotalQuantity int                `json:"total_quantity"`
+	TotalValue    float64            `json:"total_value"`
+	Products      []ProductValuation `json:"products"`
+}
+
+type ProductValuation struct {
+	ProductID     int     `json:"product_id"`
+	Barcode       string  `json:"barcode"`
+	Name          string  `json:"name"`
+	CostingMethod string  `json:"costing_method"`
+	Quantity      int     `json:"quantity"`
+	UnitCost      float64 `json:"unit_cost"`
+	Value         float64 `json:"value"`
+}
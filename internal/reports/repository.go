@@ -0,0 +1,45 @@
+package reports
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repository struct {
+	DB *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{DB: db}
+}
+
+// GetValuation soma quantidade e valor das movimentações de cada produto até asOf (todas, quando
+// nulo). Cada movimentação já guarda seu efeito no valor pelo método de custeio do produto.
+func (r *Repository) GetValuation(ctx context.Context, asOf *time.Time) ([]ProductValuation, error) {
+	query := `SELECT p.id, p.barcode, p.name, p.costing_method, SUM(m.delta), SUM(m.value)
+		FROM stock_movements m JOIN products p ON p.id = m.product_id
+		WHERE $1::timestamptz IS NULL OR m.created_at <= $1
+		GROUP BY p.id
+		HAVING SUM(m.delta) <> 0 OR SUM(m.value) <> 0
+		ORDER BY p.id`
+	rows, err := r.DB.Query(ctx, query, asOf)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	valuations := []ProductValuation{}
+	for rows.Next() {
+		var v ProductValuation
+		if err := rows.Scan(&v.ProductID, &v.Barcode, &v.Name, &v.CostingMethod, &v.Quantity, &v.Value); err != nil {
+			return nil, err
+		}
+		valuations = append(valuations, v)
+	}
+	return valuations, rows.Err()
+}
+
+type RepositoryInterface interface {
+	GetValuation(ctx context.Context, asOf *time.Time) ([]ProductValuation, error)
+}
@@ -0,0 +1,46 @@
+package reports
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+type Service struct {
+	Repo RepositoryInterface
+}
+
+func NewService(repo RepositoryInterface) *Service {
+	return &Service{Repo: repo}
+}
+
+// GetValuation devolve o valor do estoque atual ou, com asOf, o de um instante passado.
+func (s *Service) GetValuation(ctx context.Context, asOf *time.Time) (*Valuation, error) {
+	products, err := s.Repo.GetValuation(ctx, asOf)
+	if err != nil {
+		return nil, err
+	}
+	return summarize(asOf, products), nil
+}
+
+// summarize calcula o custo unitário de cada produto e os totais, arredondando valores em centavos.
+func summarize(asOf *time.Time, products []ProductValuation) *Valuation {
+	v := &Valuation{AsOf: asOf, Products: products}
+	total := 0.0
+	for i := range v.Products {
+		p := &v.Products[i]
+		if p.Quantity != 0 {
+			p.UnitCost = roundTo(p.Value/float64(p.Quantity), 4)
+		}
+		total += p.Value
+		p.Value = roundTo(p.Value, 2)
+		v.TotalQuantity += p.Quantity
+	}
+	v.TotalValue = roundTo(total, 2)
+	return v
+}
+
+func roundTo(x float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(x*scale) / scale
+}
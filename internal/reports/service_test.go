@@ -0,0 +1,50 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type mockReportRepo struct {
+	valuations []ProductValuation
+	asOf       *time.Time
+	fail       bool
+}
+
+func (m *mockReportRepo) GetValuation(ctx context.Context, asOf *time.Time) ([]ProductValuation, error) {
+	if m.fail {
+		return nil, fmt.Errorf("db error")
+	}
+	m.asOf = asOf
+	return m.valuations, nil
+}
+
+func TestService_GetValuation_Mock(t *testing.T) {
+	repo := &mockReportRepo{valuations: []ProductValuation{
+		{ProductID: 1, Quantity: 3, Value: 10},
+		{ProductID: 2, Quantity: 10, Value: 25.25},
+		// Estoque zerado com valor residual continua no relatório
+		{ProductID: 3, Quantity: 0, Value: 0.5},
+	}}
+	svc := NewService(repo)
+	asOf := time.Date(2026, 12, 31, 23, 59, 59, 0, time.UTC)
+	v, err := svc.GetValuation(context.Background(), &asOf)
+	if err != nil {
+		t.Fatalf("erro ao valorar estoque: %v", err)
+	}
+	if repo.asOf == nil || !repo.asOf.Equal(asOf) || v.AsOf != &asOf {
+		t.Errorf("as_of não repassado: %v", repo.asOf)
+	}
+	if v.TotalQuantity != 13 || v.TotalValue != 35.75 {
+		t.Errorf("totais incorretos: %d / %.4f", v.TotalQuantity, v.TotalValue)
+	}
+	if v.Products[0].UnitCost != 3.3333 || v.Products[1].UnitCost != 2.525 || v.Products[2].UnitCost != 0 {
+		t.Errorf("custos unitários incorretos: %+v", v.Products)
+	}
+	repo.fail = true
+	if _, err := svc.GetValuation(context.Background(), nil); err == nil {
+		t.Error("esperava erro do repositório")
+	}
+}
@@ -0,0 +1,265 @@
+package returns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"inventory-system/internal"
+	"inventory-system/internal/products"
+	"inventory-system/internal/sales"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var validate = validator.New()
+
+func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(payload)
+}
+
+func respondError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, map[string]string{"error": message})
+}
+
+func RegisterRoutes(r chi.Router, db *pgxpool.Pool) {
+	service := NewService(NewRepository(db))
+
+	r.Route("/returns", func(r chi.Router) {
+		r.Use(internal.AuthMiddleware)
+		r.Post("/", createReturnHandler(service))
+		r.Get("/", getReturnsHandler(service))
+		r.Get("/{id}", getReturnHandler(service))
+		r.Post("/{id}/receive", receiveHandler(service))
+		r.Post("/{id}/release", releaseHandler(service))
+		r.Post("/{id}/cancel", transitionHandler(service.Cancel))
+	})
+}
+
+func respondReturnError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		respondError(w, http.StatusNotFound, "Return not found")
+	case errors.Is(err, sales.ErrNotFound):
+		respondError(w, http.StatusNotFound, "Sales order not found")
+	case errors.Is(err, products.ErrProductNotFound):
+		respondError(w, http.StatusNotFound, "Product not found")
+	case errors.Is(err, products.ErrLocationNotFound):
+		respondError(w, http.StatusNotFound, "Location not found")
+	case errors.Is(err, ErrDuplicateLine), errors.Is(err, ErrNotShipped), errors.Is(err, ErrOverReturn),
+		errors.Is(err, ErrNotOnReturn), errors.Is(err, ErrOverReceipt), errors.Is(err, ErrOverRelease), errors.Is(err, ErrRelease),
+		errors.Is(err, products.ErrReservedStock), errors.Is(err, products.ErrSystemLocation),
+		errors.Is(err, products.ErrSerialsRequired), errors.Is(err, products.ErrSerialCount), errors.Is(err, products.ErrSerialConflict),
+		errors.Is(err, products.ErrInvalidExpiryDate), errors.Is(err, products.ErrNotSerialized):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrInvalidStatus):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Authorize a customer return (RMA)
+// @Description With sales_order_id every product must have been shipped on that order, up to the shipped quantity
+// @Description not already on other returns, and the customer defaults to the order's. Without it the return is
+// @Description standalone and customer is required.
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Param return body ReturnRequest true "Return data" example({"sales_order_id":12,"reason":"damaged in transit","lines":[{"barcode":"7891234567895","quantity":1}]})
+// @Success 201 {object} Return "Created return"
+// @Failure 400 {object} map[string]string "Invalid data, duplicate product, product not shipped or quantity above the shipped"
+// @Failure 404 {object} map[string]string "Sales order or product not found"
+// @Router /returns [post]
+func createReturnHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req ReturnRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		rma, err := s.CreateReturn(r.Context(), req)
+		if err != nil {
+			respondReturnError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusCreated, rma)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary List customer returns
+// @Tags returns
+// @Produce json
+// @Param status query string false "Filter by status (open, partially_received, received, cancelled)"
+// @Param sales_order_id query int false "Filter by sales order"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 20, max: 100)"
+// @Success 200 {array} Return "List of returns, newest first, without lines"
+// @Header 200 {int} X-Total-Count "Total number of returns"
+// @Router /returns [get]
+func getReturnsHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 1 {
+			page = 1
+		}
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit < 1 || limit > 100 {
+			limit = 20
+		}
+		salesOrderID, _ := strconv.Atoi(r.URL.Query().Get("sales_order_id"))
+		q := ReturnsQuery{Status: r.URL.Query().Get("status"), SalesOrderID: salesOrderID, Page: page, Limit: limit}
+		list, total, err := s.GetReturns(r.Context(), q)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		respondJSON(w, http.StatusOK, list)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Get a customer return
+// @Tags returns
+// @Produce json
+// @Param id path int true "Return ID"
+// @Success 200 {object} Return "Return with lines, quantities per disposition and receipts"
+// @Failure 404 {object} map[string]string "Return not found"
+// @Router /returns/{id} [get]
+func getReturnHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		rma, err := s.GetReturn(r.Context(), id)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if rma == nil {
+			respondError(w, http.StatusNotFound, "Return not found")
+			return
+		}
+		respondJSON(w, http.StatusOK, rma)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Cancel a customer return
+// @Description Only returns that have not received anything can be cancelled.
+// @Tags returns
+// @Produce json
+// @Param id path int true "Return ID"
+// @Success 200 {object} Return "Cancelled return"
+// @Failure 404 {object} map[string]string "Return not found"
+// @Failure 409 {object} map[string]string "Return already received"
+// @Router /returns/{id}/cancel [post]
+func transitionHandler(action func(ctx context.Context, id int) (*Return, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		rma, err := action(r.Context(), id)
+		if err != nil {
+			respondReturnError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, rma)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Receive returned goods
+// @Description Each line has a disposition: restock enters the stock at location_id (or the default location) as a
+// @Description stock entry with reason return; quarantine stays off the books until released; scrap enters the stock
+// @Description and leaves it in the same transaction through an applied adjustment with reason return_scrap.
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Param id path int true "Return ID"
+// @Param receipt body ReceiveRequest true "Received lines" example({"lines":[{"barcode":"7891234567895","quantity":1,"disposition":"quarantine","note":"box damaged"}]})
+// @Success 200 {object} Return "Updated return"
+// @Failure 400 {object} map[string]string "Invalid data, product not on the return or quantity above the authorized"
+// @Failure 404 {object} map[string]string "Return or location not found"
+// @Failure 409 {object} map[string]string "Return already received or cancelled"
+// @Router /returns/{id}/receive [post]
+func receiveHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		var req ReceiveRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		rma, err := s.Receive(r.Context(), id, req)
+		if err != nil {
+			respondReturnError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, rma)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Release quarantined returns
+// @Description Moves quarantined items to restock (a stock entry at location_id or the default location) or scrap
+// @Description (an entry written off by an adjustment with reason return_scrap). The return status does not change.
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Param id path int true "Return ID"
+// @Param release body ReceiveRequest true "Released lines" example({"lines":[{"barcode":"7891234567895","quantity":1,"disposition":"restock"}]})
+// @Success 200 {object} Return "Updated return"
+// @Failure 400 {object} map[string]string "Invalid data, quarantine disposition or quantity above the quarantined"
+// @Failure 404 {object} map[string]string "Return or location not found"
+// @Failure 409 {object} map[string]string "Return cancelled"
+// @Router /returns/{id}/release [post]
+func releaseHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		var req ReceiveRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		rma, err := s.Release(r.Context(), id, req)
+		if err != nil {
+			respondReturnError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, rma)
+	}
+}
@@ -0,0 +1,124 @@
+package returns
+
+import (
+	"time"
+
+	"inventory-system/internal/products"
+)
+
+// Estados de uma devolução
+const (
+	StatusOpen              = "open"
+	StatusPartiallyReceived = "partially_received"
+	StatusReceived          = "received"
+	StatusCancelled         = "cancelled"
+)
+
+// Destinações dos itens recebidos: de volta ao estoque, para a quarentena ou descarte (baixa)
+const (
+	DispositionRestock    = "restock"
+	DispositionQuarantine = "quarantine"
+	DispositionScrap      = "scrap"
+)
+
+// ReasonReturn é o motivo gravado nas movimentações de entrada das devoluções
+const ReasonReturn = "return"
+
+// Return é uma autorização de devolução (RMA), ligada ou não a um pedido de venda expedido.
+type Return struct {
+	ID           int        `json:"id"`
+	SalesOrderID *int       `json:"sales_order_id"`
+	Customer     string     `json:"customer"`
+	Reason       string     `json:"reason"`
+	Notes        string     `json:"notes"`
+	Status       string     `json:"status"`
+	CreatedBy    *int       `json:"created_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ClosedAt     *time.Time `json:"closed_at"`
+	Lines        []Line     `json:"lines,omitempty"`
+	Receipts     []Receipt  `json:"receipts,omitempty"`
+}
+
+// Line é um item autorizado; Received é a soma de Restocked, Quarantined e Scrapped. Quarantined é o
+// que ainda aguarda liberação: itens liberados passam a contar como devolvidos ao estoque ou descartados.
+type Line struct {
+	ID          int    `json:"id"`
+	ProductID   int    `json:"product_id"`
+	Barcode     string `json:"barcode"`
+	Name        string `json:"name"`
+	Quantity    int    `json:"quantity"`
+	Received    int    `json:"received"`
+	Restocked   int    `json:"restocked"`
+	Quarantined int    `json:"quarantined"`
+	Scrapped    int    `json:"scrapped"`
+
+	serialized bool
+	// codes são todos os identificadores do produto, para achar a linha por qualquer um deles
+	codes []string
+}
+
+// Receipt registra o recebimento de um item com sua destinação. Value é o valor que voltou ao estoque
+// ou, no descarte, o valor baixado pelo ajuste AdjustmentID. Released marca a liberação de itens
+// que estavam em quarentena.
+type Receipt struct {
+	ID           int       `json:"id"`
+	Barcode      string    `json:"barcode"`
+	Disposition  string    `json:"disposition"`
+	Quantity     int       `json:"quantity"`
+	LocationID   *int      `json:"location_id"`
+	Value        float64   `json:"value"`
+	Released     bool      `json:"released"`
+	AdjustmentID *int      `json:"adjustment_id"`
+	Note         string    `json:"note"`
+	CreatedBy    *int      `json:"created_by"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type ReturnRequest struct {
+	// Pedido de venda expedido de onde vêm os itens; sem ele a devolução é avulsa, só por código de barras
+	SalesOrderID int           `json:"sales_order_id"`
+	Customer     string        `json:"customer" validate:"required_without=SalesOrderID"`
+	Reason       string        `json:"reason"`
+	Notes        string        `json:"notes"`
+	Lines        []LineRequest `json:"lines" validate:"required,min=1,dive"`
+}
+
+type LineRequest struct {
+	Barcode  string `json:"barcode" validate:"required"`
+	Quantity int    `json:"quantity" validate:"required,gt=0"`
+}
+
+// ReceiveRequest é o corpo do recebimento e também da liberação da quarentena, que aceita apenas
+// as destinações restock e scrap.
+type ReceiveRequest struct {
+	// Local dos itens devolvidos ao estoque; sem ele vale o padrão
+	LocationID int           `json:"location_id"`
+	Lines      []ReceiveLine `json:"lines" validate:"required,min=1,dive"`
+}
+
+type ReceiveLine struct {
+	Barcode     string   `json:"barcode" validate:"required"`
+	Quantity    int      `json:"quantity" validate:"required_without=Serials,gte=0"`
+	Disposition string   `json:"disposition" validate:"required,oneof=restock quarantine scrap"`
+	LotNumber   string   `json:"lot_number" validate:"required_with=ExpiryDate"`
+	ExpiryDate  string   `json:"expiry_date" validate:"omitempty,datetime=2006-01-02"`
+	Serials     []string `json:"serials" validate:"omitempty,unique,dive,required"`
+	Note        string   `json:"note"`
+}
+
+// Item é uma linha de recebimento já convertida para a movimentação de entrada.
+type Item struct {
+	Barcode     string
+	Quantity    int
+	Disposition string
+	Lots        []products.LotQuantity
+	Serials     []string
+	Note        string
+}
+
+type ReturnsQuery struct {
+	Status       string
+	SalesOrderID int
+	Page         int
+	Limit        int
+}
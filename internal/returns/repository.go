@@ -0,0 +1,436 @@
+package returns
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+
+	"inventory-system/internal/adjustments"
+	"inventory-system/internal/products"
+	"inventory-system/internal/sales"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrNotFound      = errors.New("return not found")
+	ErrInvalidStatus = errors.New("return cannot change in its current status")
+	ErrDuplicateLine = errors.New("product appears more than once in the return")
+	ErrNotShipped    = errors.New("product was not shipped on the sales order")
+	ErrOverReturn    = errors.New("returned quantity exceeds the shipped quantity not yet returned")
+	ErrNotOnReturn   = errors.New("product is not on the return")
+	ErrOverReceipt   = errors.New("received quantity exceeds the authorized quantity")
+	ErrOverRelease   = errors.New("released quantity exceeds the quantity in quarantine")
+	ErrRelease       = errors.New("items leave quarantine only as restock or scrap")
+)
+
+type Repository struct {
+	DB *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{DB: db}
+}
+
+const returnColumns = "id, sales_order_id, customer, reason, notes, status, created_by, created_at, closed_at"
+
+func scanReturn(row pgx.Row, rma *Return) error {
+	return row.Scan(&rma.ID, &rma.SalesOrderID, &rma.Customer, &rma.Reason, &rma.Notes, &rma.Status, &rma.CreatedBy, &rma.CreatedAt, &rma.ClosedAt)
+}
+
+// querier é atendido tanto pelo pool quanto por uma transação.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
+func queryLines(ctx context.Context, q querier, returnID int) ([]Line, error) {
+	query := `SELECT l.id, l.product_id, p.barcode, ` + products.Identifiers("p.id") + `, p.name, l.quantity, l.received, p.serialized,
+			COALESCE(SUM(rc.quantity) FILTER (WHERE rc.disposition = 'restock'), 0),
+			COALESCE(SUM(rc.quantity) FILTER (WHERE rc.disposition = 'quarantine'), 0) - COALESCE(SUM(rc.quantity) FILTER (WHERE rc.released), 0),
+			COALESCE(SUM(rc.quantity) FILTER (WHERE rc.disposition = 'scrap'), 0)
+		FROM return_lines l
+		JOIN products p ON p.id = l.product_id
+		LEFT JOIN return_receipts rc ON rc.line_id = l.id
+		WHERE l.return_id = $1
+		GROUP BY l.id, p.id ORDER BY l.id`
+	rows, err := q.Query(ctx, query, returnID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	lines := []Line{}
+	for rows.Next() {
+		var l Line
+		err := rows.Scan(&l.ID, &l.ProductID, &l.Barcode, &l.codes, &l.Name, &l.Quantity, &l.Received, &l.serialized, &l.Restocked, &l.Quarantined, &l.Scrapped)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
+
+func queryReceipts(ctx context.Context, q querier, returnID int) ([]Receipt, error) {
+	query := `SELECT rc.id, p.barcode, rc.disposition, rc.quantity, rc.location_id, rc.value, rc.released, rc.adjustment_id,
+			rc.note, rc.created_by, rc.created_at
+		FROM return_receipts rc
+		JOIN return_lines l ON l.id = rc.line_id
+		JOIN products p ON p.id = l.product_id
+		WHERE rc.return_id = $1 ORDER BY rc.id`
+	rows, err := q.Query(ctx, query, returnID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	receipts := []Receipt{}
+	for rows.Next() {
+		var rc Receipt
+		if err := rows.Scan(&rc.ID, &rc.Barcode, &rc.Disposition, &rc.Quantity, &rc.LocationID, &rc.Value, &rc.Released, &rc.AdjustmentID,
+			&rc.Note, &rc.CreatedBy, &rc.CreatedAt); err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, rc)
+	}
+	return receipts, rows.Err()
+}
+
+func loadDetails(ctx context.Context, q querier, rma *Return) error {
+	var err error
+	if rma.Lines, err = queryLines(ctx, q, rma.ID); err != nil {
+		return err
+	}
+	rma.Receipts, err = queryReceipts(ctx, q, rma.ID)
+	return err
+}
+
+// CreateReturn grava a autorização de devolução. Com pedido de venda, cada item precisa ter sido
+// expedido nele, e a quantidade não pode passar do expedido menos o que outras devoluções não
+// canceladas do mesmo pedido já autorizaram; o pedido fica bloqueado para serializar essas contas.
+func (r *Repository) CreateReturn(ctx context.Context, rma *Return, lines []LineRequest) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if rma.SalesOrderID != nil {
+		var customer string
+		err := tx.QueryRow(ctx, `SELECT customer FROM sales_orders WHERE id = $1 FOR UPDATE`, *rma.SalesOrderID).Scan(&customer)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return sales.ErrNotFound
+			}
+			return err
+		}
+		if rma.Customer == "" {
+			rma.Customer = customer
+		}
+	}
+	query := `INSERT INTO returns (sales_order_id, customer, reason, notes, created_by) VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, status, created_at`
+	if err := tx.QueryRow(ctx, query, rma.SalesOrderID, rma.Customer, rma.Reason, rma.Notes, rma.CreatedBy).Scan(&rma.ID, &rma.Status, &rma.CreatedAt); err != nil {
+		return err
+	}
+	for _, l := range lines {
+		if err := insertLine(ctx, tx, rma, l); err != nil {
+			return err
+		}
+	}
+	if rma.Lines, err = queryLines(ctx, tx, rma.ID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func insertLine(ctx context.Context, tx pgx.Tx, rma *Return, l LineRequest) error {
+	var productID int
+	if rma.SalesOrderID != nil {
+		// Expedido no pedido menos o já autorizado em outras devoluções (esta incluída)
+		query := `SELECT l.product_id, l.shipped - COALESCE((SELECT SUM(rl.quantity) FROM return_lines rl
+				JOIN returns r ON r.id = rl.return_id
+				WHERE r.sales_order_id = l.sales_order_id AND rl.product_id = l.product_id AND r.status <> 'cancelled'), 0)
+			FROM sales_order_lines l
+			WHERE l.sales_order_id = $1 AND ` + products.ByCode("l.product_id", "$2") + ` AND l.shipped > 0`
+		var returnable int
+		if err := tx.QueryRow(ctx, query, *rma.SalesOrderID, l.Barcode).Scan(&productID, &returnable); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrNotShipped
+			}
+			return err
+		}
+		if l.Quantity > returnable {
+			return ErrOverReturn
+		}
+	} else {
+		if err := tx.QueryRow(ctx, `SELECT id FROM products WHERE `+products.ByCode("id", "$1"), l.Barcode).Scan(&productID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return products.ErrProductNotFound
+			}
+			return err
+		}
+	}
+	_, err := tx.Exec(ctx, `INSERT INTO return_lines (return_id, product_id, quantity) VALUES ($1, $2, $3)`, rma.ID, productID, l.Quantity)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrDuplicateLine
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *Repository) GetReturns(ctx context.Context, q ReturnsQuery) ([]Return, int, error) {
+	args := []interface{}{}
+	where := ""
+	idx := 1
+	if q.Status != "" {
+		where += " AND status = $" + strconv.Itoa(idx)
+		args = append(args, q.Status)
+		idx++
+	}
+	if q.SalesOrderID != 0 {
+		where += " AND sales_order_id = $" + strconv.Itoa(idx)
+		args = append(args, q.SalesOrderID)
+		idx++
+	}
+	limit := q.Limit
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	page := q.Page
+	if page < 1 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+	query := "SELECT " + returnColumns + " FROM returns WHERE 1=1" + where +
+		" ORDER BY id DESC LIMIT $" + strconv.Itoa(idx) + " OFFSET $" + strconv.Itoa(idx+1)
+	rows, err := r.DB.Query(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	list := []Return{}
+	for rows.Next() {
+		var rma Return
+		if err := scanReturn(rows, &rma); err != nil {
+			return nil, 0, err
+		}
+		list = append(list, rma)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	total := 0
+	if err := r.DB.QueryRow(ctx, "SELECT COUNT(*) FROM returns WHERE 1=1"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+func (r *Repository) GetReturn(ctx context.Context, id int) (*Return, error) {
+	var rma Return
+	if err := scanReturn(r.DB.QueryRow(ctx, "SELECT "+returnColumns+" FROM returns WHERE id = $1", id), &rma); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err := loadDetails(ctx, r.DB, &rma); err != nil {
+		return nil, err
+	}
+	return &rma, nil
+}
+
+// lockReturn lê a devolução bloqueando-a até o fim de tx.
+func lockReturn(ctx context.Context, tx pgx.Tx, id int) (*Return, error) {
+	var rma Return
+	if err := scanReturn(tx.QueryRow(ctx, "SELECT "+returnColumns+" FROM returns WHERE id = $1 FOR UPDATE", id), &rma); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &rma, nil
+}
+
+// Receive recebe os itens devolvidos conforme a destinação de cada um (veja dispose). Tudo ocorre
+// em uma transação.
+func (r *Repository) Receive(ctx context.Context, id, locationID int, items []Item, createdBy *int) (*Return, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	rma, err := lockReturn(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rma.Status != StatusOpen && rma.Status != StatusPartiallyReceived {
+		return nil, ErrInvalidStatus
+	}
+	lines, err := queryLines(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, it := range items {
+		l := findLine(lines, it.Barcode)
+		if l == nil {
+			return nil, ErrNotOnReturn
+		}
+		if l.Received+it.Quantity > l.Quantity {
+			return nil, ErrOverReceipt
+		}
+		if err := dispose(ctx, tx, id, l, locationID, it, false, createdBy); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE return_lines SET received = received + $1 WHERE id = $2`, it.Quantity, l.ID); err != nil {
+			return nil, err
+		}
+		l.Received += it.Quantity
+	}
+	rma.Status = receivedStatus(lines)
+	query := `UPDATE returns SET status = $1, closed_at = CASE WHEN $1 = 'received' THEN NOW() END WHERE id = $2 RETURNING closed_at`
+	if err := tx.QueryRow(ctx, query, rma.Status, id).Scan(&rma.ClosedAt); err != nil {
+		return nil, err
+	}
+	if err := loadDetails(ctx, tx, rma); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return rma, nil
+}
+
+// Release libera itens da quarentena, devolvendo-os ao estoque ou descartando-os como no recebimento.
+// A situação da devolução não muda: os itens já contam como recebidos.
+func (r *Repository) Release(ctx context.Context, id, locationID int, items []Item, createdBy *int) (*Return, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	rma, err := lockReturn(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rma.Status == StatusCancelled {
+		return nil, ErrInvalidStatus
+	}
+	lines, err := queryLines(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, it := range items {
+		l := findLine(lines, it.Barcode)
+		if l == nil {
+			return nil, ErrNotOnReturn
+		}
+		if it.Quantity > l.Quarantined {
+			return nil, ErrOverRelease
+		}
+		if err := dispose(ctx, tx, id, l, locationID, it, true, createdBy); err != nil {
+			return nil, err
+		}
+		l.Quarantined -= it.Quantity
+	}
+	if err := loadDetails(ctx, tx, rma); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return rma, nil
+}
+
+// dispose aplica a destinação de um item e grava o recebimento. Itens em quarentena ficam fora do
+// estoque, sem movimentação, até serem liberados. Itens devolvidos ao estoque entram pelo mesmo
+// caminho das entradas (products.ApplyMovement, motivo return) no local informado ou no padrão.
+// Itens descartados entram da mesma forma e saem na mesma transação por um ajuste de baixa (motivo
+// return_scrap) com os mesmos lotes e séries, valorizado pelo custo da entrada.
+func dispose(ctx context.Context, tx pgx.Tx, id int, l *Line, locationID int, it Item, released bool, createdBy *int) error {
+	var location, adjustmentID *int
+	var value float64
+	if it.Disposition != DispositionQuarantine {
+		if l.serialized && len(it.Serials) == 0 {
+			return products.ErrSerialsRequired
+		}
+		reference := "return #" + strconv.Itoa(id)
+		m := &products.StockMovement{ProductID: l.ProductID, LocationID: locationID, Delta: it.Quantity, Reason: ReasonReturn,
+			Reference: reference, Lots: it.Lots, Serials: it.Serials}
+		if err := products.ApplyMovement(ctx, tx, m); err != nil {
+			return err
+		}
+		location, value = &m.LocationID, m.Value
+		if it.Disposition == DispositionScrap {
+			a := adjustments.Adjustment{
+				ProductID:  l.ProductID,
+				LocationID: m.LocationID,
+				Quantity:   -it.Quantity,
+				ReasonCode: adjustments.ReasonReturnScrap,
+				Note:       reference,
+				Value:      value,
+				Status:     adjustments.StatusApplied,
+				CreatedBy:  createdBy,
+				Lots:       m.Lots,
+				Serials:    m.Serials,
+			}
+			if err := adjustments.Insert(ctx, tx, &a); err != nil {
+				return err
+			}
+			adjustmentID = &a.ID
+		}
+	}
+	query := `INSERT INTO return_receipts (return_id, line_id, disposition, quantity, location_id, value, released, adjustment_id, note, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	_, err := tx.Exec(ctx, query, id, l.ID, it.Disposition, it.Quantity, location, value, released, adjustmentID, it.Note, createdBy)
+	return err
+}
+
+// Cancel cancela uma devolução que ainda não recebeu nada.
+func (r *Repository) Cancel(ctx context.Context, id int) (*Return, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	rma, err := lockReturn(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rma.Status != StatusOpen {
+		return nil, ErrInvalidStatus
+	}
+	query := `UPDATE returns SET status = $1, closed_at = NOW() WHERE id = $2 RETURNING status, closed_at`
+	if err := tx.QueryRow(ctx, query, StatusCancelled, id).Scan(&rma.Status, &rma.ClosedAt); err != nil {
+		return nil, err
+	}
+	if err := loadDetails(ctx, tx, rma); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return rma, nil
+}
+
+func findLine(lines []Line, barcode string) *Line {
+	for i := range lines {
+		if lines[i].Barcode == barcode || slices.Contains(lines[i].codes, barcode) {
+			return &lines[i]
+		}
+	}
+	return nil
+}
+
+type RepositoryInterface interface {
+	CreateReturn(ctx context.Context, rma *Return, lines []LineRequest) error
+	GetReturns(ctx context.Context, q ReturnsQuery) ([]Return, int, error)
+	GetReturn(ctx context.Context, id int) (*Return, error)
+	Receive(ctx context.Context, id, locationID int, items []Item, createdBy *int) (*Return, error)
+	Release(ctx context.Context, id, locationID int, items []Item, createdBy *int) (*Return, error)
+	Cancel(ctx context.Context, id int) (*Return, error)
+}
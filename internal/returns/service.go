@@ -0,0 +1,109 @@
+package returns
+
+import (
+	"context"
+	"time"
+
+	"inventory-system/internal"
+	"inventory-system/internal/products"
+)
+
+type Service struct {
+	Repo RepositoryInterface
+}
+
+func NewService(repo RepositoryInterface) *Service {
+	return &Service{Repo: repo}
+}
+
+func (s *Service) CreateReturn(ctx context.Context, req ReturnRequest) (*Return, error) {
+	rma := &Return{Customer: req.Customer, Reason: req.Reason, Notes: req.Notes, CreatedBy: currentUser(ctx)}
+	if req.SalesOrderID != 0 {
+		rma.SalesOrderID = &req.SalesOrderID
+	}
+	if err := s.Repo.CreateReturn(ctx, rma, req.Lines); err != nil {
+		return nil, err
+	}
+	return rma, nil
+}
+
+func (s *Service) GetReturns(ctx context.Context, q ReturnsQuery) ([]Return, int, error) {
+	return s.Repo.GetReturns(ctx, q)
+}
+
+func (s *Service) GetReturn(ctx context.Context, id int) (*Return, error) {
+	return s.Repo.GetReturn(ctx, id)
+}
+
+func (s *Service) Cancel(ctx context.Context, id int) (*Return, error) {
+	return s.Repo.Cancel(ctx, id)
+}
+
+// Receive converte as linhas recebidas e aplica a destinação de cada uma.
+func (s *Service) Receive(ctx context.Context, id int, req ReceiveRequest) (*Return, error) {
+	items, err := toItems(req.Lines)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repo.Receive(ctx, id, req.LocationID, items, currentUser(ctx))
+}
+
+// Release libera itens da quarentena, de volta ao estoque ou para descarte.
+func (s *Service) Release(ctx context.Context, id int, req ReceiveRequest) (*Return, error) {
+	items, err := toItems(req.Lines)
+	if err != nil {
+		return nil, err
+	}
+	for _, it := range items {
+		if it.Disposition == DispositionQuarantine {
+			return nil, ErrRelease
+		}
+	}
+	return s.Repo.Release(ctx, id, req.LocationID, items, currentUser(ctx))
+}
+
+// toItems converte as linhas (quantidade pelos números de série, lote e validade) em itens.
+func toItems(lines []ReceiveLine) ([]Item, error) {
+	items := make([]Item, 0, len(lines))
+	for _, l := range lines {
+		it := Item{Barcode: l.Barcode, Quantity: l.Quantity, Disposition: l.Disposition, Serials: l.Serials, Note: l.Note}
+		if len(l.Serials) > 0 {
+			if it.Quantity == 0 {
+				it.Quantity = len(l.Serials)
+			}
+			if it.Quantity != len(l.Serials) {
+				return nil, products.ErrSerialCount
+			}
+		}
+		if l.LotNumber != "" {
+			lot := products.LotQuantity{LotNumber: l.LotNumber, Quantity: it.Quantity}
+			if l.ExpiryDate != "" {
+				expiry, err := time.Parse("2006-01-02", l.ExpiryDate)
+				if err != nil {
+					return nil, products.ErrInvalidExpiryDate
+				}
+				lot.ExpiryDate = &expiry
+			}
+			it.Lots = []products.LotQuantity{lot}
+		}
+		items = append(items, it)
+	}
+	return items, nil
+}
+
+// receivedStatus devolve received quando todas as linhas foram recebidas por completo.
+func receivedStatus(lines []Line) string {
+	for _, l := range lines {
+		if l.Received < l.Quantity {
+			return StatusPartiallyReceived
+		}
+	}
+	return StatusReceived
+}
+
+func currentUser(ctx context.Context) *int {
+	if userID, ok := internal.UserIDFromContext(ctx); ok {
+		return &userID
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package returns
+
+import (
+	"context"
+	"testing"
+
+	"inventory-system/internal/products"
+)
+
+type mockReturnRepo struct {
+	returns []Return
+	items   []Item
+}
+
+func (m *mockReturnRepo) CreateReturn(ctx context.Context, rma *Return, lines []LineRequest) error {
+	rma.ID = len(m.returns) + 1
+	rma.Status = StatusOpen
+	for i, l := range lines {
+		rma.Lines = append(rma.Lines, Line{ID: i + 1, Barcode: l.Barcode, Quantity: l.Quantity})
+	}
+	m.returns = append(m.returns, *rma)
+	return nil
+}
+func (m *mockReturnRepo) GetReturns(ctx context.Context, q ReturnsQuery) ([]Return, int, error) {
+	return m.returns, len(m.returns), nil
+}
+func (m *mockReturnRepo) GetReturn(ctx context.Context, id int) (*Return, error) {
+	if id < 1 || id > len(m.returns) {
+		return nil, nil
+	}
+	return &m.returns[id-1], nil
+}
+func (m *mockReturnRepo) Receive(ctx context.Context, id, locationID int, items []Item, createdBy *int) (*Return, error) {
+	rma := &m.returns[id-1]
+	if rma.Status != StatusOpen && rma.Status != StatusPartiallyReceived {
+		return nil, ErrInvalidStatus
+	}
+	for _, it := range items {
+		l := findLine(rma.Lines, it.Barcode)
+		if l == nil {
+			return nil, ErrNotOnReturn
+		}
+		if l.Received+it.Quantity > l.Quantity {
+			return nil, ErrOverReceipt
+		}
+		l.Received += it.Quantity
+		switch it.Disposition {
+		case DispositionRestock:
+			l.Restocked += it.Quantity
+		case DispositionQuarantine:
+			l.Quarantined += it.Quantity
+		case DispositionScrap:
+			l.Scrapped += it.Quantity
+		}
+	}
+	m.items = append(m.items, items...)
+	rma.Status = receivedStatus(rma.Lines)
+	return rma, nil
+}
+func (m *mockReturnRepo) Release(ctx context.Context, id, locationID int, items []Item, createdBy *int) (*Return, error) {
+	rma := &m.returns[id-1]
+	for _, it := range items {
+		l := findLine(rma.Lines, it.Barcode)
+		if l == nil {
+			return nil, ErrNotOnReturn
+		}
+		if it.Quantity > l.Quarantined {
+			return nil, ErrOverRelease
+		}
+		l.Quarantined -= it.Quantity
+		if it.Disposition == DispositionScrap {
+			l.Scrapped += it.Quantity
+		} else {
+			l.Restocked += it.Quantity
+		}
+	}
+	return rma, nil
+}
+func (m *mockReturnRepo) Cancel(ctx context.Context, id int) (*Return, error) {
+	rma := &m.returns[id-1]
+	if rma.Status != StatusOpen {
+		return nil, ErrInvalidStatus
+	}
+	rma.Status = StatusCancelled
+	return rma, nil
+}
+
+func TestReceivedStatus(t *testing.T) {
+	if got := receivedStatus([]Line{{Quantity: 2, Received: 2}, {Quantity: 1}}); got != StatusPartiallyReceived {
+		t.Errorf("esperado %s, veio %s", StatusPartiallyReceived, got)
+	}
+	if got := receivedStatus([]Line{{Quantity: 2, Received: 2}, {Quantity: 1, Received: 1}}); got != StatusReceived {
+		t.Errorf("esperado %s, veio %s", StatusReceived, got)
+	}
+}
+
+func TestService_Return_Mock(t *testing.T) {
+	repo := &mockReturnRepo{}
+	svc := NewService(repo)
+	ctx := context.Background()
+	rma, err := svc.CreateReturn(ctx, ReturnRequest{SalesOrderID: 7, Reason: "damaged", Lines: []LineRequest{
+		{Barcode: "111", Quantity: 3},
+		{Barcode: "nb", Quantity: 2},
+	}})
+	if err != nil || rma.Status != StatusOpen || rma.SalesOrderID == nil || *rma.SalesOrderID != 7 {
+		t.Fatalf("erro ao criar devolução: %v %+v", err, rma)
+	}
+
+	_, err = svc.Receive(ctx, rma.ID, ReceiveRequest{Lines: []ReceiveLine{
+		{Barcode: "nb", Quantity: 3, Disposition: DispositionRestock, Serials: []string{"S1", "S2"}},
+	}})
+	if err != products.ErrSerialCount {
+		t.Errorf("esperado ErrSerialCount, veio %v", err)
+	}
+	_, err = svc.Receive(ctx, rma.ID, ReceiveRequest{Lines: []ReceiveLine{
+		{Barcode: "111", Quantity: 1, Disposition: DispositionRestock, LotNumber: "L1", ExpiryDate: "31/12/2026"},
+	}})
+	if err != products.ErrInvalidExpiryDate {
+		t.Errorf("esperado ErrInvalidExpiryDate, veio %v", err)
+	}
+
+	rma, err = svc.Receive(ctx, rma.ID, ReceiveRequest{Lines: []ReceiveLine{
+		{Barcode: "111", Quantity: 1, Disposition: DispositionRestock, LotNumber: "L1", ExpiryDate: "2026-12-31"},
+		{Barcode: "111", Quantity: 1, Disposition: DispositionQuarantine},
+		{Barcode: "nb", Disposition: DispositionRestock, Serials: []string{"S1"}},
+	}})
+	if err != nil || rma.Status != StatusPartiallyReceived {
+		t.Fatalf("esperado recebimento parcial, veio %v %+v", err, rma)
+	}
+	if it := repo.items[0]; len(it.Lots) != 1 || it.Lots[0].Quantity != 1 || it.Lots[0].ExpiryDate == nil {
+		t.Errorf("lote não convertido: %+v", it)
+	}
+	if it := repo.items[2]; it.Quantity != 1 {
+		t.Errorf("quantidade pelos números de série esperada 1, veio %d", it.Quantity)
+	}
+	if _, err := svc.Cancel(ctx, rma.ID); err != ErrInvalidStatus {
+		t.Errorf("esperado ErrInvalidStatus ao cancelar devolução recebida, veio %v", err)
+	}
+
+	rma, err = svc.Receive(ctx, rma.ID, ReceiveRequest{Lines: []ReceiveLine{
+		{Barcode: "111", Quantity: 1, Disposition: DispositionScrap},
+		{Barcode: "nb", Quantity: 1, Disposition: DispositionScrap},
+	}})
+	if err != nil || rma.Status != StatusReceived {
+		t.Fatalf("esperado devolução recebida, veio %v %+v", err, rma)
+	}
+	if l := rma.Lines[0]; l.Restocked != 1 || l.Quarantined != 1 || l.Scrapped != 1 {
+		t.Errorf("destinações incorretas: %+v", l)
+	}
+	if _, err := svc.Receive(ctx, rma.ID, ReceiveRequest{Lines: []ReceiveLine{{Barcode: "111", Quantity: 1, Disposition: DispositionScrap}}}); err != ErrInvalidStatus {
+		t.Errorf("esperado ErrInvalidStatus, veio %v", err)
+	}
+
+	if _, err := svc.Release(ctx, rma.ID, ReceiveRequest{Lines: []ReceiveLine{{Barcode: "111", Quantity: 1, Disposition: DispositionQuarantine}}}); err != ErrRelease {
+		t.Errorf("esperado ErrRelease, veio %v", err)
+	}
+	if _, err := svc.Release(ctx, rma.ID, ReceiveRequest{Lines: []ReceiveLine{{Barcode: "111", Quantity: 2, Disposition: DispositionRestock}}}); err != ErrOverRelease {
+		t.Errorf("esperado ErrOverRelease, veio %v", err)
+	}
+	rma, err = svc.Release(ctx, rma.ID, ReceiveRequest{Lines: []ReceiveLine{{Barcode: "111", Quantity: 1, Disposition: DispositionRestock}}})
+	if err != nil || rma.Status != StatusReceived {
+		t.Fatalf("erro ao liberar quarentena: %v %+v", err, rma)
+	}
+	if l := rma.Lines[0]; l.Restocked != 2 || l.Quarantined != 0 {
+		t.Errorf("liberação incorreta: %+v", l)
+	}
+}
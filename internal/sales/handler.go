@@ -0,0 +1,281 @@
+package sales
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"inventory-system/internal"
+	"inventory-system/internal/products"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var validate = validator.New()
+
+func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(payload)
+}
+
+func respondError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, map[string]string{"error": message})
+}
+
+func RegisterRoutes(r chi.Router, db *pgxpool.Pool) {
+	service := NewService(NewRepository(db))
+
+	r.Route("/sales-orders", func(r chi.Router) {
+		r.Use(internal.AuthMiddleware)
+		r.Post("/", createOrderHandler(service))
+		r.Get("/", getOrdersHandler(service))
+		r.Get("/{id}", getOrderHandler(service))
+		r.Post("/{id}/allocate", transitionHandler(service.Allocate))
+		r.Get("/{id}/pick-list", getPickListHandler(service))
+		r.Post("/{id}/pack", packHandler(service))
+		r.Post("/{id}/ship", shipHandler(service))
+		r.Post("/{id}/cancel", transitionHandler(service.Cancel))
+	})
+}
+
+func respondOrderError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		respondError(w, http.StatusNotFound, "Sales order not found")
+	case errors.Is(err, products.ErrProductNotFound):
+		respondError(w, http.StatusNotFound, "Product not found")
+	case errors.Is(err, ErrDuplicateLine), errors.Is(err, ErrNotOnOrder), errors.Is(err, ErrOverPack):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrInvalidStatus), errors.Is(err, ErrNothingToPack), errors.Is(err, ErrNothingToShip),
+		errors.Is(err, products.ErrInsufficientStock):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Create a sales order
+// @Description The order starts as a draft with nothing allocated. Lines without unit_price use the product's price.
+// @Tags sales-orders
+// @Accept json
+// @Produce json
+// @Param order body OrderRequest true "Order data" example({"customer":"Maria Silva","reference":"WEB-1042","lines":[{"barcode":"7891234567895","quantity":3},{"barcode":"7891234567901","quantity":1,"unit_price":9.9}]})
+// @Success 201 {object} SalesOrder "Created sales order"
+// @Failure 400 {object} map[string]string "Invalid data or duplicate product"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Router /sales-orders [post]
+func createOrderHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req OrderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		so, err := s.CreateOrder(r.Context(), req)
+		if err != nil {
+			respondOrderError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusCreated, so)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary List sales orders
+// @Tags sales-orders
+// @Produce json
+// @Param status query string false "Filter by status (draft, allocated, packed, partially_shipped, shipped, cancelled)"
+// @Param customer query string false "Filter by customer (partial match)"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 20, max: 100)"
+// @Success 200 {array} SalesOrder "List of sales orders, newest first, without lines"
+// @Header 200 {int} X-Total-Count "Total number of sales orders"
+// @Router /sales-orders [get]
+func getOrdersHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 1 {
+			page = 1
+		}
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit < 1 || limit > 100 {
+			limit = 20
+		}
+		q := OrdersQuery{Status: r.URL.Query().Get("status"), Customer: r.URL.Query().Get("customer"), Page: page, Limit: limit}
+		orders, total, err := s.GetOrders(r.Context(), q)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		respondJSON(w, http.StatusOK, orders)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Get a sales order
+// @Tags sales-orders
+// @Produce json
+// @Param id path int true "Sales order ID"
+// @Success 200 {object} SalesOrder "Sales order with lines and shipments"
+// @Failure 404 {object} map[string]string "Sales order not found"
+// @Router /sales-orders/{id} [get]
+func getOrderHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		so, err := s.GetOrder(r.Context(), id)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if so == nil {
+			respondError(w, http.StatusNotFound, "Sales order not found")
+			return
+		}
+		respondJSON(w, http.StatusOK, so)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Allocate or cancel a sales order
+// @Description allocate holds the available stock (quantity minus reservations and other allocations) for every line
+// @Description not yet allocated, by location, and can be called again for what is still missing;
+// @Description cancel releases the allocations of anything not shipped yet.
+// @Tags sales-orders
+// @Produce json
+// @Param id path int true "Sales order ID"
+// @Success 200 {object} SalesOrder "Updated sales order"
+// @Failure 404 {object} map[string]string "Sales order not found"
+// @Failure 409 {object} map[string]string "Sales order already shipped or cancelled"
+// @Router /sales-orders/{id}/allocate [post]
+// @Router /sales-orders/{id}/cancel [post]
+func transitionHandler(action func(ctx context.Context, id int) (*SalesOrder, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		so, err := action(r.Context(), id)
+		if err != nil {
+			respondOrderError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, so)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Pick list of a sales order
+// @Description Allocated quantities not shipped yet, grouped by location.
+// @Tags sales-orders
+// @Produce json
+// @Param id path int true "Sales order ID"
+// @Success 200 {object} PickList "Items to pick per location"
+// @Failure 404 {object} map[string]string "Sales order not found"
+// @Router /sales-orders/{id}/pick-list [get]
+func getPickListHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		pl, err := s.GetPickList(r.Context(), id)
+		if err != nil {
+			respondOrderError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, pl)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Pack a sales order
+// @Description Marks allocated quantities as packed; without lines everything allocated is packed.
+// @Tags sales-orders
+// @Accept json
+// @Produce json
+// @Param id path int true "Sales order ID"
+// @Param body body PackRequest false "Lines to pack" example({"lines":[{"barcode":"7891234567895","quantity":2}]})
+// @Success 200 {object} SalesOrder "Updated sales order"
+// @Failure 400 {object} map[string]string "Invalid data, product not on the order or quantity above the allocated"
+// @Failure 404 {object} map[string]string "Sales order not found"
+// @Failure 409 {object} map[string]string "Nothing to pack or sales order closed"
+// @Router /sales-orders/{id}/pack [post]
+func packHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		var req PackRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				respondError(w, http.StatusBadRequest, "Invalid data")
+				return
+			}
+		}
+		if err := validate.Struct(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		so, err := s.Pack(r.Context(), id, req)
+		if err != nil {
+			respondOrderError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, so)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Ship a sales order
+// @Description Ships everything packed in one transaction: each allocation leaves its location as a stock exit
+// @Description (reason sale) and a shipment is recorded. Unpacked lines stay allocated for a later shipment.
+// @Tags sales-orders
+// @Accept json
+// @Produce json
+// @Param id path int true "Sales order ID"
+// @Param body body ShipRequest false "Shipment data" example({"tracking":"BR123456789"})
+// @Success 200 {object} SalesOrder "Updated sales order with its shipments"
+// @Failure 404 {object} map[string]string "Sales order not found"
+// @Failure 409 {object} map[string]string "Nothing packed, sales order closed or insufficient stock"
+// @Router /sales-orders/{id}/ship [post]
+func shipHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		var req ShipRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				respondError(w, http.StatusBadRequest, "Invalid data")
+				return
+			}
+		}
+		so, err := s.Ship(r.Context(), id, req)
+		if err != nil {
+			respondOrderError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, so)
+	}
+}
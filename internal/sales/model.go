@@ -0,0 +1,114 @@
+package sales
+
+import "time"
+
+// Estados de um pedido de venda, calculados pelas quantidades das linhas (ver orderStatus)
+const (
+	StatusDraft            = "draft"
+	StatusAllocated        = "allocated"
+	StatusPacked           = "packed"
+	StatusPartiallyShipped = "partially_shipped"
+	StatusShipped          = "shipped"
+	StatusCancelled        = "cancelled"
+)
+
+type SalesOrder struct {
+	ID        int        `json:"id"`
+	Customer  string     `json:"customer"`
+	Reference string     `json:"reference"`
+	Notes     string     `json:"notes"`
+	Status    string     `json:"status"`
+	Total     float64    `json:"total"`
+	CreatedBy *int       `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	ClosedAt  *time.Time `json:"closed_at"`
+	Lines     []Line     `json:"lines,omitempty"`
+	Shipments []Shipment `json:"shipments,omitempty"`
+}
+
+// Line é um item do pedido. Allocated é o que está separado no estoque e ainda não saiu; Packed é a
+// parte de Allocated já embalada, que sai na próxima expedição; Shipped é o que já foi expedido.
+type Line struct {
+	ID        int     `json:"id"`
+	ProductID int     `json:"product_id"`
+	Barcode   string  `json:"barcode"`
+	Name      string  `json:"name"`
+	Quantity  int     `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+	Allocated int     `json:"allocated"`
+	Packed    int     `json:"packed"`
+	Shipped   int     `json:"shipped"`
+
+	// codes são todos os identificadores do produto, para achar a linha por qualquer um deles
+	codes []string
+}
+
+type Shipment struct {
+	ID        int            `json:"id"`
+	Tracking  string         `json:"tracking"`
+	CreatedBy *int           `json:"created_by"`
+	CreatedAt time.Time      `json:"created_at"`
+	Lines     []ShipmentLine `json:"lines"`
+}
+
+type ShipmentLine struct {
+	LineID    int    `json:"line_id"`
+	ProductID int    `json:"product_id"`
+	Barcode   string `json:"barcode"`
+	Quantity  int    `json:"quantity"`
+}
+
+type OrderRequest struct {
+	Customer  string        `json:"customer" validate:"required"`
+	Reference string        `json:"reference"`
+	Notes     string        `json:"notes"`
+	Lines     []LineRequest `json:"lines" validate:"required,min=1,dive"`
+}
+
+type LineRequest struct {
+	Barcode  string `json:"barcode" validate:"required"`
+	Quantity int    `json:"quantity" validate:"required,gt=0"`
+	// Sem preço, vale o preço de venda do produto
+	UnitPrice *float64 `json:"unit_price" validate:"omitempty,gte=0"`
+}
+
+type PackRequest struct {
+	// Linhas a embalar; vazio embala tudo o que está alocado
+	Lines []PackLine `json:"lines" validate:"omitempty,dive"`
+}
+
+type PackLine struct {
+	Barcode  string `json:"barcode" validate:"required"`
+	Quantity int    `json:"quantity" validate:"required,gt=0"`
+}
+
+type ShipRequest struct {
+	Tracking string `json:"tracking"`
+}
+
+// PickList é a lista de separação do pedido agrupada por local, na ordem de percurso dos locais.
+type PickList struct {
+	OrderID   int            `json:"order_id"`
+	Locations []PickLocation `json:"locations"`
+}
+
+type PickLocation struct {
+	LocationID   int        `json:"location_id"`
+	LocationCode string     `json:"location_code"`
+	LocationName string     `json:"location_name"`
+	Items        []PickItem `json:"items"`
+}
+
+// PickItem é a quantidade alocada de um produto em um local, ainda não expedida.
+type PickItem struct {
+	Barcode  string `json:"barcode"`
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+}
+
+type OrdersQuery struct {
+	Status   string
+	Customer string
+	Page     int
+	Limit    int
+}
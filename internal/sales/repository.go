@@ -0,0 +1,543 @@
+package sales
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+
+	"inventory-system/internal/products"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrNotFound      = errors.New("sales order not found")
+	ErrInvalidStatus = errors.New("sales order cannot change in its current status")
+	ErrDuplicateLine = errors.New("product appears more than once in the sales order")
+	ErrNotOnOrder    = errors.New("product is not on the sales order")
+	ErrOverPack      = errors.New("packed quantity exceeds the allocated quantity")
+	ErrNothingToPack = errors.New("sales order has nothing allocated to pack")
+	ErrNothingToShip = errors.New("sales order has nothing packed to ship")
+)
+
+type Repository struct {
+	DB *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{DB: db}
+}
+
+const orderColumns = `o.id, o.customer, o.reference, o.notes, o.status,
+	(SELECT COALESCE(SUM(l.quantity * l.unit_price), 0) FROM sales_order_lines l WHERE l.sales_order_id = o.id),
+	o.created_by, o.created_at, o.closed_at`
+
+func scanOrder(row pgx.Row, so *SalesOrder) error {
+	return row.Scan(&so.ID, &so.Customer, &so.Reference, &so.Notes, &so.Status, &so.Total, &so.CreatedBy, &so.CreatedAt, &so.ClosedAt)
+}
+
+// querier é atendido tanto pelo pool quanto por uma transação.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
+func queryLines(ctx context.Context, q querier, orderID int) ([]Line, error) {
+	query := `SELECT l.id, l.product_id, p.barcode, ` + products.Identifiers("p.id") + `, p.name, l.quantity, l.unit_price, l.allocated, l.packed, l.shipped
+		FROM sales_order_lines l JOIN products p ON p.id = l.product_id
+		WHERE l.sales_order_id = $1 ORDER BY l.id`
+	rows, err := q.Query(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	lines := []Line{}
+	for rows.Next() {
+		var l Line
+		if err := rows.Scan(&l.ID, &l.ProductID, &l.Barcode, &l.codes, &l.Name, &l.Quantity, &l.UnitPrice, &l.Allocated, &l.Packed, &l.Shipped); err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
+
+func queryShipments(ctx context.Context, q querier, orderID int) ([]Shipment, error) {
+	query := `SELECT s.id, s.tracking, s.created_by, s.created_at, sl.line_id, l.product_id, p.barcode, sl.quantity
+		FROM sales_shipments s
+		JOIN sales_shipment_lines sl ON sl.shipment_id = s.id
+		JOIN sales_order_lines l ON l.id = sl.line_id
+		JOIN products p ON p.id = l.product_id
+		WHERE s.sales_order_id = $1 ORDER BY s.id, sl.line_id`
+	rows, err := q.Query(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	shipments := []Shipment{}
+	for rows.Next() {
+		var s Shipment
+		var sl ShipmentLine
+		if err := rows.Scan(&s.ID, &s.Tracking, &s.CreatedBy, &s.CreatedAt, &sl.LineID, &sl.ProductID, &sl.Barcode, &sl.Quantity); err != nil {
+			return nil, err
+		}
+		if n := len(shipments); n == 0 || shipments[n-1].ID != s.ID {
+			shipments = append(shipments, s)
+		}
+		last := &shipments[len(shipments)-1]
+		last.Lines = append(last.Lines, sl)
+	}
+	return shipments, rows.Err()
+}
+
+// CreateOrder grava o pedido em rascunho; nenhuma quantidade fica alocada até Allocate.
+func (r *Repository) CreateOrder(ctx context.Context, so *SalesOrder, lines []LineRequest) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	query := `INSERT INTO sales_orders (customer, reference, notes, created_by) VALUES ($1, $2, $3, $4)
+		RETURNING id, status, created_at`
+	if err := tx.QueryRow(ctx, query, so.Customer, so.Reference, so.Notes, so.CreatedBy).Scan(&so.ID, &so.Status, &so.CreatedAt); err != nil {
+		return err
+	}
+	query = `INSERT INTO sales_order_lines (sales_order_id, product_id, quantity, unit_price)
+		SELECT $1, id, $3, COALESCE($4::numeric, price) FROM products WHERE ` + products.ByCode("id", "$2") + ` RETURNING id`
+	for _, l := range lines {
+		var id int
+		if err := tx.QueryRow(ctx, query, so.ID, l.Barcode, l.Quantity, l.UnitPrice).Scan(&id); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return products.ErrProductNotFound
+			}
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return ErrDuplicateLine
+			}
+			return err
+		}
+	}
+	if so.Lines, err = queryLines(ctx, tx, so.ID); err != nil {
+		return err
+	}
+	so.Total = orderTotal(so.Lines)
+	return tx.Commit(ctx)
+}
+
+func (r *Repository) GetOrders(ctx context.Context, q OrdersQuery) ([]SalesOrder, int, error) {
+	args := []interface{}{}
+	where := ""
+	idx := 1
+	if q.Status != "" {
+		where += " AND o.status = $" + strconv.Itoa(idx)
+		args = append(args, q.Status)
+		idx++
+	}
+	if q.Customer != "" {
+		where += " AND o.customer ILIKE $" + strconv.Itoa(idx)
+		args = append(args, "%"+q.Customer+"%")
+		idx++
+	}
+	limit := q.Limit
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	page := q.Page
+	if page < 1 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+	query := "SELECT " + orderColumns + " FROM sales_orders o WHERE 1=1" + where +
+		" ORDER BY o.id DESC LIMIT $" + strconv.Itoa(idx) + " OFFSET $" + strconv.Itoa(idx+1)
+	rows, err := r.DB.Query(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	orders := []SalesOrder{}
+	for rows.Next() {
+		var so SalesOrder
+		if err := scanOrder(rows, &so); err != nil {
+			return nil, 0, err
+		}
+		orders = append(orders, so)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	total := 0
+	if err := r.DB.QueryRow(ctx, "SELECT COUNT(*) FROM sales_orders o WHERE 1=1"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	return orders, total, nil
+}
+
+func (r *Repository) GetOrder(ctx context.Context, id int) (*SalesOrder, error) {
+	var so SalesOrder
+	if err := scanOrder(r.DB.QueryRow(ctx, "SELECT "+orderColumns+" FROM sales_orders o WHERE o.id = $1", id), &so); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err := loadDetails(ctx, r.DB, &so); err != nil {
+		return nil, err
+	}
+	return &so, nil
+}
+
+func loadDetails(ctx context.Context, q querier, so *SalesOrder) error {
+	var err error
+	if so.Lines, err = queryLines(ctx, q, so.ID); err != nil {
+		return err
+	}
+	so.Shipments, err = queryShipments(ctx, q, so.ID)
+	return err
+}
+
+// lockOrder lê o pedido bloqueando-o até o fim de tx.
+func lockOrder(ctx context.Context, tx pgx.Tx, id int) (*SalesOrder, error) {
+	var so SalesOrder
+	if err := scanOrder(tx.QueryRow(ctx, "SELECT "+orderColumns+" FROM sales_orders o WHERE o.id = $1 FOR UPDATE", id), &so); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &so, nil
+}
+
+// closed informa se o pedido não aceita mais alterações.
+func closed(status string) bool {
+	return status == StatusShipped || status == StatusCancelled
+}
+
+// byProduct ordena as linhas pelo produto, para que transações concorrentes bloqueiem os produtos
+// sempre na mesma ordem.
+func byProduct(lines []Line) []*Line {
+	sorted := make([]*Line, len(lines))
+	for i := range lines {
+		sorted[i] = &lines[i]
+	}
+	slices.SortFunc(sorted, func(a, b *Line) int { return cmp.Compare(a.ProductID, b.ProductID) })
+	return sorted
+}
+
+// finish recalcula o estado do pedido pelas linhas, grava e confirma tx.
+func finish(ctx context.Context, tx pgx.Tx, so *SalesOrder, lines []Line) error {
+	so.Status = orderStatus(lines)
+	query := `UPDATE sales_orders SET status = $1, closed_at = CASE WHEN $1 = 'shipped' THEN NOW() END
+		WHERE id = $2 RETURNING closed_at`
+	if err := tx.QueryRow(ctx, query, so.Status, so.ID).Scan(&so.ClosedAt); err != nil {
+		return err
+	}
+	if err := loadDetails(ctx, tx, so); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Allocate separa o disponível de cada linha ainda não alocada, sem passar do disponível do produto
+// (saldo menos reservas e outras alocações). O que falta fica pendente e pode ser alocado depois.
+// Cada produto é alocado primeiro no local padrão e depois nos locais com mais saldo livre.
+func (r *Repository) Allocate(ctx context.Context, id int) (*SalesOrder, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	so, err := lockOrder(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if closed(so.Status) {
+		return nil, ErrInvalidStatus
+	}
+	lines, err := queryLines(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range byProduct(lines) {
+		needed := l.Quantity - l.Shipped - l.Allocated
+		if needed <= 0 {
+			continue
+		}
+		available, err := products.Available(ctx, tx, l.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		if available <= 0 {
+			continue
+		}
+		allocated, err := allocateLine(ctx, tx, l, min(needed, available))
+		if err != nil {
+			return nil, err
+		}
+		l.Allocated += allocated
+	}
+	if err := finish(ctx, tx, so, lines); err != nil {
+		return nil, err
+	}
+	return so, nil
+}
+
+// allocateLine distribui quantity da linha entre os locais de armazenagem com saldo livre e devolve
+// quanto conseguiu alocar.
+func allocateLine(ctx context.Context, tx pgx.Tx, l *Line, quantity int) (int, error) {
+	query := `SELECT s.location_id, s.quantity - COALESCE((SELECT SUM(a.quantity) FROM sales_allocations a
+			WHERE a.product_id = s.product_id AND a.location_id = s.location_id), 0) AS free
+		FROM stock_levels s JOIN locations loc ON loc.id = s.location_id
+		WHERE s.product_id = $1 AND loc.kind = 'storage'
+		ORDER BY loc.is_default DESC, free DESC, loc.id`
+	rows, err := tx.Query(ctx, query, l.ProductID)
+	if err != nil {
+		return 0, err
+	}
+	type freeStock struct{ locationID, quantity int }
+	var free []freeStock
+	for rows.Next() {
+		var f freeStock
+		if err := rows.Scan(&f.locationID, &f.quantity); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		free = append(free, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	allocated := 0
+	for _, f := range free {
+		take := min(quantity-allocated, f.quantity)
+		if take <= 0 {
+			continue
+		}
+		_, err := tx.Exec(ctx, `INSERT INTO sales_allocations (line_id, product_id, location_id, quantity) VALUES ($1, $2, $3, $4)`,
+			l.ID, l.ProductID, f.locationID, take)
+		if err != nil {
+			return 0, err
+		}
+		allocated += take
+	}
+	if allocated > 0 {
+		if _, err := tx.Exec(ctx, `UPDATE sales_order_lines SET allocated = allocated + $1 WHERE id = $2`, allocated, l.ID); err != nil {
+			return 0, err
+		}
+	}
+	return allocated, nil
+}
+
+// GetPickList lista as alocações do pedido agrupadas por local; nil quando o pedido não existe.
+func (r *Repository) GetPickList(ctx context.Context, id int) (*PickList, error) {
+	var exists bool
+	if err := r.DB.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM sales_orders WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, nil
+	}
+	query := `SELECT loc.id, loc.code, loc.name, p.barcode, p.name, SUM(a.quantity)
+		FROM sales_allocations a
+		JOIN sales_order_lines l ON l.id = a.line_id
+		JOIN products p ON p.id = a.product_id
+		JOIN locations loc ON loc.id = a.location_id
+		WHERE l.sales_order_id = $1
+		GROUP BY loc.id, p.id
+		ORDER BY loc.code, p.name, p.barcode`
+	rows, err := r.DB.Query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	pl := &PickList{OrderID: id, Locations: []PickLocation{}}
+	for rows.Next() {
+		var loc PickLocation
+		var item PickItem
+		if err := rows.Scan(&loc.LocationID, &loc.LocationCode, &loc.LocationName, &item.Barcode, &item.Name, &item.Quantity); err != nil {
+			return nil, err
+		}
+		if n := len(pl.Locations); n == 0 || pl.Locations[n-1].LocationID != loc.LocationID {
+			pl.Locations = append(pl.Locations, loc)
+		}
+		last := &pl.Locations[len(pl.Locations)-1]
+		last.Items = append(last.Items, item)
+	}
+	return pl, rows.Err()
+}
+
+// Pack marca como embaladas as quantidades informadas, ou tudo o que está alocado quando pack é vazio.
+func (r *Repository) Pack(ctx context.Context, id int, pack []PackLine) (*SalesOrder, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	so, err := lockOrder(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if closed(so.Status) {
+		return nil, ErrInvalidStatus
+	}
+	lines, err := queryLines(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyPack(lines, pack); err != nil {
+		return nil, err
+	}
+	for _, l := range lines {
+		if _, err := tx.Exec(ctx, `UPDATE sales_order_lines SET packed = $1 WHERE id = $2`, l.Packed, l.ID); err != nil {
+			return nil, err
+		}
+	}
+	if err := finish(ctx, tx, so, lines); err != nil {
+		return nil, err
+	}
+	return so, nil
+}
+
+// Ship expede tudo o que está embalado: cada alocação embalada sai do seu local pelo mesmo caminho
+// das saídas de estoque (products.ApplyMovement, motivo sale), e a remessa é registrada. Tudo ocorre
+// em uma transação: se uma saída falhar, nada é expedido.
+func (r *Repository) Ship(ctx context.Context, id int, tracking string, createdBy *int) (*SalesOrder, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	so, err := lockOrder(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if closed(so.Status) {
+		return nil, ErrInvalidStatus
+	}
+	lines, err := queryLines(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(lines, func(l Line) bool { return l.Packed > 0 }) {
+		return nil, ErrNothingToShip
+	}
+	var shipmentID int
+	query := `INSERT INTO sales_shipments (sales_order_id, tracking, created_by) VALUES ($1, $2, $3) RETURNING id`
+	if err := tx.QueryRow(ctx, query, id, tracking, createdBy).Scan(&shipmentID); err != nil {
+		return nil, err
+	}
+	reference := "sales order #" + strconv.Itoa(id)
+	for _, l := range byProduct(lines) {
+		if l.Packed == 0 {
+			continue
+		}
+		if err := shipLine(ctx, tx, l, reference); err != nil {
+			return nil, err
+		}
+		_, err := tx.Exec(ctx, `INSERT INTO sales_shipment_lines (shipment_id, line_id, quantity) VALUES ($1, $2, $3)`, shipmentID, l.ID, l.Packed)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(ctx, `UPDATE sales_order_lines SET shipped = shipped + packed, allocated = allocated - packed, packed = 0 WHERE id = $1`, l.ID)
+		if err != nil {
+			return nil, err
+		}
+		l.Shipped, l.Allocated, l.Packed = l.Shipped+l.Packed, l.Allocated-l.Packed, 0
+	}
+	if err := finish(ctx, tx, so, lines); err != nil {
+		return nil, err
+	}
+	return so, nil
+}
+
+// shipLine dá saída da quantidade embalada da linha consumindo suas alocações da mais antiga para a
+// mais nova, uma movimentação por local.
+func shipLine(ctx context.Context, tx pgx.Tx, l *Line, reference string) error {
+	rows, err := tx.Query(ctx, `SELECT id, location_id, quantity FROM sales_allocations WHERE line_id = $1 ORDER BY id FOR UPDATE`, l.ID)
+	if err != nil {
+		return err
+	}
+	type allocation struct{ id, locationID, quantity int }
+	var allocations []allocation
+	for rows.Next() {
+		var a allocation
+		if err := rows.Scan(&a.id, &a.locationID, &a.quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		allocations = append(allocations, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	remaining := l.Packed
+	for _, a := range allocations {
+		if remaining == 0 {
+			break
+		}
+		take := min(remaining, a.quantity)
+		m := &products.StockMovement{ProductID: l.ProductID, LocationID: a.locationID, Delta: -take, Reason: products.ReasonSale, Reference: reference}
+		if err := products.ApplyMovement(ctx, tx, m); err != nil {
+			return err
+		}
+		if take == a.quantity {
+			_, err = tx.Exec(ctx, `DELETE FROM sales_allocations WHERE id = $1`, a.id)
+		} else {
+			_, err = tx.Exec(ctx, `UPDATE sales_allocations SET quantity = quantity - $1 WHERE id = $2`, take, a.id)
+		}
+		if err != nil {
+			return err
+		}
+		remaining -= take
+	}
+	return nil
+}
+
+// Cancel cancela o que ainda não foi expedido e libera as alocações.
+func (r *Repository) Cancel(ctx context.Context, id int) (*SalesOrder, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	so, err := lockOrder(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if closed(so.Status) {
+		return nil, ErrInvalidStatus
+	}
+	query := `DELETE FROM sales_allocations a USING sales_order_lines l WHERE a.line_id = l.id AND l.sales_order_id = $1`
+	if _, err := tx.Exec(ctx, query, id); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE sales_order_lines SET allocated = 0, packed = 0 WHERE sales_order_id = $1`, id); err != nil {
+		return nil, err
+	}
+	query = `UPDATE sales_orders SET status = $1, closed_at = NOW() WHERE id = $2 RETURNING status, closed_at`
+	if err := tx.QueryRow(ctx, query, StatusCancelled, id).Scan(&so.Status, &so.ClosedAt); err != nil {
+		return nil, err
+	}
+	if err := loadDetails(ctx, tx, so); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return so, nil
+}
+
+type RepositoryInterface interface {
+	CreateOrder(ctx context.Context, so *SalesOrder, lines []LineRequest) error
+	GetOrders(ctx context.Context, q OrdersQuery) ([]SalesOrder, int, error)
+	GetOrder(ctx context.Context, id int) (*SalesOrder, error)
+	Allocate(ctx context.Context, id int) (*SalesOrder, error)
+	GetPickList(ctx context.Context, id int) (*PickList, error)
+	Pack(ctx context.Context, id int, pack []PackLine) (*SalesOrder, error)
+	Ship(ctx context.Context, id int, tracking string, createdBy *int) (*SalesOrder, error)
+	Cancel(ctx context.Context, id int) (*SalesOrder, error)
+}
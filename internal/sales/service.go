@@ -0,0 +1,138 @@
+package sales
+
+import (
+	"context"
+	"slices"
+
+	"inventory-system/internal"
+)
+
+type Service struct {
+	Repo RepositoryInterface
+}
+
+func NewService(repo RepositoryInterface) *Service {
+	return &Service{Repo: repo}
+}
+
+func (s *Service) CreateOrder(ctx context.Context, req OrderRequest) (*SalesOrder, error) {
+	so := &SalesOrder{Customer: req.Customer, Reference: req.Reference, Notes: req.Notes, CreatedBy: currentUser(ctx)}
+	if err := s.Repo.CreateOrder(ctx, so, req.Lines); err != nil {
+		return nil, err
+	}
+	return so, nil
+}
+
+func (s *Service) GetOrders(ctx context.Context, q OrdersQuery) ([]SalesOrder, int, error) {
+	return s.Repo.GetOrders(ctx, q)
+}
+
+func (s *Service) GetOrder(ctx context.Context, id int) (*SalesOrder, error) {
+	return s.Repo.GetOrder(ctx, id)
+}
+
+func (s *Service) Allocate(ctx context.Context, id int) (*SalesOrder, error) {
+	return s.Repo.Allocate(ctx, id)
+}
+
+func (s *Service) GetPickList(ctx context.Context, id int) (*PickList, error) {
+	pl, err := s.Repo.GetPickList(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if pl == nil {
+		return nil, ErrNotFound
+	}
+	return pl, nil
+}
+
+func (s *Service) Pack(ctx context.Context, id int, req PackRequest) (*SalesOrder, error) {
+	return s.Repo.Pack(ctx, id, req.Lines)
+}
+
+func (s *Service) Ship(ctx context.Context, id int, req ShipRequest) (*SalesOrder, error) {
+	return s.Repo.Ship(ctx, id, req.Tracking, currentUser(ctx))
+}
+
+func (s *Service) Cancel(ctx context.Context, id int) (*SalesOrder, error) {
+	return s.Repo.Cancel(ctx, id)
+}
+
+// applyPack soma as quantidades embaladas às linhas; sem linhas informadas, embala todo o alocado.
+func applyPack(lines []Line, pack []PackLine) error {
+	before := 0
+	for _, l := range lines {
+		before += l.Packed
+	}
+	if len(pack) == 0 {
+		for i := range lines {
+			lines[i].Packed = lines[i].Allocated
+		}
+	}
+	for _, p := range pack {
+		l := findLine(lines, p.Barcode)
+		if l == nil {
+			return ErrNotOnOrder
+		}
+		if l.Packed+p.Quantity > l.Allocated {
+			return ErrOverPack
+		}
+		l.Packed += p.Quantity
+	}
+	after := 0
+	for _, l := range lines {
+		after += l.Packed
+	}
+	if after == before {
+		return ErrNothingToPack
+	}
+	return nil
+}
+
+// orderStatus deriva o estado do pedido das quantidades das linhas: expedido quando tudo saiu;
+// senão embalado, alocado ou parcialmente expedido, nessa ordem de prioridade.
+func orderStatus(lines []Line) string {
+	complete, packed, allocated, shipped := true, false, false, false
+	for _, l := range lines {
+		complete = complete && l.Shipped >= l.Quantity
+		packed = packed || l.Packed > 0
+		allocated = allocated || l.Allocated > 0
+		shipped = shipped || l.Shipped > 0
+	}
+	switch {
+	case complete:
+		return StatusShipped
+	case packed:
+		return StatusPacked
+	case allocated:
+		return StatusAllocated
+	case shipped:
+		return StatusPartiallyShipped
+	default:
+		return StatusDraft
+	}
+}
+
+func orderTotal(lines []Line) float64 {
+	total := 0.0
+	for _, l := range lines {
+		total += float64(l.Quantity) * l.UnitPrice
+	}
+	return total
+}
+
+func findLine(lines []Line, barcode string) *Line {
+	for i := range lines {
+		if lines[i].Barcode == barcode || slices.Contains(lines[i].codes, barcode) {
+			return &lines[i]
+		}
+	}
+	return nil
+}
+
+func currentUser(ctx context.Context) *int {
+	if userID, ok := internal.UserIDFromContext(ctx); ok {
+		return &userID
+	}
+	return nil
+}
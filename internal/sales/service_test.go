@@ -0,0 +1,151 @@
+package sales
+
+import (
+	"context"
+	"testing"
+)
+
+type mockOrderRepo struct {
+	orders   []SalesOrder
+	tracking string
+}
+
+func (m *mockOrderRepo) CreateOrder(ctx context.Context, so *SalesOrder, lines []LineRequest) error {
+	so.ID = len(m.orders) + 1
+	so.Status = StatusDraft
+	for i, l := range lines {
+		line := Line{ID: i + 1, Barcode: l.Barcode, Quantity: l.Quantity}
+		if l.UnitPrice != nil {
+			line.UnitPrice = *l.UnitPrice
+		}
+		so.Lines = append(so.Lines, line)
+	}
+	so.Total = orderTotal(so.Lines)
+	m.orders = append(m.orders, *so)
+	return nil
+}
+func (m *mockOrderRepo) GetOrders(ctx context.Context, q OrdersQuery) ([]SalesOrder, int, error) {
+	return m.orders, len(m.orders), nil
+}
+func (m *mockOrderRepo) GetOrder(ctx context.Context, id int) (*SalesOrder, error) {
+	if id < 1 || id > len(m.orders) {
+		return nil, nil
+	}
+	return &m.orders[id-1], nil
+}
+
+// Allocate aloca no máximo 2 unidades por linha, simulando estoque insuficiente
+func (m *mockOrderRepo) Allocate(ctx context.Context, id int) (*SalesOrder, error) {
+	so := &m.orders[id-1]
+	for i := range so.Lines {
+		l := &so.Lines[i]
+		l.Allocated += min(2, l.Quantity-l.Shipped-l.Allocated)
+	}
+	so.Status = orderStatus(so.Lines)
+	return so, nil
+}
+func (m *mockOrderRepo) GetPickList(ctx context.Context, id int) (*PickList, error) {
+	if id < 1 || id > len(m.orders) {
+		return nil, nil
+	}
+	return &PickList{OrderID: id}, nil
+}
+func (m *mockOrderRepo) Pack(ctx context.Context, id int, pack []PackLine) (*SalesOrder, error) {
+	so := &m.orders[id-1]
+	if err := applyPack(so.Lines, pack); err != nil {
+		return nil, err
+	}
+	so.Status = orderStatus(so.Lines)
+	return so, nil
+}
+func (m *mockOrderRepo) Ship(ctx context.Context, id int, tracking string, createdBy *int) (*SalesOrder, error) {
+	so := &m.orders[id-1]
+	m.tracking = tracking
+	for i := range so.Lines {
+		l := &so.Lines[i]
+		l.Shipped, l.Allocated, l.Packed = l.Shipped+l.Packed, l.Allocated-l.Packed, 0
+	}
+	so.Status = orderStatus(so.Lines)
+	return so, nil
+}
+func (m *mockOrderRepo) Cancel(ctx context.Context, id int) (*SalesOrder, error) {
+	so := &m.orders[id-1]
+	so.Status = StatusCancelled
+	return so, nil
+}
+
+func TestOrderStatus(t *testing.T) {
+	cases := []struct {
+		lines []Line
+		want  string
+	}{
+		{[]Line{{Quantity: 2}}, StatusDraft},
+		{[]Line{{Quantity: 2, Allocated: 1}}, StatusAllocated},
+		{[]Line{{Quantity: 2, Allocated: 2, Packed: 1}, {Quantity: 1}}, StatusPacked},
+		{[]Line{{Quantity: 2, Shipped: 1}}, StatusPartiallyShipped},
+		{[]Line{{Quantity: 2, Shipped: 1, Allocated: 1}}, StatusAllocated},
+		{[]Line{{Quantity: 2, Shipped: 2}, {Quantity: 1, Shipped: 1}}, StatusShipped},
+	}
+	for i, c := range cases {
+		if got := orderStatus(c.lines); got != c.want {
+			t.Errorf("caso %d: esperado %s, veio %s", i, c.want, got)
+		}
+	}
+}
+
+func TestApplyPack(t *testing.T) {
+	lines := []Line{{Barcode: "111", Allocated: 3, codes: []string{"111", "SKU-1"}}, {Barcode: "222", Allocated: 1}}
+	if err := applyPack(lines, []PackLine{{Barcode: "SKU-1", Quantity: 2}}); err != nil || lines[0].Packed != 2 || lines[1].Packed != 0 {
+		t.Fatalf("embalagem parcial pelo SKU incorreta: %v %+v", err, lines)
+	}
+	if err := applyPack(lines, []PackLine{{Barcode: "111", Quantity: 2}}); err != ErrOverPack {
+		t.Errorf("esperado ErrOverPack, veio %v", err)
+	}
+	if err := applyPack(lines, []PackLine{{Barcode: "999", Quantity: 1}}); err != ErrNotOnOrder {
+		t.Errorf("esperado ErrNotOnOrder, veio %v", err)
+	}
+	if err := applyPack(lines, nil); err != nil || lines[0].Packed != 3 || lines[1].Packed != 1 {
+		t.Errorf("embalagem completa incorreta: %v %+v", err, lines)
+	}
+	if err := applyPack(lines, nil); err != ErrNothingToPack {
+		t.Errorf("esperado ErrNothingToPack, veio %v", err)
+	}
+}
+
+func TestService_SalesOrder_Mock(t *testing.T) {
+	repo := &mockOrderRepo{}
+	svc := NewService(repo)
+	price := 4.5
+	so, err := svc.CreateOrder(context.Background(), OrderRequest{Customer: "Maria", Lines: []LineRequest{
+		{Barcode: "111", Quantity: 3, UnitPrice: &price},
+		{Barcode: "222", Quantity: 1},
+	}})
+	if err != nil {
+		t.Fatalf("erro ao criar pedido: %v", err)
+	}
+	if so.Status != StatusDraft || so.Total != 13.5 {
+		t.Errorf("pedido criado incorretamente: %+v", so)
+	}
+	if _, err := svc.Pack(context.Background(), so.ID, PackRequest{}); err != ErrNothingToPack {
+		t.Errorf("esperado ErrNothingToPack, veio %v", err)
+	}
+	so, _ = svc.Allocate(context.Background(), so.ID)
+	so, _ = svc.Pack(context.Background(), so.ID, PackRequest{})
+	if so.Status != StatusPacked {
+		t.Errorf("esperado %s, veio %s", StatusPacked, so.Status)
+	}
+	// Expedição parcial: falta uma unidade do primeiro item
+	so, _ = svc.Ship(context.Background(), so.ID, ShipRequest{Tracking: "BR1"})
+	if so.Status != StatusPartiallyShipped || so.Lines[0].Shipped != 2 || repo.tracking != "BR1" {
+		t.Errorf("expedição parcial incorreta: %+v", so)
+	}
+	so, _ = svc.Allocate(context.Background(), so.ID)
+	so, _ = svc.Pack(context.Background(), so.ID, PackRequest{})
+	so, _ = svc.Ship(context.Background(), so.ID, ShipRequest{})
+	if so.Status != StatusShipped {
+		t.Errorf("esperado %s, veio %s", StatusShipped, so.Status)
+	}
+	if _, err := svc.GetPickList(context.Background(), 99); err != ErrNotFound {
+		t.Errorf("esperado ErrNotFound, veio %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+// Package spreadsheet lê planilhas CSV e XLSX como linhas de texto e escreve tabelas em CSV, XLSX ou
+// NDJSON, sem depender de bibliotecas de terceiros: o XLSX é um zip de XMLs, e só a primeira planilha
+// é lida.
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Formatos aceitos
+const (
+	CSV    = "csv"
+	XLSX   = "xlsx"
+	NDJSON = "ndjson"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown format, expected csv or xlsx, or ndjson for exports")
+	ErrInvalidXLSX   = errors.New("invalid XLSX file")
+)
+
+// Read lê todas as linhas da planilha. No CSV, o separador é vírgula ou, quando a primeira linha tem
+// mais ponto e vírgula que vírgulas (como exporta o Excel em português), ponto e vírgula.
+func Read(r io.Reader, format string) ([][]string, error) {
+	switch format {
+	case CSV:
+		return readCSV(r)
+	case XLSX:
+		return readXLSX(r)
+	}
+	return nil, ErrUnknownFormat
+}
+
+func readCSV(r io.Reader) ([][]string, error) {
+	br := bufio.NewReader(r)
+	// Ignora o BOM que o Excel grava no início de CSVs em UTF-8
+	if b, err := br.Peek(3); err == nil && string(b) == "\xef\xbb\xbf" {
+		br.Discard(3)
+	}
+	cr := csv.NewReader(br)
+	if first, err := br.Peek(br.Buffered()); err == nil {
+		line, _, _ := strings.Cut(string(first), "\n")
+		if strings.Count(line, ";") > strings.Count(line, ",") {
+			cr.Comma = ';'
+		}
+	}
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	return cr.ReadAll()
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		ID string `xml:"id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRels struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxSharedStrings struct {
+	Items []struct {
+		Text string `xml:"t"`
+		Runs []struct {
+			Text string `xml:"t"`
+		} `xml:"r"`
+	} `xml:"si"`
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Index int `xml:"r,attr"`
+		Cells []struct {
+			Ref    string `xml:"r,attr"`
+			Type   string `xml:"t,attr"`
+			Value  string `xml:"v"`
+			Inline struct {
+				Text string `xml:"t"`
+			} `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readXLSX(r io.Reader) ([][]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, ErrInvalidXLSX
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	var wb xlsxWorkbook
+	var rels xlsxRels
+	if err := decodeXML(files, "xl/workbook.xml", &wb); err != nil || len(wb.Sheets) == 0 {
+		return nil, ErrInvalidXLSX
+	}
+	if err := decodeXML(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, ErrInvalidXLSX
+	}
+	sheetPath := ""
+	for _, rel := range rels.Relationships {
+		if rel.ID == wb.Sheets[0].ID {
+			sheetPath = rel.Target
+		}
+	}
+	if strings.HasPrefix(sheetPath, "/") {
+		sheetPath = sheetPath[1:]
+	} else {
+		sheetPath = path.Join("xl", sheetPath)
+	}
+	var shared xlsxSharedStrings
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decodeXML(files, "xl/sharedStrings.xml", &shared); err != nil {
+			return nil, ErrInvalidXLSX
+		}
+	}
+	strs := make([]string, len(shared.Items))
+	for i, si := range shared.Items {
+		strs[i] = si.Text
+		for _, run := range si.Runs {
+			strs[i] += run.Text
+		}
+	}
+	var sheet xlsxSheet
+	if err := decodeXML(files, sheetPath, &sheet); err != nil {
+		return nil, ErrInvalidXLSX
+	}
+
+	var rows [][]string
+	for _, row := range sheet.Rows {
+		// Linhas vazias não aparecem no XML; o índice r preserva a numeração
+		for row.Index > len(rows)+1 {
+			rows = append(rows, nil)
+		}
+		var cells []string
+		for _, c := range row.Cells {
+			col := len(cells)
+			if c.Ref != "" {
+				if col, err = column(c.Ref); err != nil {
+					return nil, err
+				}
+			}
+			for len(cells) <= col {
+				cells = append(cells, "")
+			}
+			switch c.Type {
+			case "s":
+				i, err := strconv.Atoi(c.Value)
+				if err != nil || i < 0 || i >= len(strs) {
+					return nil, ErrInvalidXLSX
+				}
+				cells[col] = strs[i]
+			case "inlineStr":
+				cells[col] = c.Inline.Text
+			case "", "n":
+				cells[col] = number(c.Value)
+			default:
+				cells[col] = c.Value
+			}
+		}
+		rows = append(rows, cells)
+	}
+	return rows, nil
+}
+
+func decodeXML(files map[string]*zip.File, name string, v interface{}) error {
+	f, ok := files[name]
+	if !ok {
+		return ErrInvalidXLSX
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(rc).Decode(v)
+}
+
+// Última coluna de uma planilha XLSX (XFD), a partir de zero
+const maxColumn = 16383
+
+// column converte a referência de uma célula (ex.: "AB12") no índice da coluna, a partir de zero.
+// Referências sem letras, com colunas além de XFD ou sem o número da linha são inválidas.
+func column(ref string) (int, error) {
+	col, i := 0, 0
+	for ; i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z'; i++ {
+		col = col*26 + int(ref[i]-'A'+1)
+		if col-1 > maxColumn {
+			return 0, ErrInvalidXLSX
+		}
+	}
+	if i == 0 || i == len(ref) {
+		return 0, ErrInvalidXLSX
+	}
+	if _, err := strconv.ParseUint(ref[i:], 10, 32); err != nil {
+		return 0, ErrInvalidXLSX
+	}
+	return col - 1, nil
+}
+
+// number desfaz a notação científica com que o Excel às vezes grava números longos, como códigos de
+// barras; os demais valores seguem como vieram.
+func number(v string) string {
+	if !strings.ContainsAny(v, "eE") {
+		return v
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return v
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,125 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadCSV(t *testing.T) {
+	rows, err := Read(strings.NewReader("\xef\xbb\xbfbarcode;name;price\n7891234567895;Café, torrado;12,90\n"), CSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"barcode", "name", "price"}, {"7891234567895", "Café, torrado", "12,90"}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("esperado %v, veio %v", want, rows)
+	}
+	if rows, _ := Read(strings.NewReader("a,b\n1,2\n"), CSV); len(rows) != 2 || rows[1][1] != "2" {
+		t.Errorf("CSV com vírgulas incorreto: %v", rows)
+	}
+	if _, err := Read(strings.NewReader(""), "ods"); err != ErrUnknownFormat {
+		t.Errorf("esperado ErrUnknownFormat, veio %v", err)
+	}
+}
+
+func TestReadXLSX(t *testing.T) {
+	files := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+			<sheets><sheet name="Produtos" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/sharedStrings.xml":       `<sst><si><t>barcode</t></si><si><t>name</t></si><si><r><t>Caf</t></r><r><t>é</t></r></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData>
+			<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="D1" t="inlineStr"><is><t>price</t></is></c></row>
+			<row r="3"><c r="A3"><v>7.891234567895E12</v></c><c r="B3" t="s"><v>2</v></c><c r="D3"><v>12.9</v></c></row>
+		</sheetData></worksheet>`,
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+
+	rows, err := Read(&buf, XLSX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"barcode", "name", "", "price"}, nil, {"7891234567895", "Café", "", "12.9"}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("esperado %q, veio %q", want, rows)
+	}
+	if _, err := Read(strings.NewReader("not a zip"), XLSX); err != ErrInvalidXLSX {
+		t.Errorf("esperado ErrInvalidXLSX, veio %v", err)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	columns := []string{"barcode", "name", "price", "cost", "created_at"}
+	created := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	cost := 7.5
+	rows := [][]interface{}{
+		{"7891234567895", "Café <moído> & torrado", 12.9, &cost, created},
+		{"96385074", "Leite", 4, (*float64)(nil), created},
+	}
+	for _, format := range []string{CSV, XLSX} {
+		var buf bytes.Buffer
+		w, _ := NewWriter(&buf, format, columns)
+		for _, r := range rows {
+			if err := w.Write(append([]interface{}{}, r...)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		// O que se escreve se lê de volta
+		got, err := Read(&buf, format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		want := [][]string{columns,
+			{"7891234567895", "Café <moído> & torrado", "12.9", "7.5", "2026-10-16T09:30:00Z"},
+			{"96385074", "Leite", "4", "", "2026-10-16T09:30:00Z"}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: esperado %q, veio %q", format, want, got)
+		}
+	}
+
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, NDJSON, columns)
+	for _, r := range rows {
+		_ = w.Write(append([]interface{}{}, r...))
+	}
+	_ = w.Close()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || lines[1] != `{"barcode":"96385074","name":"Leite","price":4,"cost":null,"created_at":"2026-10-16T09:30:00Z"}` {
+		t.Errorf("NDJSON incorreto: %s", buf.String())
+	}
+
+	// Sem linhas, nada é escrito antes de Close
+	buf.Reset()
+	w, _ = NewWriter(&buf, XLSX, columns)
+	if buf.Len() != 0 {
+		t.Error("XLSX escrito antes da primeira linha")
+	}
+	_ = w.Close()
+	if got, err := Read(&buf, XLSX); err != nil || len(got) != 1 {
+		t.Errorf("XLSX vazio incorreto: %v %q", err, got)
+	}
+	if col, err := column("ZZ1"); columnName(0) != "A" || columnName(25) != "Z" || columnName(26) != "AA" || columnName(701) != "ZZ" || col != 701 || err != nil {
+		t.Error("nomes de coluna incorretos")
+	}
+	if col, err := column("XFD1048576"); col != maxColumn || err != nil {
+		t.Errorf("última coluna incorreta: %d %v", col, err)
+	}
+	for _, ref := range []string{"XFE1", "AAAAAAAAAAAAAA1", "12", "A", "a1", "A1B"} {
+		if _, err := column(ref); err != ErrInvalidXLSX {
+			t.Errorf("%s: esperado ErrInvalidXLSX, veio %v", ref, err)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ContentType devolve o tipo MIME do formato.
+func ContentType(format string) string {
+	switch format {
+	case CSV:
+		return "text/csv"
+	case XLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case NDJSON:
+		return "application/x-ndjson"
+	}
+	return "application/octet-stream"
+}
+
+// Writer escreve uma tabela linha a linha, sem acumulá-la: CSV com cabeçalho, XLSX com uma planilha
+// de strings inline (dispensa a tabela de strings compartilhadas, que exigiria conhecer todas as
+// linhas) ou NDJSON com um objeto por linha. Nada é escrito antes da primeira linha ou de Close, para
+// que um erro anterior ainda possa ser respondido de outra forma.
+type Writer struct {
+	w       io.Writer
+	format  string
+	columns []string
+	started bool
+	row     int
+
+	buf *bufio.Writer
+	csv *csv.Writer
+	zip *zip.Writer
+}
+
+// NewWriter prepara a escrita das colunas no formato pedido.
+func NewWriter(w io.Writer, format string, columns []string) (*Writer, error) {
+	switch format {
+	case CSV, XLSX, NDJSON:
+	default:
+		return nil, ErrUnknownFormat
+	}
+	return &Writer{w: w, format: format, columns: columns}, nil
+}
+
+// Write escreve uma linha com um valor por coluna. Valores aceitos: string, int, float64, bool,
+// time.Time e ponteiros para eles, com nil como célula vazia (null no NDJSON).
+func (w *Writer) Write(values []interface{}) error {
+	if err := w.start(); err != nil {
+		return err
+	}
+	for i, v := range values {
+		values[i] = deref(v)
+	}
+	w.row++
+	switch w.format {
+	case CSV:
+		record := make([]string, len(values))
+		for i, v := range values {
+			record[i] = text(v)
+		}
+		w.csv.Write(record)
+		return w.csv.Error()
+	case NDJSON:
+		w.buf.WriteByte('{')
+		for i, v := range values {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(w.columns[i])
+			value, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			w.buf.Write(key)
+			w.buf.WriteByte(':')
+			w.buf.Write(value)
+		}
+		w.buf.WriteString("}\n")
+		return nil
+	}
+	return w.xlsxRow(values)
+}
+
+// Close termina o arquivo; uma tabela sem linhas sai só com o cabeçalho.
+func (w *Writer) Close() error {
+	if err := w.start(); err != nil {
+		return err
+	}
+	switch w.format {
+	case CSV:
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	case XLSX:
+		w.buf.WriteString(`</sheetData></worksheet>`)
+		if err := w.buf.Flush(); err != nil {
+			return err
+		}
+		return w.zip.Close()
+	}
+	return w.buf.Flush()
+}
+
+func (w *Writer) start() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+	switch w.format {
+	case CSV:
+		w.buf = bufio.NewWriter(w.w)
+		w.csv = csv.NewWriter(w.buf)
+		w.csv.Write(w.columns)
+		return w.csv.Error()
+	case NDJSON:
+		w.buf = bufio.NewWriter(w.w)
+		return nil
+	}
+	w.zip = zip.NewWriter(w.w)
+	for _, part := range xlsxParts {
+		f, err := w.zip.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, xml.Header+part.content); err != nil {
+			return err
+		}
+	}
+	f, err := w.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	w.buf = bufio.NewWriter(f)
+	w.buf.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	header := make([]interface{}, len(w.columns))
+	for i, c := range w.columns {
+		header[i] = c
+	}
+	return w.xlsxRow(header)
+}
+
+// Partes fixas do XLSX, antes da planilha
+var xlsxParts = []struct{ name, content string }{
+	{"[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+// xlsxRow grava números como números e o resto como strings inline; datas vão em RFC 3339.
+func (w *Writer) xlsxRow(values []interface{}) error {
+	row := strconv.Itoa(w.row + 1)
+	w.buf.WriteString(`<row r="` + row + `">`)
+	for i, v := range values {
+		ref := columnName(i) + row
+		switch v.(type) {
+		case nil:
+		case int, float64:
+			w.buf.WriteString(`<c r="` + ref + `"><v>` + text(v) + `</v></c>`)
+		default:
+			w.buf.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">`)
+			if err := xml.EscapeText(w.buf, []byte(text(v))); err != nil {
+				return err
+			}
+			w.buf.WriteString(`</t></is></c>`)
+		}
+	}
+	w.buf.WriteString(`</row>`)
+	return nil
+}
+
+// columnName converte o índice da coluna, a partir de zero, nas letras da referência (A, B, ..., AA).
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+func deref(v interface{}) interface{} {
+	switch p := v.(type) {
+	case *string:
+		if p != nil {
+			return *p
+		}
+	case *int:
+		if p != nil {
+			return *p
+		}
+	case *float64:
+		if p != nil {
+			return *p
+		}
+	case *bool:
+		if p != nil {
+			return *p
+		}
+	case *time.Time:
+		if p != nil {
+			return *p
+		}
+	default:
+		return v
+	}
+	return nil
+}
+
+func text(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+	return ""
+}
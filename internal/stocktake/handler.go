@@ -0,0 +1,275 @@
+package stocktake
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"inventory-system/internal"
+	"inventory-system/internal/adjustments"
+	"inventory-system/internal/products"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var validate = validator.New()
+
+func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(payload)
+}
+
+func respondError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, map[string]string{"error": message})
+}
+
+func RegisterRoutes(r chi.Router, db *pgxpool.Pool) {
+	service := NewService(NewRepository(db), products.NewRepository(db), adjustments.ThresholdsFromEnv())
+
+	r.Route("/stocktakes", func(r chi.Router) {
+		r.Use(internal.AuthMiddleware)
+		r.Post("/", createStocktakeHandler(service))
+		r.Get("/", getStocktakesHandler(service))
+		r.Get("/{id}", getStocktakeHandler(service))
+		r.Post("/{id}/counts", addCountHandler(service))
+		r.Get("/{id}/variances", getVariancesHandler(service))
+		r.Post("/{id}/close", closeHandler(service))
+		r.Post("/{id}/cancel", cancelHandler(service))
+	})
+}
+
+func respondStocktakeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		respondError(w, http.StatusNotFound, "Stocktake not found")
+	case errors.Is(err, products.ErrProductNotFound):
+		respondError(w, http.StatusNotFound, "Product not found")
+	case errors.Is(err, products.ErrLocationNotFound):
+		respondError(w, http.StatusNotFound, "Location not found")
+	case errors.Is(err, ErrOutOfScope), errors.Is(err, ErrInvalidLocation), errors.Is(err, ErrNegativeCount),
+		errors.Is(err, ErrSerialized), errors.Is(err, products.ErrInsufficientStock):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrNotOpen):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Open a stocktake session
+// @Description Freezes the current quantity of every product in scope as the expected count.
+// @Description Without location_id all storage locations are included; name filters products by name.
+// @Tags stocktakes
+// @Accept json
+// @Produce json
+// @Param stocktake body StocktakeRequest true "Session scope" example({"location_id":1,"name":"coffee"})
+// @Success 201 {object} Stocktake "Opened session"
+// @Failure 400 {object} map[string]string "Invalid data or location"
+// @Router /stocktakes [post]
+func createStocktakeHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req StocktakeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		st, err := s.CreateStocktake(r.Context(), req)
+		if err != nil {
+			respondStocktakeError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusCreated, st)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary List stocktake sessions
+// @Tags stocktakes
+// @Produce json
+// @Param status query string false "Filter by status (open, closed, cancelled)"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 20, max: 100)"
+// @Success 200 {array} Stocktake "List of sessions, newest first"
+// @Header 200 {int} X-Total-Count "Total number of sessions"
+// @Router /stocktakes [get]
+func getStocktakesHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 1 {
+			page = 1
+		}
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit < 1 || limit > 100 {
+			limit = 20
+		}
+		q := StocktakesQuery{Status: r.URL.Query().Get("status"), Page: page, Limit: limit}
+		stocktakes, total, err := s.GetStocktakes(r.Context(), q)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		respondJSON(w, http.StatusOK, stocktakes)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Get a stocktake session
+// @Tags stocktakes
+// @Produce json
+// @Param id path int true "Stocktake ID"
+// @Success 200 {object} Stocktake "Session"
+// @Failure 404 {object} map[string]string "Stocktake not found"
+// @Router /stocktakes/{id} [get]
+func getStocktakeHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		st, err := s.GetStocktake(r.Context(), id)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if st == nil {
+			respondError(w, http.StatusNotFound, "Stocktake not found")
+			return
+		}
+		respondJSON(w, http.StatusOK, st)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Post a counted quantity
+// @Description Counts are additive, so several scanners can count the same item; a negative quantity undoes an over-count.
+// @Description Products found outside the frozen snapshot are added with expected 0.
+// @Tags stocktakes
+// @Accept json
+// @Produce json
+// @Param id path int true "Stocktake ID"
+// @Param count body CountRequest true "Count" example({"barcode":"7891234567895","quantity":12,"location_id":1})
+// @Success 200 {object} Line "Updated line"
+// @Failure 400 {object} map[string]string "Invalid data, product out of scope or negative count"
+// @Failure 404 {object} map[string]string "Stocktake, product or location not found"
+// @Failure 409 {object} map[string]string "Stocktake not open"
+// @Router /stocktakes/{id}/counts [post]
+func addCountHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		var req CountRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		line, err := s.AddCount(r.Context(), id, req)
+		if err != nil {
+			respondStocktakeError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, line)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Stocktake variance report
+// @Tags stocktakes
+// @Produce json
+// @Param id path int true "Stocktake ID"
+// @Param only_differences query bool false "Only counted lines whose count differs from the expected quantity"
+// @Success 200 {array} Line "Lines with expected, counted and variance"
+// @Failure 404 {object} map[string]string "Stocktake not found"
+// @Router /stocktakes/{id}/variances [get]
+func getVariancesHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		onlyDifferences, _ := strconv.ParseBool(r.URL.Query().Get("only_differences"))
+		lines, err := s.GetVariances(r.Context(), id, onlyDifferences)
+		if err != nil {
+			respondStocktakeError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, lines)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Close a stocktake session
+// @Description Posts every difference as a count_correction adjustment. Adjustments above the approval
+// @Description thresholds stay pending. Uncounted lines are ignored unless zero_uncounted is true.
+// @Tags stocktakes
+// @Accept json
+// @Produce json
+// @Param id path int true "Stocktake ID"
+// @Param options body CloseRequest false "Close options" example({"zero_uncounted":false})
+// @Success 200 {object} Stocktake "Closed session with the adjustments it created"
+// @Failure 400 {object} map[string]string "Insufficient stock"
+// @Failure 404 {object} map[string]string "Stocktake not found"
+// @Failure 409 {object} map[string]string "Stocktake not open"
+// @Router /stocktakes/{id}/close [post]
+func closeHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		var req CloseRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				respondError(w, http.StatusBadRequest, "Invalid data")
+				return
+			}
+		}
+		st, err := s.Close(r.Context(), id, req)
+		if err != nil {
+			respondStocktakeError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, st)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Cancel a stocktake session
+// @Description Closes the session without posting any adjustment.
+// @Tags stocktakes
+// @Produce json
+// @Param id path int true "Stocktake ID"
+// @Success 200 {object} Stocktake "Cancelled session"
+// @Failure 404 {object} map[string]string "Stocktake not found"
+// @Failure 409 {object} map[string]string "Stocktake not open"
+// @Router /stocktakes/{id}/cancel [post]
+func cancelHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		st, err := s.Cancel(r.Context(), id)
+		if err != nil {
+			respondStocktakeError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, st)
+	}
+}
@@ -0,0 +1,69 @@
+package stocktake
+
+import (
+	"time"
+
+	"inventory-system/internal/adjustments"
+)
+
+// Estados de uma sessão de inventário
+const (
+	StatusOpen      = "open"
+	StatusClosed    = "closed"
+	StatusCancelled = "cancelled"
+)
+
+type Stocktake struct {
+	ID         int        `json:"id"`
+	LocationID *int       `json:"location_id"`
+	NameFilter string     `json:"name_filter"`
+	Status     string     `json:"status"`
+	Lines      int        `json:"lines"`
+	CreatedBy  *int       `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ClosedBy   *int       `json:"closed_by"`
+	ClosedAt   *time.Time `json:"closed_at"`
+	// Ajustes lançados no fechamento
+	Adjustments []adjustments.Adjustment `json:"adjustments,omitempty"`
+}
+
+// Line é um produto em um local dentro do inventário. Counted é nulo enquanto ninguém contou o item.
+type Line struct {
+	ProductID    int        `json:"product_id"`
+	Barcode      string     `json:"barcode"`
+	Name         string     `json:"name"`
+	LocationID   int        `json:"location_id"`
+	LocationCode string     `json:"location_code"`
+	Expected     int        `json:"expected"`
+	Counted      *int       `json:"counted"`
+	Variance     *int       `json:"variance"`
+	CountedBy    *int       `json:"counted_by"`
+	CountedAt    *time.Time `json:"counted_at"`
+	AdjustmentID *int       `json:"adjustment_id"`
+	Price        float64    `json:"-"`
+}
+
+type StocktakeRequest struct {
+	// Limita o inventário a um local; sem ele, todos os locais de armazenagem entram
+	LocationID int `json:"location_id"`
+	// Limita o inventário aos produtos cujo nome contém o texto
+	Name string `json:"name"`
+}
+
+type CountRequest struct {
+	Barcode string `json:"barcode" validate:"required"`
+	// Somada à contagem já registrada; negativa corrige uma leitura a mais
+	Quantity   int `json:"quantity" validate:"required,ne=0"`
+	LocationID int `json:"location_id"`
+}
+
+type CloseRequest struct {
+	// Trata itens não contados como zerados em vez de ignorá-los
+	ZeroUncounted bool `json:"zero_uncounted"`
+}
+
+type StocktakesQuery struct {
+	Status string
+	Page   int
+	Limit  int
+}
@@ -0,0 +1,327 @@
+package stocktake
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"inventory-system/internal/adjustments"
+	"inventory-system/internal/products"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrNotFound        = errors.New("stocktake not found")
+	ErrNotOpen         = errors.New("stocktake is not open")
+	ErrOutOfScope      = errors.New("product or location is outside the stocktake scope")
+	ErrInvalidLocation = errors.New("stocktakes must be scoped to an existing storage location")
+	ErrNegativeCount   = errors.New("counted quantity cannot be negative")
+	ErrSerialized      = errors.New("serialized products are counted by serial number, not by stocktake")
+)
+
+type Repository struct {
+	DB *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{DB: db}
+}
+
+// querier é atendido tanto pelo pool quanto por uma transação.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
+// CloseOptions controla o fechamento de um inventário.
+type CloseOptions struct {
+	ZeroUncounted bool
+	Thresholds    adjustments.Thresholds
+	UserID        *int
+}
+
+const stocktakeColumns = `s.id, s.location_id, s.name_filter, s.status,
+	(SELECT COUNT(*) FROM stocktake_lines sl WHERE sl.stocktake_id = s.id),
+	s.created_by, s.created_at, s.closed_by, s.closed_at`
+
+func scanStocktake(row pgx.Row, st *Stocktake) error {
+	return row.Scan(&st.ID, &st.LocationID, &st.NameFilter, &st.Status, &st.Lines, &st.CreatedBy, &st.CreatedAt, &st.ClosedBy, &st.ClosedAt)
+}
+
+// CreateStocktake abre a sessão e congela como esperado o saldo atual de cada produto do escopo.
+func (r *Repository) CreateStocktake(ctx context.Context, st *Stocktake) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if st.LocationID != nil {
+		var storage bool
+		err := tx.QueryRow(ctx, `SELECT kind = 'storage' FROM locations WHERE id = $1`, *st.LocationID).Scan(&storage)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && !storage) {
+			return ErrInvalidLocation
+		}
+		if err != nil {
+			return err
+		}
+	}
+	query := `INSERT INTO stocktakes (location_id, name_filter, created_by) VALUES ($1, $2, $3) RETURNING id, status, created_at`
+	if err := tx.QueryRow(ctx, query, st.LocationID, st.NameFilter, st.CreatedBy).Scan(&st.ID, &st.Status, &st.CreatedAt); err != nil {
+		return err
+	}
+	query = `INSERT INTO stocktake_lines (stocktake_id, product_id, location_id, expected)
+		SELECT $1, s.product_id, s.location_id, s.quantity
+		FROM stock_levels s JOIN products p ON p.id = s.product_id JOIN locations l ON l.id = s.location_id
+		WHERE l.kind = 'storage' AND NOT p.serialized AND ($2::int IS NULL OR s.location_id = $2) AND p.name ILIKE '%' || $3 || '%'`
+	cmd, err := tx.Exec(ctx, query, st.ID, st.LocationID, st.NameFilter)
+	if err != nil {
+		return err
+	}
+	st.Lines = int(cmd.RowsAffected())
+	return tx.Commit(ctx)
+}
+
+func (r *Repository) GetStocktakes(ctx context.Context, q StocktakesQuery) ([]Stocktake, int, error) {
+	args := []interface{}{}
+	where := ""
+	idx := 1
+	if q.Status != "" {
+		where += " AND s.status = $" + strconv.Itoa(idx)
+		args = append(args, q.Status)
+		idx++
+	}
+	limit := q.Limit
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	page := q.Page
+	if page < 1 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+	query := "SELECT " + stocktakeColumns + " FROM stocktakes s WHERE 1=1" + where +
+		" ORDER BY s.id DESC LIMIT $" + strconv.Itoa(idx) + " OFFSET $" + strconv.Itoa(idx+1)
+	rows, err := r.DB.Query(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	stocktakes := []Stocktake{}
+	for rows.Next() {
+		var st Stocktake
+		if err := scanStocktake(rows, &st); err != nil {
+			return nil, 0, err
+		}
+		stocktakes = append(stocktakes, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	total := 0
+	if err := r.DB.QueryRow(ctx, "SELECT COUNT(*) FROM stocktakes s WHERE 1=1"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	return stocktakes, total, nil
+}
+
+func (r *Repository) GetStocktake(ctx context.Context, id int) (*Stocktake, error) {
+	var st Stocktake
+	if err := scanStocktake(r.DB.QueryRow(ctx, "SELECT "+stocktakeColumns+" FROM stocktakes s WHERE s.id = $1", id), &st); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &st, nil
+}
+
+// AddCount soma quantity à contagem do produto no local. A sessão fica com um bloqueio
+// compartilhado, então vários leitores contam ao mesmo tempo, mas ninguém conta durante o fechamento.
+func (r *Repository) AddCount(ctx context.Context, id, productID, locationID, quantity int, userID *int) (*Line, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	var status, nameFilter string
+	var scope *int
+	err = tx.QueryRow(ctx, `SELECT status, location_id, name_filter FROM stocktakes WHERE id = $1 FOR SHARE`, id).Scan(&status, &scope, &nameFilter)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if status != StatusOpen {
+		return nil, ErrNotOpen
+	}
+	if scope != nil {
+		if locationID != 0 && locationID != *scope {
+			return nil, ErrOutOfScope
+		}
+		locationID = *scope
+	}
+	var inScope bool
+	query := `SELECT l.id, l.kind = 'storage' AND p.name ILIKE '%' || $3 || '%'
+		FROM locations l, products p WHERE (l.id = $1 OR ($1 = 0 AND l.is_default)) AND p.id = $2`
+	if err := tx.QueryRow(ctx, query, locationID, productID, nameFilter).Scan(&locationID, &inScope); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, products.ErrLocationNotFound
+		}
+		return nil, err
+	}
+	if !inScope {
+		return nil, ErrOutOfScope
+	}
+	// Itens achados fora do saldo congelado entram com esperado zero
+	query = `INSERT INTO stocktake_lines (stocktake_id, product_id, location_id, counted, counted_by, counted_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (stocktake_id, product_id, location_id) DO UPDATE
+		SET counted = COALESCE(stocktake_lines.counted, 0) + EXCLUDED.counted, counted_by = EXCLUDED.counted_by, counted_at = NOW()`
+	if _, err := tx.Exec(ctx, query, id, productID, locationID, quantity, userID); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23514" {
+			return nil, ErrNegativeCount
+		}
+		return nil, err
+	}
+	lines, err := queryLines(ctx, tx, " AND sl.product_id = $2 AND sl.location_id = $3", id, productID, locationID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &lines[0], nil
+}
+
+// GetLines devolve as linhas do inventário com a diferença entre contado e esperado.
+func (r *Repository) GetLines(ctx context.Context, id int) ([]Line, error) {
+	return queryLines(ctx, r.DB, "", id)
+}
+
+func queryLines(ctx context.Context, q querier, where string, args ...interface{}) ([]Line, error) {
+	query := `SELECT sl.product_id, p.barcode, p.name, sl.location_id, l.code, sl.expected, sl.counted,
+			sl.counted_by, sl.counted_at, sl.adjustment_id, p.price
+		FROM stocktake_lines sl JOIN products p ON p.id = sl.product_id JOIN locations l ON l.id = sl.location_id
+		WHERE sl.stocktake_id = $1` + where + " ORDER BY l.code, p.name, p.id"
+	rows, err := q.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	lines := []Line{}
+	for rows.Next() {
+		var l Line
+		if err := rows.Scan(&l.ProductID, &l.Barcode, &l.Name, &l.LocationID, &l.LocationCode, &l.Expected, &l.Counted,
+			&l.CountedBy, &l.CountedAt, &l.AdjustmentID, &l.Price); err != nil {
+			return nil, err
+		}
+		if l.Counted != nil {
+			v := *l.Counted - l.Expected
+			l.Variance = &v
+		}
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
+
+// Close lança as diferenças como ajustes (motivo count_correction) e fecha a sessão, tudo em uma
+// transação. Cada ajuste é a diferença do relatório de divergências, contado menos o esperado
+// congelado na abertura, e entra como delta: movimentações registradas durante a contagem
+// continuam no saldo. Ajustes acima dos limites ficam pendentes de aprovação como qualquer outro.
+func (r *Repository) Close(ctx context.Context, id int, opts CloseOptions) (*Stocktake, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	var st Stocktake
+	if err := scanStocktake(tx.QueryRow(ctx, "SELECT "+stocktakeColumns+" FROM stocktakes s WHERE s.id = $1 FOR UPDATE", id), &st); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if st.Status != StatusOpen {
+		return nil, ErrNotOpen
+	}
+	lines, err := queryLines(ctx, tx, "", id)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range lines {
+		delta := Difference(l, opts.ZeroUncounted)
+		if delta == 0 {
+			continue
+		}
+		a := adjustments.Adjustment{
+			ProductID:  l.ProductID,
+			Barcode:    l.Barcode,
+			LocationID: l.LocationID,
+			Quantity:   delta,
+			ReasonCode: adjustments.ReasonCountCorrection,
+			Note:       "stocktake #" + strconv.Itoa(id),
+			CreatedBy:  opts.UserID,
+		}
+		opts.Thresholds.Prepare(&a, l.Price)
+		if err := adjustments.Insert(ctx, tx, &a); err != nil {
+			return nil, err
+		}
+		query := `UPDATE stocktake_lines SET adjustment_id = $1 WHERE stocktake_id = $2 AND product_id = $3 AND location_id = $4`
+		if _, err := tx.Exec(ctx, query, a.ID, id, l.ProductID, l.LocationID); err != nil {
+			return nil, err
+		}
+		st.Adjustments = append(st.Adjustments, a)
+	}
+	if err := finish(ctx, tx, &st, StatusClosed, opts.UserID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// Cancel encerra a sessão sem lançar ajustes.
+func (r *Repository) Cancel(ctx context.Context, id int, userID *int) (*Stocktake, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	var st Stocktake
+	if err := scanStocktake(tx.QueryRow(ctx, "SELECT "+stocktakeColumns+" FROM stocktakes s WHERE s.id = $1 FOR UPDATE", id), &st); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if st.Status != StatusOpen {
+		return nil, ErrNotOpen
+	}
+	if err := finish(ctx, tx, &st, StatusCancelled, userID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+func finish(ctx context.Context, tx pgx.Tx, st *Stocktake, status string, userID *int) error {
+	query := `UPDATE stocktakes SET status = $1, closed_by = $2, closed_at = NOW() WHERE id = $3 RETURNING status, closed_by, closed_at`
+	return tx.QueryRow(ctx, query, status, userID, st.ID).Scan(&st.Status, &st.ClosedBy, &st.ClosedAt)
+}
+
+type RepositoryInterface interface {
+	CreateStocktake(ctx context.Context, st *Stocktake) error
+	GetStocktakes(ctx context.Context, q StocktakesQuery) ([]Stocktake, int, error)
+	GetStocktake(ctx context.Context, id int) (*Stocktake, error)
+	AddCount(ctx context.Context, id, productID, locationID, quantity int, userID *int) (*Line, error)
+	GetLines(ctx context.Context, id int) ([]Line, error)
+	Close(ctx context.Context, id int, opts CloseOptions) (*Stocktake, error)
+	Cancel(ctx context.Context, id int, userID *int) (*Stocktake, error)
+}
@@ -0,0 +1,113 @@
+package stocktake
+
+import (
+	"context"
+
+	"inventory-system/internal"
+	"inventory-system/internal/adjustments"
+	"inventory-system/internal/products"
+)
+
+// ProductLookup é a busca por código de barras do pacote products.
+type ProductLookup interface {
+	GetProductByBarcode(ctx context.Context, barcode string) (*products.Product, error)
+}
+
+type Service struct {
+	Repo       RepositoryInterface
+	Products   ProductLookup
+	Thresholds adjustments.Thresholds
+}
+
+func NewService(repo RepositoryInterface, lookup ProductLookup, thresholds adjustments.Thresholds) *Service {
+	return &Service{Repo: repo, Products: lookup, Thresholds: thresholds}
+}
+
+// Difference devolve o ajuste necessário para uma linha: contado menos esperado, a mesma divergência
+// do relatório. Linhas não contadas só geram ajuste (zerando o esperado) quando zeroUncounted é
+// verdadeiro.
+func Difference(l Line, zeroUncounted bool) int {
+	if l.Counted == nil {
+		if !zeroUncounted {
+			return 0
+		}
+		return -l.Expected
+	}
+	return *l.Counted - l.Expected
+}
+
+func (s *Service) CreateStocktake(ctx context.Context, req StocktakeRequest) (*Stocktake, error) {
+	st := &Stocktake{NameFilter: req.Name, CreatedBy: currentUser(ctx)}
+	if req.LocationID != 0 {
+		st.LocationID = &req.LocationID
+	}
+	if err := s.Repo.CreateStocktake(ctx, st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+func (s *Service) GetStocktakes(ctx context.Context, q StocktakesQuery) ([]Stocktake, int, error) {
+	return s.Repo.GetStocktakes(ctx, q)
+}
+
+func (s *Service) GetStocktake(ctx context.Context, id int) (*Stocktake, error) {
+	return s.Repo.GetStocktake(ctx, id)
+}
+
+// AddCount registra uma leitura de contagem; o produto é buscado pelo código de barras.
+func (s *Service) AddCount(ctx context.Context, id int, req CountRequest) (*Line, error) {
+	p, err := s.Products.GetProductByBarcode(ctx, req.Barcode)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, products.ErrProductNotFound
+	}
+	if p.Serialized {
+		return nil, ErrSerialized
+	}
+	return s.Repo.AddCount(ctx, id, p.ID, req.LocationID, req.Quantity, currentUser(ctx))
+}
+
+// GetVariances devolve o relatório de diferenças; com onlyDifferences, omite linhas sem divergência
+// e ainda não contadas.
+func (s *Service) GetVariances(ctx context.Context, id int, onlyDifferences bool) ([]Line, error) {
+	st, err := s.Repo.GetStocktake(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if st == nil {
+		return nil, ErrNotFound
+	}
+	lines, err := s.Repo.GetLines(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !onlyDifferences {
+		return lines, nil
+	}
+	result := []Line{}
+	for _, l := range lines {
+		if l.Variance != nil && *l.Variance != 0 {
+			result = append(result, l)
+		}
+	}
+	return result, nil
+}
+
+// Close fecha o inventário lançando as diferenças como ajustes.
+func (s *Service) Close(ctx context.Context, id int, req CloseRequest) (*Stocktake, error) {
+	return s.Repo.Close(ctx, id, CloseOptions{ZeroUncounted: req.ZeroUncounted, Thresholds: s.Thresholds, UserID: currentUser(ctx)})
+}
+
+func (s *Service) Cancel(ctx context.Context, id int) (*Stocktake, error) {
+	return s.Repo.Cancel(ctx, id, currentUser(ctx))
+}
+
+func currentUser(ctx context.Context) *int {
+	if userID, ok := internal.UserIDFromContext(ctx); ok {
+		return &userID
+	}
+	return nil
+}
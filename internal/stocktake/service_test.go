@@ -0,0 +1,171 @@
+package stocktake
+
+import (
+	"context"
+	"testing"
+
+	"inventory-system/internal/adjustments"
+	"inventory-system/internal/products"
+)
+
+type mockLookup struct {
+	products map[string]*products.Product
+}
+
+func (m *mockLookup) GetProductByBarcode(ctx context.Context, barcode string) (*products.Product, error) {
+	return m.products[barcode], nil
+}
+
+type mockStocktakeRepo struct {
+	stocktakes []Stocktake
+	lines      []Line
+	closed     CloseOptions
+}
+
+func (m *mockStocktakeRepo) CreateStocktake(ctx context.Context, st *Stocktake) error {
+	st.ID = len(m.stocktakes) + 1
+	st.Status = StatusOpen
+	m.stocktakes = append(m.stocktakes, *st)
+	return nil
+}
+func (m *mockStocktakeRepo) GetStocktakes(ctx context.Context, q StocktakesQuery) ([]Stocktake, int, error) {
+	return m.stocktakes, len(m.stocktakes), nil
+}
+func (m *mockStocktakeRepo) GetStocktake(ctx context.Context, id int) (*Stocktake, error) {
+	if id < 1 || id > len(m.stocktakes) {
+		return nil, nil
+	}
+	return &m.stocktakes[id-1], nil
+}
+func (m *mockStocktakeRepo) AddCount(ctx context.Context, id, productID, locationID, quantity int, userID *int) (*Line, error) {
+	if id < 1 || id > len(m.stocktakes) {
+		return nil, ErrNotFound
+	}
+	if m.stocktakes[id-1].Status != StatusOpen {
+		return nil, ErrNotOpen
+	}
+	for i := range m.lines {
+		l := &m.lines[i]
+		if l.ProductID == productID {
+			counted := quantity
+			if l.Counted != nil {
+				counted += *l.Counted
+			}
+			if counted < 0 {
+				return nil, ErrNegativeCount
+			}
+			l.Counted = &counted
+			v := counted - l.Expected
+			l.Variance = &v
+			return l, nil
+		}
+	}
+	return nil, ErrOutOfScope
+}
+func (m *mockStocktakeRepo) GetLines(ctx context.Context, id int) ([]Line, error) {
+	return m.lines, nil
+}
+func (m *mockStocktakeRepo) Close(ctx context.Context, id int, opts CloseOptions) (*Stocktake, error) {
+	m.closed = opts
+	m.stocktakes[id-1].Status = StatusClosed
+	return &m.stocktakes[id-1], nil
+}
+func (m *mockStocktakeRepo) Cancel(ctx context.Context, id int, userID *int) (*Stocktake, error) {
+	m.stocktakes[id-1].Status = StatusCancelled
+	return &m.stocktakes[id-1], nil
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestDifference(t *testing.T) {
+	cases := []struct {
+		line          Line
+		zeroUncounted bool
+		want          int
+	}{
+		{Line{Expected: 10, Counted: intPtr(8)}, false, -2},
+		{Line{Expected: 0, Counted: intPtr(3)}, false, 3},
+		{Line{Expected: 5, Counted: intPtr(5)}, true, 0},
+		{Line{Expected: 7}, false, 0},
+		{Line{Expected: 7}, true, -7},
+	}
+	for _, c := range cases {
+		if got := Difference(c.line, c.zeroUncounted); got != c.want {
+			t.Errorf("%+v (zerar %v): esperado %d, veio %d", c.line, c.zeroUncounted, c.want, got)
+		}
+	}
+}
+
+func TestService_AddCount_Mock(t *testing.T) {
+	repo := &mockStocktakeRepo{lines: []Line{{ProductID: 1, Barcode: "123", Expected: 10}}}
+	lookup := &mockLookup{products: map[string]*products.Product{
+		"123": {ID: 1, Barcode: "123"},
+		"456": {ID: 2, Barcode: "456", Serialized: true},
+	}}
+	svc := NewService(repo, lookup, adjustments.Thresholds{Quantity: 10})
+	st, _ := svc.CreateStocktake(context.Background(), StocktakeRequest{LocationID: 1})
+	if st.LocationID == nil || *st.LocationID != 1 {
+		t.Errorf("esperado escopo no local 1, veio %v", st.LocationID)
+	}
+	// Duas leituras do mesmo item se somam
+	svc.AddCount(context.Background(), st.ID, CountRequest{Barcode: "123", Quantity: 6})
+	line, err := svc.AddCount(context.Background(), st.ID, CountRequest{Barcode: "123", Quantity: 3})
+	if err != nil {
+		t.Fatalf("erro ao contar: %v", err)
+	}
+	if *line.Counted != 9 || *line.Variance != -1 {
+		t.Errorf("esperado contado 9 e diferença -1, veio %+v", line)
+	}
+	if _, err := svc.AddCount(context.Background(), st.ID, CountRequest{Barcode: "999", Quantity: 1}); err != products.ErrProductNotFound {
+		t.Errorf("esperado ErrProductNotFound, veio %v", err)
+	}
+	if _, err := svc.AddCount(context.Background(), st.ID, CountRequest{Barcode: "456", Quantity: 1}); err != ErrSerialized {
+		t.Errorf("esperado ErrSerialized, veio %v", err)
+	}
+	if _, err := svc.AddCount(context.Background(), st.ID, CountRequest{Barcode: "123", Quantity: -10}); err != ErrNegativeCount {
+		t.Errorf("esperado ErrNegativeCount, veio %v", err)
+	}
+}
+
+func TestService_Variances_Mock(t *testing.T) {
+	repo := &mockStocktakeRepo{lines: []Line{
+		{ProductID: 1, Expected: 10, Counted: intPtr(10), Variance: intPtr(0)},
+		{ProductID: 2, Expected: 4, Counted: intPtr(1), Variance: intPtr(-3)},
+		{ProductID: 3, Expected: 2},
+	}}
+	svc := NewService(repo, &mockLookup{}, adjustments.Thresholds{Quantity: 10})
+	st, _ := svc.CreateStocktake(context.Background(), StocktakeRequest{})
+	all, _ := svc.GetVariances(context.Background(), st.ID, false)
+	if len(all) != 3 {
+		t.Errorf("esperado 3 linhas, veio %d", len(all))
+	}
+	diffs, _ := svc.GetVariances(context.Background(), st.ID, true)
+	if len(diffs) != 1 || diffs[0].ProductID != 2 {
+		t.Errorf("esperado só o produto 2, veio %+v", diffs)
+	}
+	if _, err := svc.GetVariances(context.Background(), 99, false); err != ErrNotFound {
+		t.Errorf("esperado ErrNotFound, veio %v", err)
+	}
+}
+
+func TestService_Close_Mock(t *testing.T) {
+	repo := &mockStocktakeRepo{}
+	thresholds := adjustments.Thresholds{Quantity: 10}
+	svc := NewService(repo, &mockLookup{}, thresholds)
+	st, _ := svc.CreateStocktake(context.Background(), StocktakeRequest{})
+	closed, err := svc.Close(context.Background(), st.ID, CloseRequest{ZeroUncounted: true})
+	if err != nil || closed.Status != StatusClosed {
+		t.Fatalf("erro ao fechar: %v", err)
+	}
+	if !repo.closed.ZeroUncounted || repo.closed.Thresholds != thresholds {
+		t.Errorf("opções de fechamento não repassadas: %+v", repo.closed)
+	}
+	// Depois de fechado, não aceita mais contagens
+	repo.lines = []Line{{ProductID: 1, Barcode: "123"}}
+	svc.Products = &mockLookup{products: map[string]*products.Product{"123": {ID: 1}}}
+	if _, err := svc.AddCount(context.Background(), st.ID, CountRequest{Barcode: "123", Quantity: 1}); err != ErrNotOpen {
+		t.Errorf("esperado ErrNotOpen, veio %v", err)
+	}
+}
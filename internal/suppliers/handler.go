@@ -0,0 +1,276 @@
+package suppliers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"inventory-system/internal"
+	"inventory-system/internal/products"
+	"inventory-system/internal/users"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var validate = validator.New()
+
+func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(payload)
+}
+
+func respondError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, map[string]string{"error": message})
+}
+
+func RegisterRoutes(r chi.Router, db *pgxpool.Pool) {
+	service := NewService(NewRepository(db))
+
+	r.Route("/suppliers", func(r chi.Router) {
+		r.Use(internal.AuthMiddleware)
+		r.Post("/", createSupplierHandler(service))
+		r.Get("/", getSuppliersHandler(service))
+		r.Get("/{id}", getSupplierHandler(service))
+		r.Put("/{id}", updateSupplierHandler(service))
+		r.With(users.RequireRole("admin", []byte("changeme"))).Delete("/{id}", deleteSupplierHandler(service))
+		r.Get("/{id}/products", getSupplierProductsHandler(service))
+		r.Put("/{id}/products/{barcode}", setSupplierProductHandler(service))
+		r.Delete("/{id}/products/{barcode}", deleteSupplierProductHandler(service))
+	})
+}
+
+func respondSupplierError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		respondError(w, http.StatusNotFound, "Supplier not found")
+	case errors.Is(err, products.ErrProductNotFound):
+		respondError(w, http.StatusNotFound, "Product not found")
+	case errors.Is(err, ErrLinkNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrInUse):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Create a supplier
+// @Tags suppliers
+// @Accept json
+// @Produce json
+// @Param supplier body Supplier true "Supplier data" example({"name":"Acme Foods","email":"orders@acme.example","phone":"+55 86 3000-0000"})
+// @Success 201 {object} Supplier "Created supplier"
+// @Failure 400 {object} map[string]string "Invalid data"
+// @Router /suppliers [post]
+func createSupplierHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var sup Supplier
+		if err := json.NewDecoder(r.Body).Decode(&sup); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&sup); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		if err := s.CreateSupplier(r.Context(), &sup); err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respondJSON(w, http.StatusCreated, sup)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary List suppliers
+// @Tags suppliers
+// @Produce json
+// @Param name query string false "Filter by name (partial match)"
+// @Success 200 {array} Supplier "List of suppliers"
+// @Router /suppliers [get]
+func getSuppliersHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		suppliers, err := s.GetSuppliers(r.Context(), r.URL.Query().Get("name"))
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, suppliers)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Get a supplier
+// @Tags suppliers
+// @Produce json
+// @Param id path int true "Supplier ID"
+// @Success 200 {object} Supplier "Supplier data"
+// @Failure 404 {object} map[string]string "Supplier not found"
+// @Router /suppliers/{id} [get]
+func getSupplierHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		sup, err := s.GetSupplier(r.Context(), id)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if sup == nil {
+			respondError(w, http.StatusNotFound, "Supplier not found")
+			return
+		}
+		respondJSON(w, http.StatusOK, sup)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Update a supplier
+// @Tags suppliers
+// @Accept json
+// @Param id path int true "Supplier ID"
+// @Param supplier body Supplier true "Supplier data" example({"name":"Acme Foods","email":"orders@acme.example"})
+// @Success 200 {object} map[string]string "Updated"
+// @Failure 400 {object} map[string]string "Invalid data"
+// @Failure 404 {object} map[string]string "Supplier not found"
+// @Router /suppliers/{id} [put]
+func updateSupplierHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		var sup Supplier
+		if err := json.NewDecoder(r.Body).Decode(&sup); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&sup); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		if err := s.UpdateSupplier(r.Context(), id, &sup); err != nil {
+			respondSupplierError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, nil)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Delete a supplier (admin)
+// @Tags suppliers
+// @Param id path int true "Supplier ID"
+// @Success 204 {object} map[string]string "Deleted"
+// @Failure 404 {object} map[string]string "Supplier not found"
+// @Failure 409 {object} map[string]string "Supplier has purchase orders"
+// @Router /suppliers/{id} [delete]
+func deleteSupplierHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		if err := s.DeleteSupplier(r.Context(), id); err != nil {
+			respondSupplierError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusNoContent, nil)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary List the products of a supplier
+// @Tags suppliers
+// @Produce json
+// @Param id path int true "Supplier ID"
+// @Success 200 {array} SupplierProduct "Linked products with supplier SKU, cost and lead time"
+// @Failure 404 {object} map[string]string "Supplier not found"
+// @Router /suppliers/{id}/products [get]
+func getSupplierProductsHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		links, err := s.GetSupplierProducts(r.Context(), id)
+		if err != nil {
+			respondSupplierError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, links)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Link a product to a supplier
+// @Description Creates the link or replaces its supplier SKU, unit cost, lead time and preferred flag. Marking a supplier as preferred unmarks the others for the product.
+// @Tags suppliers
+// @Accept json
+// @Produce json
+// @Param id path int true "Supplier ID"
+// @Param barcode path string true "Product barcode"
+// @Param link body SupplierProduct true "Link data" example({"supplier_sku":"AC-1001","unit_cost":2.35,"lead_time_days":7,"preferred":true})
+// @Success 200 {object} SupplierProduct "Link"
+// @Failure 400 {object} map[string]string "Invalid data"
+// @Failure 404 {object} map[string]string "Supplier or product not found"
+// @Router /suppliers/{id}/products/{barcode} [put]
+func setSupplierProductHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		var link SupplierProduct
+		if err := json.NewDecoder(r.Body).Decode(&link); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&link); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		link.SupplierID = id
+		link.Barcode = chi.URLParam(r, "barcode")
+		if err := s.SetSupplierProduct(r.Context(), &link); err != nil {
+			respondSupplierError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, link)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Unlink a product from a supplier
+// @Tags suppliers
+// @Param id path int true "Supplier ID"
+// @Param barcode path string true "Product barcode"
+// @Success 204 {object} map[string]string "Deleted"
+// @Failure 404 {object} map[string]string "Link not found"
+// @Router /suppliers/{id}/products/{barcode} [delete]
+func deleteSupplierProductHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		if err := s.DeleteSupplierProduct(r.Context(), id, chi.URLParam(r, "barcode")); err != nil {
+			respondSupplierError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusNoContent, nil)
+	}
+}
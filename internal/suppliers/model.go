@@ -0,0 +1,25 @@
+package suppliers
+
+import "time"
+
+type Supplier struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name" validate:"required"`
+	Email     string    `json:"email" validate:"omitempty,email"`
+	Phone     string    `json:"phone"`
+	Notes     string    `json:"notes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SupplierProduct liga um produto a um fornecedor com o código, o custo e o prazo de entrega dele.
+type SupplierProduct struct {
+	SupplierID   int     `json:"supplier_id"`
+	ProductID    int     `json:"product_id"`
+	Barcode      string  `json:"barcode"`
+	Name         string  `json:"name"`
+	SupplierSKU  string  `json:"supplier_sku"`
+	UnitCost     float64 `json:"unit_cost" validate:"gte=0"`
+	LeadTimeDays int     `json:"lead_time_days" validate:"gte=0"`
+	// Preferred marca o fornecedor usado nas sugestões de reposição; só um por produto
+	Preferred bool `json:"preferred"`
+}
@@ -0,0 +1,172 @@
+package suppliers
+
+import (
+	"context"
+	"errors"
+
+	"inventory-system/internal/products"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrNotFound     = errors.New("supplier not found")
+	ErrInUse        = errors.New("supplier has purchase orders")
+	ErrLinkNotFound = errors.New("product is not linked to this supplier")
+)
+
+type Repository struct {
+	DB *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{DB: db}
+}
+
+const supplierColumns = "id, name, email, phone, notes, created_at"
+
+func scanSupplier(row pgx.Row, s *Supplier) error {
+	return row.Scan(&s.ID, &s.Name, &s.Email, &s.Phone, &s.Notes, &s.CreatedAt)
+}
+
+func (r *Repository) CreateSupplier(ctx context.Context, s *Supplier) error {
+	query := `INSERT INTO suppliers (name, email, phone, notes) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	return r.DB.QueryRow(ctx, query, s.Name, s.Email, s.Phone, s.Notes).Scan(&s.ID, &s.CreatedAt)
+}
+
+func (r *Repository) GetSuppliers(ctx context.Context, name string) ([]Supplier, error) {
+	query := "SELECT " + supplierColumns + " FROM suppliers WHERE name ILIKE '%' || $1 || '%' ORDER BY name, id"
+	rows, err := r.DB.Query(ctx, query, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	suppliers := []Supplier{}
+	for rows.Next() {
+		var s Supplier
+		if err := scanSupplier(rows, &s); err != nil {
+			return nil, err
+		}
+		suppliers = append(suppliers, s)
+	}
+	return suppliers, rows.Err()
+}
+
+func (r *Repository) GetSupplier(ctx context.Context, id int) (*Supplier, error) {
+	var s Supplier
+	if err := scanSupplier(r.DB.QueryRow(ctx, "SELECT "+supplierColumns+" FROM suppliers WHERE id = $1", id), &s); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *Repository) UpdateSupplier(ctx context.Context, id int, s *Supplier) error {
+	query := `UPDATE suppliers SET name=$1, email=$2, phone=$3, notes=$4 WHERE id=$5 RETURNING id, created_at`
+	if err := r.DB.QueryRow(ctx, query, s.Name, s.Email, s.Phone, s.Notes, id).Scan(&s.ID, &s.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteSupplier remove o fornecedor e seus vínculos; fornecedores com pedidos de compra ficam.
+func (r *Repository) DeleteSupplier(ctx context.Context, id int) error {
+	cmd, err := r.DB.Exec(ctx, `DELETE FROM suppliers WHERE id=$1`, id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return ErrInUse
+		}
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+const linkColumns = "sp.supplier_id, sp.product_id, p.barcode, p.name, sp.supplier_sku, sp.unit_cost, sp.lead_time_days, sp.preferred"
+
+func (r *Repository) GetSupplierProducts(ctx context.Context, supplierID int) ([]SupplierProduct, error) {
+	query := "SELECT " + linkColumns + ` FROM supplier_products sp JOIN products p ON p.id = sp.product_id
+		WHERE sp.supplier_id = $1 ORDER BY p.name, p.id`
+	rows, err := r.DB.Query(ctx, query, supplierID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	links := []SupplierProduct{}
+	for rows.Next() {
+		var l SupplierProduct
+		if err := rows.Scan(&l.SupplierID, &l.ProductID, &l.Barcode, &l.Name, &l.SupplierSKU, &l.UnitCost, &l.LeadTimeDays, &l.Preferred); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+// SetSupplierProduct cria ou atualiza o vínculo do produto l.Barcode com o fornecedor l.SupplierID.
+// Marcar o vínculo como preferido desmarca os demais fornecedores do produto.
+func (r *Repository) SetSupplierProduct(ctx context.Context, l *SupplierProduct) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if l.Preferred {
+		query := `UPDATE supplier_products SET preferred = FALSE
+			WHERE ` + products.ByCode("product_id", "$1") + ` AND supplier_id <> $2 AND preferred`
+		if _, err := tx.Exec(ctx, query, l.Barcode, l.SupplierID); err != nil {
+			return err
+		}
+	}
+	query := `INSERT INTO supplier_products (supplier_id, product_id, supplier_sku, unit_cost, lead_time_days, preferred)
+		SELECT $1, id, $3, $4, $5, $6 FROM products WHERE ` + products.ByCode("id", "$2") + `
+		ON CONFLICT (supplier_id, product_id) DO UPDATE
+		SET supplier_sku = EXCLUDED.supplier_sku, unit_cost = EXCLUDED.unit_cost, lead_time_days = EXCLUDED.lead_time_days,
+			preferred = EXCLUDED.preferred
+		RETURNING product_id, (SELECT name FROM products WHERE id = supplier_products.product_id)`
+	err = tx.QueryRow(ctx, query, l.SupplierID, l.Barcode, l.SupplierSKU, l.UnitCost, l.LeadTimeDays, l.Preferred).Scan(&l.ProductID, &l.Name)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return products.ErrProductNotFound
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return ErrNotFound
+		}
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *Repository) DeleteSupplierProduct(ctx context.Context, supplierID int, barcode string) error {
+	query := `DELETE FROM supplier_products WHERE supplier_id = $1 AND ` + products.ByCode("product_id", "$2")
+	cmd, err := r.DB.Exec(ctx, query, supplierID, barcode)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrLinkNotFound
+	}
+	return nil
+}
+
+type RepositoryInterface interface {
+	CreateSupplier(ctx context.Context, s *Supplier) error
+	GetSuppliers(ctx context.Context, name string) ([]Supplier, error)
+	GetSupplier(ctx context.Context, id int) (*Supplier, error)
+	UpdateSupplier(ctx context.Context, id int, s *Supplier) error
+	DeleteSupplier(ctx context.Context, id int) error
+	GetSupplierProducts(ctx context.Context, supplierID int) ([]SupplierProduct, error)
+	SetSupplierProduct(ctx context.Context, l *SupplierProduct) error
+	DeleteSupplierProduct(ctx context.Context, supplierID int, barcode string) error
+}
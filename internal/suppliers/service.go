@@ -0,0 +1,52 @@
+package suppliers
+
+import "context"
+
+type Service struct {
+	Repo RepositoryInterface
+}
+
+func NewService(repo RepositoryInterface) *Service {
+	return &Service{Repo: repo}
+}
+
+func (s *Service) CreateSupplier(ctx context.Context, sup *Supplier) error {
+	return s.Repo.CreateSupplier(ctx, sup)
+}
+
+func (s *Service) GetSuppliers(ctx context.Context, name string) ([]Supplier, error) {
+	return s.Repo.GetSuppliers(ctx, name)
+}
+
+func (s *Service) GetSupplier(ctx context.Context, id int) (*Supplier, error) {
+	return s.Repo.GetSupplier(ctx, id)
+}
+
+func (s *Service) UpdateSupplier(ctx context.Context, id int, sup *Supplier) error {
+	return s.Repo.UpdateSupplier(ctx, id, sup)
+}
+
+func (s *Service) DeleteSupplier(ctx context.Context, id int) error {
+	return s.Repo.DeleteSupplier(ctx, id)
+}
+
+// GetSupplierProducts lista os produtos vinculados ao fornecedor.
+func (s *Service) GetSupplierProducts(ctx context.Context, supplierID int) ([]SupplierProduct, error) {
+	sup, err := s.Repo.GetSupplier(ctx, supplierID)
+	if err != nil {
+		return nil, err
+	}
+	if sup == nil {
+		return nil, ErrNotFound
+	}
+	return s.Repo.GetSupplierProducts(ctx, supplierID)
+}
+
+// SetSupplierProduct vincula o produto ao fornecedor, ou atualiza o vínculo existente.
+func (s *Service) SetSupplierProduct(ctx context.Context, l *SupplierProduct) error {
+	return s.Repo.SetSupplierProduct(ctx, l)
+}
+
+func (s *Service) DeleteSupplierProduct(ctx context.Context, supplierID int, barcode string) error {
+	return s.Repo.DeleteSupplierProduct(ctx, supplierID, barcode)
+}
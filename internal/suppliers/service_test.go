@@ -0,0 +1,137 @@
+package suppliers
+
+import (
+	"context"
+	"testing"
+
+	"inventory-system/internal/products"
+)
+
+type mockSupplierRepo struct {
+	suppliers map[int]*Supplier
+	links     []SupplierProduct
+	barcodes  map[string]int
+}
+
+func (m *mockSupplierRepo) CreateSupplier(ctx context.Context, s *Supplier) error {
+	s.ID = len(m.suppliers) + 1
+	m.suppliers[s.ID] = s
+	return nil
+}
+func (m *mockSupplierRepo) GetSuppliers(ctx context.Context, name string) ([]Supplier, error) {
+	suppliers := []Supplier{}
+	for _, s := range m.suppliers {
+		suppliers = append(suppliers, *s)
+	}
+	return suppliers, nil
+}
+func (m *mockSupplierRepo) GetSupplier(ctx context.Context, id int) (*Supplier, error) {
+	return m.suppliers[id], nil
+}
+func (m *mockSupplierRepo) UpdateSupplier(ctx context.Context, id int, s *Supplier) error {
+	if _, ok := m.suppliers[id]; !ok {
+		return ErrNotFound
+	}
+	s.ID = id
+	m.suppliers[id] = s
+	return nil
+}
+func (m *mockSupplierRepo) DeleteSupplier(ctx context.Context, id int) error {
+	if _, ok := m.suppliers[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.suppliers, id)
+	return nil
+}
+func (m *mockSupplierRepo) GetSupplierProducts(ctx context.Context, supplierID int) ([]SupplierProduct, error) {
+	links := []SupplierProduct{}
+	for _, l := range m.links {
+		if l.SupplierID == supplierID {
+			links = append(links, l)
+		}
+	}
+	return links, nil
+}
+func (m *mockSupplierRepo) SetSupplierProduct(ctx context.Context, l *SupplierProduct) error {
+	if _, ok := m.suppliers[l.SupplierID]; !ok {
+		return ErrNotFound
+	}
+	productID, ok := m.barcodes[l.Barcode]
+	if !ok {
+		return products.ErrProductNotFound
+	}
+	l.ProductID = productID
+	for i := range m.links {
+		if m.links[i].SupplierID == l.SupplierID && m.links[i].ProductID == productID {
+			m.links[i] = *l
+			return nil
+		}
+	}
+	m.links = append(m.links, *l)
+	return nil
+}
+func (m *mockSupplierRepo) DeleteSupplierProduct(ctx context.Context, supplierID int, barcode string) error {
+	for i, l := range m.links {
+		if l.SupplierID == supplierID && l.Barcode == barcode {
+			m.links = append(m.links[:i], m.links[i+1:]...)
+			return nil
+		}
+	}
+	return ErrLinkNotFound
+}
+
+func TestService_Suppliers_Mock(t *testing.T) {
+	repo := &mockSupplierRepo{suppliers: map[int]*Supplier{}}
+	svc := NewService(repo)
+	sup := &Supplier{Name: "Acme"}
+	if err := svc.CreateSupplier(context.Background(), sup); err != nil || sup.ID != 1 {
+		t.Fatalf("erro ao criar fornecedor: %v", err)
+	}
+	if err := svc.UpdateSupplier(context.Background(), 1, &Supplier{Name: "Acme Foods"}); err != nil {
+		t.Fatalf("erro ao atualizar fornecedor: %v", err)
+	}
+	got, _ := svc.GetSupplier(context.Background(), 1)
+	if got == nil || got.Name != "Acme Foods" {
+		t.Errorf("fornecedor não atualizado: %+v", got)
+	}
+	if err := svc.UpdateSupplier(context.Background(), 99, &Supplier{Name: "X"}); err != ErrNotFound {
+		t.Errorf("esperado ErrNotFound, veio %v", err)
+	}
+	if err := svc.DeleteSupplier(context.Background(), 1); err != nil {
+		t.Fatalf("erro ao remover fornecedor: %v", err)
+	}
+	if got, _ := svc.GetSupplier(context.Background(), 1); got != nil {
+		t.Errorf("fornecedor deveria ter sido removido: %+v", got)
+	}
+}
+
+func TestService_SupplierProducts_Mock(t *testing.T) {
+	repo := &mockSupplierRepo{suppliers: map[int]*Supplier{}, barcodes: map[string]int{"123": 7}}
+	svc := NewService(repo)
+	_ = svc.CreateSupplier(context.Background(), &Supplier{Name: "Acme"})
+	link := &SupplierProduct{SupplierID: 1, Barcode: "123", SupplierSKU: "AC-1", UnitCost: 2, LeadTimeDays: 5}
+	if err := svc.SetSupplierProduct(context.Background(), link); err != nil {
+		t.Fatalf("erro ao vincular produto: %v", err)
+	}
+	// Vincular de novo atualiza o vínculo existente
+	_ = svc.SetSupplierProduct(context.Background(), &SupplierProduct{SupplierID: 1, Barcode: "123", SupplierSKU: "AC-1", UnitCost: 2.5})
+	links, err := svc.GetSupplierProducts(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("erro ao listar vínculos: %v", err)
+	}
+	if len(links) != 1 || links[0].ProductID != 7 || links[0].UnitCost != 2.5 {
+		t.Errorf("vínculos incorretos: %+v", links)
+	}
+	if err := svc.SetSupplierProduct(context.Background(), &SupplierProduct{SupplierID: 1, Barcode: "999"}); err != products.ErrProductNotFound {
+		t.Errorf("esperado ErrProductNotFound, veio %v", err)
+	}
+	if _, err := svc.GetSupplierProducts(context.Background(), 99); err != ErrNotFound {
+		t.Errorf("esperado ErrNotFound, veio %v", err)
+	}
+	if err := svc.DeleteSupplierProduct(context.Background(), 1, "123"); err != nil {
+		t.Fatalf("erro ao desvincular: %v", err)
+	}
+	if err := svc.DeleteSupplierProduct(context.Background(), 1, "123"); err != ErrLinkNotFound {
+		t.Errorf("esperado ErrLinkNotFound, veio %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package transfers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"inventory-system/internal"
+	"inventory-system/internal/products"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var validate = validator.New()
+
+func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(payload)
+}
+
+func respondError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, map[string]string{"error": message})
+}
+
+func RegisterRoutes(r chi.Router, db *pgxpool.Pool) {
+	service := NewService(NewRepository(db))
+
+	r.Route("/transfers", func(r chi.Router) {
+		r.Use(internal.AuthMiddleware)
+		r.Post("/", createTransferHandler(service))
+		r.Get("/", getTransfersHandler(service))
+		r.Get("/{id}", getTransferHandler(service))
+		r.Post("/{id}/dispatch", transitionHandler(service.Dispatch))
+		r.Post("/{id}/receive", transitionHandler(service.Receive))
+		r.Post("/{id}/cancel", transitionHandler(service.Cancel))
+	})
+}
+
+func respondTransferError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		respondError(w, http.StatusNotFound, "Transfer not found")
+	case errors.Is(err, products.ErrProductNotFound):
+		respondError(w, http.StatusNotFound, "Product not found")
+	case errors.Is(err, ErrInvalidLocation), errors.Is(err, products.ErrInsufficientStock), errors.Is(err, products.ErrReservedStock),
+		errors.Is(err, products.ErrSerialsRequired), errors.Is(err, products.ErrSerialCount), errors.Is(err, products.ErrSerialNotInStock),
+		errors.Is(err, products.ErrNotSerialized):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrInvalidTransition):
+		respondError(w, http.StatusConflict, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Create a stock transfer between locations
+// @Description Creates a draft by default. With status "in_transit" the goods leave the source immediately;
+// @Description with status "received" they are moved from source to destination in a single transaction.
+// @Tags transfers
+// @Accept json
+// @Produce json
+// @Param transfer body TransferRequest true "Transfer data" example({"barcode":"7891234567895","from_location_id":1,"to_location_id":3,"quantity":5,"status":"in_transit"})
+// @Success 201 {object} Transfer "Created transfer"
+// @Failure 400 {object} map[string]string "Invalid data or insufficient stock at source"
+// @Failure 404 {object} map[string]string "Product not found"
+// @Router /transfers [post]
+func createTransferHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req TransferRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid data")
+			return
+		}
+		if err := validate.Struct(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Validation failed: "+err.Error())
+			return
+		}
+		t, err := s.CreateTransfer(r.Context(), req)
+		if err != nil {
+			respondTransferError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusCreated, t)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary List stock transfers
+// @Tags transfers
+// @Produce json
+// @Param status query string false "Filter by status (draft, in_transit, received, cancelled)"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 20, max: 100)"
+// @Success 200 {array} Transfer "List of transfers"
+// @Header 200 {int} X-Total-Count "Total number of transfers"
+// @Router /transfers [get]
+func getTransfersHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 1 {
+			page = 1
+		}
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit < 1 || limit > 100 {
+			limit = 20
+		}
+		transfers, total, err := s.GetTransfers(r.Context(), TransfersQuery{
+			Status: r.URL.Query().Get("status"),
+			Page:   page,
+			Limit:  limit,
+		})
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		respondJSON(w, http.StatusOK, transfers)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Get a stock transfer
+// @Tags transfers
+// @Produce json
+// @Param id path int true "Transfer ID"
+// @Success 200 {object} Transfer "Transfer data"
+// @Failure 404 {object} map[string]string "Transfer not found"
+// @Router /transfers/{id} [get]
+func getTransferHandler(s *Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		t, err := s.GetTransfer(r.Context(), id)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if t == nil {
+			respondError(w, http.StatusNotFound, "Transfer not found")
+			return
+		}
+		respondJSON(w, http.StatusOK, t)
+	}
+}
+
+// @Security ApiKeyAuth
+// @Summary Dispatch, receive or cancel a stock transfer
+// @Description dispatch: draft -> in_transit; receive: draft or in_transit -> received;
+// @Description cancel: draft or in_transit -> cancelled (goods in transit return to the source).
+// @Tags transfers
+// @Produce json
+// @Param id path int true "Transfer ID"
+// @Success 200 {object} Transfer "Updated transfer"
+// @Failure 400 {object} map[string]string "Insufficient stock at source"
+// @Failure 404 {object} map[string]string "Transfer not found"
+// @Failure 409 {object} map[string]string "Invalid status transition"
+// @Router /transfers/{id}/dispatch [post]
+// @Router /transfers/{id}/receive [post]
+// @Router /transfers/{id}/cancel [post]
+func transitionHandler(action func(ctx context.Context, id int) (*Transfer, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid ID")
+			return
+		}
+		t, err := action(r.Context(), id)
+		if err != nil {
+			respondTransferError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, t)
+	}
+}
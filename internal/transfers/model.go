@@ -0,0 +1,45 @@
+package transfers
+
+import "time"
+
+// Estados de uma transferência
+const (
+	StatusDraft     = "draft"
+	StatusInTransit = "in_transit"
+	StatusReceived  = "received"
+	StatusCancelled = "cancelled"
+)
+
+type Transfer struct {
+	ID             int        `json:"id"`
+	ProductID      int        `json:"product_id"`
+	Barcode        string     `json:"barcode"`
+	FromLocationID int        `json:"from_location_id"`
+	ToLocationID   int        `json:"to_location_id"`
+	Quantity       int        `json:"quantity"`
+	Serials        []string   `json:"serials,omitempty"`
+	Status         string     `json:"status"`
+	Reference      string     `json:"reference"`
+	CreatedBy      *int       `json:"created_by"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DispatchedAt   *time.Time `json:"dispatched_at"`
+	ReceivedAt     *time.Time `json:"received_at"`
+}
+
+type TransferRequest struct {
+	Barcode        string `json:"barcode" validate:"required"`
+	FromLocationID int    `json:"from_location_id" validate:"required"`
+	ToLocationID   int    `json:"to_location_id" validate:"required,nefield=FromLocationID"`
+	// Pode ser omitida quando Serials é informado
+	Quantity  int    `json:"quantity" validate:"required_without=Serials,gte=0"`
+	Reference string `json:"reference"`
+	Status    string `json:"status" validate:"omitempty,oneof=draft in_transit received"`
+	// Serials nomeia as unidades de um produto serializado, obrigatórias para ele
+	Serials []string `json:"serials" validate:"omitempty,unique,dive,required"`
+}
+
+type TransfersQuery struct {
+	Status string
+	Page   int
+	Limit  int
+}
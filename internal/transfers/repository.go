@@ -0,0 +1,231 @@
+package transfers
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+
+	"inventory-system/internal/products"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrNotFound          = errors.New("transfer not found")
+	ErrInvalidTransition = errors.New("transfer cannot move to the requested status")
+	ErrInvalidLocation   = errors.New("transfers must go to an existing storage location from a storage or quarantine location")
+)
+
+type Repository struct {
+	DB *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{DB: db}
+}
+
+const transferColumns = `t.id, t.product_id, p.barcode, t.from_location_id, t.to_location_id, t.quantity, t.serials,
+	t.status, t.reference, t.created_by, t.created_at, t.dispatched_at, t.received_at`
+
+func scanTransfer(row pgx.Row, t *Transfer) error {
+	return row.Scan(&t.ID, &t.ProductID, &t.Barcode, &t.FromLocationID, &t.ToLocationID, &t.Quantity, &t.Serials,
+		&t.Status, &t.Reference, &t.CreatedBy, &t.CreatedAt, &t.DispatchedAt, &t.ReceivedAt)
+}
+
+// CreateTransfer grava a transferência como rascunho e, se status for outro, já a avança na mesma transação.
+func (r *Repository) CreateTransfer(ctx context.Context, t *Transfer, status string) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	var serialized bool
+	query := `SELECT id, serialized FROM products WHERE ` + products.ByCode("id", "$1")
+	if err := tx.QueryRow(ctx, query, t.Barcode).Scan(&t.ProductID, &serialized); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return products.ErrProductNotFound
+		}
+		return err
+	}
+	// Como nas saídas pela API, as unidades de um produto serializado precisam ser nomeadas
+	if serialized && len(t.Serials) == 0 {
+		return products.ErrSerialsRequired
+	}
+	if !serialized && len(t.Serials) > 0 {
+		return products.ErrNotSerialized
+	}
+	if t.Serials == nil {
+		t.Serials = []string{}
+	}
+	// A origem pode ser a quarentena, para esvaziar o estoque que versões anteriores deixaram lá
+	var valid int
+	query = `SELECT COUNT(*) FROM locations
+		WHERE (id = $1 AND kind IN ('storage', 'quarantine')) OR (id = $2 AND kind = 'storage')`
+	if err := tx.QueryRow(ctx, query, t.FromLocationID, t.ToLocationID).Scan(&valid); err != nil {
+		return err
+	}
+	if valid != 2 {
+		return ErrInvalidLocation
+	}
+	query = `INSERT INTO transfers (product_id, from_location_id, to_location_id, quantity, serials, reference, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, status, created_at`
+	err = tx.QueryRow(ctx, query, t.ProductID, t.FromLocationID, t.ToLocationID, t.Quantity, t.Serials, t.Reference, t.CreatedBy).
+		Scan(&t.ID, &t.Status, &t.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if status != StatusDraft {
+		if err := transition(ctx, tx, t, status); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *Repository) GetTransfers(ctx context.Context, q TransfersQuery) ([]Transfer, int, error) {
+	args := []interface{}{}
+	where := ""
+	idx := 1
+	if q.Status != "" {
+		where += " AND t.status = $" + strconv.Itoa(idx)
+		args = append(args, q.Status)
+		idx++
+	}
+	limit := q.Limit
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	page := q.Page
+	if page < 1 {
+		page = 1
+	}
+	query := "SELECT " + transferColumns + " FROM transfers t JOIN products p ON p.id = t.product_id WHERE 1=1" + where +
+		" ORDER BY t.id DESC LIMIT $" + strconv.Itoa(idx) + " OFFSET $" + strconv.Itoa(idx+1)
+	rows, err := r.DB.Query(ctx, query, append(args, limit, (page-1)*limit)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	transfers := []Transfer{}
+	for rows.Next() {
+		var t Transfer
+		if err := scanTransfer(rows, &t); err != nil {
+			return nil, 0, err
+		}
+		transfers = append(transfers, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	total := 0
+	if err := r.DB.QueryRow(ctx, "SELECT COUNT(*) FROM transfers t WHERE 1=1"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	return transfers, total, nil
+}
+
+func (r *Repository) GetTransfer(ctx context.Context, id int) (*Transfer, error) {
+	var t Transfer
+	query := "SELECT " + transferColumns + " FROM transfers t JOIN products p ON p.id = t.product_id WHERE t.id = $1"
+	if err := scanTransfer(r.DB.QueryRow(ctx, query, id), &t); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Transition avança a transferência para o status indicado, movimentando o estoque na mesma transação.
+func (r *Repository) Transition(ctx context.Context, id int, status string) (*Transfer, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	var t Transfer
+	query := "SELECT " + transferColumns + " FROM transfers t JOIN products p ON p.id = t.product_id WHERE t.id = $1 FOR UPDATE OF t"
+	if err := scanTransfer(tx.QueryRow(ctx, query, id), &t); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if err := transition(ctx, tx, &t, status); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func transition(ctx context.Context, tx pgx.Tx, t *Transfer, status string) error {
+	if !CanTransition(t.Status, status) {
+		return ErrInvalidTransition
+	}
+	var transitID int
+	if err := tx.QueryRow(ctx, `SELECT id FROM locations WHERE kind = 'transit' ORDER BY id LIMIT 1`).Scan(&transitID); err != nil {
+		return err
+	}
+	// Os lotes baixados na origem (FEFO) são recriados no destino com a mesma validade, e as séries
+	// que saem são as que entram. Transferências gravadas antes das séries levam as mais antigas do
+	// local, que passam a ser as da transferência
+	var lots []products.LotQuantity
+	var exits []*products.StockMovement
+	for _, l := range transferLegs(t, status, transitID) {
+		m := &products.StockMovement{
+			ProductID:  t.ProductID,
+			LocationID: l.locationID,
+			Delta:      l.delta,
+			Reason:     products.ReasonTransfer,
+			Reference:  "transfer #" + strconv.Itoa(t.ID),
+			Serials:    slices.Clone(l.serials),
+			System:     true,
+		}
+		if l.delta > 0 {
+			m.Lots = lots
+			if len(m.Serials) == 0 {
+				m.Serials = t.Serials
+			}
+		}
+		if err := products.ApplyMovement(ctx, tx, m); err != nil {
+			return err
+		}
+		if l.delta < 0 {
+			lots = m.Lots
+			exits = append(exits, m)
+			if len(t.Serials) == 0 {
+				t.Serials = m.Serials
+			}
+		}
+	}
+	// Unidades alocadas a pedidos de venda não saem do local; a verificação vem depois de todas as
+	// pernas, com o total do produto já recomposto
+	for _, m := range exits {
+		if err := products.CheckHeldStock(ctx, tx, m); err != nil {
+			return err
+		}
+	}
+	if t.Serials == nil {
+		t.Serials = []string{}
+	}
+	query := `UPDATE transfers SET status = $1, serials = $3,
+		dispatched_at = CASE WHEN $1 = 'in_transit' THEN NOW() ELSE dispatched_at END,
+		received_at = CASE WHEN $1 = 'received' THEN NOW() ELSE received_at END
+		WHERE id = $2 RETURNING dispatched_at, received_at`
+	if err := tx.QueryRow(ctx, query, status, t.ID, t.Serials).Scan(&t.DispatchedAt, &t.ReceivedAt); err != nil {
+		return err
+	}
+	t.Status = status
+	return nil
+}
+
+type RepositoryInterface interface {
+	CreateTransfer(ctx context.Context, t *Transfer, status string) error
+	GetTransfers(ctx context.Context, q TransfersQuery) ([]Transfer, int, error)
+	GetTransfer(ctx context.Context, id int) (*Transfer, error)
+	Transition(ctx context.Context, id int, status string) (*Transfer, error)
+}
@@ -0,0 +1,108 @@
+package transfers
+
+import (
+	"context"
+
+	"inventory-system/internal"
+	"inventory-system/internal/products"
+)
+
+type Service struct {
+	Repo RepositoryInterface
+}
+
+func NewService(repo RepositoryInterface) *Service {
+	return &Service{Repo: repo}
+}
+
+var transitions = map[string][]string{
+	StatusDraft:     {StatusInTransit, StatusReceived, StatusCancelled},
+	StatusInTransit: {StatusReceived, StatusCancelled},
+}
+
+// CanTransition informa se uma transferência em from pode ir para to.
+func CanTransition(from, to string) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+type leg struct {
+	locationID int
+	delta      int
+	serials    []string
+}
+
+// transferLegs devolve as movimentações de estoque necessárias para levar t até status.
+// Mercadorias despachadas ficam no local de trânsito até o recebimento ou cancelamento. As duas
+// pernas levam as séries da transferência, para que as mesmas unidades saiam e entrem.
+func transferLegs(t *Transfer, status string, transitID int) []leg {
+	q, s := t.Quantity, t.Serials
+	switch {
+	case t.Status == StatusDraft && status == StatusInTransit:
+		return []leg{{t.FromLocationID, -q, s}, {transitID, q, s}}
+	case t.Status == StatusDraft && status == StatusReceived:
+		return []leg{{t.FromLocationID, -q, s}, {t.ToLocationID, q, s}}
+	case t.Status == StatusInTransit && status == StatusReceived:
+		return []leg{{transitID, -q, s}, {t.ToLocationID, q, s}}
+	case t.Status == StatusInTransit && status == StatusCancelled:
+		return []leg{{transitID, -q, s}, {t.FromLocationID, q, s}}
+	}
+	return nil
+}
+
+func (s *Service) CreateTransfer(ctx context.Context, req TransferRequest) (*Transfer, error) {
+	t := &Transfer{
+		Barcode:        req.Barcode,
+		FromLocationID: req.FromLocationID,
+		ToLocationID:   req.ToLocationID,
+		Quantity:       req.Quantity,
+		Reference:      req.Reference,
+		Serials:        req.Serials,
+	}
+	if len(req.Serials) > 0 {
+		if t.Quantity == 0 {
+			t.Quantity = len(req.Serials)
+		}
+		if t.Quantity != len(req.Serials) {
+			return nil, products.ErrSerialCount
+		}
+	}
+	if userID, ok := internal.UserIDFromContext(ctx); ok {
+		t.CreatedBy = &userID
+	}
+	status := req.Status
+	if status == "" {
+		status = StatusDraft
+	}
+	if err := s.Repo.CreateTransfer(ctx, t, status); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *Service) GetTransfers(ctx context.Context, q TransfersQuery) ([]Transfer, int, error) {
+	return s.Repo.GetTransfers(ctx, q)
+}
+
+func (s *Service) GetTransfer(ctx context.Context, id int) (*Transfer, error) {
+	return s.Repo.GetTransfer(ctx, id)
+}
+
+// Dispatch retira a mercadoria da origem e a coloca em trânsito.
+func (s *Service) Dispatch(ctx context.Context, id int) (*Transfer, error) {
+	return s.Repo.Transition(ctx, id, StatusInTransit)
+}
+
+// Receive dá entrada da mercadoria no destino, vinda do trânsito ou diretamente da origem.
+func (s *Service) Receive(ctx context.Context, id int) (*Transfer, error) {
+	return s.Repo.Transition(ctx, id, StatusReceived)
+}
+
+// Cancel cancela a transferência; mercadorias em trânsito voltam para a origem.
+func (s *Service) Cancel(ctx context.Context, id int) (*Transfer, error) {
+	return s.Repo.Transition(ctx, id, StatusCancelled)
+}
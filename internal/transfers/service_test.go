@@ -0,0 +1,158 @@
+package transfers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"inventory-system/internal/products"
+)
+
+func TestCanTransition(t *testing.T) {
+	cases := []struct {
+		from, to string
+		want     bool
+	}{
+		{StatusDraft, StatusInTransit, true},
+		{StatusDraft, StatusReceived, true},
+		{StatusDraft, StatusCancelled, true},
+		{StatusInTransit, StatusReceived, true},
+		{StatusInTransit, StatusCancelled, true},
+		{StatusInTransit, StatusDraft, false},
+		{StatusReceived, StatusCancelled, false},
+		{StatusCancelled, StatusInTransit, false},
+	}
+	for _, c := range cases {
+		if got := CanTransition(c.from, c.to); got != c.want {
+			t.Errorf("%s -> %s: esperado %v, veio %v", c.from, c.to, c.want, got)
+		}
+	}
+}
+
+func TestTransferLegs(t *testing.T) {
+	const transit = 99
+	tr := &Transfer{FromLocationID: 1, ToLocationID: 2, Quantity: 5}
+	cases := []struct {
+		status, to string
+		want       []leg
+	}{
+		{StatusDraft, StatusInTransit, []leg{{1, -5, nil}, {transit, 5, nil}}},
+		{StatusDraft, StatusReceived, []leg{{1, -5, nil}, {2, 5, nil}}},
+		{StatusInTransit, StatusReceived, []leg{{transit, -5, nil}, {2, 5, nil}}},
+		{StatusInTransit, StatusCancelled, []leg{{transit, -5, nil}, {1, 5, nil}}},
+		{StatusDraft, StatusCancelled, nil},
+	}
+	for _, c := range cases {
+		tr.Status = c.status
+		if got := transferLegs(tr, c.to, transit); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s -> %s: esperado %v, veio %v", c.status, c.to, c.want, got)
+		}
+	}
+}
+
+func TestTransferLegs_Serials(t *testing.T) {
+	// Saída e entrada levam as séries da transferência, inclusive as que saem do trânsito
+	serials := []string{"S1", "S2"}
+	tr := &Transfer{FromLocationID: 1, ToLocationID: 2, Quantity: 2, Serials: serials}
+	for _, c := range []struct{ status, to string }{
+		{StatusDraft, StatusInTransit},
+		{StatusInTransit, StatusReceived},
+		{StatusInTransit, StatusCancelled},
+	} {
+		tr.Status = c.status
+		for _, l := range transferLegs(tr, c.to, 99) {
+			if !reflect.DeepEqual(l.serials, serials) {
+				t.Errorf("%s -> %s: perna %d esperava séries %v, veio %v", c.status, c.to, l.locationID, serials, l.serials)
+			}
+		}
+	}
+}
+
+type mockTransferRepo struct {
+	transfers map[int]*Transfer
+	fail      bool
+}
+
+func (m *mockTransferRepo) CreateTransfer(ctx context.Context, t *Transfer, status string) error {
+	if m.fail {
+		return fmt.Errorf("db error")
+	}
+	t.ID = len(m.transfers) + 1
+	t.Status = status
+	m.transfers[t.ID] = t
+	return nil
+}
+func (m *mockTransferRepo) GetTransfers(ctx context.Context, q TransfersQuery) ([]Transfer, int, error) {
+	var result []Transfer
+	for _, t := range m.transfers {
+		if q.Status == "" || t.Status == q.Status {
+			result = append(result, *t)
+		}
+	}
+	return result, len(result), nil
+}
+func (m *mockTransferRepo) GetTransfer(ctx context.Context, id int) (*Transfer, error) {
+	return m.transfers[id], nil
+}
+func (m *mockTransferRepo) Transition(ctx context.Context, id int, status string) (*Transfer, error) {
+	t, ok := m.transfers[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if !CanTransition(t.Status, status) {
+		return nil, ErrInvalidTransition
+	}
+	t.Status = status
+	return t, nil
+}
+
+func TestService_TransferLifecycle_Mock(t *testing.T) {
+	svc := NewService(&mockTransferRepo{transfers: map[int]*Transfer{}})
+	ctx := context.Background()
+	tr, err := svc.CreateTransfer(ctx, TransferRequest{Barcode: "123", FromLocationID: 1, ToLocationID: 2, Quantity: 3})
+	if err != nil {
+		t.Fatalf("erro ao criar transferência: %v", err)
+	}
+	if tr.Status != StatusDraft {
+		t.Errorf("status inicial esperado draft, veio %s", tr.Status)
+	}
+	if _, err := svc.Dispatch(ctx, tr.ID); err != nil {
+		t.Fatalf("erro ao despachar: %v", err)
+	}
+	inTransit, _, _ := svc.GetTransfers(ctx, TransfersQuery{Status: StatusInTransit})
+	if len(inTransit) != 1 {
+		t.Errorf("esperado 1 transferência em trânsito, veio %d", len(inTransit))
+	}
+	if _, err := svc.Dispatch(ctx, tr.ID); err != ErrInvalidTransition {
+		t.Errorf("esperado ErrInvalidTransition ao despachar duas vezes, veio %v", err)
+	}
+	if _, err := svc.Receive(ctx, tr.ID); err != nil {
+		t.Fatalf("erro ao receber: %v", err)
+	}
+	if _, err := svc.Cancel(ctx, tr.ID); err != ErrInvalidTransition {
+		t.Errorf("transferência recebida não pode ser cancelada, veio %v", err)
+	}
+	// Criação já recebida
+	direct, _ := svc.CreateTransfer(ctx, TransferRequest{Barcode: "123", FromLocationID: 1, ToLocationID: 2, Quantity: 1, Status: StatusReceived})
+	if direct.Status != StatusReceived {
+		t.Errorf("status esperado received, veio %s", direct.Status)
+	}
+}
+
+func TestService_SerializedTransfer_Mock(t *testing.T) {
+	svc := NewService(&mockTransferRepo{transfers: map[int]*Transfer{}})
+	ctx := context.Background()
+	// Sem quantidade, ela vem das séries; as séries ficam gravadas na transferência
+	tr, err := svc.CreateTransfer(ctx, TransferRequest{Barcode: "ser", FromLocationID: 1, ToLocationID: 2, Serials: []string{"S1", "S2"}})
+	if err != nil {
+		t.Fatalf("erro ao criar transferência: %v", err)
+	}
+	if tr.Quantity != 2 || len(tr.Serials) != 2 {
+		t.Errorf("esperado 2 unidades com séries, veio %d %v", tr.Quantity, tr.Serials)
+	}
+	_, err = svc.CreateTransfer(ctx, TransferRequest{Barcode: "ser", FromLocationID: 1, ToLocationID: 2, Quantity: 3, Serials: []string{"S1"}})
+	if err != products.ErrSerialCount {
+		t.Errorf("esperado ErrSerialCount, veio %v", err)
+	}
+}